@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -21,7 +22,7 @@ import (
 	"configuratix/agent/internal/updater"
 )
 
-const Version = "0.5.3"
+const Version = "0.10.0"
 
 func main() {
 	enrollCmd := flag.NewFlagSet("enroll", flag.ExitOnError)
@@ -165,28 +166,90 @@ func run() error {
 			}
 
 		case <-jobTicker.C:
-			jobs, err := c.GetJobs()
-			if err != nil {
-				log.Printf("Failed to get jobs: %v", err)
-				continue
+			// Claim one job at a time so queued jobs aren't leased while waiting
+			for {
+				jobs, lease, err := c.ClaimJobs(1)
+				if err != nil {
+					log.Printf("Failed to claim jobs: %v", err)
+					break
+				}
+				if len(jobs) == 0 {
+					break
+				}
+				runJob(c, exec, jobs[0], lease)
 			}
+		}
+	}
+}
 
-			for _, job := range jobs {
-				log.Printf("Processing job %s (type: %s)", job.ID, job.Type)
+// runJob executes a claimed job while renewing its lease. The job is stopped when it
+// is cancelled or the lease is lost.
+func runJob(c *client.Client, exec *executor.Executor, job client.Job, lease time.Duration) {
+	log.Printf("Processing job %s (type: %s)", job.ID, job.Type)
 
-				// Mark as running
-				c.UpdateJob(job.ID, "running", "Starting job execution...")
+	c.UpdateJob(job.ID, "running", "Starting job execution...")
 
-				// Execute job
-				logs, err := exec.Execute(job.Type, job.Payload)
-				if err != nil {
-					log.Printf("Job %s failed: %v", job.ID, err)
-					c.UpdateJob(job.ID, "failed", logs+"\nError: "+err.Error())
-				} else {
-					log.Printf("Job %s completed", job.ID)
-					c.UpdateJob(job.ID, "completed", logs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leaseState := make(chan string, 1)
+	go func() {
+		leaseState <- keepLease(ctx, c, job.ID, lease, cancel)
+	}()
+
+	// Output is streamed while the job runs; the final update carries the full log
+	output := c.StreamJobLog(job.ID)
+	logs, result, err := exec.Execute(ctx, job.Type, job.Payload, output)
+	cancel()
+	output.Close()
+
+	switch <-leaseState {
+	case client.LeaseCancelled:
+		log.Printf("Job %s cancelled", job.ID)
+		c.FinishJob(job.ID, "failed", logs+"\nJob cancelled, execution stopped", result)
+	case client.LeaseLost:
+		// The server already requeued or failed the job
+		log.Printf("Job %s stopped: lease lost", job.ID)
+	default:
+		if err != nil {
+			log.Printf("Job %s failed: %v", job.ID, err)
+			c.FinishJob(job.ID, "failed", logs+"\nError: "+err.Error(), result)
+		} else {
+			log.Printf("Job %s completed", job.ID)
+			c.FinishJob(job.ID, "completed", logs, result)
+		}
+	}
+}
+
+// keepLease renews a job lease until ctx is done. It cancels the job and returns the
+// lease state when the job was cancelled or the lease could not be kept.
+func keepLease(ctx context.Context, c *client.Client, jobID string, lease time.Duration, cancel context.CancelFunc) string {
+	if lease <= 0 {
+		lease = 90 * time.Second
+	}
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return client.LeaseHeld
+		case <-ticker.C:
+			state, err := c.RenewLease(jobID)
+			if err != nil {
+				log.Printf("Failed to renew lease of job %s: %v", jobID, err)
+				if time.Since(renewed) < lease {
+					continue
 				}
+				// The server has reclaimed the job by now
+				state = client.LeaseLost
+			}
+			if state != client.LeaseHeld {
+				cancel()
+				return state
 			}
+			renewed = time.Now()
 		}
 	}
 }
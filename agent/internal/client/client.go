@@ -2,23 +2,32 @@ package client
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
 )
 
 type Client struct {
 	serverURL string
 	apiKey    string
+	workerID  string // Identifies this process as the holder of job leases
 	http      *http.Client
 }
 
 func New(serverURL, apiKey string) *Client {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
 	return &Client{
 		serverURL: serverURL,
 		apiKey:    apiKey,
+		workerID:  fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), hex.EncodeToString(suffix)),
 		http: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -85,33 +94,89 @@ type Job struct {
 	Status  string          `json:"status"`
 }
 
-func (c *Client) GetJobs() ([]Job, error) {
-	req, _ := http.NewRequest("GET", c.serverURL+"/api/agent/jobs", nil)
+// ClaimJobs claims up to limit pending jobs. Claimed jobs are leased to this process
+// for the returned duration and must be renewed with RenewLease while they run.
+func (c *Client) ClaimJobs(limit int) ([]Job, time.Duration, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"worker_id": c.workerID,
+		"limit":     limit,
+	})
+	req, _ := http.NewRequest("POST", c.serverURL+"/api/agent/jobs/claim", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-API-Key", c.apiKey)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get jobs failed: %d", resp.StatusCode)
+		return nil, 0, fmt.Errorf("claim jobs failed: %d", resp.StatusCode)
 	}
 
-	var jobs []Job
-	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
-		return nil, err
+	var result struct {
+		Jobs         []Job `json:"jobs"`
+		LeaseSeconds int   `json:"lease_seconds"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, err
 	}
 
-	return jobs, nil
+	return result.Jobs, time.Duration(result.LeaseSeconds) * time.Second, nil
 }
 
-func (c *Client) UpdateJob(jobID, status, logs string) error {
+// Lease states returned by RenewLease
+const (
+	LeaseHeld      = "running"
+	LeaseCancelled = "cancelled" // The job was cancelled by a user
+	LeaseLost      = "lost"      // The lease expired and the server took the job back
+)
+
+// RenewLease extends the lease of a running job and returns its lease state
+func (c *Client) RenewLease(jobID string) (string, error) {
 	body, _ := json.Marshal(map[string]string{
-		"job_id": jobID,
-		"status": status,
-		"logs":   logs,
+		"worker_id": c.workerID,
+	})
+	req, _ := http.NewRequest("POST", c.serverURL+"/api/agent/jobs/"+jobID+"/lease", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return LeaseLost, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("renew lease failed: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.Status, nil
+}
+
+func (c *Client) UpdateJob(jobID, status, logs string) error {
+	return c.FinishJob(jobID, status, logs, nil)
+}
+
+// FinishJob reports the final status of a job with its structured result (may be nil)
+func (c *Client) FinishJob(jobID, status, logs string, result interface{}) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"job_id":    jobID,
+		"status":    status,
+		"logs":      logs,
+		"worker_id": c.workerID,
+		"result":    result,
 	})
 	req, _ := http.NewRequest("POST", c.serverURL+"/api/agent/jobs/update", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	logFlushInterval = time.Second
+	logFlushSize     = 64 << 10 // Flush early once this much output is buffered
+	maxPendingLog    = 4 << 20  // Output kept while the server is unreachable
+)
+
+// errLogRejected means the server no longer accepts output for the job
+var errLogRejected = errors.New("job log rejected")
+
+// LogChunk is a piece of job output sent to the server
+type LogChunk struct {
+	Seq      int    `json:"seq"`
+	Step     int    `json:"step"`
+	Kind     string `json:"kind"` // output, step_start, step_end
+	Content  string `json:"content"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+// JobLog streams the output of a running job to the server in the background.
+// It implements executor.Output.
+type JobLog struct {
+	c     *Client
+	jobID string
+
+	mu       sync.Mutex
+	pending  []LogChunk
+	sent     int // Leading pending chunks from a failed send; not merged into
+	size     int
+	seq      int
+	step     int
+	dropped  int
+	rejected bool
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// StreamJobLog starts streaming output for a job. Close flushes the rest.
+func (c *Client) StreamJobLog(jobID string) *JobLog {
+	l := &JobLog{
+		c:       c,
+		jobID:   jobID,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Write adds command output to the current step
+func (l *JobLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	if l.rejected || l.size+len(p) > maxPendingLog {
+		l.dropped += len(p)
+		l.mu.Unlock()
+		return len(p), nil
+	}
+	n := len(l.pending)
+	if n > l.sent && l.pending[n-1].Kind == "output" && len(l.pending[n-1].Content) < logFlushSize {
+		l.pending[n-1].Content += string(p)
+	} else {
+		l.add(LogChunk{Step: l.step, Kind: "output", Content: string(p)})
+	}
+	l.size += len(p)
+	full := l.size >= logFlushSize
+	l.mu.Unlock()
+
+	if full {
+		select {
+		case l.wake <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// StepStarted marks the start of a run step
+func (l *JobLog) StepStarted(step int, action string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.step = step
+	l.add(LogChunk{Step: step, Kind: "step_start", Content: action})
+}
+
+// StepFinished marks the end of a run step with its exit code
+func (l *JobLog) StepFinished(step int, exitCode int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(LogChunk{Step: step, Kind: "step_end", ExitCode: &exitCode})
+	l.step = 0
+}
+
+// Close stops streaming after sending the remaining output
+func (l *JobLog) Close() {
+	close(l.done)
+	<-l.stopped
+	if l.dropped > 0 {
+		log.Printf("Job %s: %d bytes of output were not streamed", l.jobID, l.dropped)
+	}
+}
+
+// add appends a chunk; the caller holds mu
+func (l *JobLog) add(chunk LogChunk) {
+	if l.rejected {
+		return
+	}
+	l.seq++
+	chunk.Seq = l.seq
+	l.pending = append(l.pending, chunk)
+}
+
+func (l *JobLog) run() {
+	defer close(l.stopped)
+
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.wake:
+		case <-l.done:
+			l.flush()
+			return
+		}
+		l.flush()
+	}
+}
+
+// flush sends the pending chunks. On failure they are kept for the next flush; the
+// server ignores chunks it already stored.
+func (l *JobLog) flush() {
+	l.mu.Lock()
+	chunks := l.pending
+	l.pending = nil
+	l.sent = 0
+	l.size = 0
+	l.mu.Unlock()
+
+	if len(chunks) == 0 {
+		return
+	}
+
+	err := l.c.sendJobLog(l.jobID, chunks)
+	if err == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if errors.Is(err, errLogRejected) {
+		l.rejected = true
+		l.pending = nil
+		return
+	}
+	log.Printf("Failed to stream output of job %s: %v", l.jobID, err)
+	l.pending = append(chunks, l.pending...)
+	l.sent = len(chunks)
+	for _, chunk := range l.pending {
+		l.size += len(chunk.Content)
+	}
+}
+
+func (c *Client) sendJobLog(jobID string, chunks []LogChunk) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"worker_id": c.workerID,
+		"chunks":    chunks,
+	})
+	req, _ := http.NewRequest("POST", c.serverURL+"/api/agent/jobs/"+jobID+"/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusConflict:
+		return errLogRejected
+	default:
+		return fmt.Errorf("send job log failed: %d", resp.StatusCode)
+	}
+}
@@ -6,13 +6,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -35,18 +34,57 @@ func (e *Executor) SetConfig(serverURL, apiKey string) {
 	e.apiKey = apiKey
 }
 
+// Output receives job output while the job runs. Run jobs mark step boundaries.
+type Output interface {
+	io.Writer
+	StepStarted(step int, action string)
+	StepFinished(step int, exitCode int)
+}
+
+// discardOutput is used when the caller doesn't stream output
+type discardOutput struct{}
+
+func (discardOutput) Write(p []byte) (int, error)         { return len(p), nil }
+func (discardOutput) StepStarted(step int, action string) {}
+func (discardOutput) StepFinished(step int, exitCode int) {}
+
 // Step represents a single operation in a run job
 type Step struct {
-	Action  string `json:"action"` // exec, file, service, fetch
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"` // seconds, default 300
-	Path    string `json:"path"`
-	Content string `json:"content"`
-	URL     string `json:"url"`
-	Mode    string `json:"mode"`
-	Op      string `json:"op"` // write, append, delete, backup
-	Name    string `json:"name"`
-	Log     string `json:"log"`
+	ID        string   `json:"id"`     // Optional, referenced by outputs and conditions
+	Action    string   `json:"action"` // exec, file, service, fetch, package, line_in_file, user, systemd_unit, sysctl
+	Command   string   `json:"command"`
+	Timeout   int      `json:"timeout"` // seconds, default 300
+	Path      string   `json:"path"`
+	Content   string   `json:"content"`
+	URL       string   `json:"url"`
+	Mode      string   `json:"mode"`
+	Op        string   `json:"op"` // write, append, delete, backup, template, directory
+	Name      string   `json:"name"`
+	Log       string   `json:"log"`
+	When      string   `json:"when"`       // Condition, the step is skipped unless true. See evalWhen.
+	OnError   string   `json:"on_error"`   // Overrides the payload on_error for this step
+	Register  string   `json:"register"`   // Var that receives the trimmed stdout
+	Retries   int      `json:"retries"`    // Extra attempts after a failure
+	Delay     int      `json:"delay"`      // Seconds between attempts, default 5
+	Loop      []string `json:"loop"`       // Runs the step once per item, see loopItems
+	WithItems string   `json:"with_items"` // Template expression giving more items, one per line
+	LoopVar   string   `json:"loop_var"`   // Name of the item in the step, default item
+	ReadOnly  bool     `json:"read_only"`  // The exec command doesn't change the host; it also runs in check mode
+
+	// Declarative resources (package, line_in_file, user, systemd_unit, sysctl) and
+	// the file action. See resources.go.
+	State       string `json:"state"`        // package/user/line_in_file: present, absent; systemd_unit: started, stopped, restarted, reloaded
+	Version     string `json:"version"`      // package version to pin
+	UpdateCache bool   `json:"update_cache"` // package: apt-get update before installing
+	Owner       string `json:"owner"`
+	Group       string `json:"group"`
+	Line        string `json:"line"`    // line_in_file
+	Regexp      string `json:"regexp"`  // line_in_file: lines to replace or remove
+	Value       string `json:"value"`   // sysctl
+	Shell       string `json:"shell"`   // user
+	Home        string `json:"home"`    // user
+	System      bool   `json:"system"`  // user: create a system account
+	Enabled     *bool  `json:"enabled"` // systemd_unit: enable or disable at boot
 }
 
 // RunPayload is the unified job type for complex operations
@@ -54,6 +92,7 @@ type RunPayload struct {
 	Steps   []Step            `json:"steps"`
 	OnError string            `json:"on_error"` // stop (default), continue, rollback
 	Vars    map[string]string `json:"vars"`
+	Outputs []OutputDef       `json:"outputs"`
 }
 
 // DeployLandingPayload for static content deployment
@@ -65,12 +104,27 @@ type DeployLandingPayload struct {
 	ReplaceContent *bool  `json:"replace_content"`
 }
 
-func (e *Executor) Execute(jobType string, payload json.RawMessage) (string, error) {
-	switch jobType {
-	case "run":
+// Execute runs a job. Output of run and exec jobs is also streamed to out (may be
+// nil). Run jobs also return a structured result per step. Cancelling ctx kills the
+// running command and skips the remaining steps of a run job. A check job is a run
+// job in check mode: it reports what would change without changing anything.
+func (e *Executor) Execute(ctx context.Context, jobType string, payload json.RawMessage, out Output) (string, *RunResult, error) {
+	if out == nil {
+		out = discardOutput{}
+	}
+
+	if jobType == "run" || jobType == "check" {
 		var p RunPayload
 		json.Unmarshal(payload, &p)
-		return e.executeRun(p)
+		return e.executeRun(ctx, p, jobType == "check", out)
+	}
+	logs, err := e.execute(ctx, jobType, payload, out)
+	return logs, nil, err
+}
+
+// execute runs the job types that only produce a log
+func (e *Executor) execute(ctx context.Context, jobType string, payload json.RawMessage, out Output) (string, error) {
+	switch jobType {
 
 	case "deploy_landing":
 		var p DeployLandingPayload
@@ -94,7 +148,7 @@ func (e *Executor) Execute(jobType string, payload json.RawMessage) (string, err
 		if cmd == "" {
 			cmd = p.Script
 		}
-		return execWithTimeout(cmd, p.Timeout)
+		return execWithTimeout(ctx, cmd, p.Timeout, out)
 
 	case "file":
 		var p struct {
@@ -140,70 +194,304 @@ func (e *Executor) Execute(jobType string, payload json.RawMessage) (string, err
 }
 
 // executeRun processes a run job with multiple steps
-func (e *Executor) executeRun(payload RunPayload) (string, error) {
+func (e *Executor) executeRun(ctx context.Context, payload RunPayload, check bool, out Output) (string, *RunResult, error) {
 	var logs strings.Builder
 	var backups []string
+	result := &RunResult{Steps: []StepResult{}, Check: check}
+
+	// emit writes to the returned log and the live output
+	emit := func(s string) {
+		logs.WriteString(s)
+		io.WriteString(out, s)
+	}
+
+	if check {
+		emit("=== Check mode: changes are reported, not applied ===\n")
+	}
 
 	onError := payload.OnError
 	if onError == "" {
 		onError = "stop"
 	}
 
+	// Registered vars are added to a copy of the payload vars
+	vars := make(map[string]string, len(payload.Vars))
+	for k, v := range payload.Vars {
+		vars[k] = v
+	}
+	facts := gatherFacts()
+	unknown := &unknownRefs{vars: make(map[string]bool), steps: make(map[string]bool)}
+
 	for i, step := range payload.Steps {
-		logs.WriteString(fmt.Sprintf("\n=== Step %d: %s ===\n", i+1, step.Action))
+		if ctx.Err() != nil {
+			emit(fmt.Sprintf("\n=== Cancelled before step %d ===\n", i+1))
+			result.resolveOutputs(payload.Outputs)
+			return logs.String(), result, fmt.Errorf("job cancelled")
+		}
 
-		// Variable substitution
-		step = substituteVars(step, payload.Vars)
+		out.StepStarted(i+1, step.Action)
+		emit(fmt.Sprintf("\n=== Step %d: %s ===\n", i+1, step.Action))
 
-		var stepLog string
-		var err error
+		// Variable substitution. Template content sees vars as .vars instead, so var
+		// values can't inject template actions.
+		content := step.Content
+		step = substituteVars(step, vars)
+		if step.Action == "file" && step.Op == "template" {
+			step.Content = content
+		}
 
-		switch step.Action {
-		case "exec":
-			stepLog, err = execWithTimeout(step.Command, step.Timeout)
-		case "file":
-			stepLog, err, backups = fileOpSafe(step, backups)
-		case "service":
-			stepLog, err = serviceOp(step.Name, step.Op)
-		case "fetch":
-			stepLog, err = fetchToFile(step.URL, step.Path, step.Mode)
-		default:
-			err = fmt.Errorf("unknown action: %s", step.Action)
+		stepResult := StepResult{Step: i + 1, ID: step.ID, Action: step.Action}
+		data := templateData(vars, facts, result.Steps)
+		started := time.Now()
+
+		// In check mode a step that reads the output of a step that didn't run can't be
+		// decided, so it isn't run either
+		if check && (unknown.dependsOn(step.When) || unknown.dependsOn(step.WithItems) || (step.Op == "template" && unknown.dependsOn(step.Content))) {
+			emit("Unknown: depends on the output of a step that is not run in check mode\n")
+			stepResult.Unknown = true
+			unknown.add(step)
+			unknown.prev = true
+			out.StepFinished(i+1, 0)
+			result.Steps = append(result.Steps, stepResult)
+			continue
 		}
 
-		logs.WriteString(stepLog)
+		// A loop step runs once per item, each with its own condition and retries
+		items, err := loopItems(step, data)
+		loop := items != nil
+		switch {
+		case err != nil:
+			items = nil // Nothing runs, the error fails the step below
+		case !loop:
+			items = []string{""}
+		}
+		ran := 0
+		for _, item := range items {
+			iter, iterData := step, data
+			if loop {
+				iter, iterData = withItem(step, data, item)
+			}
+			run := true
+			if iter.When != "" {
+				var whenErr error
+				if run, whenErr = evalWhen(iter.When, iterData); whenErr != nil {
+					err = whenErr
+					break
+				}
+			}
+			if !run {
+				if loop {
+					emit(fmt.Sprintf("Skipped item %s, condition not met: %s\n", item, step.When))
+				}
+				continue
+			}
+			if loop {
+				emit(fmt.Sprintf("--- Item: %s ---\n", item))
+			}
+			ran++
+			var itemResult StepResult
+			err = e.runWithRetries(ctx, iter, iterData, check, &itemResult, &backups, out, &logs)
+			stepResult.addItem(itemResult)
+			if err != nil {
+				break
+			}
+		}
+		if err == nil && ran == 0 {
+			if loop && len(items) == 0 {
+				emit("Skipped, no loop items\n")
+			} else if !loop {
+				emit(fmt.Sprintf("Skipped, condition not met: %s\n", step.When))
+			}
+			stepResult.Skipped = true
+			unknown.prev = false
+			out.StepFinished(i+1, 0)
+			result.Steps = append(result.Steps, stepResult)
+			continue
+		}
 
 		// Handle custom logging
 		if step.Log != "" && step.Log != "out" {
-			logs.WriteString("\n--- Log output ---\n")
-			logOutput, logErr := execWithTimeout(step.Log, 30)
+			emit("\n--- Log output ---\n")
+			logOutput, logErr := execWithTimeout(ctx, step.Log, 30, out)
 			logs.WriteString(logOutput)
 			if logErr != nil {
-				logs.WriteString(fmt.Sprintf("(log command failed: %v)\n", logErr))
+				emit(fmt.Sprintf("(log command failed: %v)\n", logErr))
 			}
 		}
 
+		stepResult.DurationMs = time.Since(started).Milliseconds()
+		stepResult.ExitCode = exitCode(err)
 		if err != nil {
-			logs.WriteString(fmt.Sprintf("ERROR: %v\n", err))
+			stepResult.Error = err.Error()
+			emit(fmt.Sprintf("ERROR: %v\n", err))
+		}
+		out.StepFinished(i+1, stepResult.ExitCode)
 
-			if onError == "rollback" {
-				logs.WriteString("\n=== Rolling back ===\n")
-				rollbackLog := rollback(backups)
-				logs.WriteString(rollbackLog)
-			}
+		if stepResult.Unknown {
+			unknown.add(step)
+		} else if step.Register != "" {
+			vars[step.Register] = strings.TrimSpace(stepResult.Stdout)
+			delete(unknown.vars, step.Register)
+		}
+		unknown.prev = stepResult.Unknown
+
+		stepOnError := onError
+		if step.OnError != "" {
+			stepOnError = step.OnError
+		}
+		if err != nil && stepOnError == "rollback" {
+			emit("\n=== Rolling back ===\n")
+			rollbackLog := rollback(backups)
+			emit(rollbackLog)
+			stepResult.RolledBack = true
+			result.RolledBack = true
+		}
+		result.Steps = append(result.Steps, stepResult)
+		result.Changed = result.Changed || stepResult.Changed
+
+		if err != nil && stepOnError != "continue" {
+			result.resolveOutputs(payload.Outputs)
+			return logs.String(), result, err
+		}
+	}
+
+	emit("\n=== All steps completed ===\n")
+	result.resolveOutputs(payload.Outputs)
+	return logs.String(), result, nil
+}
+
+// runWithRetries runs a step, retrying a failed attempt as set by its retries and
+// delay. r.Attempts counts the attempts.
+func (e *Executor) runWithRetries(ctx context.Context, step Step, data map[string]interface{}, check bool, r *StepResult, backups *[]string, out Output, logs *strings.Builder) error {
+	for {
+		r.Attempts++
+		err := e.runStep(ctx, step, data, check, r, backups, out, logs)
+		if err == nil || r.Attempts > step.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := step.Delay
+		if delay <= 0 {
+			delay = 5
+		}
+		msg := fmt.Sprintf("Attempt %d/%d failed: %v, retrying in %ds\n", r.Attempts, step.Retries+1, err, delay)
+		logs.WriteString(msg)
+		io.WriteString(out, msg)
+		select {
+		case <-time.After(time.Duration(delay) * time.Second):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// runStep performs one attempt of a step's action, recording its output in r. In
+// check mode resources report what they would change; other steps that may change
+// the host are reported instead of run.
+func (e *Executor) runStep(ctx context.Context, step Step, data map[string]interface{}, check bool, r *StepResult, backups *[]string, out Output, logs *strings.Builder) error {
+	emit := func(s string) {
+		logs.WriteString(s)
+		io.WriteString(out, s)
+	}
+	sh := func(cmd string, timeout int) error {
+		shell := runShell(ctx, cmd, timeout, out)
+		logs.WriteString(shell.Log)
+		return shell.Err
+	}
+	r.Stdout, r.Stderr, r.StdoutTruncated, r.Outputs, r.FilesChanged, r.Changed, r.Unknown = "", "", false, nil, nil, false, false
+
+	var stepLog string
+	var err error
 
-			if onError != "continue" {
-				return logs.String(), err
+	switch step.Action {
+	case "exec":
+		if check && !step.ReadOnly {
+			emit("Would run: " + step.Command + "\n")
+			r.Changed, r.Unknown = true, true
+			break
+		}
+		// Streams its output as the command runs
+		shell := runShell(ctx, step.Command, step.Timeout, out)
+		logs.WriteString(shell.Log)
+		err = shell.Err
+		r.Stdout, r.Stderr = shell.Stdout, shell.Stderr
+		r.StdoutTruncated = shell.StdoutTruncated
+		r.Outputs = shell.Outputs
+		r.Changed = !step.ReadOnly
+	case "file":
+		if step.Op == "template" {
+			rendered, err := renderTemplate(step.Content, data)
+			if err != nil {
+				return fmt.Errorf("failed to render template: %v", err)
 			}
+			emit(fmt.Sprintf("Rendered template (%d bytes)\n", len(rendered)))
+			step.Op, step.Content = "write", rendered
+		}
+		stepLog, r.Changed, err = fileOp(step, check, backups)
+		emit(stepLog)
+		r.Stdout = stepLog
+	case "line_in_file":
+		stepLog, r.Changed, err = ensureLineInFile(step, check, backups)
+		emit(stepLog)
+		r.Stdout = stepLog
+	case "service":
+		if check && step.Op != "status" {
+			emit(fmt.Sprintf("Would %s service %s\n", step.Op, step.Name))
+			r.Changed, r.Unknown = true, true
+			break
+		}
+		stepLog, err = serviceOp(step.Name, step.Op)
+		emit(stepLog)
+		r.Stdout = stepLog
+		r.Changed = step.Op != "status"
+	case "fetch":
+		stepLog, r.Changed, err = fetchToFile(step, check)
+		emit(stepLog)
+		r.Stdout = stepLog
+	case "package", "user", "systemd_unit", "sysctl":
+		// Resources that run commands describe each change before applying it
+		var summary strings.Builder
+		w := io.MultiWriter(&summary, logs, out)
+		switch step.Action {
+		case "package":
+			r.Changed, err = ensurePackage(step, check, sh, w)
+		case "user":
+			r.Changed, err = ensureUser(step, check, sh, w)
+		case "systemd_unit":
+			r.Changed, err = ensureSystemdUnit(step, check, sh, backups, w)
+		case "sysctl":
+			r.Changed, err = ensureSysctl(step, check, sh, backups, w)
 		}
+		r.Stdout = summary.String()
+	default:
+		err = fmt.Errorf("unknown action: %s", step.Action)
 	}
 
-	logs.WriteString("\n=== All steps completed ===\n")
-	return logs.String(), nil
+	if r.Changed && !check {
+		switch step.Action {
+		case "file", "fetch", "line_in_file":
+			r.FilesChanged = []string{step.Path}
+		}
+	}
+	return err
+}
+
+// exitCode returns the exit status of a failed command, 0 on success and -1 for
+// errors that aren't an exit status (including commands killed by a signal)
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
 
 // substituteVars replaces {{var}} with values
 func substituteVars(step Step, vars map[string]string) Step {
+	step.Loop = append([]string(nil), step.Loop...)
 	for k, v := range vars {
 		placeholder := "{{" + k + "}}"
 		step.Command = strings.ReplaceAll(step.Command, placeholder, v)
@@ -212,35 +500,38 @@ func substituteVars(step Step, vars map[string]string) Step {
 		step.URL = strings.ReplaceAll(step.URL, placeholder, v)
 		step.Name = strings.ReplaceAll(step.Name, placeholder, v)
 		step.Log = strings.ReplaceAll(step.Log, placeholder, v)
+		step.State = strings.ReplaceAll(step.State, placeholder, v)
+		step.Version = strings.ReplaceAll(step.Version, placeholder, v)
+		step.Owner = strings.ReplaceAll(step.Owner, placeholder, v)
+		step.Group = strings.ReplaceAll(step.Group, placeholder, v)
+		step.Line = strings.ReplaceAll(step.Line, placeholder, v)
+		step.Regexp = strings.ReplaceAll(step.Regexp, placeholder, v)
+		step.Value = strings.ReplaceAll(step.Value, placeholder, v)
+		step.Shell = strings.ReplaceAll(step.Shell, placeholder, v)
+		step.Home = strings.ReplaceAll(step.Home, placeholder, v)
+		for j := range step.Loop {
+			step.Loop[j] = strings.ReplaceAll(step.Loop[j], placeholder, v)
+		}
 	}
 	return step
 }
 
-// execWithTimeout runs a command with timeout
-func execWithTimeout(cmdStr string, timeoutSec int) (string, error) {
-	var logs strings.Builder
-	logs.WriteString("$ " + cmdStr + "\n")
-
-	if timeoutSec <= 0 {
-		timeoutSec = 300
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", cmdStr)
-	out, err := cmd.CombinedOutput()
-	logs.WriteString(string(out))
-
-	if ctx.Err() == context.DeadlineExceeded {
-		return logs.String(), fmt.Errorf("command timed out after %ds", timeoutSec)
-	}
-
-	return logs.String(), err
+// execWithTimeout runs a command with timeout, copying its output to live as it is
+// produced. The command is also killed when the job's ctx is cancelled.
+func execWithTimeout(jobCtx context.Context, cmdStr string, timeoutSec int, live io.Writer) (string, error) {
+	result := runShell(jobCtx, cmdStr, timeoutSec, live)
+	return result.Log, result.Err
 }
 
 // fileOpSafe performs file operations with backup support
 func fileOpSafe(step Step, backups []string) (string, error, []string) {
+	logs, _, err := fileOp(step, false, &backups)
+	return logs, err, backups
+}
+
+// fileOp performs a file operation and reports whether it changed the host. Writes
+// leave identical files alone. Replaced and deleted files are backed up for rollback.
+func fileOp(step Step, check bool, backups *[]string) (string, bool, error) {
 	var logs strings.Builder
 	path := step.Path
 	op := step.Op
@@ -254,12 +545,12 @@ func fileOpSafe(step Step, backups []string) (string, error, []string) {
 		logs.WriteString(fmt.Sprintf("Fetching content from %s...\n", step.URL))
 		resp, err := http.Get(step.URL)
 		if err != nil {
-			return logs.String(), err, backups
+			return logs.String(), false, err
 		}
 		defer resp.Body.Close()
 		data, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return logs.String(), err, backups
+			return logs.String(), false, err
 		}
 		content = string(data)
 		logs.WriteString(fmt.Sprintf("Fetched %d bytes\n", len(data)))
@@ -267,53 +558,57 @@ func fileOpSafe(step Step, backups []string) (string, error, []string) {
 
 	switch op {
 	case "write":
-		// Backup existing file for rollback
-		if _, err := os.Stat(path); err == nil {
-			backupPath := path + ".configuratix-backup"
-			if data, err := os.ReadFile(path); err == nil {
-				os.WriteFile(backupPath, data, 0644)
-				backups = append(backups, backupPath+":"+path)
-				logs.WriteString(fmt.Sprintf("Backed up to %s\n", backupPath))
-			}
-		}
+		fileLog, changed, err := ensureFile(path, []byte(content), step, check, backups)
+		logs.WriteString(fileLog)
+		return logs.String(), changed, err
 
-		logs.WriteString(fmt.Sprintf("Writing to %s (%d bytes)...\n", path, len(content)))
-		os.MkdirAll(filepath.Dir(path), 0755)
-		perm := os.FileMode(0644)
-		if step.Mode != "" {
-			if m, err := strconv.ParseUint(step.Mode, 8, 32); err == nil {
-				perm = os.FileMode(m)
-			}
-		}
-		if err := os.WriteFile(path, []byte(content), perm); err != nil {
-			return logs.String(), err, backups
-		}
-		logs.WriteString("Done\n")
+	case "directory":
+		dirLog, changed, err := ensureDirectory(step, check)
+		logs.WriteString(dirLog)
+		return logs.String(), changed, err
 
 	case "append":
+		if check {
+			logs.WriteString(changeLine(check, "append %d bytes to %s", len(content), path))
+			return logs.String(), true, nil
+		}
 		logs.WriteString(fmt.Sprintf("Appending to %s...\n", path))
 		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			return logs.String(), err, backups
+			return logs.String(), false, err
 		}
 		defer f.Close()
 		f.WriteString(content)
 		logs.WriteString("Done\n")
+		return logs.String(), true, nil
 
 	case "delete":
-		if data, err := os.ReadFile(path); err == nil {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			logs.WriteString(fmt.Sprintf("%s doesn't exist\n", path))
+			return logs.String(), false, nil
+		}
+		logs.WriteString(changeLine(check, "delete %s", path))
+		if check {
+			return logs.String(), true, nil
+		}
+		if err == nil && backups != nil {
 			backupPath := path + ".configuratix-backup"
 			os.WriteFile(backupPath, data, 0644)
-			backups = append(backups, backupPath+":"+path)
+			*backups = append(*backups, backupPath+":"+path)
 		}
-		logs.WriteString(fmt.Sprintf("Deleting %s...\n", path))
 		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			return logs.String(), err, backups
+			return logs.String(), true, err
 		}
 		logs.WriteString("Done\n")
+		return logs.String(), true, nil
 
 	case "backup":
 		backupPath := path + ".configuratix-backup"
+		if check {
+			logs.WriteString(fmt.Sprintf("Would back up %s to %s\n", path, backupPath))
+			return logs.String(), false, nil
+		}
 		logs.WriteString(fmt.Sprintf("Backing up %s to %s...\n", path, backupPath))
 		if data, err := os.ReadFile(path); err == nil {
 			os.WriteFile(backupPath, data, 0644)
@@ -321,48 +616,37 @@ func fileOpSafe(step Step, backups []string) (string, error, []string) {
 		} else {
 			logs.WriteString("File not found, skipped\n")
 		}
+		return logs.String(), false, nil
 
 	default:
-		return logs.String(), fmt.Errorf("unknown file op: %s", op), backups
+		return logs.String(), false, fmt.Errorf("unknown file op: %s", op)
 	}
-
-	return logs.String(), nil, backups
 }
 
-// fetchToFile downloads a URL to a file
-func fetchToFile(url, path, mode string) (string, error) {
+// fetchToFile downloads a URL to a file, leaving an identical file alone
+func fetchToFile(step Step, check bool) (string, bool, error) {
 	var logs strings.Builder
-	logs.WriteString(fmt.Sprintf("Fetching %s -> %s\n", url, path))
+	logs.WriteString(fmt.Sprintf("Fetching %s -> %s\n", step.URL, step.Path))
 
-	resp, err := http.Get(url)
+	resp, err := http.Get(step.URL)
 	if err != nil {
-		return logs.String(), err
+		return logs.String(), false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return logs.String(), fmt.Errorf("HTTP %d", resp.StatusCode)
-	}
-
-	os.MkdirAll(filepath.Dir(path), 0755)
-	perm := os.FileMode(0644)
-	if mode != "" {
-		if m, err := strconv.ParseUint(mode, 8, 32); err == nil {
-			perm = os.FileMode(m)
-		}
+		return logs.String(), false, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return logs.String(), err
+		return logs.String(), false, err
 	}
-
-	if err := os.WriteFile(path, data, perm); err != nil {
-		return logs.String(), err
-	}
-
 	logs.WriteString(fmt.Sprintf("Downloaded %d bytes\n", len(data)))
-	return logs.String(), nil
+
+	fileLog, changed, err := ensureFile(step.Path, data, step, check, nil)
+	logs.WriteString(fileLog)
+	return logs.String(), changed, err
 }
 
 // rollback restores backed up files
@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// gatherFacts collects the host facts available to step conditions and templates
+// as .facts. Facts that can't be read are left empty.
+func gatherFacts() map[string]string {
+	facts := map[string]string{
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+		"cpus": strconv.Itoa(runtime.NumCPU()),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		facts["hostname"] = hostname
+	}
+
+	// Distribution, e.g. distro=ubuntu distro_version=22.04 distro_codename=jammy
+	osRelease := readOSRelease("/etc/os-release")
+	facts["distro"] = osRelease["ID"]
+	facts["distro_like"] = osRelease["ID_LIKE"]
+	facts["distro_version"] = osRelease["VERSION_ID"]
+	facts["distro_codename"] = osRelease["VERSION_CODENAME"]
+
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		facts["kernel"] = strings.TrimSpace(string(data))
+	}
+
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			var kb int64
+			if _, err := fmt.Sscanf(line, "MemTotal: %d kB", &kb); err == nil {
+				facts["memory_mb"] = strconv.FormatInt(kb/1024, 10)
+				break
+			}
+		}
+	}
+
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		facts["init"] = "systemd"
+	}
+
+	for _, pm := range []string{"apt-get", "dnf", "yum", "apk"} {
+		if _, err := exec.LookPath(pm); err == nil {
+			facts["package_manager"] = strings.TrimSuffix(pm, "-get")
+			break
+		}
+	}
+
+	return facts
+}
+
+// readOSRelease parses the KEY=value lines of an os-release file
+func readOSRelease(path string) map[string]string {
+	values := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+	return values
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package executor
+
+import (
+	"os"
+)
+
+// fileOwner is not supported on Windows; owner and group can't be managed there
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package executor
+
+import (
+	"os/exec"
+)
+
+// killProcessGroup is a no-op on Windows; cancelling kills only the command itself
+func killProcessGroup(cmd *exec.Cmd) {}
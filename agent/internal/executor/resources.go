@@ -0,0 +1,578 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Declarative resources describe the desired state of a part of the host. They
+// only act when the host differs, report whether they changed it, and in check
+// mode report what they would change without applying it.
+
+// defaultSysctlFile persists sysctl values set by sysctl steps
+const defaultSysctlFile = "/etc/sysctl.d/99-configuratix.conf"
+
+// shellFunc runs a command for a resource, streaming its output
+type shellFunc func(cmd string, timeout int) error
+
+// changeLine describes a change, as planned in check mode
+func changeLine(check bool, format string, args ...interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+	if check {
+		return "Would " + msg + "\n"
+	}
+	return strings.ToUpper(msg[:1]) + msg[1:] + "\n"
+}
+
+// shellQuote quotes s for bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ensureFile makes path hold content, comparing checksums so an identical file is
+// left alone, then applies the step's mode, owner and group. A replaced file is
+// backed up for rollback.
+func ensureFile(path string, content []byte, step Step, check bool, backups *[]string) (string, bool, error) {
+	var logs strings.Builder
+
+	current, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+
+	wantSum := sha256Hex(content)
+	changed := true
+	switch {
+	case !exists:
+		logs.WriteString(changeLine(check, "create %s (%d bytes, sha256 %.12s)", path, len(content), wantSum))
+	case sha256Hex(current) != wantSum:
+		logs.WriteString(changeLine(check, "update %s (%d bytes, sha256 %.12s -> %.12s)", path, len(content), sha256Hex(current), wantSum))
+	default:
+		logs.WriteString(fmt.Sprintf("%s is up to date (sha256 %.12s)\n", path, wantSum))
+		changed = false
+	}
+
+	if changed && !check {
+		if exists && backups != nil {
+			backupPath := path + ".configuratix-backup"
+			if err := os.WriteFile(backupPath, current, 0644); err == nil {
+				*backups = append(*backups, backupPath+":"+path)
+				logs.WriteString(fmt.Sprintf("Backed up to %s\n", backupPath))
+			}
+		}
+		os.MkdirAll(filepath.Dir(path), 0755)
+		perm := os.FileMode(0644)
+		if m, err := strconv.ParseUint(step.Mode, 8, 32); err == nil {
+			perm = os.FileMode(m)
+		}
+		if err := os.WriteFile(path, content, perm); err != nil {
+			return logs.String(), true, err
+		}
+	}
+
+	attrLog, attrChanged, err := ensureAttributes(path, step, check)
+	logs.WriteString(attrLog)
+	return logs.String(), changed || attrChanged, err
+}
+
+// ensureDirectory creates a directory with the step's mode, owner and group
+func ensureDirectory(step Step, check bool) (string, bool, error) {
+	var logs strings.Builder
+	changed := false
+
+	info, err := os.Stat(step.Path)
+	switch {
+	case err == nil && !info.IsDir():
+		return "", false, fmt.Errorf("%s exists and is not a directory", step.Path)
+	case os.IsNotExist(err):
+		logs.WriteString(changeLine(check, "create directory %s", step.Path))
+		changed = true
+		if !check {
+			if err := os.MkdirAll(step.Path, 0755); err != nil {
+				return logs.String(), true, err
+			}
+		}
+	case err != nil:
+		return "", false, err
+	default:
+		logs.WriteString(fmt.Sprintf("Directory %s exists\n", step.Path))
+	}
+
+	attrLog, attrChanged, err := ensureAttributes(step.Path, step, check)
+	logs.WriteString(attrLog)
+	return logs.String(), changed || attrChanged, err
+}
+
+// ensureAttributes applies the step's mode, owner and group to path
+func ensureAttributes(path string, step Step, check bool) (string, bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) && check {
+		// Would be created; the attributes are applied then
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	var logs strings.Builder
+	changed := false
+
+	if step.Mode != "" {
+		m, err := strconv.ParseUint(step.Mode, 8, 32)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid mode %q", step.Mode)
+		}
+		if perm := os.FileMode(m); info.Mode().Perm() != perm {
+			logs.WriteString(changeLine(check, "change mode of %s from %04o to %04o", path, info.Mode().Perm(), perm))
+			changed = true
+			if !check {
+				if err := os.Chmod(path, perm); err != nil {
+					return logs.String(), true, err
+				}
+			}
+		}
+	}
+
+	if step.Owner != "" || step.Group != "" {
+		uid, gid, ok := fileOwner(info)
+		if !ok {
+			return logs.String(), changed, fmt.Errorf("owner and group are not supported on this platform")
+		}
+		wantUID, wantGID := uid, gid
+		if step.Owner != "" {
+			u, err := user.Lookup(step.Owner)
+			if err != nil {
+				return logs.String(), changed, fmt.Errorf("unknown owner %q", step.Owner)
+			}
+			wantUID, _ = strconv.Atoi(u.Uid)
+		}
+		if step.Group != "" {
+			g, err := user.LookupGroup(step.Group)
+			if err != nil {
+				return logs.String(), changed, fmt.Errorf("unknown group %q", step.Group)
+			}
+			wantGID, _ = strconv.Atoi(g.Gid)
+		}
+		if wantUID != uid || wantGID != gid {
+			logs.WriteString(changeLine(check, "change owner of %s from %d:%d to %d:%d", path, uid, gid, wantUID, wantGID))
+			changed = true
+			if !check {
+				if err := os.Chown(path, wantUID, wantGID); err != nil {
+					return logs.String(), true, err
+				}
+			}
+		}
+	}
+
+	return logs.String(), changed, nil
+}
+
+// ensureLineInFile makes sure a line is present in (or absent from) a file. With a
+// regexp, the last matching line is replaced by the line, or all matching lines are
+// removed; without one, the line is matched exactly. A missing file is created.
+func ensureLineInFile(step Step, check bool, backups *[]string) (string, bool, error) {
+	if step.Path == "" {
+		return "", false, fmt.Errorf("path is required")
+	}
+	state := step.State
+	if state == "" {
+		state = "present"
+	}
+	if state != "present" && state != "absent" {
+		return "", false, fmt.Errorf("invalid line_in_file state: %s", state)
+	}
+	if state == "present" && step.Line == "" {
+		return "", false, fmt.Errorf("line is required")
+	}
+
+	var re *regexp.Regexp
+	if step.Regexp != "" {
+		var err error
+		if re, err = regexp.Compile(step.Regexp); err != nil {
+			return "", false, fmt.Errorf("invalid regexp: %v", err)
+		}
+	}
+	matches := func(line string) bool {
+		if re != nil {
+			return re.MatchString(line)
+		}
+		return line == step.Line
+	}
+
+	data, err := os.ReadFile(step.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+	exists := err == nil
+	if !exists && state == "absent" {
+		return fmt.Sprintf("%s doesn't exist\n", step.Path), false, nil
+	}
+
+	var lines []string
+	if text := strings.TrimSuffix(string(data), "\n"); text != "" {
+		lines = strings.Split(text, "\n")
+	}
+
+	var logs strings.Builder
+	edited := false
+	if state == "present" {
+		idx := -1
+		for i, line := range lines {
+			if matches(line) {
+				idx = i
+			}
+		}
+		switch {
+		case idx >= 0 && lines[idx] == step.Line:
+			logs.WriteString(fmt.Sprintf("Line already present in %s (line %d)\n", step.Path, idx+1))
+		case idx >= 0:
+			logs.WriteString(changeLine(check, "replace line %d of %s: %q -> %q", idx+1, step.Path, lines[idx], step.Line))
+			lines[idx] = step.Line
+			edited = true
+		default:
+			logs.WriteString(changeLine(check, "append to %s: %q", step.Path, step.Line))
+			lines = append(lines, step.Line)
+			edited = true
+		}
+	} else {
+		kept := lines[:0]
+		for _, line := range lines {
+			if !matches(line) {
+				kept = append(kept, line)
+			}
+		}
+		if removed := len(lines) - len(kept); removed > 0 {
+			logs.WriteString(changeLine(check, "remove %d line(s) from %s", removed, step.Path))
+			edited = true
+		} else {
+			logs.WriteString(fmt.Sprintf("No matching line in %s\n", step.Path))
+		}
+		lines = kept
+	}
+
+	if !edited {
+		attrLog, attrChanged, err := ensureAttributes(step.Path, step, check)
+		logs.WriteString(attrLog)
+		return logs.String(), attrChanged, err
+	}
+
+	content := ""
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	fileLog, _, err := ensureFile(step.Path, []byte(content), step, check, backups)
+	logs.WriteString(fileLog)
+	return logs.String(), true, err
+}
+
+// ensurePackage installs or removes apt packages. Name may list several packages
+// separated by spaces; version pins a single package.
+func ensurePackage(step Step, check bool, sh shellFunc, w io.Writer) (bool, error) {
+	names := strings.Fields(step.Name)
+	if len(names) == 0 {
+		return false, fmt.Errorf("package name is required")
+	}
+	if step.Version != "" && len(names) > 1 {
+		return false, fmt.Errorf("version can only be set for a single package")
+	}
+	state := step.State
+	if state == "" {
+		state = "present"
+	}
+	if state != "present" && state != "absent" {
+		return false, fmt.Errorf("invalid package state: %s", state)
+	}
+
+	var install, remove []string
+	for _, name := range names {
+		installed, version := dpkgStatus(name)
+		switch {
+		case state == "absent" && installed:
+			io.WriteString(w, changeLine(check, "remove %s %s", name, version))
+			remove = append(remove, name)
+		case state == "absent":
+			io.WriteString(w, fmt.Sprintf("%s is not installed\n", name))
+		case !installed && step.Version != "":
+			io.WriteString(w, changeLine(check, "install %s %s", name, step.Version))
+			install = append(install, name+"="+step.Version)
+		case !installed:
+			io.WriteString(w, changeLine(check, "install %s", name))
+			install = append(install, name)
+		case step.Version != "" && version != step.Version:
+			io.WriteString(w, changeLine(check, "change %s from %s to %s", name, version, step.Version))
+			install = append(install, name+"="+step.Version)
+		default:
+			io.WriteString(w, fmt.Sprintf("%s %s is installed\n", name, version))
+		}
+	}
+
+	changed := len(install) > 0 || len(remove) > 0
+	if check || !changed {
+		return changed, nil
+	}
+
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = 600
+	}
+	if len(install) > 0 {
+		if step.UpdateCache {
+			if err := sh("apt-get update", timeout); err != nil {
+				return changed, err
+			}
+		}
+		cmd := "DEBIAN_FRONTEND=noninteractive apt-get install -y "
+		if step.Version != "" {
+			cmd += "--allow-downgrades "
+		}
+		if err := sh(cmd+shellJoin(install), timeout); err != nil {
+			return changed, err
+		}
+	}
+	if len(remove) > 0 {
+		if err := sh("DEBIAN_FRONTEND=noninteractive apt-get remove -y "+shellJoin(remove), timeout); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// dpkgStatus reports whether a package is installed and its version
+func dpkgStatus(name string) (bool, string) {
+	out, err := exec.Command("dpkg-query", "-W", "-f=${Status}\t${Version}", name).Output()
+	if err != nil {
+		return false, ""
+	}
+	status, version, _ := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	return status == "install ok installed", version
+}
+
+// ensureUser creates, updates or removes a local user
+func ensureUser(step Step, check bool, sh shellFunc, w io.Writer) (bool, error) {
+	if step.Name == "" {
+		return false, fmt.Errorf("user name is required")
+	}
+	state := step.State
+	if state == "" {
+		state = "present"
+	}
+	if state != "present" && state != "absent" {
+		return false, fmt.Errorf("invalid user state: %s", state)
+	}
+
+	// name:password:uid:gid:gecos:home:shell
+	out, err := exec.Command("getent", "passwd", step.Name).Output()
+	entry := strings.Split(strings.TrimSpace(string(out)), ":")
+	exists := err == nil && len(entry) == 7
+
+	var cmd string
+	switch {
+	case state == "absent" && exists:
+		io.WriteString(w, changeLine(check, "remove user %s", step.Name))
+		cmd = "userdel " + shellQuote(step.Name)
+	case state == "absent":
+		io.WriteString(w, fmt.Sprintf("User %s doesn't exist\n", step.Name))
+	case !exists:
+		args := []string{"useradd"}
+		if step.System {
+			args = append(args, "--system")
+		} else {
+			args = append(args, "--create-home")
+		}
+		if step.Home != "" {
+			args = append(args, "--home-dir", step.Home)
+		}
+		if step.Shell != "" {
+			args = append(args, "--shell", step.Shell)
+		}
+		io.WriteString(w, changeLine(check, "create user %s", step.Name))
+		cmd = shellJoin(append(args, step.Name))
+	default:
+		var args []string
+		if step.Shell != "" && entry[6] != step.Shell {
+			io.WriteString(w, changeLine(check, "change shell of %s from %s to %s", step.Name, entry[6], step.Shell))
+			args = append(args, "--shell", step.Shell)
+		}
+		if step.Home != "" && entry[5] != step.Home {
+			io.WriteString(w, changeLine(check, "move home of %s from %s to %s", step.Name, entry[5], step.Home))
+			args = append(args, "--home", step.Home, "--move-home")
+		}
+		if len(args) == 0 {
+			io.WriteString(w, fmt.Sprintf("User %s is up to date\n", step.Name))
+		} else {
+			cmd = shellJoin(append(append([]string{"usermod"}, args...), step.Name))
+		}
+	}
+
+	if cmd == "" {
+		return false, nil
+	}
+	if !check {
+		if err := sh(cmd, 60); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// ensureSystemdUnit manages a systemd unit: its unit file (when content is set),
+// whether it is enabled, and its state (started, stopped, restarted, reloaded). A
+// started unit whose unit file changed is restarted.
+func ensureSystemdUnit(step Step, check bool, sh shellFunc, backups *[]string, w io.Writer) (bool, error) {
+	if step.Name == "" {
+		return false, fmt.Errorf("unit name is required")
+	}
+	unit := step.Name
+	if !strings.Contains(unit, ".") {
+		unit += ".service"
+	}
+
+	changed := false
+	run := func(cmd string) error {
+		changed = true
+		if check {
+			return nil
+		}
+		return sh(cmd, 120)
+	}
+
+	fileChanged := false
+	if step.Content != "" {
+		path := step.Path
+		if path == "" {
+			path = "/etc/systemd/system/" + unit
+		}
+		fileLog, c, err := ensureFile(path, []byte(step.Content), step, check, backups)
+		io.WriteString(w, fileLog)
+		if err != nil {
+			return c, err
+		}
+		if c {
+			fileChanged = true
+			if err := run("systemctl daemon-reload"); err != nil {
+				return true, err
+			}
+		}
+	}
+
+	if step.Enabled != nil {
+		enabled := systemctlQuery("is-enabled", unit) == "enabled"
+		if enabled != *step.Enabled {
+			verb := "enable"
+			if !*step.Enabled {
+				verb = "disable"
+			}
+			io.WriteString(w, changeLine(check, "%s %s", verb, unit))
+			if err := run("systemctl " + verb + " " + shellQuote(unit)); err != nil {
+				return true, err
+			}
+		} else if enabled {
+			io.WriteString(w, fmt.Sprintf("%s is enabled\n", unit))
+		} else {
+			io.WriteString(w, fmt.Sprintf("%s is disabled\n", unit))
+		}
+	}
+
+	active := systemctlQuery("is-active", unit) == "active"
+	var verb string
+	switch step.State {
+	case "":
+	case "started":
+		if !active {
+			verb = "start"
+		} else if fileChanged {
+			verb = "restart"
+		} else {
+			io.WriteString(w, fmt.Sprintf("%s is running\n", unit))
+		}
+	case "stopped":
+		if active {
+			verb = "stop"
+		} else {
+			io.WriteString(w, fmt.Sprintf("%s is stopped\n", unit))
+		}
+	case "restarted":
+		verb = "restart"
+	case "reloaded":
+		verb = "reload"
+	default:
+		return changed, fmt.Errorf("invalid unit state: %s", step.State)
+	}
+	if verb != "" {
+		io.WriteString(w, changeLine(check, "%s %s", verb, unit))
+		if err := run("systemctl " + verb + " " + shellQuote(unit)); err != nil {
+			return true, err
+		}
+	}
+
+	return changed, nil
+}
+
+// systemctlQuery returns the answer of systemctl is-active/is-enabled
+func systemctlQuery(query, unit string) string {
+	out, _ := exec.Command("systemctl", query, unit).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// ensureSysctl sets a kernel parameter now and persists it in a sysctl.d file
+// (path, default /etc/sysctl.d/99-configuratix.conf)
+func ensureSysctl(step Step, check bool, sh shellFunc, backups *[]string, w io.Writer) (bool, error) {
+	if step.Name == "" || step.Value == "" {
+		return false, fmt.Errorf("sysctl name and value are required")
+	}
+	normalize := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+	out, err := exec.Command("sysctl", "-n", step.Name).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to read sysctl %s: %v", step.Name, err)
+	}
+
+	changed := false
+	if current := normalize(string(out)); current != normalize(step.Value) {
+		io.WriteString(w, changeLine(check, "set %s to %s (was %s)", step.Name, step.Value, current))
+		changed = true
+		if !check {
+			if err := sh("sysctl -w "+shellQuote(step.Name+"="+step.Value), 30); err != nil {
+				return true, err
+			}
+		}
+	} else {
+		io.WriteString(w, fmt.Sprintf("%s is %s\n", step.Name, step.Value))
+	}
+
+	path := step.Path
+	if path == "" {
+		path = defaultSysctlFile
+	}
+	persist := Step{
+		Path:   path,
+		Line:   step.Name + " = " + step.Value,
+		Regexp: `^\s*` + regexp.QuoteMeta(step.Name) + `\s*=`,
+	}
+	fileLog, fileChanged, err := ensureLineInFile(persist, check, backups)
+	io.WriteString(w, fileLog)
+	return changed || fileChanged, err
+}
@@ -0,0 +1,234 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxResultStdout = 1 << 20  // Stdout kept per step in the result
+	maxResultStderr = 64 << 10 // Stderr kept per step in the result
+)
+
+// OutputEnv names the file exec steps can write key=value lines to. Each line
+// becomes a named output of the step.
+const OutputEnv = "CONFIGURATIX_OUTPUT"
+
+// OutputDef declares a named job output taken from a step result
+type OutputDef struct {
+	Name string `json:"name"`
+	Step string `json:"step"` // Step ID
+	From string `json:"from"` // stdout (default), stderr, exit_code, or a key the step wrote to $CONFIGURATIX_OUTPUT
+	Trim bool   `json:"trim"` // Trim surrounding whitespace
+}
+
+// StepResult is the structured outcome of a run step
+type StepResult struct {
+	Step            int               `json:"step"`
+	ID              string            `json:"id,omitempty"`
+	Action          string            `json:"action"`
+	ExitCode        int               `json:"exit_code"`
+	Stdout          string            `json:"stdout"`
+	Stderr          string            `json:"stderr"`
+	StdoutTruncated bool              `json:"stdout_truncated,omitempty"`
+	DurationMs      int64             `json:"duration_ms"`
+	FilesChanged    []string          `json:"files_changed,omitempty"`
+	Outputs         map[string]string `json:"outputs,omitempty"`
+	RolledBack      bool              `json:"rolled_back"` // The failure of this step rolled back file changes
+	Error           string            `json:"error,omitempty"`
+	Skipped         bool              `json:"skipped,omitempty"`  // The step's when condition was false
+	Attempts        int               `json:"attempts,omitempty"` // Runs including retries
+	Changed         bool              `json:"changed"`            // The step changed the host, or would in check mode
+	Unknown         bool              `json:"unknown,omitempty"`  // Check mode: not run, so its outcome is unknown
+}
+
+// RunResult is the structured outcome of a run job
+type RunResult struct {
+	Steps      []StepResult      `json:"steps"`
+	Outputs    map[string]string `json:"outputs,omitempty"`
+	RolledBack bool              `json:"rolled_back"`
+	Changed    bool              `json:"changed"` // Any step changed the host
+	Check      bool              `json:"check"`   // Check mode: changes were reported, not applied
+}
+
+// addItem adds the result of one item of a step to r. A step that isn't a loop has a
+// single item.
+func (r *StepResult) addItem(item StepResult) {
+	if r.Stdout != "" && item.Stdout != "" && !strings.HasSuffix(r.Stdout, "\n") {
+		r.Stdout += "\n"
+	}
+	r.Stdout += item.Stdout
+	r.Stderr += item.Stderr
+	r.StdoutTruncated = r.StdoutTruncated || item.StdoutTruncated
+	r.FilesChanged = append(r.FilesChanged, item.FilesChanged...)
+	for k, v := range item.Outputs {
+		if r.Outputs == nil {
+			r.Outputs = make(map[string]string)
+		}
+		r.Outputs[k] = v
+	}
+	r.Attempts += item.Attempts
+	r.Changed = r.Changed || item.Changed
+	r.Unknown = r.Unknown || item.Unknown
+}
+
+// resolveOutputs fills the job outputs declared by the payload. Outputs of steps that
+// didn't run (skipped, or not run in check mode) are left out.
+func (r *RunResult) resolveOutputs(defs []OutputDef) {
+	for _, def := range defs {
+		for _, step := range r.Steps {
+			if step.ID == "" || step.ID != def.Step || step.Skipped || step.Unknown {
+				continue
+			}
+			var value string
+			var ok bool
+			switch def.From {
+			case "", "stdout":
+				value, ok = step.Stdout, true
+			case "stderr":
+				value, ok = step.Stderr, true
+			case "exit_code":
+				value, ok = strconv.Itoa(step.ExitCode), true
+			default:
+				value, ok = step.Outputs[def.From]
+			}
+			if !ok {
+				continue
+			}
+			if def.Trim {
+				value = strings.TrimSpace(value)
+			}
+			if r.Outputs == nil {
+				r.Outputs = make(map[string]string)
+			}
+			r.Outputs[def.Name] = value
+		}
+	}
+}
+
+// shellResult is the outcome of a shell command
+type shellResult struct {
+	Log             string // "$ command" followed by the combined output
+	Stdout          string
+	Stderr          string
+	StdoutTruncated bool
+	Outputs         map[string]string
+	Err             error
+}
+
+// syncWriter serializes writes from the stdout and stderr copiers
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// cappedBuffer keeps the first limit bytes written to it
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := c.limit - c.buf.Len(); room < len(p) {
+		c.truncated = true
+		if room > 0 {
+			c.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
+
+// runShell runs a command with bash, keeping stdout and stderr apart while streaming
+// the combined output to live. The command is killed on timeout or when the job's
+// ctx is cancelled.
+func runShell(jobCtx context.Context, cmdStr string, timeoutSec int, live io.Writer) shellResult {
+	var combined strings.Builder
+	combined.WriteString("$ " + cmdStr + "\n")
+	io.WriteString(live, "$ "+cmdStr+"\n")
+
+	if timeoutSec <= 0 {
+		timeoutSec = 300
+	}
+
+	ctx, cancel := context.WithTimeout(jobCtx, time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	outputFile, err := os.CreateTemp("", "configuratix-output-*")
+	if err != nil {
+		return shellResult{Log: combined.String(), Err: fmt.Errorf("failed to create output file: %v", err)}
+	}
+	outputFile.Close()
+	defer os.Remove(outputFile.Name())
+
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", cmdStr)
+	cmd.Env = append(os.Environ(), OutputEnv+"="+outputFile.Name())
+	killProcessGroup(cmd)
+	cmd.WaitDelay = 5 * time.Second // Don't wait forever on pipes held by orphans
+
+	stdout := &cappedBuffer{limit: maxResultStdout}
+	stderr := &cappedBuffer{limit: maxResultStderr}
+	shared := &syncWriter{w: io.MultiWriter(&combined, live)}
+	cmd.Stdout = io.MultiWriter(stdout, shared)
+	cmd.Stderr = io.MultiWriter(stderr, shared)
+	err = cmd.Run()
+
+	result := shellResult{
+		Stdout:          stdout.buf.String(),
+		Stderr:          stderr.buf.String(),
+		StdoutTruncated: stdout.truncated,
+		Outputs:         readOutputs(outputFile.Name()),
+		Err:             err,
+	}
+
+	if jobCtx.Err() != nil {
+		io.WriteString(live, "\n[command killed: job cancelled]\n")
+		result.Err = fmt.Errorf("command killed: job cancelled")
+	} else if ctx.Err() == context.DeadlineExceeded {
+		io.WriteString(live, fmt.Sprintf("\n[command timed out after %ds]\n", timeoutSec))
+		result.Err = fmt.Errorf("command timed out after %ds", timeoutSec)
+	}
+	result.Log = combined.String()
+	return result
+}
+
+// readOutputs parses the key=value lines a step wrote to its output file
+func readOutputs(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var outputs map[string]string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), maxResultStdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		if outputs == nil {
+			outputs = make(map[string]string)
+		}
+		outputs[key] = value
+	}
+	return outputs
+}
@@ -0,0 +1,202 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are available to step conditions and the template file op in
+// addition to the text/template builtins (eq, ne, and, or, not, ...)
+var templateFuncs = template.FuncMap{
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"replace":   strings.ReplaceAll,
+	"hasLine":   hasLine,
+	"split":     strings.Split,
+	"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"default": func(def, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// templateData is what conditions and templates are evaluated against:
+//
+//	.vars.<name>        payload vars and vars registered by earlier steps
+//	.facts.<name>       host facts, see gatherFacts
+//	.steps.<id>.<field> result of an earlier step with that ID
+//	.prev.<field>       result of the previous step
+//
+// Step fields are exit_code, stdout, stderr, error, failed, skipped, changed, unknown
+// and outputs.
+func templateData(vars, facts map[string]string, steps []StepResult) map[string]interface{} {
+	byID := make(map[string]interface{})
+	var prev interface{}
+	for _, step := range steps {
+		view := stepView(step)
+		prev = view
+		if step.ID == "" {
+			continue
+		}
+		// A skipped alternative doesn't hide a step with the same ID that ran
+		if _, ok := byID[step.ID]; ok && step.Skipped {
+			continue
+		}
+		byID[step.ID] = view
+	}
+	return map[string]interface{}{
+		"vars":  vars,
+		"facts": facts,
+		"steps": byID,
+		"prev":  prev,
+	}
+}
+
+func stepView(step StepResult) map[string]interface{} {
+	return map[string]interface{}{
+		"exit_code": step.ExitCode,
+		"stdout":    step.Stdout,
+		"stderr":    step.Stderr,
+		"error":     step.Error,
+		"failed":    step.ExitCode != 0 || step.Error != "",
+		"skipped":   step.Skipped,
+		"changed":   step.Changed,
+		"unknown":   step.Unknown,
+		"outputs":   step.Outputs,
+	}
+}
+
+// renderTemplate renders text as a Go template
+func renderTemplate(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("step").Option("missingkey=zero").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// evalWhen evaluates a step condition, a template expression with or without the
+// surrounding braces, e.g. `ne .steps.check.exit_code 0` or `eq .facts.distro "ubuntu"`.
+// Empty, false, 0 and missing values are false.
+func evalWhen(when string, data map[string]interface{}) (bool, error) {
+	expr := strings.TrimSpace(when)
+	if !strings.Contains(expr, "{{") {
+		expr = "{{" + expr + "}}"
+	}
+	value, err := renderTemplate(expr, data)
+	if err != nil {
+		return false, fmt.Errorf("invalid when condition: %v", err)
+	}
+	switch strings.TrimSpace(value) {
+	case "", "false", "0", "<no value>":
+		return false, nil
+	}
+	return true, nil
+}
+
+// loopItems returns the items of a loop step, nil if the step isn't a loop. The
+// loop items come first, then one item per non-empty line of with_items, a template
+// expression like a when condition, e.g. `.steps.list.stdout` or
+// `join "\n" (split .vars.packages ",")`.
+func loopItems(step Step, data map[string]interface{}) ([]string, error) {
+	if len(step.Loop) == 0 && step.WithItems == "" {
+		return nil, nil
+	}
+	items := append([]string{}, step.Loop...)
+	if step.WithItems == "" {
+		return items, nil
+	}
+	expr := strings.TrimSpace(step.WithItems)
+	if !strings.Contains(expr, "{{") {
+		expr = "{{" + expr + "}}"
+	}
+	value, err := renderTemplate(expr, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid with_items: %v", err)
+	}
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" && line != "<no value>" {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
+
+// withItem returns the step and template data for one item of a loop step. The item
+// is {{item}} in the step's fields and .item in its condition and template, or the
+// name set by loop_var.
+func withItem(step Step, data map[string]interface{}, item string) (Step, map[string]interface{}) {
+	name := step.LoopVar
+	if name == "" {
+		name = "item"
+	}
+	content := step.Content
+	step = substituteVars(step, map[string]string{name: item})
+	if step.Action == "file" && step.Op == "template" {
+		step.Content = content
+	}
+	itemData := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		itemData[k] = v
+	}
+	itemData[name] = item
+	return step, itemData
+}
+
+// templateRefPattern matches the vars and step results a condition or template reads
+var templateRefPattern = regexp.MustCompile(`\.(vars|steps)\.(\w+)|\.prev\b`)
+
+// unknownRefs tracks what a check run doesn't know: vars registered by steps that
+// were not run and the IDs of those steps
+type unknownRefs struct {
+	vars  map[string]bool
+	steps map[string]bool
+	prev  bool // The previous step was not run
+}
+
+// add records a step whose outcome is unknown
+func (u *unknownRefs) add(step Step) {
+	if step.Register != "" {
+		u.vars[step.Register] = true
+	}
+	if step.ID != "" {
+		u.steps[step.ID] = true
+	}
+}
+
+// dependsOn reports whether text reads an unknown var or step result
+func (u *unknownRefs) dependsOn(text string) bool {
+	for _, m := range templateRefPattern.FindAllStringSubmatch(text, -1) {
+		switch {
+		case m[1] == "vars" && u.vars[m[2]],
+			m[1] == "steps" && u.steps[m[2]],
+			m[1] == "" && u.prev:
+			return true
+		}
+	}
+	return false
+}
+
+// hasLine reports whether text has a line equal to line, ignoring surrounding
+// whitespace, e.g. `hasLine .vars.ufw_rules "ufw allow 22/tcp"`
+func hasLine(text, line string) bool {
+	line = strings.TrimSpace(line)
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -64,6 +64,9 @@ func main() {
 	agentRouter.HandleFunc("/heartbeat", agentHandler.Heartbeat).Methods("POST", "OPTIONS")
 	agentRouter.HandleFunc("/jobs", agentHandler.GetJobs).Methods("GET", "OPTIONS")
 	agentRouter.HandleFunc("/jobs/update", agentHandler.UpdateJob).Methods("POST", "OPTIONS")
+	agentRouter.HandleFunc("/jobs/claim", agentHandler.ClaimJobs).Methods("POST", "OPTIONS")
+	agentRouter.HandleFunc("/jobs/{id}/lease", agentHandler.RenewJobLease).Methods("POST", "OPTIONS")
+	agentRouter.HandleFunc("/jobs/{id}/logs", agentHandler.AppendJobLogs).Methods("POST", "OPTIONS")
 
 	// Protected API routes (requires user auth)
 	apiRouter := router.PathPrefix("/api").Subrouter()
@@ -184,7 +187,15 @@ func main() {
 	apiRouter.HandleFunc("/domains/{id}", domainsHandler.DeleteDomain).Methods("DELETE", "OPTIONS")
 
 	// DNS Management (completely separate module from main domains)
-	dnsHandler := handlers.NewDNSHandler(db)
+	dnsDriftInterval := 30 // Default: 30 minutes
+	if intervalStr := os.Getenv("DNS_DRIFT_INTERVAL_MINUTES"); intervalStr != "" {
+		if val, err := strconv.Atoi(intervalStr); err == nil && val > 0 {
+			dnsDriftInterval = val
+		}
+	}
+	dnsDriftDetector := services.NewDNSDriftDetector(db, dnsDriftInterval)
+	dnsZoneMigrator := services.NewDNSZoneMigrator(db)
+	dnsHandler := handlers.NewDNSHandler(db, dnsDriftDetector, dnsZoneMigrator)
 	// DNS Accounts
 	apiRouter.HandleFunc("/dns-accounts", dnsHandler.ListDNSAccounts).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/dns-accounts", dnsHandler.CreateDNSAccount).Methods("POST", "OPTIONS")
@@ -199,6 +210,14 @@ func main() {
 	apiRouter.HandleFunc("/dns-domains/{id}", dnsHandler.UpdateDNSManagedDomain).Methods("PUT", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}", dnsHandler.DeleteDNSManagedDomain).Methods("DELETE", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}/ns-check", dnsHandler.CheckDomainNS).Methods("POST", "OPTIONS")
+	// DNSSEC
+	apiRouter.HandleFunc("/dns-domains/{id}/dnssec", dnsHandler.GetDNSSEC).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/dnssec", dnsHandler.SetDNSSEC).Methods("PUT", "OPTIONS")
+	// DNS Zone Migration (move a domain to another DNS account)
+	apiRouter.HandleFunc("/dns-domains/{id}/migrate", dnsHandler.MigrateZone).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/migration", dnsHandler.GetZoneMigration).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/migration/check", dnsHandler.CheckZoneMigration).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/migration", dnsHandler.CancelZoneMigration).Methods("DELETE", "OPTIONS")
 	// DNS Records
 	apiRouter.HandleFunc("/dns-domains/{id}/records", dnsHandler.ListDNSRecords).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}/records", dnsHandler.CreateDNSRecord).Methods("POST", "OPTIONS")
@@ -207,9 +226,13 @@ func main() {
 	// DNS Sync
 	apiRouter.HandleFunc("/dns-domains/{id}/sync", dnsHandler.CompareDNSRecords).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}/sync/apply", dnsHandler.ApplyDNSToRemote).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/drift-check", dnsHandler.CheckDNSDrift).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}/sync/import", dnsHandler.ImportDNSFromRemote).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}/lookup", dnsHandler.LookupDNS).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/propagation", dnsHandler.CheckPropagation).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{id}/remote-records", dnsHandler.ListRemoteRecords).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/zonefile", dnsHandler.ExportZoneFile).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns-domains/{id}/zonefile", dnsHandler.ImportZoneFile).Methods("POST", "OPTIONS")
 
 	// DNS Passthrough (Dynamic rotation pools)
 	passthroughHandler := handlers.NewPassthroughHandler(db, dnsHandler)
@@ -221,6 +244,9 @@ func main() {
 	apiRouter.HandleFunc("/dns/records/{recordId}/passthrough", passthroughHandler.CreateOrUpdateRecordPool).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns/records/{recordId}/passthrough", passthroughHandler.DeleteRecordPool).Methods("DELETE", "OPTIONS")
 	apiRouter.HandleFunc("/dns/passthrough/{poolId}/rotate", passthroughHandler.RotateRecordPool).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns/passthrough/{poolId}/rotations/{operationId}", passthroughHandler.GetRecordPoolRotation).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns/passthrough/{poolId}/simulate", passthroughHandler.SimulateRecordPool).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns/passthrough/{poolId}/report", passthroughHandler.GetRecordPoolReport).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/dns/passthrough/{poolId}/pause", passthroughHandler.PauseRecordPool).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns/passthrough/{poolId}/resume", passthroughHandler.ResumeRecordPool).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns/passthrough/{poolId}/history", passthroughHandler.GetRotationHistory).Methods("GET", "OPTIONS")
@@ -230,6 +256,9 @@ func main() {
 	apiRouter.HandleFunc("/dns-domains/{domainId}/wildcard", passthroughHandler.CreateOrUpdateWildcardPool).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns-domains/{domainId}/wildcard", passthroughHandler.DeleteWildcardPool).Methods("DELETE", "OPTIONS")
 	apiRouter.HandleFunc("/dns/wildcard/{poolId}/rotate", passthroughHandler.RotateWildcardPool).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns/wildcard/{poolId}/rotations/{operationId}", passthroughHandler.GetWildcardPoolRotation).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/dns/wildcard/{poolId}/simulate", passthroughHandler.SimulateWildcardPool).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/dns/wildcard/{poolId}/report", passthroughHandler.GetWildcardPoolReport).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/dns/wildcard/{poolId}/pause", passthroughHandler.PauseWildcardPool).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/dns/wildcard/{poolId}/resume", passthroughHandler.ResumeWildcardPool).Methods("POST", "OPTIONS")
 	// Nginx config generation
@@ -273,6 +302,19 @@ func main() {
 	agentRouter.HandleFunc("/security/ua-patterns", securityHandler.AgentGetUAPatterns).Methods("GET", "OPTIONS")
 	agentRouter.HandleFunc("/security/whitelist", securityHandler.AgentGetWhitelist).Methods("GET", "OPTIONS")
 
+	// Notifications
+	notificationsHandler := handlers.NewNotificationsHandler(db)
+	apiRouter.HandleFunc("/notifications/channels", notificationsHandler.ListChannels).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/channels", notificationsHandler.CreateChannel).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/channels/{id}", notificationsHandler.UpdateChannel).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/channels/{id}", notificationsHandler.DeleteChannel).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/channels/{id}/test", notificationsHandler.TestChannel).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/channels/{id}/subscriptions", notificationsHandler.ListSubscriptions).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/channels/{id}/subscriptions", notificationsHandler.CreateSubscription).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/subscriptions/{id}", notificationsHandler.DeleteSubscription).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/deliveries", notificationsHandler.ListDeliveries).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/notifications/deliveries/{id}/retry", notificationsHandler.RetryDelivery).Methods("POST", "OPTIONS")
+
 	// Nginx Configs
 	nginxConfigsHandler := handlers.NewNginxConfigsHandler(db)
 	apiRouter.HandleFunc("/nginx-configs", nginxConfigsHandler.ListNginxConfigs).Methods("GET", "OPTIONS")
@@ -286,6 +328,8 @@ func main() {
 	apiRouter.HandleFunc("/jobs", jobsHandler.ListJobs).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/jobs", jobsHandler.CreateJob).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/jobs/{id}", jobsHandler.GetJob).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/jobs/{id}/cancel", jobsHandler.CancelJob).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/jobs/{id}/logs", jobsHandler.GetJobLogs).Methods("GET", "OPTIONS")
 
 	// Commands (templates)
 	commandsHandler := handlers.NewCommandsHandler(db)
@@ -337,9 +381,33 @@ func main() {
 
 	// Start passthrough scheduler for DNS rotation
 	passthroughSched := services.NewPassthroughScheduler(db)
+	passthroughSched.SetNginxApplier(passthroughHandler.NginxGenerator())
 	go passthroughSched.Start()
 	defer passthroughSched.Stop()
 
+	// Start active health probes for passthrough pool members
+	passthroughProber := services.NewPassthroughProber(db)
+	go passthroughProber.Start()
+	defer passthroughProber.Stop()
+
+	// Start DNS drift detector
+	go dnsDriftDetector.Start()
+	defer dnsDriftDetector.Stop()
+
+	// Start DNS zone migrator (waits for delegation changes)
+	go dnsZoneMigrator.Start()
+	defer dnsZoneMigrator.Stop()
+
+	// Start job lease reaper (requeues jobs of agents that stopped responding)
+	jobLeaseReaper := services.NewJobLeaseReaper(db)
+	go jobLeaseReaper.Start()
+	defer jobLeaseReaper.Stop()
+
+	// Start notification dispatcher (sends queued notifications, detects offline agents)
+	notificationDispatcher := services.NewNotificationDispatcher(db)
+	go notificationDispatcher.Start()
+	defer notificationDispatcher.Stop()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -23,6 +23,8 @@ const (
 	EventMachineDeleted      EventType = "machine_deleted"
 	EventProjectCreated      EventType = "project_created"
 	EventProjectDeleted      EventType = "project_deleted"
+	EventDNSDriftDetected    EventType = "dns_drift_detected"
+	EventDNSDriftEnforced    EventType = "dns_drift_enforced"
 )
 
 // Log records an audit event
@@ -0,0 +1,198 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		zone string
+		from string // RFC 3339
+		want []string
+	}{
+		{
+			name: "minute step",
+			expr: "*/15 * * * *",
+			zone: "UTC",
+			from: "2026-01-01T10:07:30Z",
+			want: []string{"2026-01-01 10:15 UTC", "2026-01-01 10:30 UTC", "2026-01-01 10:45 UTC", "2026-01-01 11:00 UTC"},
+		},
+		{
+			name: "step within a range",
+			expr: "0 9-17/4 * * *",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2026-01-01 09:00 UTC", "2026-01-01 13:00 UTC", "2026-01-01 17:00 UTC", "2026-01-02 09:00 UTC"},
+		},
+		{
+			name: "step from a value",
+			expr: "5/20 * * * *",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2026-01-01 00:05 UTC", "2026-01-01 00:25 UTC", "2026-01-01 00:45 UTC", "2026-01-01 01:05 UTC"},
+		},
+		{
+			name: "list of days of month",
+			expr: "30 6 1,15 * *",
+			zone: "UTC",
+			from: "2026-01-10T00:00:00Z",
+			want: []string{"2026-01-15 06:30 UTC", "2026-02-01 06:30 UTC", "2026-02-15 06:30 UTC"},
+		},
+		{
+			name: "weekday names",
+			expr: "0 0 * * mon-fri",
+			zone: "UTC",
+			from: "2026-01-02T12:00:00Z", // Friday
+			want: []string{"2026-01-05 00:00 UTC", "2026-01-06 00:00 UTC"},
+		},
+		{
+			name: "day of week 7 is sunday",
+			expr: "0 0 * * 7",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2026-01-04 00:00 UTC", "2026-01-11 00:00 UTC"},
+		},
+		{
+			name: "day of month or day of week",
+			expr: "0 12 13 * fri",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2026-01-02 12:00 UTC", "2026-01-09 12:00 UTC", "2026-01-13 12:00 UTC", "2026-01-16 12:00 UTC"},
+		},
+		{
+			name: "day of month and unrestricted day of week",
+			expr: "0 12 13 * *",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2026-01-13 12:00 UTC", "2026-02-13 12:00 UTC"},
+		},
+		{
+			name: "month names",
+			expr: "0 0 1 jan,jul *",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2026-07-01 00:00 UTC", "2027-01-01 00:00 UTC"},
+		},
+		{
+			name: "macro",
+			expr: "@monthly",
+			zone: "UTC",
+			from: "2026-01-15T08:00:00Z",
+			want: []string{"2026-02-01 00:00 UTC", "2026-03-01 00:00 UTC"},
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"2028-02-29 00:00 UTC"},
+		},
+		{
+			name: "never matches",
+			expr: "0 0 30 2 *",
+			zone: "UTC",
+			from: "2026-01-01T00:00:00Z",
+			want: []string{"0001-01-01 00:00 UTC"},
+		},
+		{
+			name: "local time zone",
+			expr: "0 9 * * *",
+			zone: "Europe/Berlin",
+			from: "2026-01-01T09:00:00+01:00",
+			want: []string{"2026-01-02 09:00 CET"},
+		},
+		{
+			name: "daily time in the spring forward gap",
+			expr: "30 2 * * *",
+			zone: "America/New_York",
+			from: "2026-03-07T12:00:00-05:00",
+			want: []string{"2026-03-08 03:30 EDT", "2026-03-09 02:30 EDT"},
+		},
+		{
+			name: "minute steps across the spring forward gap",
+			expr: "*/30 * * * *",
+			zone: "America/New_York",
+			from: "2026-03-08T01:10:00-05:00",
+			want: []string{"2026-03-08 01:30 EST", "2026-03-08 03:00 EDT", "2026-03-08 03:30 EDT"},
+		},
+		{
+			name: "daily time in the fall back overlap runs once",
+			expr: "30 1 * * *",
+			zone: "America/New_York",
+			from: "2026-10-31T12:00:00-04:00",
+			want: []string{"2026-11-01 01:30 EDT", "2026-11-02 01:30 EST"},
+		},
+		{
+			name: "hourly doesn't repeat the fall back hour",
+			expr: "0 * * * *",
+			zone: "America/New_York",
+			from: "2026-11-01T00:30:00-04:00",
+			want: []string{"2026-11-01 01:00 EDT", "2026-11-01 02:00 EST", "2026-11-01 03:00 EST"},
+		},
+		{
+			name: "from inside the repeated hour",
+			expr: "20 1 * * *",
+			zone: "America/New_York",
+			from: "2026-11-01T01:10:00-05:00",
+			want: []string{"2026-11-02 01:20 EST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, err := time.Parse(time.RFC3339, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next := from.In(loc)
+			for i, want := range tt.want {
+				next = s.Next(next)
+				if got := next.Format("2006-01-02 15:04 MST"); got != want {
+					t.Fatalf("run %d: got %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1-2-3 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"* * * * funday",
+		"1,,2 * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChangeAction is the kind of change applied to a remote record
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Change status values reported in ChangeResult
+const (
+	ChangeStatusApplied        = "applied"
+	ChangeStatusFailed         = "failed"
+	ChangeStatusRolledBack     = "rolled_back"
+	ChangeStatusRollbackFailed = "rollback_failed"
+	ChangeStatusSkipped        = "skipped"
+)
+
+// Change is a single create/update/delete in a changeset
+type Change struct {
+	Action   ChangeAction `json:"action"`
+	LocalID  string       `json:"local_id,omitempty"`  // Local DB record this change belongs to
+	RecordID string       `json:"record_id,omitempty"` // Remote ID (update/delete)
+	Record   Record       `json:"record"`              // Desired record (create/update), current record (delete)
+	Previous *Record      `json:"previous,omitempty"`  // Remote state before an update, used for rollback
+}
+
+// ChangeResult reports what happened to one change of a changeset
+type ChangeResult struct {
+	Change Change  `json:"change"`
+	Status string  `json:"status"` // applied, failed, rolled_back, rollback_failed, skipped
+	Record *Record `json:"record,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// BatchProvider is implemented by providers with a native bulk API.
+// ApplyChanges must apply all changes atomically: either all succeed or none.
+type BatchProvider interface {
+	Provider
+
+	// ApplyChanges applies the changeset and returns one result per change, in order
+	ApplyChanges(ctx context.Context, domain string, changes []Change) ([]ChangeResult, error)
+}
+
+// ApplyChanges applies a changeset using the provider's native batch API when available,
+// otherwise sequentially with rollback of already-applied changes on failure
+func ApplyChanges(ctx context.Context, provider Provider, domain string, changes []Change) ([]ChangeResult, error) {
+	if len(changes) == 0 {
+		return []ChangeResult{}, nil
+	}
+	if bp, ok := provider.(BatchProvider); ok {
+		return bp.ApplyChanges(ctx, domain, changes)
+	}
+	return applySequential(ctx, provider, domain, changes)
+}
+
+// applySequential applies changes one by one; on the first failure it reverts
+// everything applied so far (in reverse order) and skips the rest
+func applySequential(ctx context.Context, provider Provider, domain string, changes []Change) ([]ChangeResult, error) {
+	results := make([]ChangeResult, len(changes))
+	for i, change := range changes {
+		results[i] = ChangeResult{Change: change, Status: ChangeStatusSkipped}
+	}
+
+	for i, change := range changes {
+		record, err := applyChange(ctx, provider, domain, change)
+		if err != nil {
+			results[i].Status = ChangeStatusFailed
+			results[i].Error = err.Error()
+
+			for j := i - 1; j >= 0; j-- {
+				if rbErr := revertChange(ctx, provider, domain, results[j]); rbErr != nil {
+					results[j].Status = ChangeStatusRollbackFailed
+					results[j].Error = rbErr.Error()
+				} else {
+					results[j].Status = ChangeStatusRolledBack
+				}
+			}
+			return results, fmt.Errorf("failed to %s %s %s: %w", change.Action, change.Record.Type, change.Record.Name, err)
+		}
+
+		results[i].Status = ChangeStatusApplied
+		results[i].Record = record
+	}
+
+	return results, nil
+}
+
+func applyChange(ctx context.Context, provider Provider, domain string, change Change) (*Record, error) {
+	switch change.Action {
+	case ChangeCreate:
+		return provider.CreateRecord(ctx, domain, change.Record)
+	case ChangeUpdate:
+		return provider.UpdateRecord(ctx, domain, change.RecordID, change.Record)
+	case ChangeDelete:
+		if err := provider.DeleteRecord(ctx, domain, change.RecordID); err != nil {
+			return nil, err
+		}
+		record := change.Record
+		return &record, nil
+	default:
+		return nil, fmt.Errorf("unknown change action: %s", change.Action)
+	}
+}
+
+// revertChange undoes an applied change
+func revertChange(ctx context.Context, provider Provider, domain string, result ChangeResult) error {
+	switch result.Change.Action {
+	case ChangeCreate:
+		return provider.DeleteRecord(ctx, domain, result.Record.ID)
+	case ChangeUpdate:
+		if result.Change.Previous == nil {
+			return fmt.Errorf("no previous state to restore")
+		}
+		_, err := provider.UpdateRecord(ctx, domain, result.Record.ID, *result.Change.Previous)
+		return err
+	case ChangeDelete:
+		_, err := provider.CreateRecord(ctx, domain, result.Change.Record)
+		return err
+	}
+	return nil
+}
+
+// batchResults marks every change with the same outcome (native batches are all-or-nothing)
+func batchResults(changes []Change, records []*Record, err error) ([]ChangeResult, error) {
+	results := make([]ChangeResult, len(changes))
+	for i, change := range changes {
+		results[i] = ChangeResult{Change: change}
+		if err != nil {
+			results[i].Status = ChangeStatusFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Status = ChangeStatusApplied
+		if records != nil && records[i] != nil {
+			results[i].Record = records[i]
+		} else {
+			record := change.Record
+			record.ID = change.RecordID
+			results[i].Record = &record
+		}
+	}
+	return results, err
+}
+
+// ==================== RRset planning ====================
+// Providers that replace whole RRsets (deSEC, PowerDNS, Route53) compute the
+// final content of every touched RRset and submit them in one request.
+
+type rrsetKey struct {
+	Name string // "www", "@"
+	Type string
+}
+
+type rrsetState struct {
+	TTL      int
+	Contents []string
+}
+
+// rrsetPlan is the outcome of applying a changeset on top of the current RRsets
+type rrsetPlan struct {
+	Sets    map[rrsetKey]*rrsetState
+	Touched []rrsetKey // In first-touched order
+	Records []*Record  // Resulting record per change (nil for deletes)
+	keys    []rrsetKey // RRset each change landed in
+	content []string   // Content each change wrote
+}
+
+// planRRsets applies changes to the current RRsets. resolve maps a remote record ID to
+// the RRset and content it refers to.
+func planRRsets(current map[rrsetKey]*rrsetState, changes []Change, resolve func(recordID string) (rrsetKey, string, error)) (*rrsetPlan, error) {
+	plan := &rrsetPlan{
+		Sets:    current,
+		Records: make([]*Record, len(changes)),
+		keys:    make([]rrsetKey, len(changes)),
+		content: make([]string, len(changes)),
+	}
+	touched := make(map[rrsetKey]bool)
+
+	touch := func(key rrsetKey) *rrsetState {
+		state, ok := plan.Sets[key]
+		if !ok {
+			state = &rrsetState{}
+			plan.Sets[key] = state
+		}
+		if !touched[key] {
+			touched[key] = true
+			plan.Touched = append(plan.Touched, key)
+		}
+		return state
+	}
+
+	remove := func(recordID string) error {
+		key, content, err := resolve(recordID)
+		if err != nil {
+			return err
+		}
+		state := touch(key)
+		kept := state.Contents[:0]
+		for _, c := range state.Contents {
+			if !sameContent(key.Type, c, content) {
+				kept = append(kept, c)
+			}
+		}
+		state.Contents = kept
+		return nil
+	}
+
+	add := func(i int, record Record) {
+		key := rrsetKey{Name: record.Name, Type: record.Type}
+		state := touch(key)
+		content := rrsetContent(record)
+		kept := state.Contents[:0]
+		for _, c := range state.Contents {
+			if !sameContent(key.Type, c, content) {
+				kept = append(kept, c)
+			}
+		}
+		state.Contents = append(kept, content)
+		if record.TTL > 0 {
+			state.TTL = record.TTL
+		}
+		result := record
+		plan.Records[i] = &result
+		plan.keys[i] = key
+		plan.content[i] = content
+	}
+
+	for i, change := range changes {
+		switch change.Action {
+		case ChangeCreate:
+			add(i, change.Record)
+		case ChangeUpdate:
+			if err := remove(change.RecordID); err != nil {
+				return nil, err
+			}
+			add(i, change.Record)
+		case ChangeDelete:
+			if err := remove(change.RecordID); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown change action: %s", change.Action)
+		}
+	}
+
+	return plan, nil
+}
+
+// assignIDs sets the resulting record IDs once the plan is final
+func (plan *rrsetPlan) assignIDs(id func(key rrsetKey, content string) string) {
+	for i, record := range plan.Records {
+		if record == nil {
+			continue
+		}
+		record.ID = id(plan.keys[i], plan.content[i])
+		if state := plan.Sets[plan.keys[i]]; state != nil && record.TTL == 0 {
+			record.TTL = state.TTL
+		}
+	}
+}
+
+// resolveRRsetRecordID resolves synthetic "name:type:content" IDs
+func resolveRRsetRecordID(recordID string) (rrsetKey, string, error) {
+	name, recordType, content, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return rrsetKey{}, "", err
+	}
+	return rrsetKey{Name: name, Type: recordType}, content, nil
+}
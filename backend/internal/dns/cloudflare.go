@@ -43,6 +43,21 @@ type cfError struct {
 	Message string `json:"message"`
 }
 
+// cfData holds the structured fields of SRV, CAA and TLSA records
+type cfData struct {
+	Priority     int    `json:"priority"`
+	Weight       int    `json:"weight"`
+	Port         int    `json:"port"`
+	Target       string `json:"target"`
+	Flags        int    `json:"flags"`
+	Tag          string `json:"tag"`
+	Value        string `json:"value"`
+	Usage        int    `json:"usage"`
+	Selector     int    `json:"selector"`
+	MatchingType int    `json:"matching_type"`
+	Certificate  string `json:"certificate"`
+}
+
 func (p *CloudflareProvider) doRequest(ctx context.Context, method, path string, body interface{}) (*cfResponse, error) {
 	var reqBody io.Reader
 	if body != nil {
@@ -229,6 +244,7 @@ func (p *CloudflareProvider) ListRecords(ctx context.Context, domain string) ([]
 		Priority   int       `json:"priority"`
 		Proxied    bool      `json:"proxied"`
 		ModifiedOn time.Time `json:"modified_on"`
+		Data       *cfData   `json:"data"`
 	}
 	if err := json.Unmarshal(result.Result, &cfRecords); err != nil {
 		return nil, fmt.Errorf("failed to parse records: %w", err)
@@ -252,18 +268,35 @@ func (p *CloudflareProvider) ListRecords(ctx context.Context, domain string) ([]
 			Proxied:   r.Proxied,
 			UpdatedAt: r.ModifiedOn,
 		}
+
+		// Structured types carry their fields in "data"
+		if r.Data != nil {
+			switch r.Type {
+			case "SRV":
+				record.Priority = r.Data.Priority
+				record.Weight = r.Data.Weight
+				record.Port = r.Data.Port
+				record.Value = strings.TrimSuffix(r.Data.Target, ".")
+			case "CAA":
+				record.Flags = r.Data.Flags
+				record.Tag = r.Data.Tag
+				record.Value = r.Data.Value
+			case "TLSA":
+				record.Usage = r.Data.Usage
+				record.Selector = r.Data.Selector
+				record.MatchingType = r.Data.MatchingType
+				record.Value = strings.ToLower(r.Data.Certificate)
+			}
+		}
+
 		records = append(records, record)
 	}
 
 	return records, nil
 }
 
-func (p *CloudflareProvider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
-	zoneID, err := p.getZoneID(ctx, domain)
-	if err != nil {
-		return nil, err
-	}
-
+// recordBody builds the API representation of a record
+func (p *CloudflareProvider) recordBody(domain string, record Record) map[string]interface{} {
 	// Build full name
 	name := record.Name
 	if name == "@" {
@@ -284,11 +317,49 @@ func (p *CloudflareProvider) CreateRecord(ctx context.Context, domain string, re
 		body["ttl"] = 1 // 1 = auto
 	}
 
-	if record.Type == "MX" {
+	switch record.Type {
+	case "MX":
 		body["priority"] = record.Priority
+	case "SRV":
+		// Structured types are sent as "data" instead of "content"
+		delete(body, "content")
+		body["data"] = map[string]interface{}{
+			"priority": record.Priority,
+			"weight":   record.Weight,
+			"port":     record.Port,
+			"target":   record.Value,
+		}
+	case "CAA":
+		delete(body, "content")
+		body["data"] = map[string]interface{}{
+			"flags": record.Flags,
+			"tag":   record.Tag,
+			"value": record.Value,
+		}
+	case "TLSA":
+		delete(body, "content")
+		body["data"] = map[string]interface{}{
+			"usage":         record.Usage,
+			"selector":      record.Selector,
+			"matching_type": record.MatchingType,
+			"certificate":   record.Value,
+		}
+	}
+
+	if record.Type != "A" && record.Type != "AAAA" && record.Type != "CNAME" {
+		delete(body, "proxied") // Only A/AAAA/CNAME can be proxied
 	}
 
-	result, err := p.doRequest(ctx, "POST", "/zones/"+zoneID+"/dns_records", body)
+	return body
+}
+
+func (p *CloudflareProvider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.doRequest(ctx, "POST", "/zones/"+zoneID+"/dns_records", p.recordBody(domain, record))
 	if err != nil {
 		return nil, err
 	}
@@ -310,31 +381,7 @@ func (p *CloudflareProvider) UpdateRecord(ctx context.Context, domain string, re
 		return nil, err
 	}
 
-	// Build full name
-	name := record.Name
-	if name == "@" {
-		name = domain
-	} else {
-		name = record.Name + "." + domain
-	}
-
-	body := map[string]interface{}{
-		"type":    record.Type,
-		"name":    name,
-		"content": record.Value,
-		"ttl":     record.TTL,
-		"proxied": record.Proxied,
-	}
-
-	if record.TTL == 0 {
-		body["ttl"] = 1
-	}
-
-	if record.Type == "MX" {
-		body["priority"] = record.Priority
-	}
-
-	_, err = p.doRequest(ctx, "PUT", "/zones/"+zoneID+"/dns_records/"+recordID, body)
+	_, err = p.doRequest(ctx, "PUT", "/zones/"+zoneID+"/dns_records/"+recordID, p.recordBody(domain, record))
 	if err != nil {
 		return nil, err
 	}
@@ -353,3 +400,135 @@ func (p *CloudflareProvider) DeleteRecord(ctx context.Context, domain string, re
 	return err
 }
 
+// ApplyChanges submits the changeset through the batch endpoint, which Cloudflare
+// executes in a single transaction (deletes, then puts, then posts)
+func (p *CloudflareProvider) ApplyChanges(ctx context.Context, domain string, changes []Change) ([]ChangeResult, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	deletes := []map[string]interface{}{}
+	puts := []map[string]interface{}{}
+	posts := []map[string]interface{}{}
+	position := make([]int, len(changes)) // Index of each change within its list
+
+	for i, change := range changes {
+		switch change.Action {
+		case ChangeCreate:
+			position[i] = len(posts)
+			posts = append(posts, p.recordBody(domain, change.Record))
+		case ChangeUpdate:
+			body := p.recordBody(domain, change.Record)
+			body["id"] = change.RecordID
+			position[i] = len(puts)
+			puts = append(puts, body)
+		case ChangeDelete:
+			position[i] = len(deletes)
+			deletes = append(deletes, map[string]interface{}{"id": change.RecordID})
+		default:
+			return batchResults(changes, nil, fmt.Errorf("unknown change action: %s", change.Action))
+		}
+	}
+
+	result, err := p.doRequest(ctx, "POST", "/zones/"+zoneID+"/dns_records/batch", map[string]interface{}{
+		"deletes": deletes,
+		"puts":    puts,
+		"posts":   posts,
+	})
+	if err != nil {
+		return batchResults(changes, nil, fmt.Errorf("batch request failed: %w", err))
+	}
+
+	var batch struct {
+		Puts []struct {
+			ID string `json:"id"`
+		} `json:"puts"`
+		Posts []struct {
+			ID string `json:"id"`
+		} `json:"posts"`
+	}
+	if err := json.Unmarshal(result.Result, &batch); err != nil {
+		return batchResults(changes, nil, fmt.Errorf("failed to parse batch result: %w", err))
+	}
+
+	records := make([]*Record, len(changes))
+	for i, change := range changes {
+		record := change.Record
+		switch change.Action {
+		case ChangeCreate:
+			if position[i] < len(batch.Posts) {
+				record.ID = batch.Posts[position[i]].ID
+			}
+		case ChangeUpdate:
+			record.ID = change.RecordID
+			if position[i] < len(batch.Puts) && batch.Puts[position[i]].ID != "" {
+				record.ID = batch.Puts[position[i]].ID
+			}
+		case ChangeDelete:
+			record.ID = change.RecordID
+		}
+		records[i] = &record
+	}
+
+	return batchResults(changes, records, nil)
+}
+
+type cfDNSSEC struct {
+	Status     string `json:"status"` // active, pending, disabled, pending-disabled, error
+	DS         string `json:"ds"`
+	PublicKey  string `json:"public_key"`
+	KeyTag     int    `json:"key_tag"`
+	Algorithm  string `json:"algorithm"`
+	DigestType string `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+func (d cfDNSSEC) toStatus() *DNSSECStatus {
+	status := &DNSSECStatus{
+		Enabled:   d.Status == "active" || d.Status == "pending",
+		Status:    d.Status,
+		DSRecords: []DSRecord{},
+	}
+	if d.DS != "" {
+		status.DSRecords = parseDSRecords([]string{d.DS})
+	}
+	if d.PublicKey != "" {
+		status.DNSKeys = []string{d.PublicKey}
+	}
+	return status
+}
+
+// GetDNSSEC returns the zone's DNSSEC state and DS record
+func (p *CloudflareProvider) GetDNSSEC(ctx context.Context, domain string) (*DNSSECStatus, error) {
+	return p.setDNSSEC(ctx, domain, "GET", nil)
+}
+
+// EnableDNSSEC turns on signing; Cloudflare reports "pending" until the DS is seen at the parent
+func (p *CloudflareProvider) EnableDNSSEC(ctx context.Context, domain string) (*DNSSECStatus, error) {
+	return p.setDNSSEC(ctx, domain, "PATCH", map[string]string{"status": "active"})
+}
+
+// DisableDNSSEC turns off signing
+func (p *CloudflareProvider) DisableDNSSEC(ctx context.Context, domain string) error {
+	_, err := p.setDNSSEC(ctx, domain, "PATCH", map[string]string{"status": "disabled"})
+	return err
+}
+
+func (p *CloudflareProvider) setDNSSEC(ctx context.Context, domain, method string, body interface{}) (*DNSSECStatus, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.doRequest(ctx, method, "/zones/"+zoneID+"/dnssec", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var dnssec cfDNSSEC
+	if err := json.Unmarshal(result.Result, &dnssec); err != nil {
+		return nil, fmt.Errorf("failed to parse DNSSEC status: %w", err)
+	}
+	return dnssec.toStatus(), nil
+}
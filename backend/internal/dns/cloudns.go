@@ -64,7 +64,17 @@ type cloudnsRecord struct {
 	Type     string `json:"type"`     // A, AAAA, MX, TXT, CNAME, etc
 	Record   string `json:"record"`   // The record value
 	TTL      string `json:"ttl"`
-	Priority string `json:"priority,omitempty"` // For MX records
+	Priority string `json:"priority,omitempty"` // For MX and SRV records
+	Weight   string `json:"weight,omitempty"`   // For SRV records
+	Port     string `json:"port,omitempty"`     // For SRV records
+
+	CAAFlag  string `json:"caa_flag,omitempty"`
+	CAAType  string `json:"caa_type,omitempty"`
+	CAAValue string `json:"caa_value,omitempty"`
+
+	TLSAUsage        string `json:"tlsa_usage,omitempty"`
+	TLSASelector     string `json:"tlsa_selector,omitempty"`
+	TLSAMatchingType string `json:"tlsa_matching_type,omitempty"`
 }
 
 func (p *ClouDNSProvider) buildParams() url.Values {
@@ -254,12 +264,49 @@ func (p *ClouDNSProvider) ListRecords(ctx context.Context, domain string) ([]Rec
 			Priority: priority,
 			Proxied:  false, // ClouDNS doesn't support proxying
 		}
+
+		switch r.Type {
+		case "SRV":
+			record.Weight, _ = strconv.Atoi(r.Weight)
+			record.Port, _ = strconv.Atoi(r.Port)
+		case "CAA":
+			record.Flags, _ = strconv.Atoi(r.CAAFlag)
+			record.Tag = r.CAAType
+			record.Value = r.CAAValue
+		case "TLSA":
+			record.Usage, _ = strconv.Atoi(r.TLSAUsage)
+			record.Selector, _ = strconv.Atoi(r.TLSASelector)
+			record.MatchingType, _ = strconv.Atoi(r.TLSAMatchingType)
+		}
 		records = append(records, record)
 	}
 
 	return records, nil
 }
 
+// setClouDNSRecordParams adds the type-specific fields of a record
+func setClouDNSRecordParams(params url.Values, record Record) {
+	switch record.Type {
+	case "MX":
+		if record.Priority > 0 {
+			params.Set("priority", strconv.Itoa(record.Priority))
+		}
+	case "SRV":
+		params.Set("priority", strconv.Itoa(record.Priority))
+		params.Set("weight", strconv.Itoa(record.Weight))
+		params.Set("port", strconv.Itoa(record.Port))
+	case "CAA":
+		params.Del("record")
+		params.Set("caa_flag", strconv.Itoa(record.Flags))
+		params.Set("caa_type", record.Tag)
+		params.Set("caa_value", record.Value)
+	case "TLSA":
+		params.Set("tlsa_usage", strconv.Itoa(record.Usage))
+		params.Set("tlsa_selector", strconv.Itoa(record.Selector))
+		params.Set("tlsa_matching_type", strconv.Itoa(record.MatchingType))
+	}
+}
+
 func (p *ClouDNSProvider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
 	name := record.Name
 	if name == "@" {
@@ -277,9 +324,7 @@ func (p *ClouDNSProvider) CreateRecord(ctx context.Context, domain string, recor
 		params.Set("ttl", "3600")
 	}
 
-	if record.Type == "MX" && record.Priority > 0 {
-		params.Set("priority", strconv.Itoa(record.Priority))
-	}
+	setClouDNSRecordParams(params, record)
 
 	body, err := p.doPost(ctx, "add-record", params)
 	if err != nil {
@@ -321,9 +366,7 @@ func (p *ClouDNSProvider) UpdateRecord(ctx context.Context, domain string, recor
 	params.Set("record", record.Value)
 	params.Set("ttl", strconv.Itoa(record.TTL))
 
-	if record.Type == "MX" && record.Priority > 0 {
-		params.Set("priority", strconv.Itoa(record.Priority))
-	}
+	setClouDNSRecordParams(params, record)
 
 	_, err := p.doPost(ctx, "mod-record", params)
 	if err != nil {
@@ -193,37 +193,18 @@ func (p *DeSECProvider) ListRecords(ctx context.Context, domain string) ([]Recor
 				name = "@"
 			}
 
-			// Parse priority for MX records
-			priority := 0
-			recordValue := value
-			if rrset.Type == "MX" {
-				// MX records in deSEC are stored as "priority target"
-				parts := strings.SplitN(value, " ", 2)
-				if len(parts) == 2 {
-					priority, _ = strconv.Atoi(parts[0])
-					recordValue = strings.TrimSuffix(parts[1], ".")
-				}
-			}
-
-			// Clean up trailing dots from values
-			recordValue = strings.TrimSuffix(recordValue, ".")
+			// deSEC stores zone-file rdata ("10 mail.example.com.", "0 issue \"letsencrypt.org\"")
+			record := recordFromContent(rrset.Type, value)
+			record.ID = recordID
+			record.Name = name
+			record.TTL = rrset.TTL
+			record.Proxied = false // deSEC doesn't have proxying
 
 			// Parse timestamp
-			var updatedAt time.Time
 			if rrset.Touched != "" {
-				updatedAt, _ = time.Parse(time.RFC3339, rrset.Touched)
+				record.UpdatedAt, _ = time.Parse(time.RFC3339, rrset.Touched)
 			}
 
-			record := Record{
-				ID:        recordID,
-				Name:      name,
-				Type:      rrset.Type,
-				Value:     recordValue,
-				TTL:       rrset.TTL,
-				Priority:  priority,
-				Proxied:   false, // deSEC doesn't have proxying
-				UpdatedAt: updatedAt,
-			}
 			records = append(records, record)
 		}
 	}
@@ -238,22 +219,8 @@ func (p *DeSECProvider) CreateRecord(ctx context.Context, domain string, record
 		subname = ""
 	}
 
-	// Format value based on record type
-	value := record.Value
-	if record.Type == "MX" {
-		// MX records need priority prefix
-		value = fmt.Sprintf("%d %s.", record.Priority, strings.TrimSuffix(record.Value, "."))
-	} else if record.Type == "CNAME" || record.Type == "NS" {
-		// Add trailing dot for CNAME and NS
-		if !strings.HasSuffix(value, ".") {
-			value = value + "."
-		}
-	} else if record.Type == "TXT" {
-		// TXT records need to be quoted
-		if !strings.HasPrefix(value, "\"") {
-			value = fmt.Sprintf("\"%s\"", value)
-		}
-	}
+	// Format value as zone-file rdata (MX/SRV priority prefix, trailing dots, TXT quoting)
+	value := rrsetContent(record)
 
 	ttl := record.TTL
 	if ttl == 0 {
@@ -332,18 +299,7 @@ func (p *DeSECProvider) UpdateRecord(ctx context.Context, domain string, recordI
 	}
 
 	// Format value
-	value := record.Value
-	if record.Type == "MX" {
-		value = fmt.Sprintf("%d %s.", record.Priority, strings.TrimSuffix(record.Value, "."))
-	} else if record.Type == "CNAME" || record.Type == "NS" {
-		if !strings.HasSuffix(value, ".") {
-			value = value + "."
-		}
-	} else if record.Type == "TXT" {
-		if !strings.HasPrefix(value, "\"") {
-			value = fmt.Sprintf("\"%s\"", value)
-		}
-	}
+	value := rrsetContent(record)
 
 	ttl := record.TTL
 	if ttl < 3600 {
@@ -447,3 +403,131 @@ func (p *DeSECProvider) DeleteRecord(ctx context.Context, domain string, recordI
 	_, err = p.doRequest(ctx, "PATCH", path, updateBody)
 	return err
 }
+
+// ApplyChanges applies the changeset with a single bulk PATCH on the rrsets
+// endpoint, which deSEC processes atomically
+func (p *DeSECProvider) ApplyChanges(ctx context.Context, domain string, changes []Change) ([]ChangeResult, error) {
+	respBody, err := p.doRequest(ctx, "GET", "/domains/"+url.PathEscape(domain)+"/rrsets/", nil)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	var rrsets []desecRRset
+	if err := json.Unmarshal(respBody, &rrsets); err != nil {
+		return batchResults(changes, nil, fmt.Errorf("failed to parse rrsets: %w", err))
+	}
+
+	// Snapshot of the current records - IDs are "subname:type:index" into these
+	original := make(map[rrsetKey][]string)
+	current := make(map[rrsetKey]*rrsetState)
+	for _, rrset := range rrsets {
+		name := rrset.Subname
+		if name == "" {
+			name = "@"
+		}
+		key := rrsetKey{Name: name, Type: rrset.Type}
+		original[key] = rrset.Records
+		current[key] = &rrsetState{
+			TTL:      rrset.TTL,
+			Contents: append([]string{}, rrset.Records...),
+		}
+	}
+
+	resolve := func(recordID string) (rrsetKey, string, error) {
+		parts := strings.SplitN(recordID, ":", 3)
+		if len(parts) < 2 {
+			return rrsetKey{}, "", fmt.Errorf("invalid record ID format")
+		}
+		name := parts[0]
+		if name == "" {
+			name = "@"
+		}
+		key := rrsetKey{Name: name, Type: parts[1]}
+		index := 0
+		if len(parts) == 3 {
+			index, _ = strconv.Atoi(parts[2])
+		}
+		if index >= len(original[key]) {
+			return rrsetKey{}, "", fmt.Errorf("record %s not found", recordID)
+		}
+		return key, original[key][index], nil
+	}
+
+	plan, err := planRRsets(current, changes, resolve)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	body := make([]desecRRset, 0, len(plan.Touched))
+	for _, key := range plan.Touched {
+		state := plan.Sets[key]
+		if state.TTL < 3600 {
+			state.TTL = 3600 // deSEC has a minimum TTL of 3600
+		}
+		subname := key.Name
+		if subname == "@" {
+			subname = ""
+		}
+		// An empty record list deletes the RRset
+		body = append(body, desecRRset{
+			Subname: subname,
+			Type:    key.Type,
+			Records: state.Contents,
+			TTL:     state.TTL,
+		})
+	}
+
+	if _, err := p.doRequest(ctx, "PATCH", "/domains/"+url.PathEscape(domain)+"/rrsets/", body); err != nil {
+		return batchResults(changes, nil, fmt.Errorf("failed to apply changes: %w", err))
+	}
+
+	plan.assignIDs(func(key rrsetKey, content string) string {
+		subname := key.Name
+		if subname == "@" {
+			subname = ""
+		}
+		index := 0
+		for i, c := range plan.Sets[key].Contents {
+			if c == content {
+				index = i
+				break
+			}
+		}
+		return fmt.Sprintf("%s:%s:%d", subname, key.Type, index)
+	})
+	return batchResults(changes, plan.Records, nil)
+}
+
+// GetDNSSEC returns the domain's signing keys; deSEC signs every domain
+func (p *DeSECProvider) GetDNSSEC(ctx context.Context, domain string) (*DNSSECStatus, error) {
+	respBody, err := p.doRequest(ctx, "GET", "/domains/"+url.PathEscape(domain)+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d desecDomain
+	if err := json.Unmarshal(respBody, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse domain: %w", err)
+	}
+
+	status := &DNSSECStatus{
+		Enabled:   true,
+		Status:    "active",
+		DSRecords: []DSRecord{},
+	}
+	for _, key := range d.Keys {
+		status.DNSKeys = append(status.DNSKeys, key.DNSKey)
+		status.DSRecords = append(status.DSRecords, parseDSRecords(key.DS)...)
+	}
+	return status, nil
+}
+
+// EnableDNSSEC is a no-op: deSEC always signs
+func (p *DeSECProvider) EnableDNSSEC(ctx context.Context, domain string) (*DNSSECStatus, error) {
+	return p.GetDNSSEC(ctx, domain)
+}
+
+// DisableDNSSEC is not supported by deSEC
+func (p *DeSECProvider) DisableDNSSEC(ctx context.Context, domain string) error {
+	return fmt.Errorf("deSEC always signs zones; remove the DS at the registrar instead")
+}
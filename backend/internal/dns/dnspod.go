@@ -192,6 +192,13 @@ func (p *DNSPodProvider) ListRecords(ctx context.Context, domain string) ([]Reco
 			Priority: mx,
 		}
 
+		// SRV, CAA and TLSA values are zone-file rdata ("0 5 5060 sip.example.com.")
+		if r.Type == "SRV" || r.Type == "CAA" || r.Type == "TLSA" {
+			parsed := recordFromContent(r.Type, r.Value)
+			parsed.ID, parsed.Name, parsed.TTL = record.ID, record.Name, record.TTL
+			record = parsed
+		}
+
 		if r.UpdatedOn != "" {
 			record.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", r.UpdatedOn)
 		}
@@ -202,13 +209,22 @@ func (p *DNSPodProvider) ListRecords(ctx context.Context, domain string) ([]Reco
 	return records, nil
 }
 
+// dnspodValue formats the record value; structured types are sent as zone-file rdata
+func dnspodValue(record Record) string {
+	switch record.Type {
+	case "SRV", "CAA", "TLSA":
+		return rrsetContent(record)
+	}
+	return record.Value
+}
+
 func (p *DNSPodProvider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
 	params := url.Values{
 		"domain":      {domain},
 		"sub_domain":  {record.Name},
 		"record_type": {record.Type},
 		"record_line": {"默认"}, // Default line
-		"value":       {record.Value},
+		"value":       {dnspodValue(record)},
 	}
 
 	if record.TTL > 0 {
@@ -245,7 +261,7 @@ func (p *DNSPodProvider) UpdateRecord(ctx context.Context, domain string, record
 		"sub_domain":  {record.Name},
 		"record_type": {record.Type},
 		"record_line": {"默认"},
-		"value":       {record.Value},
+		"value":       {dnspodValue(record)},
 	}
 
 	if record.TTL > 0 {
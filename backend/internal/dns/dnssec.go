@@ -0,0 +1,236 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// DSRecord is a delegation signer record as published at the parent zone
+type DSRecord struct {
+	KeyTag     int    `json:"key_tag"`
+	Algorithm  int    `json:"algorithm"`
+	DigestType int    `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+// String returns the DS rdata in presentation format
+func (d DSRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, d.Digest)
+}
+
+// Equal reports whether two DS records describe the same key digest
+func (d DSRecord) Equal(other DSRecord) bool {
+	return d.KeyTag == other.KeyTag && d.Algorithm == other.Algorithm &&
+		d.DigestType == other.DigestType && strings.EqualFold(d.Digest, other.Digest)
+}
+
+// ParseDSRecord parses DS rdata ("2371 13 2 abcd...") or a full DS RR
+// ("example.com. 3600 IN DS 2371 13 2 abcd...")
+func ParseDSRecord(s string) (DSRecord, error) {
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		if strings.EqualFold(f, "DS") {
+			fields = fields[i+1:]
+			break
+		}
+	}
+	if len(fields) < 4 {
+		return DSRecord{}, fmt.Errorf("invalid DS record: %q", s)
+	}
+
+	var nums [3]int
+	for i := range nums {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return DSRecord{}, fmt.Errorf("invalid DS record: %q", s)
+		}
+		nums[i] = n
+	}
+	return DSRecord{
+		KeyTag:     nums[0],
+		Algorithm:  nums[1],
+		DigestType: nums[2],
+		Digest:     strings.ToUpper(strings.Join(fields[3:], "")),
+	}, nil
+}
+
+// DNSSECStatus is the signing state of a zone at the provider
+type DNSSECStatus struct {
+	Enabled   bool       `json:"enabled"`
+	Status    string     `json:"status"` // Provider-reported state: active, pending, disabled, ...
+	DSRecords []DSRecord `json:"ds_records"`
+	DNSKeys   []string   `json:"dnskeys,omitempty"`
+}
+
+// DNSSECProvider is implemented by providers that can sign zones.
+// Providers that always sign (deSEC) may reject DisableDNSSEC.
+type DNSSECProvider interface {
+	Provider
+
+	// GetDNSSEC returns the signing state and the DS records to publish at the registrar
+	GetDNSSEC(ctx context.Context, domain string) (*DNSSECStatus, error)
+
+	// EnableDNSSEC turns on signing for the zone
+	EnableDNSSEC(ctx context.Context, domain string) (*DNSSECStatus, error)
+
+	// DisableDNSSEC turns off signing for the zone
+	DisableDNSSEC(ctx context.Context, domain string) error
+}
+
+// DNSSECValidation compares the DS records at the parent with the provider's keys
+type DNSSECValidation struct {
+	Status     string     `json:"status"` // unsigned, valid, missing_ds, mismatch, insecure_ds, error
+	ProviderDS []DSRecord `json:"provider_ds"`
+	ParentDS   []DSRecord `json:"parent_ds"`
+	Matched    []DSRecord `json:"matched"`
+	Message    string     `json:"message"`
+}
+
+// ValidateDNSSEC resolves the domain's DS records at the parent zone and checks that
+// they match the keys the provider signs with
+func ValidateDNSSEC(ctx context.Context, domain string, status *DNSSECStatus) *DNSSECValidation {
+	result := &DNSSECValidation{
+		ProviderDS: []DSRecord{},
+		ParentDS:   []DSRecord{},
+		Matched:    []DSRecord{},
+	}
+	if status != nil {
+		result.ProviderDS = append(result.ProviderDS, status.DSRecords...)
+	}
+
+	parentDS, err := LookupParentDS(ctx, domain)
+	if err != nil {
+		result.Status = "error"
+		result.Message = fmt.Sprintf("DS lookup failed: %v", err)
+		return result
+	}
+	result.ParentDS = append(result.ParentDS, parentDS...)
+
+	signed := status != nil && status.Enabled
+	switch {
+	case !signed && len(parentDS) == 0:
+		result.Status = "unsigned"
+		result.Message = "Zone is not signed and no DS is published"
+		return result
+	case !signed:
+		// A DS at the parent for an unsigned zone makes the domain bogus for validating resolvers
+		result.Status = "insecure_ds"
+		result.Message = "DS published at the parent but the zone is not signed - remove the DS at the registrar"
+		return result
+	case len(parentDS) == 0:
+		result.Status = "missing_ds"
+		result.Message = "Zone is signed but no DS is published at the parent"
+		return result
+	}
+
+	for _, ds := range parentDS {
+		for _, expected := range result.ProviderDS {
+			if ds.Equal(expected) {
+				result.Matched = append(result.Matched, ds)
+				break
+			}
+		}
+	}
+
+	if len(result.Matched) == 0 {
+		result.Status = "mismatch"
+		result.Message = "DS at the parent does not match any provider key - chain of trust is broken"
+		return result
+	}
+
+	result.Status = "valid"
+	result.Message = "Chain of trust is intact"
+	return result
+}
+
+// LookupParentDS queries the parent zone's authoritative servers for the domain's DS records
+func LookupParentDS(ctx context.Context, domain string) ([]DSRecord, error) {
+	servers, err := parentNameservers(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetQuestion(mdns.Fqdn(domain), mdns.TypeDS)
+	msg.RecursionDesired = false
+	msg.SetEdns0(4096, true)
+
+	client := &mdns.Client{Timeout: 5 * time.Second}
+
+	var lastErr error
+	for _, server := range servers {
+		resp, _, err := client.ExchangeContext(ctx, msg, server)
+		if err == nil && resp.Truncated {
+			tcp := &mdns.Client{Net: "tcp", Timeout: 5 * time.Second}
+			resp, _, err = tcp.ExchangeContext(ctx, msg, server)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != mdns.RcodeSuccess {
+			lastErr = fmt.Errorf("%s answered %s", server, mdns.RcodeToString[resp.Rcode])
+			continue
+		}
+
+		records := []DSRecord{}
+		for _, rr := range resp.Answer {
+			if ds, ok := rr.(*mdns.DS); ok {
+				records = append(records, DSRecord{
+					KeyTag:     int(ds.KeyTag),
+					Algorithm:  int(ds.Algorithm),
+					DigestType: int(ds.DigestType),
+					Digest:     strings.ToUpper(ds.Digest),
+				})
+			}
+		}
+		return records, nil
+	}
+
+	return nil, fmt.Errorf("no parent nameserver answered: %v", lastErr)
+}
+
+// parentNameservers returns host:port addresses of the nameservers serving the
+// closest enclosing zone of domain (e.g. the "com" servers for example.com)
+func parentNameservers(domain string) ([]string, error) {
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	for i := 1; i < len(labels); i++ {
+		parent := strings.Join(labels[i:], ".")
+		nsRecords, err := net.LookupNS(parent)
+		if err != nil || len(nsRecords) == 0 {
+			continue
+		}
+
+		var servers []string
+		for _, ns := range nsRecords {
+			addrs, err := net.LookupHost(ns.Host)
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				servers = append(servers, net.JoinHostPort(addr, "53"))
+			}
+		}
+		if len(servers) > 0 {
+			return servers, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find the parent zone of %s", domain)
+}
+
+// parseDSRecords parses a list of DS strings, skipping malformed entries
+func parseDSRecords(values []string) []DSRecord {
+	records := []DSRecord{}
+	for _, v := range values {
+		if ds, err := ParseDSRecord(v); err == nil {
+			records = append(records, ds)
+		}
+	}
+	return records
+}
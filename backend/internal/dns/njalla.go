@@ -60,7 +60,9 @@ type njallaRecord struct {
 	Type     string `json:"type"`     // A, AAAA, MX, TXT, CNAME, etc
 	Content  string `json:"content"`  // The record value
 	TTL      int    `json:"ttl"`
-	Priority int    `json:"prio,omitempty"` // For MX records
+	Priority int    `json:"prio,omitempty"` // For MX and SRV records
+	Weight   int    `json:"weight,omitempty"` // For SRV records
+	Port     int    `json:"port,omitempty"`   // For SRV records
 }
 
 func (p *NjallaProvider) doRequest(ctx context.Context, method string, params interface{}) (*njallaResponse, error) {
@@ -196,14 +198,39 @@ func (p *NjallaProvider) ListRecords(ctx context.Context, domain string) ([]Reco
 			Value:    r.Content,
 			TTL:      r.TTL,
 			Priority: r.Priority,
+			Weight:   r.Weight,
+			Port:     r.Port,
 			Proxied:  false, // Njalla doesn't support proxying
 		}
+
+		// CAA and TLSA are stored as zone-file rdata in content
+		if r.Type == "CAA" || r.Type == "TLSA" {
+			parsed := recordFromContent(r.Type, r.Content)
+			parsed.ID, parsed.Name, parsed.TTL = record.ID, record.Name, record.TTL
+			record = parsed
+		}
 		records = append(records, record)
 	}
 
 	return records, nil
 }
 
+// setNjallaRecordParams adds the type-specific fields of a record
+func setNjallaRecordParams(params map[string]interface{}, record Record) {
+	switch record.Type {
+	case "MX":
+		if record.Priority > 0 {
+			params["prio"] = record.Priority
+		}
+	case "SRV":
+		params["prio"] = record.Priority
+		params["weight"] = record.Weight
+		params["port"] = record.Port
+	case "CAA", "TLSA":
+		params["content"] = rrsetContent(record)
+	}
+}
+
 func (p *NjallaProvider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
 	name := record.Name
 	if name == "@" {
@@ -222,9 +249,7 @@ func (p *NjallaProvider) CreateRecord(ctx context.Context, domain string, record
 		params["ttl"] = 3600
 	}
 
-	if record.Type == "MX" && record.Priority > 0 {
-		params["prio"] = record.Priority
-	}
+	setNjallaRecordParams(params, record)
 
 	result, err := p.doRequest(ctx, "add-record", params)
 	if err != nil {
@@ -264,9 +289,7 @@ func (p *NjallaProvider) UpdateRecord(ctx context.Context, domain string, record
 		"ttl":     record.TTL,
 	}
 
-	if record.Type == "MX" && record.Priority > 0 {
-		params["prio"] = record.Priority
-	}
+	setNjallaRecordParams(params, record)
 
 	_, err := p.doRequest(ctx, "edit-record", params)
 	if err != nil {
@@ -0,0 +1,420 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PowerDNSProvider implements the Provider interface for PowerDNS Authoritative (HTTP API)
+type PowerDNSProvider struct {
+	baseURL string // e.g. http://pdns.internal:8081/api/v1/servers/localhost
+	apiKey  string
+	client  *http.Client
+}
+
+// NewPowerDNSProvider creates a new PowerDNS provider
+// apiURL is the API address ("http://host:8081", optionally ending in /api/v1/servers/<id>),
+// apiKey is the X-API-Key value
+func NewPowerDNSProvider(apiURL, apiKey string) *PowerDNSProvider {
+	baseURL := strings.TrimSuffix(apiURL, "/")
+	if !strings.Contains(baseURL, "/servers/") {
+		baseURL = strings.TrimSuffix(baseURL, "/api/v1") + "/api/v1/servers/localhost"
+	}
+
+	return &PowerDNSProvider{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (p *PowerDNSProvider) Name() string {
+	return "powerdns"
+}
+
+type pdnsZone struct {
+	ID          string      `json:"id,omitempty"`
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind,omitempty"`
+	Nameservers []string    `json:"nameservers,omitempty"`
+	RRsets      []pdnsRRset `json:"rrsets,omitempty"`
+}
+
+type pdnsRRset struct {
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`
+	TTL        int          `json:"ttl,omitempty"`
+	ChangeType string       `json:"changetype,omitempty"` // REPLACE, DELETE
+	Records    []pdnsRecord `json:"records"`
+}
+
+type pdnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type pdnsError struct {
+	Error string `json:"error"`
+}
+
+func (p *PowerDNSProvider) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewBuffer(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-API-Key", p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var errResp pdnsError
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("PowerDNS error [%d]: %s", resp.StatusCode, errResp.Error)
+		}
+		return nil, fmt.Errorf("PowerDNS error [%d]: %s", resp.StatusCode, string(respBody))
+	}
+
+	return respBody, nil
+}
+
+// zonePath returns the API path for a zone ("example.com" -> /zones/example.com.)
+func (p *PowerDNSProvider) zonePath(domain string) string {
+	return "/zones/" + url.PathEscape(strings.TrimSuffix(domain, ".")+".")
+}
+
+func (p *PowerDNSProvider) getZone(ctx context.Context, domain string) (*pdnsZone, error) {
+	respBody, err := p.doRequest(ctx, "GET", p.zonePath(domain), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var zone pdnsZone
+	if err := json.Unmarshal(respBody, &zone); err != nil {
+		return nil, fmt.Errorf("failed to parse zone: %w", err)
+	}
+	return &zone, nil
+}
+
+// getRRset returns the RRset for name/type, or nil if it doesn't exist
+func (p *PowerDNSProvider) getRRset(ctx context.Context, domain, name, recordType string) (*pdnsRRset, error) {
+	zone, err := p.getZone(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	fqdn := fqdnName(name, domain)
+	for _, rrset := range zone.RRsets {
+		if strings.EqualFold(rrset.Name, fqdn) && rrset.Type == recordType {
+			return &rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// patchRRsets sends a PATCH with the given RRset changes
+func (p *PowerDNSProvider) patchRRsets(ctx context.Context, domain string, rrsets []pdnsRRset) error {
+	_, err := p.doRequest(ctx, "PATCH", p.zonePath(domain), map[string]interface{}{
+		"rrsets": rrsets,
+	})
+	return err
+}
+
+func (p *PowerDNSProvider) ValidateCredentials(ctx context.Context) error {
+	_, err := p.doRequest(ctx, "GET", "", nil)
+	return err
+}
+
+// CreateZone creates a new native zone in PowerDNS
+func (p *PowerDNSProvider) CreateZone(ctx context.Context, domain string) error {
+	zone := pdnsZone{
+		Name:        strings.TrimSuffix(domain, ".") + ".",
+		Kind:        "Native",
+		Nameservers: []string{},
+	}
+
+	_, err := p.doRequest(ctx, "POST", "/zones", zone)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "[409]") {
+			return nil // Zone exists, that's fine
+		}
+		return fmt.Errorf("failed to create zone: %w", err)
+	}
+
+	return nil
+}
+
+// GetExpectedNameservers returns the apex NS RRset of the zone
+func (p *PowerDNSProvider) GetExpectedNameservers(ctx context.Context, domain string) ([]string, error) {
+	rrset, err := p.getRRset(ctx, domain, "@", "NS")
+	if err != nil {
+		return nil, err
+	}
+	if rrset == nil {
+		return nil, fmt.Errorf("no NS records configured for %s", domain)
+	}
+
+	nameservers := make([]string, 0, len(rrset.Records))
+	for _, r := range rrset.Records {
+		nameservers = append(nameservers, strings.TrimSuffix(r.Content, "."))
+	}
+	return nameservers, nil
+}
+
+// GetOrCreateZone creates the zone if it doesn't exist and returns nameservers
+func (p *PowerDNSProvider) GetOrCreateZone(ctx context.Context, domain string) ([]string, error) {
+	if _, err := p.getZone(ctx, domain); err != nil {
+		if err := p.CreateZone(ctx, domain); err != nil {
+			return nil, err
+		}
+	}
+
+	nameservers, err := p.GetExpectedNameservers(ctx, domain)
+	if err != nil {
+		// Zone was created without NS - the server's default-ns setting applies
+		return []string{}, nil
+	}
+	return nameservers, nil
+}
+
+func (p *PowerDNSProvider) ListRecords(ctx context.Context, domain string) ([]Record, error) {
+	zone, err := p.getZone(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, rrset := range zone.RRsets {
+		name := relativeName(rrset.Name, domain)
+
+		// Skip SOA and apex NS (managed by the server)
+		if rrset.Type == "SOA" || (rrset.Type == "NS" && name == "@") {
+			continue
+		}
+
+		for _, r := range rrset.Records {
+			if r.Disabled {
+				continue
+			}
+			record := recordFromContent(rrset.Type, r.Content)
+			record.ID = rrsetRecordID(name, rrset.Type, r.Content)
+			record.Name = name
+			record.TTL = rrset.TTL
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func (p *PowerDNSProvider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
+	content := rrsetContent(record)
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = 600
+	}
+
+	existing, err := p.getRRset(ctx, domain, record.Name, record.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the new value to the existing RRset (PowerDNS replaces whole RRsets)
+	records := []pdnsRecord{}
+	if existing != nil {
+		for _, r := range existing.Records {
+			if sameContent(record.Type, r.Content, content) {
+				continue
+			}
+			records = append(records, r)
+		}
+	}
+	records = append(records, pdnsRecord{Content: content})
+
+	err = p.patchRRsets(ctx, domain, []pdnsRRset{{
+		Name:       fqdnName(record.Name, domain),
+		Type:       record.Type,
+		TTL:        ttl,
+		ChangeType: "REPLACE",
+		Records:    records,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+
+	record.ID = rrsetRecordID(record.Name, record.Type, content)
+	record.TTL = ttl
+	return &record, nil
+}
+
+func (p *PowerDNSProvider) UpdateRecord(ctx context.Context, domain string, recordID string, record Record) (*Record, error) {
+	oldName, oldType, oldContent, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	// If name or type changed, we need to delete old and create new
+	if oldName != record.Name || oldType != record.Type {
+		if err := p.DeleteRecord(ctx, domain, recordID); err != nil {
+			return nil, fmt.Errorf("failed to delete old record: %w", err)
+		}
+		return p.CreateRecord(ctx, domain, record)
+	}
+
+	existing, err := p.getRRset(ctx, domain, oldName, oldType)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return p.CreateRecord(ctx, domain, record)
+	}
+
+	content := rrsetContent(record)
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = existing.TTL
+	}
+
+	// Swap the old value for the new one, keeping the rest of the RRset
+	records := []pdnsRecord{{Content: content}}
+	for _, r := range existing.Records {
+		if sameContent(oldType, r.Content, oldContent) || sameContent(oldType, r.Content, content) {
+			continue
+		}
+		records = append(records, r)
+	}
+
+	err = p.patchRRsets(ctx, domain, []pdnsRRset{{
+		Name:       existing.Name,
+		Type:       existing.Type,
+		TTL:        ttl,
+		ChangeType: "REPLACE",
+		Records:    records,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update record: %w", err)
+	}
+
+	record.ID = rrsetRecordID(record.Name, record.Type, content)
+	record.TTL = ttl
+	return &record, nil
+}
+
+func (p *PowerDNSProvider) DeleteRecord(ctx context.Context, domain string, recordID string) error {
+	name, recordType, content, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := p.getRRset(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil // Nothing to delete
+	}
+
+	var remaining []pdnsRecord
+	for _, r := range existing.Records {
+		if !sameContent(recordType, r.Content, content) {
+			remaining = append(remaining, r)
+		}
+	}
+
+	change := pdnsRRset{
+		Name:       existing.Name,
+		Type:       existing.Type,
+		TTL:        existing.TTL,
+		ChangeType: "REPLACE",
+		Records:    remaining,
+	}
+	if len(remaining) == 0 {
+		change.ChangeType = "DELETE"
+		change.Records = []pdnsRecord{}
+	}
+
+	return p.patchRRsets(ctx, domain, []pdnsRRset{change})
+}
+
+// ApplyChanges applies the changeset in a single zone PATCH, which PowerDNS
+// commits in one transaction
+func (p *PowerDNSProvider) ApplyChanges(ctx context.Context, domain string, changes []Change) ([]ChangeResult, error) {
+	zone, err := p.getZone(ctx, domain)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	current := make(map[rrsetKey]*rrsetState)
+	for _, rrset := range zone.RRsets {
+		state := &rrsetState{TTL: rrset.TTL}
+		for _, r := range rrset.Records {
+			state.Contents = append(state.Contents, r.Content)
+		}
+		current[rrsetKey{Name: relativeName(rrset.Name, domain), Type: rrset.Type}] = state
+	}
+
+	plan, err := planRRsets(current, changes, resolveRRsetRecordID)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	rrsets := make([]pdnsRRset, 0, len(plan.Touched))
+	for _, key := range plan.Touched {
+		state := plan.Sets[key]
+		if state.TTL == 0 {
+			state.TTL = 600
+		}
+		rrset := pdnsRRset{
+			Name:       fqdnName(key.Name, domain),
+			Type:       key.Type,
+			TTL:        state.TTL,
+			ChangeType: "REPLACE",
+			Records:    []pdnsRecord{},
+		}
+		if len(state.Contents) == 0 {
+			rrset.ChangeType = "DELETE"
+		}
+		for _, content := range state.Contents {
+			rrset.Records = append(rrset.Records, pdnsRecord{Content: content})
+		}
+		rrsets = append(rrsets, rrset)
+	}
+
+	if err := p.patchRRsets(ctx, domain, rrsets); err != nil {
+		return batchResults(changes, nil, fmt.Errorf("failed to apply changes: %w", err))
+	}
+
+	plan.assignIDs(func(key rrsetKey, content string) string {
+		return rrsetRecordID(key.Name, key.Type, content)
+	})
+	return batchResults(changes, plan.Records, nil)
+}
@@ -0,0 +1,293 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakePowerDNS serves one zone over the PowerDNS HTTP API and applies PATCHes to it
+type fakePowerDNS struct {
+	mu      sync.Mutex
+	zone    pdnsZone
+	patches [][]pdnsRRset
+}
+
+func newFakePowerDNS(t *testing.T, rrsets ...pdnsRRset) (*fakePowerDNS, *PowerDNSProvider) {
+	f := &fakePowerDNS{zone: pdnsZone{ID: "example.com.", Name: "example.com.", Kind: "Native", RRsets: rrsets}}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, NewPowerDNSProvider(srv.URL, "secret")
+}
+
+func (f *fakePowerDNS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(pdnsError{Error: "Unauthorized"})
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/v1/servers/localhost":
+		json.NewEncoder(w).Encode(map[string]string{"id": "localhost"})
+	case r.URL.Path != "/api/v1/servers/localhost/zones/example.com.":
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(pdnsError{Error: "Could not find domain"})
+	case r.Method == "GET":
+		json.NewEncoder(w).Encode(f.zone)
+	case r.Method == "PATCH":
+		var body struct {
+			RRsets []pdnsRRset `json:"rrsets"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.patches = append(f.patches, body.RRsets)
+		for _, change := range body.RRsets {
+			kept := f.zone.RRsets[:0]
+			for _, rrset := range f.zone.RRsets {
+				if rrset.Name != change.Name || rrset.Type != change.Type {
+					kept = append(kept, rrset)
+				}
+			}
+			f.zone.RRsets = kept
+			if change.ChangeType == "REPLACE" {
+				change.ChangeType = ""
+				f.zone.RRsets = append(f.zone.RRsets, change)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// rrset returns the contents of an RRset in the zone, nil if it doesn't exist
+func (f *fakePowerDNS) rrset(name, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rrset := range f.zone.RRsets {
+		if rrset.Name == name && rrset.Type == recordType {
+			contents := []string{}
+			for _, r := range rrset.Records {
+				contents = append(contents, r.Content)
+			}
+			return contents
+		}
+	}
+	return nil
+}
+
+func pdnsSet(name, recordType string, ttl int, contents ...string) pdnsRRset {
+	rrset := pdnsRRset{Name: name, Type: recordType, TTL: ttl}
+	for _, c := range contents {
+		rrset.Records = append(rrset.Records, pdnsRecord{Content: c})
+	}
+	return rrset
+}
+
+func TestNewPowerDNSProviderBaseURL(t *testing.T) {
+	tests := []struct {
+		apiURL string
+		want   string
+	}{
+		{"http://pdns:8081", "http://pdns:8081/api/v1/servers/localhost"},
+		{"http://pdns:8081/", "http://pdns:8081/api/v1/servers/localhost"},
+		{"http://pdns:8081/api/v1", "http://pdns:8081/api/v1/servers/localhost"},
+		{"http://pdns:8081/api/v1/servers/ns1/", "http://pdns:8081/api/v1/servers/ns1"},
+	}
+	for _, tt := range tests {
+		if got := NewPowerDNSProvider(tt.apiURL, "key").baseURL; got != tt.want {
+			t.Errorf("NewPowerDNSProvider(%q).baseURL = %q, want %q", tt.apiURL, got, tt.want)
+		}
+	}
+}
+
+func TestPowerDNSListRecords(t *testing.T) {
+	_, p := newFakePowerDNS(t,
+		pdnsSet("example.com.", "SOA", 3600, "ns1.example.com. hostmaster.example.com. 1 10800 3600 604800 3600"),
+		pdnsSet("example.com.", "NS", 3600, "ns1.example.com."),
+		pdnsSet("example.com.", "MX", 300, "10 mail.example.com."),
+		pdnsSet("www.example.com.", "A", 60, "192.0.2.1", "192.0.2.2"),
+		pdnsSet("_sip._tcp.example.com.", "SRV", 300, "10 5 5060 sip.example.com."),
+		pdnsSet("example.com.", "CAA", 300, `0 issue "letsencrypt.org"`),
+		pdnsSet("txt.example.com.", "TXT", 300, `"v=spf1 " "-all"`),
+		pdnsSet("_443._tcp.example.com.", "TLSA", 300, "3 1 1 ABCDEF"),
+		pdnsSet("sub.example.com.", "NS", 300, "ns.other.net."),
+		pdnsRRset{Name: "off.example.com.", Type: "A", TTL: 60, Records: []pdnsRecord{{Content: "192.0.2.9", Disabled: true}}},
+	)
+
+	records, err := p.ListRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Record{
+		{ID: "@:MX:10 mail.example.com.", Name: "@", Type: "MX", Value: "mail.example.com", TTL: 300, Priority: 10},
+		{ID: "www:A:192.0.2.1", Name: "www", Type: "A", Value: "192.0.2.1", TTL: 60},
+		{ID: "www:A:192.0.2.2", Name: "www", Type: "A", Value: "192.0.2.2", TTL: 60},
+		{ID: "_sip._tcp:SRV:10 5 5060 sip.example.com.", Name: "_sip._tcp", Type: "SRV", Value: "sip.example.com", TTL: 300, Priority: 10, Weight: 5, Port: 5060},
+		{ID: `@:CAA:0 issue "letsencrypt.org"`, Name: "@", Type: "CAA", Value: "letsencrypt.org", TTL: 300, Tag: "issue"},
+		{ID: `txt:TXT:"v=spf1 " "-all"`, Name: "txt", Type: "TXT", Value: "v=spf1 -all", TTL: 300},
+		{ID: "_443._tcp:TLSA:3 1 1 ABCDEF", Name: "_443._tcp", Type: "TLSA", Value: "abcdef", TTL: 300, Usage: 3, Selector: 1, MatchingType: 1},
+		{ID: "sub:NS:ns.other.net.", Name: "sub", Type: "NS", Value: "ns.other.net", TTL: 300},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("ListRecords:\ngot  %+v\nwant %+v", records, want)
+	}
+}
+
+func TestPowerDNSCreateRecordMergesRRset(t *testing.T) {
+	f, p := newFakePowerDNS(t, pdnsSet("www.example.com.", "A", 60, "192.0.2.1"))
+
+	record, err := p.CreateRecord(context.Background(), "example.com", Record{Name: "www", Type: "A", Value: "192.0.2.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "www:A:192.0.2.2" || record.TTL != 600 {
+		t.Errorf("record = %+v", record)
+	}
+	if got, want := f.rrset("www.example.com.", "A"), []string{"192.0.2.1", "192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("www A = %v, want %v", got, want)
+	}
+
+	// Creating a value that exists doesn't duplicate it
+	if _, err := p.CreateRecord(context.Background(), "example.com", Record{Name: "www", Type: "A", Value: "192.0.2.1", TTL: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.rrset("www.example.com.", "A"), []string{"192.0.2.2", "192.0.2.1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("www A = %v, want %v", got, want)
+	}
+}
+
+func TestPowerDNSUpdateRecord(t *testing.T) {
+	f, p := newFakePowerDNS(t,
+		pdnsSet("example.com.", "MX", 300, "10 mail1.example.com.", "20 mail2.example.com."),
+		pdnsSet("old.example.com.", "CNAME", 300, "target.example.com."),
+	)
+	ctx := context.Background()
+
+	record, err := p.UpdateRecord(ctx, "example.com", "@:MX:20 mail2.example.com.", Record{Name: "@", Type: "MX", Value: "mail3.example.com", Priority: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "@:MX:30 mail3.example.com." || record.TTL != 300 {
+		t.Errorf("record = %+v", record)
+	}
+	if got, want := f.rrset("example.com.", "MX"), []string{"30 mail3.example.com.", "10 mail1.example.com."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MX = %v, want %v", got, want)
+	}
+
+	// A rename deletes the old RRset and creates the new one
+	if _, err := p.UpdateRecord(ctx, "example.com", "old:CNAME:target.example.com.", Record{Name: "new", Type: "CNAME", Value: "target.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.rrset("old.example.com.", "CNAME"); got != nil {
+		t.Errorf("old CNAME = %v, want deleted", got)
+	}
+	if got, want := f.rrset("new.example.com.", "CNAME"), []string{"target.example.com."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("new CNAME = %v, want %v", got, want)
+	}
+}
+
+func TestPowerDNSDeleteRecord(t *testing.T) {
+	f, p := newFakePowerDNS(t, pdnsSet("txt.example.com.", "TXT", 300, `"one"`, `"two"`))
+	ctx := context.Background()
+
+	// Content matches regardless of quoting
+	if err := p.DeleteRecord(ctx, "example.com", "txt:TXT:one"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.rrset("txt.example.com.", "TXT"), []string{`"two"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TXT = %v, want %v", got, want)
+	}
+
+	if err := p.DeleteRecord(ctx, "example.com", `txt:TXT:"two"`); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.rrset("txt.example.com.", "TXT"); got != nil {
+		t.Errorf("TXT = %v, want deleted", got)
+	}
+	if last := f.patches[len(f.patches)-1]; last[0].ChangeType != "DELETE" {
+		t.Errorf("last value removed with %s, want DELETE", last[0].ChangeType)
+	}
+
+	// Deleting from a missing RRset is a no-op
+	if err := p.DeleteRecord(ctx, "example.com", "nope:A:192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.patches) != 2 {
+		t.Errorf("%d PATCHes, want 2", len(f.patches))
+	}
+}
+
+func TestPowerDNSApplyChanges(t *testing.T) {
+	f, p := newFakePowerDNS(t,
+		pdnsSet("www.example.com.", "A", 60, "192.0.2.1", "192.0.2.2"),
+		pdnsSet("gone.example.com.", "A", 60, "192.0.2.5"),
+	)
+
+	changes := []Change{
+		{Action: ChangeUpdate, RecordID: "www:A:192.0.2.1", Record: Record{Name: "www", Type: "A", Value: "192.0.2.3"}},
+		{Action: ChangeDelete, RecordID: "gone:A:192.0.2.5"},
+		{Action: ChangeCreate, Record: Record{Name: "api", Type: "AAAA", Value: "2001:db8::1", TTL: 120}},
+	}
+	results, err := p.ApplyChanges(context.Background(), "example.com", changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.patches) != 1 {
+		t.Fatalf("%d PATCHes, want the changeset in one", len(f.patches))
+	}
+	for i, r := range results {
+		if r.Status != ChangeStatusApplied {
+			t.Errorf("change %d: status %s", i, r.Status)
+		}
+	}
+	if results[0].Record.ID != "www:A:192.0.2.3" || results[0].Record.TTL != 60 {
+		t.Errorf("updated record = %+v", results[0].Record)
+	}
+	if results[2].Record.ID != "api:AAAA:2001:db8::1" {
+		t.Errorf("created record = %+v", results[2].Record)
+	}
+
+	if got, want := f.rrset("www.example.com.", "A"), []string{"192.0.2.2", "192.0.2.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("www A = %v, want %v", got, want)
+	}
+	if got := f.rrset("gone.example.com.", "A"); got != nil {
+		t.Errorf("gone A = %v, want deleted", got)
+	}
+	if got, want := f.rrset("api.example.com.", "AAAA"), []string{"2001:db8::1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("api AAAA = %v, want %v", got, want)
+	}
+}
+
+func TestPowerDNSErrors(t *testing.T) {
+	_, p := newFakePowerDNS(t)
+	ctx := context.Background()
+
+	if err := p.ValidateCredentials(ctx); err != nil {
+		t.Errorf("ValidateCredentials: %v", err)
+	}
+
+	p.apiKey = "wrong"
+	err := p.ValidateCredentials(ctx)
+	if err == nil || !strings.Contains(err.Error(), "[401]") || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("ValidateCredentials with a wrong key: %v", err)
+	}
+
+	p.apiKey = "secret"
+	if _, err := p.ListRecords(ctx, "other.com"); err == nil || !strings.Contains(err.Error(), "Could not find domain") {
+		t.Errorf("ListRecords of a missing zone: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// NameserverResult is what one authoritative server answered
+type NameserverResult struct {
+	Nameserver string   `json:"nameserver"` // NS hostname
+	Address    string   `json:"address"`    // IP the query was sent to
+	Serial     uint32   `json:"serial"`     // SOA serial
+	Answers    []string `json:"answers"`    // Values returned for the queried record
+	Matched    bool     `json:"matched"`    // Answers contain the expected value
+	RTTMs      int64    `json:"rtt_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// PropagationResult reports whether a record is served by every authoritative server
+type PropagationResult struct {
+	Zone          string             `json:"zone"`
+	Name          string             `json:"name"` // Subdomain: "www", "@", "*"
+	Type          string             `json:"type"`
+	Expected      string             `json:"expected,omitempty"`
+	Servers       []NameserverResult `json:"servers"`
+	SerialsInSync bool               `json:"serials_in_sync"`
+	Propagated    bool               `json:"propagated"` // Every server answered with the expected value
+	CheckedAt     time.Time          `json:"checked_at"`
+}
+
+// CheckPropagation queries every authoritative nameserver of zone directly (no
+// recursion, bypassing resolver caches) for the SOA serial and the given record.
+// When expected is empty, Propagated reports that all servers gave the same answer.
+func CheckPropagation(ctx context.Context, zone, name, recordType, expected string) *PropagationResult {
+	result := &PropagationResult{
+		Zone:      zone,
+		Name:      name,
+		Type:      strings.ToUpper(recordType),
+		Expected:  expected,
+		Servers:   []NameserverResult{},
+		CheckedAt: time.Now(),
+	}
+
+	qtype, ok := mdns.StringToType[result.Type]
+	if !ok {
+		result.Servers = append(result.Servers, NameserverResult{Error: "unsupported record type: " + recordType})
+		return result
+	}
+
+	servers, err := authoritativeServers(zone)
+	if err != nil {
+		result.Servers = append(result.Servers, NameserverResult{Error: err.Error()})
+		return result
+	}
+
+	qname := mdns.Fqdn(zone)
+	if name != "" && name != "@" {
+		qname = mdns.Fqdn(name + "." + zone)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]NameserverResult, len(servers))
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server NameserverResult) {
+			defer wg.Done()
+			results[i] = queryNameserver(ctx, server, mdns.Fqdn(zone), qname, qtype, expected)
+		}(i, server)
+	}
+	wg.Wait()
+	result.Servers = results
+
+	result.SerialsInSync = true
+	result.Propagated = true
+	answered := 0
+	var firstSerial uint32
+	var firstAnswer string
+	for _, s := range results {
+		if s.Error != "" {
+			result.SerialsInSync = false
+			result.Propagated = false
+			continue
+		}
+		answer := strings.Join(s.Answers, ",")
+		if answered == 0 {
+			firstSerial, firstAnswer = s.Serial, answer
+		}
+		answered++
+		if s.Serial != firstSerial {
+			result.SerialsInSync = false
+		}
+		if (expected != "" && !s.Matched) || (expected == "" && answer != firstAnswer) {
+			result.Propagated = false
+		}
+	}
+	if answered == 0 {
+		result.SerialsInSync = false
+		result.Propagated = false
+	}
+
+	return result
+}
+
+// authoritativeServers resolves every address of every NS of the zone
+func authoritativeServers(zone string) ([]NameserverResult, error) {
+	nsRecords, err := net.LookupNS(zone)
+	if err != nil {
+		return nil, fmt.Errorf("NS lookup failed: %w", err)
+	}
+
+	var servers []NameserverResult
+	for _, ns := range nsRecords {
+		host := strings.TrimSuffix(ns.Host, ".")
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			servers = append(servers, NameserverResult{Nameserver: host, Error: "cannot resolve nameserver: " + err.Error()})
+			continue
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			servers = append(servers, NameserverResult{Nameserver: host, Address: addr})
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers found for %s", zone)
+	}
+	return servers, nil
+}
+
+// queryNameserver asks one server for the zone SOA and the record
+func queryNameserver(ctx context.Context, server NameserverResult, zone, qname string, qtype uint16, expected string) NameserverResult {
+	if server.Error != "" {
+		return server
+	}
+	server.Answers = []string{}
+	addr := net.JoinHostPort(server.Address, "53")
+
+	soa, rtt, err := exchangeAuthoritative(ctx, addr, zone, mdns.TypeSOA)
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	server.RTTMs = rtt.Milliseconds()
+	for _, rr := range soa.Answer {
+		if s, ok := rr.(*mdns.SOA); ok {
+			server.Serial = s.Serial
+		}
+	}
+
+	resp, _, err := exchangeAuthoritative(ctx, addr, qname, qtype)
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		value := strings.TrimPrefix(rr.String(), rr.Header().String())
+		server.Answers = append(server.Answers, value)
+		if expected != "" && sameValue(mdns.TypeToString[qtype], value, expected) {
+			server.Matched = true
+		}
+	}
+	sort.Strings(server.Answers)
+	return server
+}
+
+// exchangeAuthoritative sends a non-recursive query over UDP, retrying over TCP when truncated
+func exchangeAuthoritative(ctx context.Context, addr, qname string, qtype uint16) (*mdns.Msg, time.Duration, error) {
+	msg := new(mdns.Msg)
+	msg.SetQuestion(qname, qtype)
+	msg.RecursionDesired = false
+
+	client := &mdns.Client{Timeout: 5 * time.Second}
+	resp, rtt, err := client.ExchangeContext(ctx, msg, addr)
+	if err == nil && resp.Truncated {
+		tcp := &mdns.Client{Net: "tcp", Timeout: 5 * time.Second}
+		resp, rtt, err = tcp.ExchangeContext(ctx, msg, addr)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.Rcode != mdns.RcodeSuccess {
+		return nil, rtt, fmt.Errorf("server answered %s", mdns.RcodeToString[resp.Rcode])
+	}
+	if !resp.Authoritative {
+		return nil, rtt, fmt.Errorf("server is not authoritative for %s", qname)
+	}
+	return resp, rtt, nil
+}
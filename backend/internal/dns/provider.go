@@ -2,6 +2,7 @@ package dns
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strings"
@@ -11,13 +12,92 @@ import (
 // Record represents a DNS record (provider-agnostic)
 type Record struct {
 	ID        string    `json:"id"`       // Provider's record ID
-	Name      string    `json:"name"`     // Subdomain: "www", "@", "*"
-	Type      string    `json:"type"`     // A, AAAA, CNAME, TXT, MX
-	Value     string    `json:"value"`    // Target value
+	Name      string    `json:"name"`     // Subdomain: "www", "@", "*", "_sip._tcp"
+	Type      string    `json:"type"`     // A, AAAA, CNAME, TXT, MX, NS, SRV, CAA, PTR, TLSA
+	Value     string    `json:"value"`    // Target value (SRV target, CAA value, TLSA certificate data)
 	TTL       int       `json:"ttl"`      // Seconds
-	Priority  int       `json:"priority"` // For MX
+	Priority  int       `json:"priority"` // For MX, SRV
 	Proxied   bool      `json:"proxied"`  // CF orange cloud
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// SRV
+	Weight int `json:"weight,omitempty"`
+	Port   int `json:"port,omitempty"`
+
+	// CAA
+	Flags int    `json:"flags,omitempty"`
+	Tag   string `json:"tag,omitempty"` // issue, issuewild, iodef
+
+	// TLSA
+	Usage        int `json:"usage,omitempty"`
+	Selector     int `json:"selector,omitempty"`
+	MatchingType int `json:"matching_type,omitempty"`
+}
+
+// SupportedRecordTypes lists the record types handled end-to-end
+var SupportedRecordTypes = []string{"A", "AAAA", "CNAME", "TXT", "MX", "NS", "SRV", "CAA", "PTR", "TLSA"}
+
+func isSupportedType(recordType string) bool {
+	for _, t := range SupportedRecordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRecord checks that a record is well-formed for its type
+func ValidateRecord(r Record) error {
+	if !isSupportedType(r.Type) {
+		return fmt.Errorf("unsupported record type: %s", r.Type)
+	}
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Value == "" {
+		return fmt.Errorf("value is required")
+	}
+
+	switch r.Type {
+	case "A":
+		if ip := net.ParseIP(r.Value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("A record value must be an IPv4 address")
+		}
+	case "AAAA":
+		if ip := net.ParseIP(r.Value); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("AAAA record value must be an IPv6 address")
+		}
+	case "MX":
+		if r.Priority < 0 || r.Priority > 65535 {
+			return fmt.Errorf("MX priority must be between 0 and 65535")
+		}
+	case "SRV":
+		if !strings.HasPrefix(r.Name, "_") {
+			return fmt.Errorf("SRV name must look like _service._proto")
+		}
+		if r.Priority < 0 || r.Priority > 65535 || r.Weight < 0 || r.Weight > 65535 {
+			return fmt.Errorf("SRV priority and weight must be between 0 and 65535")
+		}
+		if r.Port < 1 || r.Port > 65535 {
+			return fmt.Errorf("SRV port must be between 1 and 65535")
+		}
+	case "CAA":
+		if r.Flags != 0 && r.Flags != 128 {
+			return fmt.Errorf("CAA flags must be 0 or 128")
+		}
+		if r.Tag != "issue" && r.Tag != "issuewild" && r.Tag != "iodef" {
+			return fmt.Errorf("CAA tag must be issue, issuewild or iodef")
+		}
+	case "TLSA":
+		if r.Usage < 0 || r.Usage > 3 || r.Selector < 0 || r.Selector > 1 || r.MatchingType < 0 || r.MatchingType > 2 {
+			return fmt.Errorf("TLSA usage must be 0-3, selector 0-1 and matching type 0-2")
+		}
+		if _, err := hex.DecodeString(r.Value); err != nil {
+			return fmt.Errorf("TLSA certificate data must be hex encoded")
+		}
+	}
+
+	return nil
 }
 
 // NSStatus represents nameserver validation result
@@ -37,22 +117,30 @@ type SyncResult struct {
 	Deleted   []Record   `json:"deleted"`   // In remote, not in local
 	Conflicts []Conflict `json:"conflicts"` // Values differ
 	Errors    []string   `json:"errors"`
+
+	// Changes holds the per-change outcome when the result comes from ApplyToRemote
+	Changes []ChangeResult `json:"changes,omitempty"`
 }
 
 // Conflict when local and remote differ
 type Conflict struct {
-	RecordName  string `json:"record_name"`
-	RecordType  string `json:"record_type"`
-	LocalValue  string `json:"local_value"`
-	RemoteValue string `json:"remote_value"`
-	RemoteID    string `json:"remote_id"`
-	LocalID     string `json:"local_id"`
+	RecordName     string   `json:"record_name"`
+	RecordType     string   `json:"record_type"`
+	LocalValue     string   `json:"local_value"`
+	RemoteValue    string   `json:"remote_value"`
+	LocalTTL       int      `json:"local_ttl"`
+	RemoteTTL      int      `json:"remote_ttl"`
+	LocalPriority  int      `json:"local_priority"`
+	RemotePriority int      `json:"remote_priority"`
+	Differences    []string `json:"differences"` // value, ttl, priority, weight, flags
+	RemoteID       string   `json:"remote_id"`
+	LocalID        string   `json:"local_id"`
 }
 
 // Provider interface - implement for each DNS provider
 type Provider interface {
 	// Name returns provider identifier
-	Name() string // "dnspod", "cloudflare"
+	Name() string // "dnspod", "cloudflare", "powerdns", "route53"
 
 	// ValidateCredentials checks if API credentials work
 	ValidateCredentials(ctx context.Context) error
@@ -92,6 +180,12 @@ func NewProvider(provider string, apiID, apiToken string) (Provider, error) {
 		return NewNjallaProvider(apiToken), nil
 	case "cloudns":
 		return NewClouDNSProvider(apiID, apiToken), nil
+	case "powerdns":
+		return NewPowerDNSProvider(apiID, apiToken), nil
+	case "route53":
+		return NewRoute53Provider(apiID, apiToken), nil
+	case "rfc2136":
+		return NewRFC2136Provider(apiID, apiToken), nil
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", provider)
 	}
@@ -132,7 +226,10 @@ func CheckNameservers(domain string, expected []string) *NSStatus {
 		}
 	}
 
-	if matchCount >= len(expected) {
+	if len(expected) == 0 {
+		// Nothing to compare against, so the delegation can't be confirmed
+		result.Message = "No expected nameservers to check against"
+	} else if matchCount >= len(expected) {
 		result.Valid = true
 		result.Status = "valid"
 		result.Message = "Nameservers correctly configured"
@@ -0,0 +1,328 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// RFC2136Provider implements the Provider interface for self-hosted authoritative
+// servers (BIND, Knot, ...) using AXFR for reads and TSIG-signed dynamic UPDATE for writes
+type RFC2136Provider struct {
+	server    string // host:port of the primary
+	keyName   string // TSIG key name, dot-terminated
+	keySecret string // base64 TSIG secret
+	timeout   time.Duration
+}
+
+// NewRFC2136Provider creates a new RFC 2136 provider
+// apiID is "keyname@host[:port]", apiToken is the base64 TSIG secret (hmac-sha256)
+func NewRFC2136Provider(apiID, apiToken string) *RFC2136Provider {
+	keyName := ""
+	server := apiID
+	if idx := strings.LastIndex(apiID, "@"); idx >= 0 {
+		keyName = apiID[:idx]
+		server = apiID[idx+1:]
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	return &RFC2136Provider{
+		server:    server,
+		keyName:   mdns.Fqdn(keyName),
+		keySecret: apiToken,
+		timeout:   30 * time.Second,
+	}
+}
+
+func (p *RFC2136Provider) Name() string {
+	return "rfc2136"
+}
+
+func (p *RFC2136Provider) hasKey() bool {
+	return p.keyName != "." && p.keySecret != ""
+}
+
+// tsigSecrets returns the secret map expected by miekg/dns clients
+func (p *RFC2136Provider) tsigSecrets() map[string]string {
+	if !p.hasKey() {
+		return nil
+	}
+	return map[string]string{p.keyName: p.keySecret}
+}
+
+// exchange sends a message (signing it if a key is configured) over TCP
+func (p *RFC2136Provider) exchange(ctx context.Context, msg *mdns.Msg) (*mdns.Msg, error) {
+	if p.hasKey() {
+		msg.SetTsig(p.keyName, mdns.HmacSHA256, 300, time.Now().Unix())
+	}
+
+	client := &mdns.Client{
+		Net:        "tcp",
+		Timeout:    p.timeout,
+		TsigSecret: p.tsigSecrets(),
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, p.server)
+	if err != nil {
+		return nil, fmt.Errorf("RFC2136 exchange with %s failed: %w", p.server, err)
+	}
+	return resp, nil
+}
+
+// update sends a dynamic UPDATE message and checks the response code
+func (p *RFC2136Provider) update(ctx context.Context, msg *mdns.Msg) error {
+	resp, err := p.exchange(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != mdns.RcodeSuccess {
+		return fmt.Errorf("RFC2136 update rejected: %s", mdns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// query performs a (signed) query against the primary
+func (p *RFC2136Provider) query(ctx context.Context, name string, qtype uint16) (*mdns.Msg, error) {
+	msg := new(mdns.Msg)
+	msg.SetQuestion(mdns.Fqdn(name), qtype)
+	msg.RecursionDesired = false
+	return p.exchange(ctx, msg)
+}
+
+// newRR builds a resource record from our provider-agnostic record
+func (p *RFC2136Provider) newRR(domain string, record Record, content string) (mdns.RR, error) {
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = 600
+	}
+	rr, err := mdns.NewRR(fmt.Sprintf("%s %d IN %s %s", fqdnName(record.Name, domain), ttl, record.Type, content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s record: %w", record.Type, err)
+	}
+	return rr, nil
+}
+
+// rdata returns the presentation-format rdata of a resource record
+func rdata(rr mdns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func (p *RFC2136Provider) ValidateCredentials(ctx context.Context) error {
+	// A signed query fails at the transport level on a bad signature, or is answered
+	// NOTAUTH when the server doesn't know the key
+	resp, err := p.query(ctx, ".", mdns.TypeSOA)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode == mdns.RcodeNotAuth {
+		return fmt.Errorf("TSIG key %s rejected by %s", p.keyName, p.server)
+	}
+	return nil
+}
+
+// CreateZone verifies the zone exists - RFC 2136 cannot create zones,
+// they must be configured on the server
+func (p *RFC2136Provider) CreateZone(ctx context.Context, domain string) error {
+	resp, err := p.query(ctx, domain, mdns.TypeSOA)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != mdns.RcodeSuccess || !resp.Authoritative {
+		return fmt.Errorf("zone %s is not served by %s; add it to the server configuration first", domain, p.server)
+	}
+	return nil
+}
+
+func (p *RFC2136Provider) GetExpectedNameservers(ctx context.Context, domain string) ([]string, error) {
+	resp, err := p.query(ctx, domain, mdns.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+
+	var nameservers []string
+	for _, rr := range resp.Answer {
+		if ns, ok := rr.(*mdns.NS); ok {
+			nameservers = append(nameservers, strings.TrimSuffix(ns.Ns, "."))
+		}
+	}
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("no NS records found for %s", domain)
+	}
+	return nameservers, nil
+}
+
+// GetOrCreateZone checks the zone is served and returns its nameservers
+func (p *RFC2136Provider) GetOrCreateZone(ctx context.Context, domain string) ([]string, error) {
+	if err := p.CreateZone(ctx, domain); err != nil {
+		return nil, err
+	}
+	return p.GetExpectedNameservers(ctx, domain)
+}
+
+func (p *RFC2136Provider) ListRecords(ctx context.Context, domain string) ([]Record, error) {
+	msg := new(mdns.Msg)
+	msg.SetAxfr(mdns.Fqdn(domain))
+	if p.hasKey() {
+		msg.SetTsig(p.keyName, mdns.HmacSHA256, 300, time.Now().Unix())
+	}
+
+	transfer := &mdns.Transfer{
+		DialTimeout:  p.timeout,
+		ReadTimeout:  p.timeout,
+		WriteTimeout: p.timeout,
+		TsigSecret:   p.tsigSecrets(),
+	}
+
+	envelopes, err := transfer.In(msg, p.server)
+	if err != nil {
+		return nil, fmt.Errorf("AXFR of %s failed: %w", domain, err)
+	}
+
+	var records []Record
+	for {
+		var env *mdns.Envelope
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case e, ok := <-envelopes:
+			if !ok {
+				return records, nil
+			}
+			env = e
+		}
+
+		if env.Error != nil {
+			return nil, fmt.Errorf("AXFR of %s failed: %w", domain, env.Error)
+		}
+
+		for _, rr := range env.RR {
+			recordType := mdns.TypeToString[rr.Header().Rrtype]
+			name := relativeName(rr.Header().Name, domain)
+
+			// Skip SOA and apex NS (managed by the server), and DNSSEC material
+			if recordType == "SOA" || (recordType == "NS" && name == "@") {
+				continue
+			}
+			if recordType == "RRSIG" || recordType == "NSEC" || recordType == "NSEC3" || recordType == "NSEC3PARAM" {
+				continue
+			}
+
+			content := rdata(rr)
+			record := recordFromContent(recordType, content)
+			record.ID = rrsetRecordID(name, recordType, content)
+			record.Name = name
+			record.TTL = int(rr.Header().Ttl)
+			records = append(records, record)
+		}
+	}
+}
+
+func (p *RFC2136Provider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
+	rr, err := p.newRR(domain, record, rrsetContent(record))
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetUpdate(mdns.Fqdn(domain))
+	msg.Insert([]mdns.RR{rr})
+	if err := p.update(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+
+	record.ID = rrsetRecordID(record.Name, record.Type, rdata(rr))
+	record.TTL = int(rr.Header().Ttl)
+	return &record, nil
+}
+
+func (p *RFC2136Provider) UpdateRecord(ctx context.Context, domain string, recordID string, record Record) (*Record, error) {
+	oldName, oldType, oldContent, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldRR, err := p.newRR(domain, Record{Name: oldName, Type: oldType}, oldContent)
+	if err != nil {
+		return nil, err
+	}
+	newRR, err := p.newRR(domain, record, rrsetContent(record))
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove and insert in a single UPDATE so the change is atomic
+	msg := new(mdns.Msg)
+	msg.SetUpdate(mdns.Fqdn(domain))
+	msg.Remove([]mdns.RR{oldRR})
+	msg.Insert([]mdns.RR{newRR})
+	if err := p.update(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to update record: %w", err)
+	}
+
+	record.ID = rrsetRecordID(record.Name, record.Type, rdata(newRR))
+	record.TTL = int(newRR.Header().Ttl)
+	return &record, nil
+}
+
+func (p *RFC2136Provider) DeleteRecord(ctx context.Context, domain string, recordID string) error {
+	name, recordType, content, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return err
+	}
+
+	rr, err := p.newRR(domain, Record{Name: name, Type: recordType}, content)
+	if err != nil {
+		return err
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetUpdate(mdns.Fqdn(domain))
+	msg.Remove([]mdns.RR{rr})
+	return p.update(ctx, msg)
+}
+
+// ApplyChanges sends the whole changeset as a single UPDATE message,
+// which the server applies atomically
+func (p *RFC2136Provider) ApplyChanges(ctx context.Context, domain string, changes []Change) ([]ChangeResult, error) {
+	msg := new(mdns.Msg)
+	msg.SetUpdate(mdns.Fqdn(domain))
+
+	records := make([]*Record, len(changes))
+	for i, change := range changes {
+		if change.Action == ChangeUpdate || change.Action == ChangeDelete {
+			name, recordType, content, err := parseRRsetRecordID(change.RecordID)
+			if err != nil {
+				return batchResults(changes, nil, err)
+			}
+			oldRR, err := p.newRR(domain, Record{Name: name, Type: recordType}, content)
+			if err != nil {
+				return batchResults(changes, nil, err)
+			}
+			msg.Remove([]mdns.RR{oldRR})
+		}
+
+		if change.Action == ChangeCreate || change.Action == ChangeUpdate {
+			newRR, err := p.newRR(domain, change.Record, rrsetContent(change.Record))
+			if err != nil {
+				return batchResults(changes, nil, err)
+			}
+			msg.Insert([]mdns.RR{newRR})
+
+			record := change.Record
+			record.ID = rrsetRecordID(record.Name, record.Type, rdata(newRR))
+			record.TTL = int(newRR.Header().Ttl)
+			records[i] = &record
+		}
+	}
+
+	if err := p.update(ctx, msg); err != nil {
+		return batchResults(changes, nil, fmt.Errorf("failed to apply changes: %w", err))
+	}
+	return batchResults(changes, records, nil)
+}
@@ -0,0 +1,339 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+const (
+	testTSIGKey    = "update-key."
+	testTSIGSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0"
+)
+
+// fakeDNSServer is an authoritative server for example.com that answers queries,
+// AXFR and dynamic UPDATE. Transfers and updates must be signed with the test key.
+type fakeDNSServer struct {
+	mu      sync.Mutex
+	zone    []mdns.RR // The SOA first
+	updates int
+	addr    string
+}
+
+func newFakeDNSServer(t *testing.T, records ...string) *fakeDNSServer {
+	f := &fakeDNSServer{}
+	for _, s := range append([]string{
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 1209600 300",
+		"example.com. 3600 IN NS ns1.example.com.",
+		"example.com. 3600 IN NS ns2.example.com.",
+	}, records...) {
+		rr, err := mdns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.zone = append(f.zone, rr)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := &mdns.Server{
+		Listener:          l,
+		Handler:           f,
+		TsigSecret:        map[string]string{testTSIGKey: testTSIGSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default rejects UPDATE messages
+		MsgAcceptFunc: func(mdns.Header) mdns.MsgAcceptAction { return mdns.MsgAccept },
+	}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+
+	f.addr = l.Addr().String()
+	return f
+}
+
+func (f *fakeDNSServer) ServeDNS(w mdns.ResponseWriter, req *mdns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resp := new(mdns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+
+	signed := req.IsTsig() != nil
+	if signed && w.TsigStatus() != nil {
+		resp.Rcode = mdns.RcodeNotAuth
+		w.WriteMsg(resp)
+		return
+	}
+
+	q := req.Question[0]
+	switch {
+	case req.Opcode == mdns.OpcodeUpdate:
+		if !signed {
+			resp.Rcode = mdns.RcodeRefused
+		} else {
+			f.update(req.Ns)
+		}
+	case q.Qtype == mdns.TypeAXFR:
+		if !signed {
+			resp.Rcode = mdns.RcodeRefused
+		} else {
+			resp.Answer = append(append([]mdns.RR{}, f.zone...), f.zone[0])
+		}
+	case !strings.HasSuffix(strings.ToLower(q.Name), "example.com."):
+		resp.Authoritative = false
+		resp.Rcode = mdns.RcodeRefused
+	default:
+		for _, rr := range f.zone {
+			if strings.EqualFold(rr.Header().Name, q.Name) && rr.Header().Rrtype == q.Qtype {
+				resp.Answer = append(resp.Answer, rr)
+			}
+		}
+	}
+
+	if signed {
+		resp.SetTsig(testTSIGKey, mdns.HmacSHA256, 300, time.Now().Unix())
+	}
+	w.WriteMsg(resp)
+}
+
+// update applies the update section of an UPDATE message (RFC 2136 section 3.4.2)
+func (f *fakeDNSServer) update(section []mdns.RR) {
+	f.updates++
+	for _, rr := range section {
+		switch rr.Header().Class {
+		case mdns.ClassINET:
+			if f.find(rr) < 0 {
+				f.zone = append(f.zone, rr)
+			}
+		case mdns.ClassNONE:
+			rr = mdns.Copy(rr)
+			rr.Header().Class = mdns.ClassINET
+			if i := f.find(rr); i > 0 {
+				f.zone = append(f.zone[:i], f.zone[i+1:]...)
+			}
+		}
+	}
+	f.zone[0].(*mdns.SOA).Serial++
+}
+
+func (f *fakeDNSServer) find(rr mdns.RR) int {
+	for i, existing := range f.zone {
+		if mdns.IsDuplicate(existing, rr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// values returns the sorted rdata of an RRset in the zone
+func (f *fakeDNSServer) values(name, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := []string{}
+	for _, rr := range f.zone {
+		if rr.Header().Name == name && mdns.TypeToString[rr.Header().Rrtype] == recordType {
+			values = append(values, rdata(rr))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (f *fakeDNSServer) provider() *RFC2136Provider {
+	p := NewRFC2136Provider(strings.TrimSuffix(testTSIGKey, ".")+"@"+f.addr, testTSIGSecret)
+	p.timeout = 5 * time.Second
+	return p
+}
+
+func TestNewRFC2136Provider(t *testing.T) {
+	tests := []struct {
+		apiID   string
+		server  string
+		keyName string
+	}{
+		{"key@ns1.example.com", "ns1.example.com:53", "key."},
+		{"key.example.@192.0.2.1:5353", "192.0.2.1:5353", "key.example."},
+		{"key@2001:db8::1", "[2001:db8::1]:53", "key."},
+		{"key@[2001:db8::1]:5353", "[2001:db8::1]:5353", "key."},
+		{"ns1.example.com", "ns1.example.com:53", "."},
+	}
+	for _, tt := range tests {
+		p := NewRFC2136Provider(tt.apiID, "secret")
+		if p.server != tt.server || p.keyName != tt.keyName {
+			t.Errorf("NewRFC2136Provider(%q): server %q key %q, want %q %q", tt.apiID, p.server, p.keyName, tt.server, tt.keyName)
+		}
+	}
+
+	if NewRFC2136Provider("ns1.example.com", "").hasKey() {
+		t.Error("provider without a key name or secret signs its messages")
+	}
+}
+
+func TestRFC2136Zone(t *testing.T) {
+	f := newFakeDNSServer(t)
+	p := f.provider()
+	ctx := context.Background()
+
+	if err := p.ValidateCredentials(ctx); err != nil {
+		t.Errorf("ValidateCredentials: %v", err)
+	}
+	nameservers, err := p.GetOrCreateZone(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(nameservers)
+	if want := []string{"ns1.example.com", "ns2.example.com"}; !reflect.DeepEqual(nameservers, want) {
+		t.Errorf("nameservers = %v, want %v", nameservers, want)
+	}
+
+	if err := p.CreateZone(ctx, "other.com"); err == nil || !strings.Contains(err.Error(), "not served") {
+		t.Errorf("CreateZone of a zone the server doesn't serve: %v", err)
+	}
+}
+
+func TestRFC2136BadKey(t *testing.T) {
+	f := newFakeDNSServer(t)
+	ctx := context.Background()
+
+	p := f.provider()
+	p.keySecret = "d3Jvbmctc2VjcmV0LXdyb25nLXNlY3JldA=="
+	if err := p.ValidateCredentials(ctx); err == nil {
+		t.Error("ValidateCredentials with a wrong secret: expected an error")
+	}
+	if _, err := p.CreateRecord(ctx, "example.com", Record{Name: "www", Type: "A", Value: "192.0.2.1"}); err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Errorf("CreateRecord with a wrong secret: %v", err)
+	}
+
+	// Unsigned updates are refused
+	p = NewRFC2136Provider(f.addr, "")
+	if _, err := p.CreateRecord(ctx, "example.com", Record{Name: "www", Type: "A", Value: "192.0.2.1"}); err == nil || !strings.Contains(err.Error(), "REFUSED") {
+		t.Errorf("unsigned CreateRecord: %v", err)
+	}
+	if f.updates != 0 {
+		t.Errorf("%d updates applied, want none", f.updates)
+	}
+}
+
+func TestRFC2136ListRecords(t *testing.T) {
+	f := newFakeDNSServer(t,
+		"ns1.example.com. 3600 IN A 192.0.2.53",
+		"www.example.com. 60 IN A 192.0.2.1",
+		"www.example.com. 60 IN A 192.0.2.2",
+		"example.com. 300 IN MX 10 mail.example.com.",
+		`example.com. 300 IN TXT "v=spf1" "-all"`,
+		"_sip._tcp.example.com. 300 IN SRV 10 5 5060 sip.example.com.",
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+		"*.example.com. 60 IN CNAME www.example.com.",
+		"sub.example.com. 300 IN NS ns.other.net.",
+	)
+
+	records, err := f.provider().ListRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Record{
+		{ID: "ns1:A:192.0.2.53", Name: "ns1", Type: "A", Value: "192.0.2.53", TTL: 3600},
+		{ID: "www:A:192.0.2.1", Name: "www", Type: "A", Value: "192.0.2.1", TTL: 60},
+		{ID: "www:A:192.0.2.2", Name: "www", Type: "A", Value: "192.0.2.2", TTL: 60},
+		{ID: "@:MX:10 mail.example.com.", Name: "@", Type: "MX", Value: "mail.example.com", TTL: 300, Priority: 10},
+		{ID: `@:TXT:"v=spf1" "-all"`, Name: "@", Type: "TXT", Value: "v=spf1-all", TTL: 300},
+		{ID: "_sip._tcp:SRV:10 5 5060 sip.example.com.", Name: "_sip._tcp", Type: "SRV", Value: "sip.example.com", TTL: 300, Priority: 10, Weight: 5, Port: 5060},
+		{ID: `@:CAA:0 issue "letsencrypt.org"`, Name: "@", Type: "CAA", Value: "letsencrypt.org", TTL: 300, Tag: "issue"},
+		{ID: "*:CNAME:www.example.com.", Name: "*", Type: "CNAME", Value: "www.example.com", TTL: 60},
+		{ID: "sub:NS:ns.other.net.", Name: "sub", Type: "NS", Value: "ns.other.net", TTL: 300},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("ListRecords:\ngot  %+v\nwant %+v", records, want)
+	}
+}
+
+func TestRFC2136RecordChanges(t *testing.T) {
+	f := newFakeDNSServer(t, "www.example.com. 60 IN A 192.0.2.1")
+	p := f.provider()
+	ctx := context.Background()
+
+	record, err := p.CreateRecord(ctx, "example.com", Record{Name: "www", Type: "A", Value: "192.0.2.2", TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "www:A:192.0.2.2" {
+		t.Errorf("created ID = %q", record.ID)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.1", "192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after create: %v, want %v", got, want)
+	}
+
+	record, err = p.UpdateRecord(ctx, "example.com", record.ID, Record{Name: "www", Type: "A", Value: "192.0.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "www:A:192.0.2.3" || record.TTL != 600 {
+		t.Errorf("updated record = %+v", record)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.1", "192.0.2.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after update: %v, want %v", got, want)
+	}
+
+	if _, err := p.CreateRecord(ctx, "example.com", Record{Name: "@", Type: "MX", Value: "mail.example.com", Priority: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.values("example.com.", "MX"), []string{"10 mail.example.com."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MX = %v, want %v", got, want)
+	}
+
+	if err := p.DeleteRecord(ctx, "example.com", "www:A:192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after delete: %v, want %v", got, want)
+	}
+	if f.updates != 4 {
+		t.Errorf("%d updates, want 4", f.updates)
+	}
+}
+
+func TestRFC2136ApplyChanges(t *testing.T) {
+	f := newFakeDNSServer(t,
+		"www.example.com. 60 IN A 192.0.2.1",
+		"old.example.com. 300 IN CNAME www.example.com.",
+	)
+
+	changes := []Change{
+		{Action: ChangeUpdate, RecordID: "www:A:192.0.2.1", Record: Record{Name: "www", Type: "A", Value: "192.0.2.2", TTL: 60}},
+		{Action: ChangeDelete, RecordID: "old:CNAME:www.example.com."},
+		{Action: ChangeCreate, Record: Record{Name: "txt", Type: "TXT", Value: "hello world"}},
+	}
+	results, err := f.provider().ApplyChanges(context.Background(), "example.com", changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.updates != 1 {
+		t.Errorf("%d updates, want the changeset in one", f.updates)
+	}
+	if results[0].Record.ID != "www:A:192.0.2.2" || results[2].Record.ID != `txt:TXT:"hello world"` {
+		t.Errorf("record IDs = %s, %s", results[0].Record.ID, results[2].Record.ID)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("www A = %v, want %v", got, want)
+	}
+	if got := f.values("old.example.com.", "CNAME"); len(got) != 0 {
+		t.Errorf("old CNAME = %v, want deleted", got)
+	}
+	if got, want := f.values("txt.example.com.", "TXT"), []string{`"hello world"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("txt TXT = %v, want %v", got, want)
+	}
+}
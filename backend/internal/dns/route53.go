@@ -0,0 +1,650 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	route53DefaultEndpoint = "https://route53.amazonaws.com"
+	route53APIVersion      = "2013-04-01"
+	route53XMLNS           = "https://route53.amazonaws.com/doc/2013-04-01/"
+	route53Region          = "us-east-1" // Route53 is global, signed against us-east-1
+)
+
+// Route53Provider implements the Provider interface for AWS Route53
+// (and Route53-compatible APIs via a custom endpoint)
+type Route53Provider struct {
+	accessKeyID string
+	secretKey   string
+	endpoint    string
+	client      *http.Client
+}
+
+// NewRoute53Provider creates a new Route53 provider
+// apiID is the access key ID, optionally "AKID@https://endpoint" for Route53-compatible APIs,
+// apiToken is the secret access key
+func NewRoute53Provider(apiID, apiToken string) *Route53Provider {
+	endpoint := route53DefaultEndpoint
+	if idx := strings.Index(apiID, "@"); idx > 0 {
+		endpoint = strings.TrimSuffix(apiID[idx+1:], "/")
+		apiID = apiID[:idx]
+	}
+
+	return &Route53Provider{
+		accessKeyID: apiID,
+		secretKey:   apiToken,
+		endpoint:    endpoint,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (p *Route53Provider) Name() string {
+	return "route53"
+}
+
+// Route53 API structures
+type r53HostedZone struct {
+	ID   string `xml:"Id"`
+	Name string `xml:"Name"`
+}
+
+type r53ListHostedZonesByNameResponse struct {
+	HostedZones []r53HostedZone `xml:"HostedZones>HostedZone"`
+}
+
+type r53GetHostedZoneResponse struct {
+	HostedZone  r53HostedZone `xml:"HostedZone"`
+	NameServers []string      `xml:"DelegationSet>NameServers>NameServer"`
+}
+
+type r53CreateHostedZoneRequest struct {
+	XMLName         xml.Name `xml:"CreateHostedZoneRequest"`
+	XMLNS           string   `xml:"xmlns,attr"`
+	Name            string   `xml:"Name"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+type r53ResourceRecordSet struct {
+	Name            string              `xml:"Name"`
+	Type            string              `xml:"Type"`
+	TTL             int                 `xml:"TTL,omitempty"`
+	ResourceRecords []r53ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+}
+
+// r53ResourceRecord is one value of an RRset; each goes in its own <ResourceRecord>
+type r53ResourceRecord struct {
+	Value string `xml:"Value"`
+}
+
+// values returns the rdata of every record in the set
+func (s r53ResourceRecordSet) values() []string {
+	values := make([]string, len(s.ResourceRecords))
+	for i, r := range s.ResourceRecords {
+		values[i] = r.Value
+	}
+	return values
+}
+
+// r53Records wraps rdata values as resource records
+func r53Records(values []string) []r53ResourceRecord {
+	records := make([]r53ResourceRecord, len(values))
+	for i, v := range values {
+		records[i] = r53ResourceRecord{Value: v}
+	}
+	return records
+}
+
+type r53ListResourceRecordSetsResponse struct {
+	ResourceRecordSets []r53ResourceRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated        bool                   `xml:"IsTruncated"`
+	NextRecordName     string                 `xml:"NextRecordName"`
+	NextRecordType     string                 `xml:"NextRecordType"`
+}
+
+type r53Change struct {
+	Action            string               `xml:"Action"` // CREATE, DELETE, UPSERT
+	ResourceRecordSet r53ResourceRecordSet `xml:"ResourceRecordSet"`
+}
+
+type r53ChangeResourceRecordSetsRequest struct {
+	XMLName xml.Name    `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Changes []r53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type r53ErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func (p *Route53Provider) doRequest(ctx context.Context, method, path string, query url.Values, body interface{}) ([]byte, error) {
+	var payload []byte
+	if body != nil {
+		b, err := xml.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = append([]byte(xml.Header), b...)
+	}
+
+	reqURL := p.endpoint + "/" + route53APIVersion + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/xml")
+	}
+	p.sign(req, payload, time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var errResp r53ErrorResponse
+		if xml.Unmarshal(respBody, &errResp) == nil && errResp.Code != "" {
+			return nil, fmt.Errorf("Route53 error [%d] %s: %s", resp.StatusCode, errResp.Code, errResp.Message)
+		}
+		return nil, fmt.Errorf("Route53 error [%d]: %s", resp.StatusCode, string(respBody))
+	}
+
+	return respBody, nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request
+func (p *Route53Provider) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers: host plus every x-amz-* / content-type header, sorted
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	// url.Values.Encode sorts by key, as SigV4 requires
+	canonicalQuery := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + route53Region + "/route53/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), dateStamp)
+	key = hmacSHA256(key, route53Region)
+	key = hmacSHA256(key, "route53")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func (p *Route53Provider) ValidateCredentials(ctx context.Context) error {
+	_, err := p.doRequest(ctx, "GET", "/hostedzone", url.Values{"maxitems": {"1"}}, nil)
+	return err
+}
+
+// getZoneID looks up the hosted zone ID ("Z123...") for a domain
+func (p *Route53Provider) getZoneID(ctx context.Context, domain string) (string, error) {
+	fqdn := strings.TrimSuffix(domain, ".") + "."
+	respBody, err := p.doRequest(ctx, "GET", "/hostedzonesbyname", url.Values{
+		"dnsname":  {fqdn},
+		"maxitems": {"1"},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result r53ListHostedZonesByNameResponse
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse hosted zones: %w", err)
+	}
+
+	for _, zone := range result.HostedZones {
+		if strings.EqualFold(zone.Name, fqdn) {
+			return strings.TrimPrefix(zone.ID, "/hostedzone/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("zone not found for %s", domain)
+}
+
+// CreateZone creates a new public hosted zone in Route53
+func (p *Route53Provider) CreateZone(ctx context.Context, domain string) error {
+	body := r53CreateHostedZoneRequest{
+		XMLNS:           route53XMLNS,
+		Name:            strings.TrimSuffix(domain, ".") + ".",
+		CallerReference: fmt.Sprintf("configuratix-%s-%d", domain, time.Now().UnixNano()),
+	}
+
+	_, err := p.doRequest(ctx, "POST", "/hostedzone", nil, body)
+	if err != nil {
+		if strings.Contains(err.Error(), "HostedZoneAlreadyExists") {
+			return nil // Zone exists, that's fine
+		}
+		return fmt.Errorf("failed to create zone: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Route53Provider) GetExpectedNameservers(ctx context.Context, domain string) ([]string, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := p.doRequest(ctx, "GET", "/hostedzone/"+zoneID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result r53GetHostedZoneResponse
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse hosted zone: %w", err)
+	}
+
+	nameservers := make([]string, len(result.NameServers))
+	for i, ns := range result.NameServers {
+		nameservers[i] = strings.TrimSuffix(ns, ".")
+	}
+	return nameservers, nil
+}
+
+// GetOrCreateZone creates the hosted zone if it doesn't exist and returns nameservers
+func (p *Route53Provider) GetOrCreateZone(ctx context.Context, domain string) ([]string, error) {
+	if _, err := p.getZoneID(ctx, domain); err != nil {
+		if err := p.CreateZone(ctx, domain); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.GetExpectedNameservers(ctx, domain)
+}
+
+// listRRsets fetches every RRset in the zone, following pagination
+func (p *Route53Provider) listRRsets(ctx context.Context, zoneID string) ([]r53ResourceRecordSet, error) {
+	var rrsets []r53ResourceRecordSet
+	query := url.Values{}
+
+	for {
+		respBody, err := p.doRequest(ctx, "GET", "/hostedzone/"+zoneID+"/rrset", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page r53ListResourceRecordSetsResponse
+		if err := xml.Unmarshal(respBody, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse record sets: %w", err)
+		}
+		rrsets = append(rrsets, page.ResourceRecordSets...)
+
+		if !page.IsTruncated {
+			break
+		}
+		query = url.Values{
+			"name": {page.NextRecordName},
+			"type": {page.NextRecordType},
+		}
+	}
+
+	return rrsets, nil
+}
+
+// getRRset returns the RRset for name/type, or nil if it doesn't exist
+func (p *Route53Provider) getRRset(ctx context.Context, zoneID, domain, name, recordType string) (*r53ResourceRecordSet, error) {
+	fqdn := fqdnName(name, domain)
+	respBody, err := p.doRequest(ctx, "GET", "/hostedzone/"+zoneID+"/rrset", url.Values{
+		"name":     {fqdn},
+		"type":     {recordType},
+		"maxitems": {"1"},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var page r53ListResourceRecordSetsResponse
+	if err := xml.Unmarshal(respBody, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse record sets: %w", err)
+	}
+
+	for _, rrset := range page.ResourceRecordSets {
+		if relativeName(rrset.Name, domain) == strings.ToLower(name) && rrset.Type == recordType {
+			return &rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// changeRRsets submits a ChangeResourceRecordSets batch (applied atomically by Route53)
+func (p *Route53Provider) changeRRsets(ctx context.Context, zoneID string, changes []r53Change) error {
+	body := r53ChangeResourceRecordSetsRequest{
+		XMLNS:   route53XMLNS,
+		Changes: changes,
+	}
+	_, err := p.doRequest(ctx, "POST", "/hostedzone/"+zoneID+"/rrset/", nil, body)
+	return err
+}
+
+func (p *Route53Provider) ListRecords(ctx context.Context, domain string) ([]Record, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	rrsets, err := p.listRRsets(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, rrset := range rrsets {
+		name := relativeName(rrset.Name, domain)
+
+		// Skip SOA and apex NS (managed by Route53)
+		if rrset.Type == "SOA" || (rrset.Type == "NS" && name == "@") {
+			continue
+		}
+
+		for _, content := range rrset.values() {
+			record := recordFromContent(rrset.Type, content)
+			record.ID = rrsetRecordID(name, rrset.Type, content)
+			record.Name = name
+			record.TTL = rrset.TTL
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func (p *Route53Provider) CreateRecord(ctx context.Context, domain string, record Record) (*Record, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	content := rrsetContent(record)
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = 600
+	}
+
+	existing, err := p.getRRset(ctx, zoneID, domain, record.Name, record.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	// Route53 replaces whole RRsets, so merge with existing values
+	values := []string{}
+	if existing != nil {
+		for _, v := range existing.values() {
+			if !sameContent(record.Type, v, content) {
+				values = append(values, v)
+			}
+		}
+	}
+	values = append(values, content)
+
+	err = p.changeRRsets(ctx, zoneID, []r53Change{{
+		Action: "UPSERT",
+		ResourceRecordSet: r53ResourceRecordSet{
+			Name:            fqdnName(record.Name, domain),
+			Type:            record.Type,
+			TTL:             ttl,
+			ResourceRecords: r53Records(values),
+		},
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+
+	record.ID = rrsetRecordID(record.Name, record.Type, content)
+	record.TTL = ttl
+	return &record, nil
+}
+
+func (p *Route53Provider) UpdateRecord(ctx context.Context, domain string, recordID string, record Record) (*Record, error) {
+	oldName, oldType, oldContent, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	// If name or type changed, we need to delete old and create new
+	if oldName != record.Name || oldType != record.Type {
+		if err := p.DeleteRecord(ctx, domain, recordID); err != nil {
+			return nil, fmt.Errorf("failed to delete old record: %w", err)
+		}
+		return p.CreateRecord(ctx, domain, record)
+	}
+
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := p.getRRset(ctx, zoneID, domain, oldName, oldType)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return p.CreateRecord(ctx, domain, record)
+	}
+
+	content := rrsetContent(record)
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = existing.TTL
+	}
+
+	values := []string{content}
+	for _, v := range existing.values() {
+		if sameContent(oldType, v, oldContent) || sameContent(oldType, v, content) {
+			continue
+		}
+		values = append(values, v)
+	}
+
+	err = p.changeRRsets(ctx, zoneID, []r53Change{{
+		Action: "UPSERT",
+		ResourceRecordSet: r53ResourceRecordSet{
+			Name:            existing.Name,
+			Type:            existing.Type,
+			TTL:             ttl,
+			ResourceRecords: r53Records(values),
+		},
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update record: %w", err)
+	}
+
+	record.ID = rrsetRecordID(record.Name, record.Type, content)
+	record.TTL = ttl
+	return &record, nil
+}
+
+func (p *Route53Provider) DeleteRecord(ctx context.Context, domain string, recordID string) error {
+	name, recordType, content, err := parseRRsetRecordID(recordID)
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	existing, err := p.getRRset(ctx, zoneID, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil // Nothing to delete
+	}
+
+	var remaining []string
+	for _, v := range existing.values() {
+		if !sameContent(recordType, v, content) {
+			remaining = append(remaining, v)
+		}
+	}
+
+	// DELETE must match the current RRset exactly
+	if len(remaining) == 0 {
+		return p.changeRRsets(ctx, zoneID, []r53Change{{
+			Action:            "DELETE",
+			ResourceRecordSet: *existing,
+		}})
+	}
+
+	return p.changeRRsets(ctx, zoneID, []r53Change{{
+		Action: "UPSERT",
+		ResourceRecordSet: r53ResourceRecordSet{
+			Name:            existing.Name,
+			Type:            existing.Type,
+			TTL:             existing.TTL,
+			ResourceRecords: r53Records(remaining),
+		},
+	}})
+}
+
+// ApplyChanges submits the changeset as one ChangeResourceRecordSets batch,
+// which Route53 applies atomically
+func (p *Route53Provider) ApplyChanges(ctx context.Context, domain string, changes []Change) ([]ChangeResult, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	rrsets, err := p.listRRsets(ctx, zoneID)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	// Keep the originals: DELETE must match the current RRset exactly
+	originals := make(map[rrsetKey]r53ResourceRecordSet)
+	current := make(map[rrsetKey]*rrsetState)
+	for _, rrset := range rrsets {
+		key := rrsetKey{Name: relativeName(rrset.Name, domain), Type: rrset.Type}
+		originals[key] = rrset
+		current[key] = &rrsetState{
+			TTL:      rrset.TTL,
+			Contents: rrset.values(),
+		}
+	}
+
+	plan, err := planRRsets(current, changes, resolveRRsetRecordID)
+	if err != nil {
+		return batchResults(changes, nil, err)
+	}
+
+	var batch []r53Change
+	for _, key := range plan.Touched {
+		state := plan.Sets[key]
+		original, existed := originals[key]
+
+		if len(state.Contents) == 0 {
+			if existed {
+				batch = append(batch, r53Change{Action: "DELETE", ResourceRecordSet: original})
+			}
+			continue
+		}
+
+		if state.TTL == 0 {
+			state.TTL = 600
+		}
+		batch = append(batch, r53Change{
+			Action: "UPSERT",
+			ResourceRecordSet: r53ResourceRecordSet{
+				Name:            fqdnName(key.Name, domain),
+				Type:            key.Type,
+				TTL:             state.TTL,
+				ResourceRecords: r53Records(state.Contents),
+			},
+		})
+	}
+
+	if len(batch) > 0 {
+		if err := p.changeRRsets(ctx, zoneID, batch); err != nil {
+			return batchResults(changes, nil, fmt.Errorf("failed to apply changes: %w", err))
+		}
+	}
+
+	plan.assignIDs(func(key rrsetKey, content string) string {
+		return rrsetRecordID(key.Name, key.Type, content)
+	})
+	return batchResults(changes, plan.Records, nil)
+}
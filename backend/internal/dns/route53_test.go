@@ -0,0 +1,388 @@
+package dns
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRoute53 serves hosted zone Z1 for example.com over the Route53 REST API
+type fakeRoute53 struct {
+	t        *testing.T
+	mu       sync.Mutex
+	rrsets   []r53ResourceRecordSet
+	pageSize int // ListResourceRecordSets page size without maxitems
+	batches  [][]r53Change
+	bodies   []string // Raw change batch requests
+}
+
+func newFakeRoute53(t *testing.T, rrsets ...r53ResourceRecordSet) (*fakeRoute53, *Route53Provider) {
+	f := &fakeRoute53{t: t, rrsets: rrsets, pageSize: 100}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, NewRoute53Provider("AKIDEXAMPLE@"+srv.URL, "secret")
+}
+
+func (f *fakeRoute53) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if want := f.signature(r, body); r.Header.Get("Authorization") != want {
+		f.error(w, http.StatusForbidden, "SignatureDoesNotMatch", "want "+want)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/2013-04-01")
+	query := r.URL.Query()
+	switch {
+	case r.Method == "GET" && path == "/hostedzone":
+		f.write(w, struct {
+			XMLName xml.Name `xml:"ListHostedZonesResponse"`
+		}{})
+	case r.Method == "GET" && path == "/hostedzonesbyname":
+		resp := r53ListHostedZonesByNameResponse{}
+		if query.Get("dnsname") == "example.com." {
+			resp.HostedZones = []r53HostedZone{{ID: "/hostedzone/Z1", Name: "example.com."}}
+		}
+		f.write(w, resp)
+	case r.Method == "GET" && path == "/hostedzone/Z1":
+		f.write(w, r53GetHostedZoneResponse{
+			HostedZone:  r53HostedZone{ID: "/hostedzone/Z1", Name: "example.com."},
+			NameServers: []string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"},
+		})
+	case r.Method == "GET" && path == "/hostedzone/Z1/rrset":
+		f.list(w, query.Get("name"), query.Get("type"), query.Get("maxitems"))
+	case r.Method == "POST" && path == "/hostedzone/Z1/rrset/":
+		changes, err := decodeR53Changes(body)
+		if err != "" {
+			f.error(w, http.StatusBadRequest, "InvalidInput", err)
+			return
+		}
+		if err := f.apply(changes); err != "" {
+			f.error(w, http.StatusBadRequest, "InvalidChangeBatch", err)
+			return
+		}
+		f.batches = append(f.batches, changes)
+		f.bodies = append(f.bodies, string(body))
+		f.write(w, struct {
+			XMLName xml.Name `xml:"ChangeResourceRecordSetsResponse"`
+		}{})
+	default:
+		f.error(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found")
+	}
+}
+
+// signature signs a copy of the request with the expected credentials
+func (f *fakeRoute53) signature(r *http.Request, body []byte) string {
+	now, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "missing X-Amz-Date"
+	}
+	req, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	signer := &Route53Provider{accessKeyID: "AKIDEXAMPLE", secretKey: "secret"}
+	signer.sign(req, body, now)
+	return req.Header.Get("Authorization")
+}
+
+// decodeR53Changes decodes a change batch as Route53 documents it, independently of
+// the provider's structs: every value is a <ResourceRecord> with a single <Value>
+func decodeR53Changes(body []byte) ([]r53Change, string) {
+	var req struct {
+		Changes []struct {
+			Action string `xml:"Action"`
+			Set    struct {
+				Name    string `xml:"Name"`
+				Type    string `xml:"Type"`
+				TTL     int    `xml:"TTL"`
+				Records []struct {
+					Values []string `xml:"Value"`
+				} `xml:"ResourceRecords>ResourceRecord"`
+			} `xml:"ResourceRecordSet"`
+		} `xml:"ChangeBatch>Changes>Change"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return nil, err.Error()
+	}
+
+	var changes []r53Change
+	for _, c := range req.Changes {
+		var values []string
+		for _, r := range c.Set.Records {
+			if len(r.Values) != 1 {
+				return nil, "a ResourceRecord must have exactly one Value"
+			}
+			values = append(values, r.Values[0])
+		}
+		changes = append(changes, r53Change{Action: c.Action, ResourceRecordSet: r53Set(c.Set.Name, c.Set.Type, c.Set.TTL, values...)})
+	}
+	return changes, ""
+}
+
+// list pages through the RRsets, starting at name/type like ListResourceRecordSets
+func (f *fakeRoute53) list(w http.ResponseWriter, name, recordType, maxItems string) {
+	start := 0
+	if name != "" {
+		for start < len(f.rrsets) && (f.rrsets[start].Name != name || f.rrsets[start].Type != recordType) {
+			start++
+		}
+	}
+	size := f.pageSize
+	if n, err := strconv.Atoi(maxItems); err == nil {
+		size = n
+	}
+
+	resp := r53ListResourceRecordSetsResponse{}
+	end := start + size
+	if end >= len(f.rrsets) {
+		end = len(f.rrsets)
+	} else {
+		resp.IsTruncated = true
+		resp.NextRecordName = f.rrsets[end].Name
+		resp.NextRecordType = f.rrsets[end].Type
+	}
+	resp.ResourceRecordSets = f.rrsets[start:end]
+	f.write(w, resp)
+}
+
+// apply applies a change batch, returning an error message if it is invalid
+func (f *fakeRoute53) apply(changes []r53Change) string {
+	for _, change := range changes {
+		set := change.ResourceRecordSet
+		i := 0
+		for i < len(f.rrsets) && (f.rrsets[i].Name != set.Name || f.rrsets[i].Type != set.Type) {
+			i++
+		}
+		switch change.Action {
+		case "UPSERT":
+			if i < len(f.rrsets) {
+				f.rrsets[i] = set
+			} else {
+				f.rrsets = append(f.rrsets, set)
+			}
+		case "DELETE":
+			if i == len(f.rrsets) || !reflect.DeepEqual(f.rrsets[i], set) {
+				return "Tried to delete resource record set but it was not found"
+			}
+			f.rrsets = append(f.rrsets[:i], f.rrsets[i+1:]...)
+		default:
+			return "unexpected action " + change.Action
+		}
+	}
+	return ""
+}
+
+func (f *fakeRoute53) write(w http.ResponseWriter, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		f.t.Error(err)
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(b)
+}
+
+func (f *fakeRoute53) error(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	f.write(w, struct {
+		XMLName xml.Name `xml:"ErrorResponse"`
+		Code    string   `xml:"Error>Code"`
+		Message string   `xml:"Error>Message"`
+	}{Code: code, Message: message})
+}
+
+// values returns the values of an RRset, nil if it doesn't exist
+func (f *fakeRoute53) values(name, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rrset := range f.rrsets {
+		if rrset.Name == name && rrset.Type == recordType {
+			return rrset.values()
+		}
+	}
+	return nil
+}
+
+func r53Set(name, recordType string, ttl int, values ...string) r53ResourceRecordSet {
+	return r53ResourceRecordSet{Name: name, Type: recordType, TTL: ttl, ResourceRecords: r53Records(values)}
+}
+
+func TestNewRoute53ProviderEndpoint(t *testing.T) {
+	p := NewRoute53Provider("AKID", "secret")
+	if p.accessKeyID != "AKID" || p.endpoint != route53DefaultEndpoint {
+		t.Errorf("default: key %q endpoint %q", p.accessKeyID, p.endpoint)
+	}
+	p = NewRoute53Provider("AKID@https://r53.example.net/", "secret")
+	if p.accessKeyID != "AKID" || p.endpoint != "https://r53.example.net" {
+		t.Errorf("custom endpoint: key %q endpoint %q", p.accessKeyID, p.endpoint)
+	}
+}
+
+func TestRoute53Sign(t *testing.T) {
+	p := NewRoute53Provider("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	req, _ := http.NewRequest("GET", route53DefaultEndpoint+"/2013-04-01/hostedzonesbyname?maxitems=1&dnsname=example.com.", nil)
+	p.sign(req, nil, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261016/us-east-1/route53/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=91accd8284616ebb393875619acc7a01101757299a7d870370cc7d04148ceec4"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization:\ngot  %s\nwant %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20261016T120000Z" {
+		t.Errorf("X-Amz-Date = %s", got)
+	}
+}
+
+func TestRoute53Zone(t *testing.T) {
+	_, p := newFakeRoute53(t)
+	ctx := context.Background()
+
+	if err := p.ValidateCredentials(ctx); err != nil {
+		t.Errorf("ValidateCredentials: %v", err)
+	}
+	nameservers, err := p.GetExpectedNameservers(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"}; !reflect.DeepEqual(nameservers, want) {
+		t.Errorf("nameservers = %v, want %v", nameservers, want)
+	}
+
+	if _, err := p.ListRecords(ctx, "other.com"); err == nil || !strings.Contains(err.Error(), "zone not found") {
+		t.Errorf("ListRecords of a missing zone: %v", err)
+	}
+
+	p.secretKey = "wrong"
+	p.accessKeyID = "OTHER"
+	if err := p.ValidateCredentials(ctx); err == nil || !strings.Contains(err.Error(), "[403] SignatureDoesNotMatch") {
+		t.Errorf("ValidateCredentials with other credentials: %v", err)
+	}
+}
+
+func TestRoute53ListRecords(t *testing.T) {
+	f, p := newFakeRoute53(t,
+		r53Set("example.com.", "NS", 172800, "ns-1.awsdns-01.org."),
+		r53Set("example.com.", "SOA", 900, "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400"),
+		r53Set("example.com.", "MX", 300, "10 mail.example.com."),
+		r53Set(`\052.example.com.`, "A", 60, "192.0.2.1"),
+		r53Set("www.example.com.", "AAAA", 60, "2001:db8::1", "2001:db8::2"),
+		r53Set("example.com.", "TXT", 300, `"hello world"`),
+	)
+	f.pageSize = 2 // Spread the zone over three pages
+
+	records, err := p.ListRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Record{
+		{ID: "@:MX:10 mail.example.com.", Name: "@", Type: "MX", Value: "mail.example.com", TTL: 300, Priority: 10},
+		{ID: "*:A:192.0.2.1", Name: "*", Type: "A", Value: "192.0.2.1", TTL: 60},
+		{ID: "www:AAAA:2001:db8::1", Name: "www", Type: "AAAA", Value: "2001:db8::1", TTL: 60},
+		{ID: "www:AAAA:2001:db8::2", Name: "www", Type: "AAAA", Value: "2001:db8::2", TTL: 60},
+		{ID: `@:TXT:"hello world"`, Name: "@", Type: "TXT", Value: "hello world", TTL: 300},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("ListRecords:\ngot  %+v\nwant %+v", records, want)
+	}
+}
+
+func TestRoute53RecordChanges(t *testing.T) {
+	f, p := newFakeRoute53(t, r53Set("www.example.com.", "A", 60, "192.0.2.1"))
+	ctx := context.Background()
+
+	record, err := p.CreateRecord(ctx, "example.com", Record{Name: "www", Type: "A", Value: "192.0.2.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "www:A:192.0.2.2" {
+		t.Errorf("created ID = %q", record.ID)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.1", "192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after create: %v, want %v", got, want)
+	}
+	if body := f.bodies[len(f.bodies)-1]; strings.Count(body, "<ResourceRecord>") != 2 {
+		t.Errorf("two-value UPSERT doesn't have a <ResourceRecord> per value:\n%s", body)
+	}
+
+	record, err = p.UpdateRecord(ctx, "example.com", "www:A:192.0.2.1", Record{Name: "www", Type: "A", Value: "192.0.2.3", TTL: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "www:A:192.0.2.3" || record.TTL != 30 {
+		t.Errorf("updated record = %+v", record)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.3", "192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after update: %v, want %v", got, want)
+	}
+
+	if err := p.DeleteRecord(ctx, "example.com", "www:A:192.0.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after delete: %v, want %v", got, want)
+	}
+
+	// Removing the last value deletes the RRset, which must match it exactly
+	if err := p.DeleteRecord(ctx, "example.com", "www:A:192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.values("www.example.com.", "A"); got != nil {
+		t.Errorf("after deleting the last value: %v", got)
+	}
+	if last := f.batches[len(f.batches)-1]; last[0].Action != "DELETE" {
+		t.Errorf("last value removed with %s, want DELETE", last[0].Action)
+	}
+}
+
+func TestRoute53ApplyChanges(t *testing.T) {
+	f, p := newFakeRoute53(t,
+		r53Set("www.example.com.", "A", 60, "192.0.2.1", "192.0.2.2"),
+		r53Set("old.example.com.", "CNAME", 300, "www.example.com."),
+	)
+
+	changes := []Change{
+		{Action: ChangeUpdate, RecordID: "www:A:192.0.2.1", Record: Record{Name: "www", Type: "A", Value: "192.0.2.3"}},
+		{Action: ChangeDelete, RecordID: "old:CNAME:www.example.com"},
+		{Action: ChangeCreate, Record: Record{Name: "_sip._tcp", Type: "SRV", Value: "sip.example.com", Priority: 10, Weight: 5, Port: 5060}},
+	}
+	results, err := p.ApplyChanges(context.Background(), "example.com", changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.batches) != 1 {
+		t.Fatalf("%d change batches, want the changeset in one", len(f.batches))
+	}
+	actions := []string{}
+	for _, c := range f.batches[0] {
+		actions = append(actions, c.Action+" "+c.ResourceRecordSet.Name)
+	}
+	if want := []string{"UPSERT www.example.com.", "DELETE old.example.com.", "UPSERT _sip._tcp.example.com."}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("batch = %v, want %v", actions, want)
+	}
+
+	if results[0].Record.ID != "www:A:192.0.2.3" || results[0].Record.TTL != 60 {
+		t.Errorf("updated record = %+v", results[0].Record)
+	}
+	if results[2].Record.ID != "_sip._tcp:SRV:10 5 5060 sip.example.com." || results[2].Record.TTL != 600 {
+		t.Errorf("created record = %+v", results[2].Record)
+	}
+	if got, want := f.values("www.example.com.", "A"), []string{"192.0.2.2", "192.0.2.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("www A = %v, want %v", got, want)
+	}
+	if got := f.values("old.example.com.", "CNAME"); got != nil {
+		t.Errorf("old CNAME = %v, want deleted", got)
+	}
+}
@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Helpers shared by providers that speak in RRsets (PowerDNS, Route53, RFC 2136).
+// These providers have no per-record IDs, so we synthesize one from name/type/content.
+
+// rrsetRecordID builds a synthetic record ID: "name:type:content"
+func rrsetRecordID(name, recordType, content string) string {
+	return fmt.Sprintf("%s:%s:%s", name, recordType, content)
+}
+
+// parseRRsetRecordID splits a synthetic record ID back into name, type and content
+func parseRRsetRecordID(recordID string) (name, recordType, content string, err error) {
+	parts := strings.SplitN(recordID, ":", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid record ID format")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// fqdnName converts a relative name ("www", "@") into an absolute, dot-terminated name
+func fqdnName(name, domain string) string {
+	domain = strings.TrimSuffix(domain, ".")
+	if name == "" || name == "@" {
+		return domain + "."
+	}
+	return name + "." + domain + "."
+}
+
+// relativeName converts an absolute name back into our format ("www", "@")
+func relativeName(fqdn, domain string) string {
+	fqdn = strings.TrimSuffix(strings.ToLower(fqdn), ".")
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if fqdn == domain {
+		return "@"
+	}
+	// Route53 escapes the wildcard label
+	fqdn = strings.ReplaceAll(fqdn, `\052`, "*")
+	return strings.TrimSuffix(fqdn, "."+domain)
+}
+
+// rrsetContent formats a record value as zone-file rdata
+func rrsetContent(record Record) string {
+	value := record.Value
+	switch record.Type {
+	case "MX":
+		value = fmt.Sprintf("%d %s", record.Priority, absoluteTarget(value))
+	case "SRV":
+		value = fmt.Sprintf("%d %d %d %s", record.Priority, record.Weight, record.Port, absoluteTarget(value))
+	case "CAA":
+		value = fmt.Sprintf("%d %s %s", record.Flags, record.Tag, strconv.Quote(strings.Trim(value, "\"")))
+	case "TLSA":
+		value = fmt.Sprintf("%d %d %d %s", record.Usage, record.Selector, record.MatchingType, strings.ToLower(value))
+	case "CNAME", "NS", "PTR":
+		value = absoluteTarget(value)
+	case "TXT":
+		if !strings.HasPrefix(value, "\"") {
+			value = strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+// absoluteTarget dot-terminates a hostname target ("." stays the root)
+func absoluteTarget(target string) string {
+	if target == "" || strings.HasSuffix(target, ".") {
+		if target == "" {
+			return "."
+		}
+		return target
+	}
+	return target + "."
+}
+
+// recordFromContent parses zone-file rdata into a record (Type and rdata fields only)
+func recordFromContent(recordType, content string) Record {
+	record := Record{Type: recordType, Value: content}
+	fields := strings.Fields(content)
+
+	switch recordType {
+	case "MX":
+		if len(fields) == 2 {
+			record.Priority, _ = strconv.Atoi(fields[0])
+			record.Value = fields[1]
+		}
+	case "SRV":
+		if len(fields) == 4 {
+			record.Priority, _ = strconv.Atoi(fields[0])
+			record.Weight, _ = strconv.Atoi(fields[1])
+			record.Port, _ = strconv.Atoi(fields[2])
+			record.Value = fields[3]
+		}
+	case "CAA":
+		if len(fields) >= 3 {
+			record.Flags, _ = strconv.Atoi(fields[0])
+			record.Tag = fields[1]
+			// The value may contain spaces, take everything after the tag
+			rest := strings.TrimSpace(content[strings.Index(content, fields[1])+len(fields[1]):])
+			record.Value = unquoteTXT(rest)
+		}
+		return record
+	case "TLSA":
+		if len(fields) >= 4 {
+			record.Usage, _ = strconv.Atoi(fields[0])
+			record.Selector, _ = strconv.Atoi(fields[1])
+			record.MatchingType, _ = strconv.Atoi(fields[2])
+			// Certificate data may be split into several chunks
+			record.Value = strings.ToLower(strings.Join(fields[3:], ""))
+		}
+		return record
+	case "TXT":
+		record.Value = unquoteTXT(content)
+		return record
+	}
+
+	if record.Value != "." {
+		record.Value = strings.TrimSuffix(record.Value, ".")
+	}
+	return record
+}
+
+// unquoteTXT joins the character-strings of a TXT rdata ("a" "b" -> ab)
+func unquoteTXT(content string) string {
+	if !strings.HasPrefix(content, "\"") {
+		return content
+	}
+	var sb strings.Builder
+	inQuote := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			sb.WriteByte(content[i])
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// sameContent compares two rdata strings ignoring quoting, case and trailing dots
+func sameContent(recordType, a, b string) bool {
+	return sameRecordData(recordFromContent(recordType, a), recordFromContent(recordType, b))
+}
+
+// sameRecordData reports whether two records of the same type carry identical rdata
+func sameRecordData(a, b Record) bool {
+	return a.Priority == b.Priority && a.Weight == b.Weight && a.Port == b.Port &&
+		a.Flags == b.Flags && a.Tag == b.Tag &&
+		a.Usage == b.Usage && a.Selector == b.Selector && a.MatchingType == b.MatchingType &&
+		sameValue(a.Type, a.Value, b.Value)
+}
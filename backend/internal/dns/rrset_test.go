@@ -0,0 +1,111 @@
+package dns
+
+import "testing"
+
+func TestRRsetContent(t *testing.T) {
+	tests := []struct {
+		record  Record
+		content string
+	}{
+		{Record{Type: "A", Value: "192.0.2.1"}, "192.0.2.1"},
+		{Record{Type: "AAAA", Value: "2001:db8::1"}, "2001:db8::1"},
+		{Record{Type: "CNAME", Value: "target.example.com"}, "target.example.com."},
+		{Record{Type: "NS", Value: "ns1.example.com."}, "ns1.example.com."},
+		{Record{Type: "PTR", Value: "host.example.com"}, "host.example.com."},
+		{Record{Type: "MX", Value: "mail.example.com", Priority: 10}, "10 mail.example.com."},
+		{Record{Type: "MX", Value: ".", Priority: 0}, "0 ."},
+		{Record{Type: "SRV", Value: "sip.example.com", Priority: 10, Weight: 5, Port: 5060}, "10 5 5060 sip.example.com."},
+		{Record{Type: "CAA", Value: "letsencrypt.org", Tag: "issue"}, `0 issue "letsencrypt.org"`},
+		{Record{Type: "CAA", Value: "mailto:security@example.com", Flags: 128, Tag: "iodef"}, `128 iodef "mailto:security@example.com"`},
+		{Record{Type: "TLSA", Value: "ABCDEF", Usage: 3, Selector: 1, MatchingType: 1}, "3 1 1 abcdef"},
+		{Record{Type: "TXT", Value: "v=spf1 -all"}, `"v=spf1 -all"`},
+		{Record{Type: "TXT", Value: `say "hi"`}, `"say \"hi\""`},
+		{Record{Type: "TXT", Value: `"already" "quoted"`}, `"already" "quoted"`},
+	}
+
+	for _, tt := range tests {
+		content := rrsetContent(tt.record)
+		if content != tt.content {
+			t.Errorf("rrsetContent(%+v) = %s, want %s", tt.record, content, tt.content)
+			continue
+		}
+
+		// Parsing the content gives back the record
+		parsed := recordFromContent(tt.record.Type, content)
+		if !sameRecordData(parsed, tt.record) {
+			t.Errorf("recordFromContent(%s, %s) = %+v, want %+v", tt.record.Type, content, parsed, tt.record)
+		}
+	}
+}
+
+func TestRecordFromContent(t *testing.T) {
+	tests := []struct {
+		recordType string
+		content    string
+		want       Record
+	}{
+		{"CNAME", "target.example.com.", Record{Type: "CNAME", Value: "target.example.com"}},
+		{"MX", "10 mail.example.com.", Record{Type: "MX", Value: "mail.example.com", Priority: 10}},
+		{"MX", "0 .", Record{Type: "MX", Value: ".", Priority: 0}},
+		{"SRV", "0 0 443 svc.example.com.", Record{Type: "SRV", Value: "svc.example.com", Port: 443}},
+		{"CAA", `0 issue "ca.example.net; account=123"`, Record{Type: "CAA", Value: "ca.example.net; account=123", Tag: "issue"}},
+		{"TLSA", "3 1 1 ABCD EF01", Record{Type: "TLSA", Value: "abcdef01", Usage: 3, Selector: 1, MatchingType: 1}},
+		{"TXT", `"v=spf1 " "include:_spf.example.com" " -all"`, Record{Type: "TXT", Value: "v=spf1 include:_spf.example.com -all"}},
+		{"TXT", `"a \"quoted\" word"`, Record{Type: "TXT", Value: `a "quoted" word`}},
+		{"TXT", "unquoted", Record{Type: "TXT", Value: "unquoted"}},
+	}
+
+	for _, tt := range tests {
+		if got := recordFromContent(tt.recordType, tt.content); got != tt.want {
+			t.Errorf("recordFromContent(%s, %s) = %+v, want %+v", tt.recordType, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestRRsetNames(t *testing.T) {
+	tests := []struct {
+		name string
+		fqdn string
+	}{
+		{"@", "example.com."},
+		{"www", "www.example.com."},
+		{"*", "*.example.com."},
+		{"_sip._tcp", "_sip._tcp.example.com."},
+		{"a.b", "a.b.example.com."},
+	}
+
+	for _, tt := range tests {
+		if got := fqdnName(tt.name, "example.com"); got != tt.fqdn {
+			t.Errorf("fqdnName(%s) = %s, want %s", tt.name, got, tt.fqdn)
+		}
+		if got := fqdnName(tt.name, "example.com."); got != tt.fqdn {
+			t.Errorf("fqdnName(%s) with a dot-terminated domain = %s, want %s", tt.name, got, tt.fqdn)
+		}
+		if got := relativeName(tt.fqdn, "example.com"); got != tt.name {
+			t.Errorf("relativeName(%s) = %s, want %s", tt.fqdn, got, tt.name)
+		}
+	}
+
+	// Route53 escapes the wildcard label, names are case-insensitive
+	if got := relativeName(`\052.Example.COM.`, "example.com"); got != "*" {
+		t.Errorf(`relativeName(\052.Example.COM.) = %s, want *`, got)
+	}
+	if got := fqdnName("", "example.com"); got != "example.com." {
+		t.Errorf("fqdnName of an empty name = %s", got)
+	}
+}
+
+func TestRRsetRecordID(t *testing.T) {
+	id := rrsetRecordID("www", "AAAA", "2001:db8::1")
+	if id != "www:AAAA:2001:db8::1" {
+		t.Fatalf("rrsetRecordID = %s", id)
+	}
+	name, recordType, content, err := parseRRsetRecordID(id)
+	if err != nil || name != "www" || recordType != "AAAA" || content != "2001:db8::1" {
+		t.Errorf("parseRRsetRecordID(%s) = %s, %s, %s, %v", id, name, recordType, content, err)
+	}
+
+	if _, _, _, err := parseRRsetRecordID("no-separators"); err == nil {
+		t.Error("parseRRsetRecordID of an invalid ID: expected an error")
+	}
+}
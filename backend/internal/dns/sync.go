@@ -3,6 +3,8 @@ package dns
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // SyncService handles comparing and syncing DNS records between local DB and remote provider
@@ -13,7 +15,44 @@ func NewSyncService() *SyncService {
 	return &SyncService{}
 }
 
-// Compare compares local records with remote records and returns the diff
+// RRset groups the records that share a name and type
+type RRset struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Records []Record `json:"records"`
+}
+
+// rrsetKeyOf returns the name:type key used to group records into RRsets
+func rrsetKeyOf(r Record) string {
+	return fmt.Sprintf("%s:%s", strings.ToLower(r.Name), strings.ToUpper(r.Type))
+}
+
+// GroupRRsets groups records into RRsets keyed by name:type
+func GroupRRsets(records []Record) map[string]*RRset {
+	sets := make(map[string]*RRset)
+	for _, r := range records {
+		key := rrsetKeyOf(r)
+		set, ok := sets[key]
+		if !ok {
+			set = &RRset{Name: r.Name, Type: r.Type}
+			sets[key] = set
+		}
+		set.Records = append(set.Records, r)
+	}
+	return sets
+}
+
+// sameValue compares record values ignoring case, trailing dots and TXT quoting
+func sameValue(recordType, a, b string) bool {
+	if recordType == "TXT" {
+		return unquoteTXT(a) == unquoteTXT(b)
+	}
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+// Compare compares local records with remote records and returns the diff.
+// Records are compared as RRsets (name+type -> set of values), so round-robin A,
+// multiple MX or several apex TXT records are diffed value by value.
 func (s *SyncService) Compare(localRecords []Record, remoteRecords []Record) *SyncResult {
 	result := &SyncResult{
 		InSync:    true,
@@ -24,55 +63,162 @@ func (s *SyncService) Compare(localRecords []Record, remoteRecords []Record) *Sy
 		Errors:    []string{},
 	}
 
-	// Build maps for comparison using name:type as key
-	localMap := make(map[string]Record)
-	remoteMap := make(map[string]Record)
+	localSets := GroupRRsets(localRecords)
+	remoteSets := GroupRRsets(remoteRecords)
 
-	for _, r := range localRecords {
-		key := fmt.Sprintf("%s:%s", r.Name, r.Type)
-		localMap[key] = r
+	keys := make([]string, 0, len(localSets))
+	for key := range localSets {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	for _, r := range remoteRecords {
-		key := fmt.Sprintf("%s:%s", r.Name, r.Type)
-		remoteMap[key] = r
-	}
-
-	// Find records in local but not in remote (need to create)
-	// Find records that differ (conflicts)
-	for key, local := range localMap {
-		if remote, exists := remoteMap[key]; exists {
-			// Both exist - check if values match
-			if local.Value != remote.Value {
-				result.InSync = false
-				result.Conflicts = append(result.Conflicts, Conflict{
-					RecordName:  local.Name,
-					RecordType:  local.Type,
-					LocalValue:  local.Value,
-					RemoteValue: remote.Value,
-					RemoteID:    remote.ID,
-					LocalID:     local.ID,
-				})
-			}
-		} else {
-			// In local only - needs to be created on remote
-			result.InSync = false
-			result.Created = append(result.Created, local)
+	for _, key := range keys {
+		local := localSets[key]
+		remote, exists := remoteSets[key]
+		if !exists {
+			// Whole RRset is local only - needs to be created on remote
+			result.Created = append(result.Created, local.Records...)
+			continue
 		}
+		s.compareRRset(local, remote, result)
 	}
 
-	// Find records in remote but not in local (remote-only)
-	for key, remote := range remoteMap {
-		if _, exists := localMap[key]; !exists {
-			result.InSync = false
-			result.Deleted = append(result.Deleted, remote)
+	// Find RRsets in remote but not in local (remote-only)
+	remoteKeys := make([]string, 0, len(remoteSets))
+	for key := range remoteSets {
+		if _, exists := localSets[key]; !exists {
+			remoteKeys = append(remoteKeys, key)
 		}
 	}
+	sort.Strings(remoteKeys)
+	for _, key := range remoteKeys {
+		result.Deleted = append(result.Deleted, remoteSets[key].Records...)
+	}
 
+	result.InSync = len(result.Created) == 0 && len(result.Deleted) == 0 && len(result.Conflicts) == 0
 	return result
 }
 
-// ApplyToRemote pushes local records to the remote provider
+// compareRRset diffs two RRsets with the same name and type. Values present on both
+// sides are checked for TTL/priority drift; leftover values are paired up as value
+// conflicts, and whatever remains is created or deleted.
+func (s *SyncService) compareRRset(local, remote *RRset, result *SyncResult) {
+	unmatchedRemote := append([]Record{}, remote.Records...)
+	var unmatchedLocal []Record
+
+	for _, l := range local.Records {
+		matched := -1
+		for j, r := range unmatchedRemote {
+			if sameIdentity(l, r) {
+				matched = j
+				break
+			}
+		}
+		if matched < 0 {
+			unmatchedLocal = append(unmatchedLocal, l)
+			continue
+		}
+
+		r := unmatchedRemote[matched]
+		unmatchedRemote = append(unmatchedRemote[:matched], unmatchedRemote[matched+1:]...)
+		if diffs := recordDifferences(l, r); len(diffs) > 0 {
+			result.Conflicts = append(result.Conflicts, newConflict(l, r, diffs))
+		}
+	}
+
+	paired := len(unmatchedLocal)
+	if len(unmatchedRemote) < paired {
+		paired = len(unmatchedRemote)
+	}
+	for i := 0; i < paired; i++ {
+		l, r := unmatchedLocal[i], unmatchedRemote[i]
+		diffs := append([]string{"value"}, recordDifferences(l, r)...)
+		result.Conflicts = append(result.Conflicts, newConflict(l, r, diffs))
+	}
+
+	result.Created = append(result.Created, unmatchedLocal[paired:]...)
+	result.Deleted = append(result.Deleted, unmatchedRemote[paired:]...)
+}
+
+// sameIdentity reports whether two records of an RRset refer to the same entry:
+// same value plus the fields that make it a distinct entry for its type
+func sameIdentity(local, remote Record) bool {
+	if !sameValue(local.Type, local.Value, remote.Value) {
+		return false
+	}
+	switch local.Type {
+	case "SRV":
+		return local.Port == remote.Port && local.Weight == remote.Weight
+	case "CAA":
+		return local.Tag == remote.Tag
+	case "TLSA":
+		return local.Usage == remote.Usage && local.Selector == remote.Selector && local.MatchingType == remote.MatchingType
+	}
+	return true
+}
+
+// MatchRecords pairs local records with the remote records that hold the same
+// entry and returns the remote ID for each matched local ID
+func MatchRecords(localRecords []Record, remoteRecords []Record) map[string]string {
+	matches := make(map[string]string)
+	remoteSets := GroupRRsets(remoteRecords)
+	for _, local := range GroupRRsets(localRecords) {
+		remote, ok := remoteSets[rrsetKeyOf(local.Records[0])]
+		if !ok {
+			continue
+		}
+		unmatched := append([]Record{}, remote.Records...)
+		for _, l := range local.Records {
+			for j, r := range unmatched {
+				if sameIdentity(l, r) {
+					matches[l.ID] = r.ID
+					unmatched = append(unmatched[:j], unmatched[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// recordDifferences lists the non-identity fields that differ between two records
+func recordDifferences(local, remote Record) []string {
+	var diffs []string
+	// TTL 0 locally means "provider default", and Cloudflare reports 1 for "auto"
+	if local.TTL > 0 && remote.TTL > 1 && local.TTL != remote.TTL {
+		diffs = append(diffs, "ttl")
+	}
+	if (local.Type == "MX" || local.Type == "SRV") && local.Priority != remote.Priority {
+		diffs = append(diffs, "priority")
+	}
+	if local.Type == "SRV" && local.Weight != remote.Weight {
+		diffs = append(diffs, "weight")
+	}
+	if local.Type == "CAA" && local.Flags != remote.Flags {
+		diffs = append(diffs, "flags")
+	}
+	return diffs
+}
+
+func newConflict(local, remote Record, diffs []string) Conflict {
+	return Conflict{
+		RecordName:     local.Name,
+		RecordType:     local.Type,
+		LocalValue:     local.Value,
+		RemoteValue:    remote.Value,
+		LocalTTL:       local.TTL,
+		RemoteTTL:      remote.TTL,
+		LocalPriority:  local.Priority,
+		RemotePriority: remote.Priority,
+		Differences:    diffs,
+		RemoteID:       remote.ID,
+		LocalID:        local.ID,
+	}
+}
+
+// ApplyToRemote pushes local records to the remote provider as one changeset.
+// Providers with a native batch API apply it atomically; others fall back to
+// sequential apply with rollback (see ApplyChanges).
 func (s *SyncService) ApplyToRemote(ctx context.Context, provider Provider, domain string, localRecords []Record, remoteRecords []Record) (*SyncResult, error) {
 	comparison := s.Compare(localRecords, remoteRecords)
 	result := &SyncResult{
@@ -83,40 +229,77 @@ func (s *SyncService) ApplyToRemote(ctx context.Context, provider Provider, doma
 		Errors:    []string{},
 	}
 
+	localByID := make(map[string]Record)
+	for _, r := range localRecords {
+		localByID[r.ID] = r
+	}
+	remoteByID := make(map[string]Record)
+	for _, r := range remoteRecords {
+		remoteByID[r.ID] = r
+	}
+
+	var changes []Change
+
 	// Create missing records
 	for _, record := range comparison.Created {
-		created, err := provider.CreateRecord(ctx, domain, record)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to create %s %s: %v", record.Type, record.Name, err))
-			continue
-		}
-		result.Created = append(result.Created, *created)
+		desired := record
+		desired.ID = ""
+		changes = append(changes, Change{
+			Action:  ChangeCreate,
+			LocalID: record.ID,
+			Record:  desired,
+		})
 	}
 
 	// Update conflicting records (use local value)
 	for _, conflict := range comparison.Conflicts {
-		localRecord := Record{
-			Name:  conflict.RecordName,
-			Type:  conflict.RecordType,
-			Value: conflict.LocalValue,
-			TTL:   600,
+		desired := localByID[conflict.LocalID]
+		desired.ID = ""
+		if desired.TTL == 0 {
+			desired.TTL = 600
 		}
-		updated, err := provider.UpdateRecord(ctx, domain, conflict.RemoteID, localRecord)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to update %s %s: %v", conflict.RecordType, conflict.RecordName, err))
-			continue
+		change := Change{
+			Action:   ChangeUpdate,
+			LocalID:  conflict.LocalID,
+			RecordID: conflict.RemoteID,
+			Record:   desired,
+		}
+		if previous, ok := remoteByID[conflict.RemoteID]; ok {
+			change.Previous = &previous
 		}
-		result.Updated = append(result.Updated, *updated)
+		changes = append(changes, change)
 	}
 
 	// Delete remote-only records
 	for _, record := range comparison.Deleted {
-		err := provider.DeleteRecord(ctx, domain, record.ID)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to delete %s %s: %v", record.Type, record.Name, err))
+		changes = append(changes, Change{
+			Action:   ChangeDelete,
+			RecordID: record.ID,
+			Record:   record,
+		})
+	}
+
+	changeResults, err := ApplyChanges(ctx, provider, domain, changes)
+	result.Changes = changeResults
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	for _, cr := range changeResults {
+		if cr.Status != ChangeStatusApplied || cr.Record == nil {
+			if cr.Status == ChangeStatusRollbackFailed {
+				result.Errors = append(result.Errors, fmt.Sprintf("Failed to roll back %s %s %s: %s", cr.Change.Action, cr.Change.Record.Type, cr.Change.Record.Name, cr.Error))
+			}
 			continue
 		}
-		result.Deleted = append(result.Deleted, record)
+		switch cr.Change.Action {
+		case ChangeCreate:
+			result.Created = append(result.Created, *cr.Record)
+		case ChangeUpdate:
+			result.Updated = append(result.Updated, *cr.Record)
+		case ChangeDelete:
+			result.Deleted = append(result.Deleted, *cr.Record)
+		}
 	}
 
 	result.InSync = len(result.Errors) == 0
@@ -0,0 +1,126 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	mdns "github.com/miekg/dns"
+)
+
+// DefaultZoneTTL is used for records without an explicit TTL and as the exported $TTL
+const DefaultZoneTTL = 600
+
+// maxTXTChunk is the maximum length of a single TXT character-string
+const maxTXTChunk = 255
+
+// ParseZoneFile parses an RFC 1035 master file for domain. $ORIGIN, $TTL, relative
+// names and parenthesized multi-line records are supported. SOA, apex NS and DNSSEC
+// records are skipped (they belong to the provider); unsupported types and names
+// outside the zone are skipped with a warning.
+func ParseZoneFile(domain, content string) ([]Record, []string, error) {
+	origin := mdns.Fqdn(strings.ToLower(domain))
+
+	zp := mdns.NewZoneParser(strings.NewReader(content), origin, "")
+	zp.SetDefaultTTL(DefaultZoneTTL)
+
+	var records []Record
+	var warnings []string
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		hdr := rr.Header()
+		recordType := mdns.TypeToString[hdr.Rrtype]
+		name := relativeName(hdr.Name, domain)
+
+		if !mdns.IsSubDomain(origin, strings.ToLower(hdr.Name)) {
+			warnings = append(warnings, fmt.Sprintf("skipped %s %s: outside of zone %s", hdr.Name, recordType, domain))
+			continue
+		}
+
+		switch recordType {
+		case "SOA", "RRSIG", "NSEC", "NSEC3", "NSEC3PARAM", "DNSKEY", "CDS", "CDNSKEY":
+			continue
+		case "NS":
+			if name == "@" {
+				continue
+			}
+		}
+
+		if !isSupportedType(recordType) {
+			warnings = append(warnings, fmt.Sprintf("skipped %s %s: record type not supported", name, recordType))
+			continue
+		}
+
+		record := recordFromContent(recordType, rdata(rr))
+		record.Name = name
+		record.TTL = int(hdr.Ttl)
+		records = append(records, record)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, nil, fmt.Errorf("invalid zone file: %w", err)
+	}
+
+	return records, warnings, nil
+}
+
+// RenderZoneFile renders records as an RFC 1035 master file with relative names
+func RenderZoneFile(domain string, records []Record) string {
+	sorted := append([]Record{}, records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			// Apex first, then alphabetical
+			if sorted[i].Name == "@" || sorted[j].Name == "@" {
+				return sorted[i].Name == "@"
+			}
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "$ORIGIN %s\n", mdns.Fqdn(domain))
+	fmt.Fprintf(&sb, "$TTL %d\n\n", DefaultZoneTTL)
+
+	for _, r := range sorted {
+		name := r.Name
+		if name == "" {
+			name = "@"
+		}
+		ttl := r.TTL
+		if ttl <= 1 {
+			// 1 is Cloudflare's "automatic"
+			ttl = DefaultZoneTTL
+		}
+		fmt.Fprintf(&sb, "%-24s %-6d IN %-5s %s\n", name, ttl, r.Type, zoneRdata(r))
+	}
+
+	return sb.String()
+}
+
+// zoneRdata formats record rdata for a zone file, splitting long TXT values
+// into several character-strings on one parenthesized multi-line entry
+func zoneRdata(r Record) string {
+	if r.Type != "TXT" {
+		return rrsetContent(r)
+	}
+	if len(r.Value) <= maxTXTChunk {
+		return txtString(r.Value)
+	}
+
+	var chunks []string
+	for value := r.Value; len(value) > 0; {
+		n := maxTXTChunk
+		if len(value) < n {
+			n = len(value)
+		}
+		chunks = append(chunks, txtString(value[:n]))
+		value = value[n:]
+	}
+	return "( " + strings.Join(chunks, "\n\t") + " )"
+}
+
+// txtString quotes a TXT character-string using zone file escaping
+func txtString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -12,8 +13,10 @@ import (
 	"configuratix/backend/internal/auth"
 	"configuratix/backend/internal/database"
 	"configuratix/backend/internal/models"
+	"configuratix/backend/internal/services"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -187,12 +190,13 @@ func (h *AgentHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 
 	// Update agent last_seen and version
 	_, err := h.db.Exec(`
-		UPDATE agents SET last_seen = NOW(), version = COALESCE(NULLIF($1, ''), version)
+		UPDATE agents SET last_seen = NOW(), offline_notified = false, version = COALESCE(NULLIF($1, ''), version)
 		WHERE id = $2
 	`, req.Version, agentID)
 	if err != nil {
 		log.Printf("Failed to update agent heartbeat: %v", err)
 	}
+	services.CloseAgentOutages(h.db, agentID)
 
 	// Serialize UFW rules to JSON
 	ufwRulesJSON, _ := json.Marshal(req.UFWRules)
@@ -218,8 +222,18 @@ func (h *AgentHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Auto-select primary IPv6: first public IPv6 (used for passthrough AAAA records)
+	var autoPrimaryIPv6 string
+	for _, ip := range req.DetectedIPs {
+		if ip.IsIPv6 && ip.IsPublic {
+			autoPrimaryIPv6 = ip.IP
+			break
+		}
+	}
+
 	// Update machine stats including detected IPs
-	// Only update primary_ip if it's not already set (user hasn't manually selected)
+	// Only update primary_ip/primary_ipv6 if not already set (user hasn't manually selected)
+	// IPv6-only hosts have no IPv4 to offer, so keep ip_address rather than blanking it
 	_, err = h.db.Exec(`
 		UPDATE machines SET 
 			cpu_percent = $1,
@@ -232,12 +246,13 @@ func (h *AgentHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 			fail2ban_enabled = $8,
 			ufw_rules_json = $9,
 			detected_ips = $10,
-			primary_ip = COALESCE(primary_ip, $11),
-			ip_address = COALESCE($11, ip_address),
+			primary_ip = COALESCE(primary_ip, NULLIF($11, '')),
+			ip_address = COALESCE(NULLIF($11, ''), ip_address),
+			primary_ipv6 = COALESCE(primary_ipv6, NULLIF($13, '')),
 			updated_at = NOW()
 		WHERE agent_id = $12
 	`, req.CPUPercent, req.MemoryUsed, req.MemoryTotal, req.DiskUsed, req.DiskTotal,
-		req.SSHPort, req.UFWEnabled, req.Fail2ban, ufwRulesJSON, detectedIPsJSON, autoPrimaryIP, agentID)
+		req.SSHPort, req.UFWEnabled, req.Fail2ban, ufwRulesJSON, detectedIPsJSON, autoPrimaryIP, agentID, autoPrimaryIPv6)
 	if err != nil {
 		log.Printf("Failed to update machine stats: %v", err)
 	}
@@ -246,7 +261,8 @@ func (h *AgentHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// GetJobs returns pending jobs for an agent
+// GetJobs claims pending jobs for agents that don't renew leases. The jobs move to
+// running under a long lease, so a second poll never hands them out again.
 func (h *AgentHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
 	agentID, ok := r.Context().Value("agent_id").(uuid.UUID)
 	if !ok {
@@ -254,13 +270,7 @@ func (h *AgentHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var jobs []models.Job
-	err := h.db.Select(&jobs, `
-		SELECT * FROM jobs 
-		WHERE agent_id = $1 AND status = 'pending'
-		ORDER BY created_at ASC
-		LIMIT 10
-	`, agentID)
+	jobs, err := services.ClaimJobs(h.db, agentID, "", 10, services.LegacyJobLease)
 	if err != nil {
 		log.Printf("Failed to get jobs: %v", err)
 		http.Error(w, "Failed to get jobs", http.StatusInternalServerError)
@@ -275,7 +285,159 @@ func (h *AgentHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(jobs)
 }
 
-// UpdateJob updates job status
+// ClaimJobs claims pending jobs under a lease held by the calling agent process.
+// The agent must renew the lease while the job runs.
+func (h *AgentHandler) ClaimJobs(w http.ResponseWriter, r *http.Request) {
+	agentID, ok := r.Context().Value("agent_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		WorkerID string `json:"worker_id"`
+		Limit    int    `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.WorkerID == "" || len(req.WorkerID) > 255 {
+		http.Error(w, "worker_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 1
+	}
+	if req.Limit > 10 {
+		req.Limit = 10
+	}
+
+	jobs, err := services.ClaimJobs(h.db, agentID, req.WorkerID, req.Limit, services.JobLeaseDuration)
+	if err != nil {
+		log.Printf("Failed to claim jobs: %v", err)
+		http.Error(w, "Failed to claim jobs", http.StatusInternalServerError)
+		return
+	}
+
+	if jobs == nil {
+		jobs = []models.Job{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":          jobs,
+		"lease_seconds": int(services.JobLeaseDuration.Seconds()),
+	})
+}
+
+// RenewJobLease extends the lease of a running job. The response status tells the
+// agent to keep going (running) or to stop the job (cancelled, lost).
+func (h *AgentHandler) RenewJobLease(w http.ResponseWriter, r *http.Request) {
+	agentID, ok := r.Context().Value("agent_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		WorkerID string `json:"worker_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := services.RenewJobLease(h.db, agentID, jobID, req.WorkerID)
+	if errors.Is(err, services.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to renew lease of job %s: %v", jobID, err)
+		http.Error(w, "Failed to renew lease", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        status,
+		"lease_seconds": int(services.JobLeaseDuration.Seconds()),
+	})
+}
+
+// AppendJobLogs stores output the agent streams while a job runs
+func (h *AgentHandler) AppendJobLogs(w http.ResponseWriter, r *http.Request) {
+	agentID, ok := r.Context().Value("agent_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		WorkerID string `json:"worker_id"`
+		Chunks   []struct {
+			Seq      int    `json:"seq"`
+			Step     int    `json:"step"`
+			Kind     string `json:"kind"`
+			Content  string `json:"content"`
+			ExitCode *int   `json:"exit_code"`
+		} `json:"chunks"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Chunks) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	chunks := make([]models.JobLogChunk, 0, len(req.Chunks))
+	for _, c := range req.Chunks {
+		if c.Kind != "output" && c.Kind != "step_start" && c.Kind != "step_end" {
+			http.Error(w, "Chunk kind must be output, step_start or step_end", http.StatusBadRequest)
+			return
+		}
+		chunks = append(chunks, models.JobLogChunk{Seq: c.Seq, Step: c.Step, Kind: c.Kind, Content: c.Content, ExitCode: c.ExitCode})
+	}
+
+	err = services.AppendJobLogChunks(h.db, agentID, jobID, req.WorkerID, chunks)
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrJobLeaseLost):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Failed to store logs of job %s: %v", jobID, err)
+		http.Error(w, "Failed to store logs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// maxJobResultSize caps the structured result stored for a job
+const maxJobResultSize = 4 << 20
+
+// UpdateJob records progress or the result of a claimed job. Only the process holding
+// the lease may finish a job; agents that predate leases send no worker_id.
 func (h *AgentHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
 	agentID, ok := r.Context().Value("agent_id").(uuid.UUID)
 	if !ok {
@@ -284,41 +446,83 @@ func (h *AgentHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		JobID  uuid.UUID `json:"job_id"`
-		Status string    `json:"status"`
-		Logs   string    `json:"logs"`
+		JobID    uuid.UUID `json:"job_id"`
+		Status   string    `json:"status"`
+		Logs     string          `json:"logs"`
+		WorkerID string          `json:"worker_id"`
+		Result   json.RawMessage `json:"result"` // Structured result of run jobs
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var finishedAt *time.Time
-	if req.Status == "completed" || req.Status == "failed" {
-		now := time.Now()
-		finishedAt = &now
+	// Streamed chunks carry the full output; the logs column keeps a bounded copy
+	req.Logs = services.TruncateLogText(req.Logs, services.MaxJobLogText)
+
+	var resultJSON interface{}
+	if len(req.Result) > 0 && string(req.Result) != "null" {
+		if len(req.Result) > maxJobResultSize {
+			log.Printf("Dropping result of job %s: %d bytes exceeds the limit", req.JobID, len(req.Result))
+		} else if normalized, err := services.NormalizeJobResult(req.Result); err != nil {
+			log.Printf("Dropping invalid result of job %s: %v", req.JobID, err)
+		} else {
+			resultJSON = string(normalized)
+		}
 	}
 
-	var startedAt *time.Time
-	if req.Status == "running" {
+	var finishedAt *time.Time
+	switch req.Status {
+	case "running":
+	case "completed", "failed":
 		now := time.Now()
-		startedAt = &now
+		finishedAt = &now
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
 	}
 
-	_, err := h.db.Exec(`
+	// A terminal status releases the lease; running only appends logs
+	result, err := h.db.Exec(`
 		UPDATE jobs 
 		SET status = $1, logs = COALESCE(logs || E'\n' || $2, $2), 
-			started_at = COALESCE(started_at, $3),
-			finished_at = $4,
+			finished_at = $3,
+			lease_owner = CASE WHEN $3::timestamptz IS NULL THEN lease_owner END,
+			lease_expires_at = CASE WHEN $3::timestamptz IS NULL THEN lease_expires_at END,
+			result = COALESCE($7::jsonb, result),
 			updated_at = NOW()
-		WHERE id = $5 AND agent_id = $6
-	`, req.Status, req.Logs, startedAt, finishedAt, req.JobID, agentID)
+		WHERE id = $4 AND agent_id = $5 AND status = 'running' AND ($6 = '' OR lease_owner = $6)
+	`, req.Status, req.Logs, finishedAt, req.JobID, agentID, req.WorkerID, resultJSON)
 	if err != nil {
 		log.Printf("Failed to update job: %v", err)
 		http.Error(w, "Failed to update job", http.StatusInternalServerError)
 		return
 	}
 
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		// A cancelled job keeps the output the agent reports while stopping it
+		result, err = h.db.Exec(`
+			UPDATE jobs SET logs = COALESCE(logs || E'\n' || $1, $1), result = COALESCE($4::jsonb, result), updated_at = NOW()
+			WHERE id = $2 AND agent_id = $3 AND status = 'cancelled'
+		`, req.Logs, req.JobID, agentID, resultJSON)
+		if err != nil {
+			log.Printf("Failed to update job: %v", err)
+			http.Error(w, "Failed to update job", http.StatusInternalServerError)
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			http.Error(w, "Job is not leased by this agent", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+		return
+	}
+
+	if finishedAt != nil {
+		services.JobFinished(h.db, req.JobID, req.Status == "completed")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
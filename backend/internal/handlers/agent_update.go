@@ -22,7 +22,7 @@ import (
 
 // CurrentAgentVersion is the version that should be distributed
 // This should match the version in agent/cmd/agent/main.go
-const CurrentAgentVersion = "0.5.3"
+const CurrentAgentVersion = "0.10.0"
 
 // AgentUpdateHandler handles agent update distribution
 type AgentUpdateHandler struct {
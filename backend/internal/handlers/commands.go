@@ -48,6 +48,7 @@ type ExecuteCommandRequest struct {
 	MachineID string            `json:"machine_id"`
 	CommandID string            `json:"command_id"`
 	Variables map[string]string `json:"variables"`
+	Check     bool              `json:"check"` // Report what would change without applying it
 }
 
 // ExecuteCommand creates a job from a command template
@@ -98,12 +99,19 @@ func (h *CommandsHandler) ExecuteCommand(w http.ResponseWriter, r *http.Request)
 	// Create job with run type
 	payload := cmd.ToPayload(req.Variables)
 
+	// Check jobs run the same payload in check mode. Agents that predate check mode
+	// fail them as an unknown job type instead of applying the changes.
+	jobType := "run"
+	if req.Check {
+		jobType = "check"
+	}
+
 	var job models.Job
 	err = h.db.Get(&job, `
 		INSERT INTO jobs (agent_id, type, payload_json, status)
-		VALUES ($1, 'run', $2, 'pending')
+		VALUES ($1, $2, $3, 'pending')
 		RETURNING *
-	`, agentID, payload)
+	`, agentID, jobType, payload)
 	if err != nil {
 		log.Printf("Failed to create job: %v", err)
 		http.Error(w, "Failed to create job", http.StatusInternalServerError)
@@ -89,14 +89,26 @@ func (h *ConfigsHandler) ReadConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Poll for job completion (max 30 seconds)
-	rawLogs, err := h.waitForJobResult(jobID, 30*time.Second)
+	job, err := h.waitForJob(jobID, 30*time.Second)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Extract just the file content from job logs
-	content := extractFileContent(rawLogs)
+	// The file content is the read step's stdout
+	result := job.ParseResult()
+	if result == nil {
+		http.Error(w, "Agent doesn't report job results, update it to read files", http.StatusConflict)
+		return
+	}
+	// The agent caps a step's stdout; saving a cut-off copy would truncate the file
+	for _, step := range result.Steps {
+		if step.ID == "read" && step.StdoutTruncated {
+			http.Error(w, "File is too large to edit here", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	content := result.Outputs["content"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -374,27 +386,36 @@ func (h *ConfigsHandler) ListConfigs(w http.ResponseWriter, r *http.Request) {
 
 // waitForJobResult polls until a job completes and returns the logs
 func (h *ConfigsHandler) waitForJobResult(jobID uuid.UUID, timeout time.Duration) (string, error) {
+	job, err := h.waitForJob(jobID, timeout)
+	if err != nil {
+		return "", err
+	}
+	return nullStringToString(job.Logs), nil
+}
+
+// waitForJob polls until a job completes and returns it
+func (h *ConfigsHandler) waitForJob(jobID uuid.UUID, timeout time.Duration) (*models.Job, error) {
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
 		var job models.Job
 		err := h.db.Get(&job, "SELECT * FROM jobs WHERE id = $1", jobID)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		switch job.Status {
 		case "completed":
-			return nullStringToString(job.Logs), nil
-		case "failed":
-			return "", &ConfigError{Message: "Job failed: " + nullStringToString(job.Logs)}
+			return &job, nil
+		case "failed", "cancelled":
+			return nil, &ConfigError{Message: "Job " + job.Status + ": " + job.FailureMessage(4000)}
 		case "pending", "running":
 			time.Sleep(500 * time.Millisecond)
 			continue
 		}
 	}
 
-	return "", &ConfigError{Message: "Job timeout"}
+	return nil, &ConfigError{Message: "Job timeout"}
 }
 
 type ConfigError struct {
@@ -412,42 +433,6 @@ func nullStringToString(s *string) string {
 	return *s
 }
 
-// extractFileContent parses job logs to get just the file content
-// Job logs format:
-// Starting...
-// === Step 1: exec ===
-// $ cat /path/to/file
-// <actual content>
-// === All steps completed ===
-func extractFileContent(logs string) string {
-	lines := strings.Split(logs, "\n")
-	var content []string
-	inContent := false
-
-	for _, line := range lines {
-		// Start capturing after the "$ cat" or "$ " command line
-		if strings.HasPrefix(line, "$ ") {
-			inContent = true
-			continue
-		}
-		// Stop at the end marker or next step
-		if strings.HasPrefix(line, "===") {
-			inContent = false
-			continue
-		}
-		// Skip metadata lines
-		if line == "Starting..." || strings.HasPrefix(line, "ERROR:") {
-			continue
-		}
-		// Capture content
-		if inContent {
-			content = append(content, line)
-		}
-	}
-
-	return strings.Join(content, "\n")
-}
-
 // isAllowedConfigPath checks if a path is allowed for reading/writing
 func isAllowedConfigPath(path string) bool {
 	allowedPrefixes := []string{
@@ -477,151 +462,6 @@ func (h *ConfigsHandler) isCustomAllowedPath(machineID uuid.UUID, path string) b
 	return err == nil && count > 0
 }
 
-// parseSiteConfigs extracts Configuratix site configs from output
-func parseSiteConfigs(output string) []ConfigFile {
-	configs := []ConfigFile{}
-	lines := strings.Split(output, "\n")
-	inSiteConfigs := false
-	
-	for _, line := range lines {
-		if strings.Contains(line, "Site Configs") || strings.Contains(line, "configuratix") {
-			inSiteConfigs = true
-			continue
-		}
-		if strings.Contains(line, "===") || strings.Contains(line, "Sites Enabled") {
-			inSiteConfigs = false
-			continue
-		}
-		if inSiteConfigs && strings.HasSuffix(strings.TrimSpace(line), ".conf") {
-			parts := strings.Fields(line)
-			if len(parts) > 0 {
-				lastPart := parts[len(parts)-1]
-				if strings.HasSuffix(lastPart, ".conf") {
-					var filename, fullPath string
-					if strings.HasPrefix(lastPart, "/") {
-						fullPath = lastPart
-						pathParts := strings.Split(lastPart, "/")
-						filename = pathParts[len(pathParts)-1]
-					} else {
-						filename = lastPart
-						fullPath = "/etc/nginx/conf.d/configuratix/" + filename
-					}
-					configs = append(configs, ConfigFile{
-						Name:     filename,
-						Path:     fullPath,
-						Type:     "nginx_site",
-						Readonly: false,
-					})
-				}
-			}
-		}
-	}
-	return configs
-}
-
-// parseSitesEnabled extracts sites-enabled configs from output
-func parseSitesEnabled(output string) []ConfigFile {
-	configs := []ConfigFile{}
-	lines := strings.Split(output, "\n")
-	inSitesEnabled := false
-	
-	for _, line := range lines {
-		if strings.Contains(line, "Sites Enabled") {
-			inSitesEnabled = true
-			continue
-		}
-		if strings.Contains(line, "===") {
-			inSitesEnabled = false
-			continue
-		}
-		trimmed := strings.TrimSpace(line)
-		
-		// Skip empty lines, command lines, total lines, metadata
-		if !inSitesEnabled || trimmed == "" {
-			continue
-		}
-		if strings.HasPrefix(trimmed, "$") || strings.HasPrefix(trimmed, "total") {
-			continue
-		}
-		if strings.HasPrefix(trimmed, "d") && strings.Contains(line, " . ") {
-			continue // Skip directory entries like . and ..
-		}
-		
-		// For ls -la output, get the filename (last part, or part before ->)
-		// Example: lrwxrwxrwx 1 root root 34 Jan 22 15:43 default -> /etc/nginx/sites-available/default
-		parts := strings.Fields(line)
-		if len(parts) < 9 {
-			continue // Not a valid ls -la line
-		}
-		
-		// Find the filename - it's after the date/time fields
-		// Format: permissions links owner group size month day time filename [-> target]
-		filename := ""
-		for i := 8; i < len(parts); i++ {
-			if parts[i] == "->" {
-				break
-			}
-			filename = parts[i]
-		}
-		
-		if filename == "" || filename == "." || filename == ".." {
-			continue
-		}
-		
-		fullPath := "/etc/nginx/sites-enabled/" + filename
-		configs = append(configs, ConfigFile{
-			Name:     filename,
-			Path:     fullPath,
-			Type:     "nginx_site",
-			Readonly: false,
-		})
-	}
-	return configs
-}
-
-// parsePHPVersions extracts PHP versions from ls /etc/php output
-func parsePHPVersions(output string) []string {
-	versions := []string{}
-	lines := strings.Split(output, "\n")
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Skip metadata lines from job output
-		if trimmed == "" || strings.HasPrefix(trimmed, "Starting") || 
-		   strings.HasPrefix(trimmed, "===") || strings.HasPrefix(trimmed, "$") ||
-		   strings.HasPrefix(trimmed, "ERROR") {
-			continue
-		}
-		
-		// Look for version patterns like 8.1, 8.2, 8.3
-		// Must match pattern X.Y where X and Y are digits
-		if len(trimmed) >= 3 && len(trimmed) <= 4 {
-			parts := strings.Split(trimmed, ".")
-			if len(parts) == 2 {
-				major := parts[0]
-				minor := parts[1]
-				if len(major) >= 1 && len(minor) >= 1 &&
-				   major[0] >= '5' && major[0] <= '9' &&
-				   minor[0] >= '0' && minor[0] <= '9' {
-					versions = append(versions, trimmed)
-				}
-			}
-		}
-	}
-	return versions
-}
-
-// parseConfigList is kept for backward compatibility
-func parseConfigList(output string) []ConfigFile {
-	configs := []ConfigFile{
-		{Name: "nginx.conf", Path: "/etc/nginx/nginx.conf", Type: "nginx", Readonly: false},
-		{Name: "sshd_config", Path: "/etc/ssh/sshd_config", Type: "ssh", Readonly: false},
-	}
-	configs = append(configs, parseSiteConfigs(output)...)
-	return configs
-}
-
 // ==================== Custom Config Categories ====================
 
 type CreateConfigCategoryRequest struct {
@@ -15,6 +15,7 @@ import (
 	"configuratix/backend/internal/database"
 	"configuratix/backend/internal/dns"
 	"configuratix/backend/internal/models"
+	"configuratix/backend/internal/services"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -22,14 +23,18 @@ import (
 )
 
 type DNSHandler struct {
-	db          *database.DB
-	syncService *dns.SyncService
+	db            *database.DB
+	syncService   *dns.SyncService
+	driftDetector *services.DNSDriftDetector
+	zoneMigrator  *services.DNSZoneMigrator
 }
 
-func NewDNSHandler(db *database.DB) *DNSHandler {
+func NewDNSHandler(db *database.DB, driftDetector *services.DNSDriftDetector, zoneMigrator *services.DNSZoneMigrator) *DNSHandler {
 	return &DNSHandler{
-		db:          db,
-		syncService: dns.NewSyncService(),
+		db:            db,
+		syncService:   dns.NewSyncService(),
+		driftDetector: driftDetector,
+		zoneMigrator:  zoneMigrator,
 	}
 }
 
@@ -83,7 +88,7 @@ func (h *DNSHandler) ListDNSAccounts(w http.ResponseWriter, r *http.Request) {
 type CreateDNSAccountRequest struct {
 	Provider  string  `json:"provider"`
 	Name      string  `json:"name"`
-	ApiID     *string `json:"api_id"` // Required for DNSPod
+	ApiID     *string `json:"api_id"` // Required for DNSPod, PowerDNS (API URL), Route53 (access key ID), RFC 2136 (keyname@server)
 	ApiToken  string  `json:"api_token"`
 	IsDefault bool    `json:"is_default"`
 }
@@ -99,8 +104,8 @@ func (h *DNSHandler) CreateDNSAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Provider != "dnspod" && req.Provider != "cloudflare" && req.Provider != "desec" && req.Provider != "njalla" && req.Provider != "cloudns" {
-		http.Error(w, "Invalid provider. Must be 'dnspod', 'cloudflare', 'desec', 'njalla', or 'cloudns'", http.StatusBadRequest)
+	if req.Provider != "dnspod" && req.Provider != "cloudflare" && req.Provider != "desec" && req.Provider != "njalla" && req.Provider != "cloudns" && req.Provider != "powerdns" && req.Provider != "route53" && req.Provider != "rfc2136" {
+		http.Error(w, "Invalid provider. Must be 'dnspod', 'cloudflare', 'desec', 'njalla', 'cloudns', 'powerdns', 'route53', or 'rfc2136'", http.StatusBadRequest)
 		return
 	}
 
@@ -114,6 +119,21 @@ func (h *DNSHandler) CreateDNSAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Provider == "powerdns" && (req.ApiID == nil || *req.ApiID == "") {
+		http.Error(w, "api_id (API URL) is required for PowerDNS", http.StatusBadRequest)
+		return
+	}
+
+	if req.Provider == "route53" && (req.ApiID == nil || *req.ApiID == "") {
+		http.Error(w, "api_id (access key ID) is required for Route53", http.StatusBadRequest)
+		return
+	}
+
+	if req.Provider == "rfc2136" && (req.ApiID == nil || *req.ApiID == "") {
+		http.Error(w, "api_id (keyname@server:port) is required for RFC 2136", http.StatusBadRequest)
+		return
+	}
+
 	// Validate credentials
 	apiID := ""
 	if req.ApiID != nil {
@@ -522,6 +542,7 @@ func (h *DNSHandler) CreateDNSManagedDomain(w http.ResponseWriter, r *http.Reque
 type UpdateDNSManagedDomainRequest struct {
 	DNSAccountID *string `json:"dns_account_id"` // Use string to detect null vs not-provided
 	NotesMD      *string `json:"notes_md"`
+	DriftEnforce *bool   `json:"drift_enforce"`
 }
 
 // UpdateDNSManagedDomain updates DNS settings for a managed domain
@@ -567,6 +588,11 @@ func (h *DNSHandler) UpdateDNSManagedDomain(w http.ResponseWriter, r *http.Reque
 		args = append(args, *req.NotesMD)
 		argNum++
 	}
+	if req.DriftEnforce != nil {
+		updates = append(updates, fmt.Sprintf("drift_enforce = $%d", argNum))
+		args = append(args, *req.DriftEnforce)
+		argNum++
+	}
 
 	query := "UPDATE dns_managed_domains SET " + updates[0]
 	for i := 1; i < len(updates); i++ {
@@ -676,11 +702,267 @@ func (h *DNSHandler) CheckDomainNS(w http.ResponseWriter, r *http.Request) {
 		SET ns_status = $1, ns_last_check = NOW(), ns_expected = $2, ns_actual = $3
 		WHERE id = $4
 	`, status.Status, pq.Array(expected), pq.Array(status.Actual), domainID)
+	services.NotifyNSStatus(h.db, domain, status.Status, expected, status.Actual)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
 
+// ==================== Zone Migration ====================
+
+type MigrateZoneRequest struct {
+	TargetAccountID string `json:"target_account_id"`
+}
+
+// MigrateZone starts moving a domain to another DNS account: the zone is created on
+// the target, records are copied, and the domain switches once the delegation flips
+func (h *DNSHandler) MigrateZone(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return
+	}
+
+	var req MigrateZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	targetID, err := uuid.Parse(req.TargetAccountID)
+	if err != nil {
+		http.Error(w, "Invalid target_account_id", http.StatusBadRequest)
+		return
+	}
+
+	var domain models.DNSManagedDomain
+	err = h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
+	if err != nil {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	var target models.DNSAccount
+	err = h.db.Get(&target, "SELECT * FROM dns_accounts WHERE id = $1 AND owner_id = $2", targetID, userID)
+	if err != nil {
+		http.Error(w, "Target DNS account not found", http.StatusNotFound)
+		return
+	}
+
+	migration, err := h.zoneMigrator.Begin(domain, target)
+	if err != nil {
+		http.Error(w, "Failed to start migration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(migration)
+}
+
+// GetZoneMigration returns the latest migration for a domain
+func (h *DNSHandler) GetZoneMigration(w http.ResponseWriter, r *http.Request) {
+	migration, ok := h.latestZoneMigration(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(migration)
+}
+
+// CheckZoneMigration re-checks the delegation of an in-flight migration right away
+func (h *DNSHandler) CheckZoneMigration(w http.ResponseWriter, r *http.Request) {
+	migration, ok := h.latestZoneMigration(w, r)
+	if !ok {
+		return
+	}
+
+	if migration.Status != "awaiting_ns" {
+		http.Error(w, "Migration is not waiting for nameservers", http.StatusBadRequest)
+		return
+	}
+
+	h.zoneMigrator.Poll(*migration)
+
+	updated, err := h.zoneMigrator.Get(migration.ID)
+	if err != nil {
+		http.Error(w, "Failed to get migration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// CancelZoneMigration stops an in-flight migration; the domain stays on its current account
+func (h *DNSHandler) CancelZoneMigration(w http.ResponseWriter, r *http.Request) {
+	migration, ok := h.latestZoneMigration(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.zoneMigrator.Cancel(migration.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// latestZoneMigration loads the most recent migration of a domain owned by the caller
+func (h *DNSHandler) latestZoneMigration(w http.ResponseWriter, r *http.Request) (*models.DNSZoneMigration, bool) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var migration models.DNSZoneMigration
+	err = h.db.Get(&migration, `
+		SELECT m.* FROM dns_zone_migrations m
+		JOIN dns_managed_domains d ON m.dns_domain_id = d.id
+		WHERE d.id = $1 AND d.owner_id = $2
+		ORDER BY m.created_at DESC
+		LIMIT 1
+	`, domainID, userID)
+	if err != nil {
+		http.Error(w, "No migration found", http.StatusNotFound)
+		return nil, false
+	}
+	return &migration, true
+}
+
+// ==================== DNSSEC ====================
+
+// DNSSECResponse combines the provider's signing state with the chain-of-trust check
+type DNSSECResponse struct {
+	Supported  bool                  `json:"supported"`
+	DNSSEC     *dns.DNSSECStatus     `json:"dnssec"`
+	Validation *dns.DNSSECValidation `json:"validation"`
+}
+
+type SetDNSSECRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// GetDNSSEC returns DNSSEC status and DS records for a domain, and validates
+// the DS published at the parent against the provider's keys
+func (h *DNSHandler) GetDNSSEC(w http.ResponseWriter, r *http.Request) {
+	domain, provider, ok := h.dnssecDomain(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	resp := DNSSECResponse{}
+	if dp, supported := provider.(dns.DNSSECProvider); supported {
+		resp.Supported = true
+		status, err := dp.GetDNSSEC(ctx, domain.FQDN)
+		if err != nil {
+			http.Error(w, "Failed to get DNSSEC status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.DNSSEC = status
+	}
+	resp.Validation = dns.ValidateDNSSEC(ctx, domain.FQDN, resp.DNSSEC)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SetDNSSEC enables or disables signing for a domain at its provider
+func (h *DNSHandler) SetDNSSEC(w http.ResponseWriter, r *http.Request) {
+	var req SetDNSSECRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	domain, provider, ok := h.dnssecDomain(w, r)
+	if !ok {
+		return
+	}
+
+	dp, supported := provider.(dns.DNSSECProvider)
+	if !supported {
+		http.Error(w, "DNSSEC is not supported by this provider", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var status *dns.DNSSECStatus
+	var err error
+	if req.Enabled {
+		status, err = dp.EnableDNSSEC(ctx, domain.FQDN)
+	} else {
+		err = dp.DisableDNSSEC(ctx, domain.FQDN)
+		if err == nil {
+			status, err = dp.GetDNSSEC(ctx, domain.FQDN)
+		}
+	}
+	if err != nil {
+		http.Error(w, "Failed to update DNSSEC: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DNSSECResponse{
+		Supported:  true,
+		DNSSEC:     status,
+		Validation: dns.ValidateDNSSEC(ctx, domain.FQDN, status),
+	})
+}
+
+// dnssecDomain loads the caller's domain and its provider client
+func (h *DNSHandler) dnssecDomain(w http.ResponseWriter, r *http.Request) (*models.DNSManagedDomain, dns.Provider, bool) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	var domain models.DNSManagedDomain
+	err = h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
+	if err != nil {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if domain.DNSAccountID == nil {
+		http.Error(w, "No DNS account configured", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	var account models.DNSAccount
+	if err := h.db.Get(&account, "SELECT * FROM dns_accounts WHERE id = $1", domain.DNSAccountID); err != nil {
+		http.Error(w, "DNS account not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	provider, err := services.ProviderForAccount(account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return &domain, provider, true
+}
+
 // ==================== DNS Records ====================
 
 // ListDNSRecords returns all DNS records for a managed domain
@@ -734,6 +1016,62 @@ type CreateDNSRecordRequest struct {
 	HTTPOutgoingPort  *int   `json:"http_outgoing_port"`
 	HTTPSIncomingPort *int   `json:"https_incoming_port"`
 	HTTPSOutgoingPort *int   `json:"https_outgoing_port"`
+
+	// SRV / CAA / TLSA fields
+	Weight           *int    `json:"weight"`
+	Port             *int    `json:"port"`
+	Flags            *int    `json:"flags"`
+	Tag              *string `json:"tag"`
+	TLSAUsage        *int    `json:"tlsa_usage"`
+	TLSASelector     *int    `json:"tlsa_selector"`
+	TLSAMatchingType *int    `json:"tlsa_matching_type"`
+}
+
+// toRecord converts the request into a provider record
+func (req CreateDNSRecordRequest) toRecord(ttl int) dns.Record {
+	return dns.Record{
+		Name:         req.Name,
+		Type:         req.RecordType,
+		Value:        req.Value,
+		TTL:          ttl,
+		Priority:     intValue(req.Priority),
+		Weight:       intValue(req.Weight),
+		Port:         intValue(req.Port),
+		Flags:        intValue(req.Flags),
+		Tag:          stringValue(req.Tag),
+		Usage:        intValue(req.TLSAUsage),
+		Selector:     intValue(req.TLSASelector),
+		MatchingType: intValue(req.TLSAMatchingType),
+		Proxied:      req.Proxied,
+	}
+}
+
+func intValue(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func stringValue(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// typeFields returns the nullable type-specific columns for a provider record,
+// in the order weight, port, flags, tag, tlsa_usage, tlsa_selector, tlsa_matching_type
+func typeFields(r dns.Record) []interface{} {
+	switch r.Type {
+	case "SRV":
+		return []interface{}{r.Weight, r.Port, nil, nil, nil, nil, nil}
+	case "CAA":
+		return []interface{}{nil, nil, r.Flags, r.Tag, nil, nil, nil}
+	case "TLSA":
+		return []interface{}{nil, nil, nil, nil, r.Usage, r.Selector, r.MatchingType}
+	}
+	return []interface{}{nil, nil, nil, nil, nil, nil, nil}
 }
 
 // CreateDNSRecord creates a new DNS record
@@ -764,6 +1102,11 @@ func (h *DNSHandler) CreateDNSRecord(w http.ResponseWriter, r *http.Request) {
 		ttl = 600
 	}
 
+	if err := dns.ValidateRecord(req.toRecord(ttl)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get domain and DNS account info
 	var domain models.DNSManagedDomain
 	err = h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
@@ -793,18 +1136,7 @@ func (h *DNSHandler) CreateDNSRecord(w http.ResponseWriter, r *http.Request) {
 			ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 			defer cancel()
 
-			priority := 0
-			if req.Priority != nil {
-				priority = *req.Priority
-			}
-			remoteRecord, err := provider.CreateRecord(ctx, domain.FQDN, dns.Record{
-				Name:     req.Name,
-				Type:     req.RecordType,
-				Value:    req.Value,
-				TTL:      ttl,
-				Priority: priority,
-				Proxied:  req.Proxied,
-			})
+			remoteRecord, err := provider.CreateRecord(ctx, domain.FQDN, req.toRecord(ttl))
 
 			if err != nil {
 				log.Printf("Failed to create record on provider: %v", err)
@@ -826,13 +1158,15 @@ func (h *DNSHandler) CreateDNSRecord(w http.ResponseWriter, r *http.Request) {
 		INSERT INTO dns_records (
 			dns_domain_id, name, record_type, value, ttl, priority, proxied,
 			http_incoming_port, http_outgoing_port, https_incoming_port, https_outgoing_port,
-			sync_status, sync_error, remote_record_id, last_synced_at
+			sync_status, sync_error, remote_record_id, last_synced_at,
+			weight, port, flags, tag, tlsa_usage, tlsa_selector, tlsa_matching_type
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, 
-			CASE WHEN $12 = 'synced' THEN NOW() ELSE NULL END)
+			CASE WHEN $12 = 'synced' THEN NOW() ELSE NULL END,
+			$15, $16, $17, $18, $19, $20, $21)
 		RETURNING *
-	`, domainID, req.Name, req.RecordType, req.Value, ttl, req.Priority, req.Proxied,
+	`, append([]interface{}{domainID, req.Name, req.RecordType, req.Value, ttl, req.Priority, req.Proxied,
 		req.HTTPIncomingPort, req.HTTPOutgoingPort, req.HTTPSIncomingPort, req.HTTPSOutgoingPort,
-		syncStatus, nullString(syncError), nullString(remoteRecordID))
+		syncStatus, nullString(syncError), nullString(remoteRecordID)}, typeFields(req.toRecord(ttl))...)...)
 	if err != nil {
 		log.Printf("Failed to create DNS record: %v", err)
 		http.Error(w, "Failed to create record (may already exist)", http.StatusInternalServerError)
@@ -883,16 +1217,23 @@ func (h *DNSHandler) UpdateDNSRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := dns.ValidateRecord(req.toRecord(req.TTL)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	_, err = h.db.Exec(`
 		UPDATE dns_records SET
 			name = $1, record_type = $2, value = $3, ttl = $4, priority = $5, proxied = $6,
 			http_incoming_port = $7, http_outgoing_port = $8, 
 			https_incoming_port = $9, https_outgoing_port = $10,
+			weight = $11, port = $12, flags = $13, tag = $14,
+			tlsa_usage = $15, tlsa_selector = $16, tlsa_matching_type = $17,
 			sync_status = 'pending', updated_at = NOW()
-		WHERE id = $11 AND dns_domain_id = $12
-	`, req.Name, req.RecordType, req.Value, req.TTL, req.Priority, req.Proxied,
-		req.HTTPIncomingPort, req.HTTPOutgoingPort, req.HTTPSIncomingPort, req.HTTPSOutgoingPort,
-		recordID, domainID)
+		WHERE id = $18 AND dns_domain_id = $19
+	`, append(append([]interface{}{req.Name, req.RecordType, req.Value, req.TTL, req.Priority, req.Proxied,
+		req.HTTPIncomingPort, req.HTTPOutgoingPort, req.HTTPSIncomingPort, req.HTTPSOutgoingPort},
+		typeFields(req.toRecord(req.TTL))...), recordID, domainID)...)
 	if err != nil {
 		log.Printf("Failed to update DNS record: %v", err)
 		http.Error(w, "Failed to update record", http.StatusInternalServerError)
@@ -979,19 +1320,7 @@ func (h *DNSHandler) CompareDNSRecords(w http.ResponseWriter, r *http.Request) {
 	// Convert to dns.Record
 	localRecords := make([]dns.Record, len(localDBRecords))
 	for i, r := range localDBRecords {
-		priority := 0
-		if r.Priority != nil {
-			priority = *r.Priority
-		}
-		localRecords[i] = dns.Record{
-			ID:       r.ID.String(),
-			Name:     r.Name,
-			Type:     r.RecordType,
-			Value:    r.Value,
-			TTL:      r.TTL,
-			Priority: priority,
-			Proxied:  r.Proxied,
-		}
+		localRecords[i] = services.DNSRecordFromModel(r)
 	}
 
 	// Get remote records
@@ -1035,6 +1364,38 @@ func (h *DNSHandler) CompareDNSRecords(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// CheckDNSDrift runs drift detection for one domain immediately and returns the stored result
+func (h *DNSHandler) CheckDNSDrift(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return
+	}
+
+	var domain models.DNSManagedDomain
+	err = h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
+	if err != nil {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	if domain.DNSAccountID == nil {
+		http.Error(w, "No DNS account configured", http.StatusBadRequest)
+		return
+	}
+
+	h.driftDetector.CheckDomain(domain)
+
+	h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1", domainID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domain)
+}
+
 // ApplyDNSToRemote pushes local records to remote provider
 func (h *DNSHandler) ApplyDNSToRemote(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("claims").(*auth.Claims)
@@ -1068,19 +1429,7 @@ func (h *DNSHandler) ApplyDNSToRemote(w http.ResponseWriter, r *http.Request) {
 
 	localRecords := make([]dns.Record, len(localDBRecords))
 	for i, r := range localDBRecords {
-		priority := 0
-		if r.Priority != nil {
-			priority = *r.Priority
-		}
-		localRecords[i] = dns.Record{
-			ID:       r.ID.String(),
-			Name:     r.Name,
-			Type:     r.RecordType,
-			Value:    r.Value,
-			TTL:      r.TTL,
-			Priority: priority,
-			Proxied:  r.Proxied,
-		}
+		localRecords[i] = services.DNSRecordFromModel(r)
 	}
 
 	apiID := ""
@@ -1100,15 +1449,8 @@ func (h *DNSHandler) ApplyDNSToRemote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update sync status
-	now := time.Now()
-	for _, r := range localDBRecords {
-		h.db.Exec(`
-			UPDATE dns_records 
-			SET sync_status = 'synced', last_synced_at = $1 
-			WHERE id = $2
-		`, now, r.ID)
-	}
+	// Update sync status per record from the changeset outcome
+	services.UpdateRecordSyncStatus(h.db, localDBRecords, result)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
@@ -1171,9 +1513,11 @@ func (h *DNSHandler) ImportDNSFromRemote(w http.ResponseWriter, r *http.Request)
 		_, err := h.db.Exec(`
 			INSERT INTO dns_records (
 				dns_domain_id, name, record_type, value, ttl, priority, proxied,
-				remote_record_id, sync_status, last_synced_at
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'synced', $9)
-		`, domainID, r.Name, r.Type, r.Value, r.TTL, priority, r.Proxied, r.ID, now)
+				remote_record_id, sync_status, last_synced_at,
+				weight, port, flags, tag, tlsa_usage, tlsa_selector, tlsa_matching_type
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'synced', $9, $10, $11, $12, $13, $14, $15, $16)
+		`, append([]interface{}{domainID, r.Name, r.Type, r.Value, r.TTL, priority, r.Proxied, r.ID, now},
+			typeFields(r)...)...)
 		if err != nil {
 			log.Printf("Failed to import record %s: %v", r.Name, err)
 			continue
@@ -1188,6 +1532,204 @@ func (h *DNSHandler) ImportDNSFromRemote(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// ==================== Zone File ====================
+
+// ExportZoneFile renders the local records as an RFC 1035 master file
+func (h *DNSHandler) ExportZoneFile(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return
+	}
+
+	var domain models.DNSManagedDomain
+	err = h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
+	if err != nil {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	var dbRecords []models.DNSRecord
+	h.db.Select(&dbRecords, "SELECT * FROM dns_records WHERE dns_domain_id = $1", domainID)
+
+	records := make([]dns.Record, len(dbRecords))
+	for i, r := range dbRecords {
+		records[i] = services.DNSRecordFromModel(r)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", domain.FQDN+".zone"))
+	w.Write([]byte(dns.RenderZoneFile(domain.FQDN, records)))
+}
+
+type ImportZoneFileRequest struct {
+	Content string `json:"content"`
+	Apply   bool   `json:"apply"` // false only previews the diff
+}
+
+// ImportZoneFileResult describes a zone file import. In Diff, "created" records are
+// added locally, "deleted" records are removed and "conflicts" are updated in place.
+type ImportZoneFileResult struct {
+	Records  []dns.Record    `json:"records"`
+	Diff     *dns.SyncResult `json:"diff"`
+	Warnings []string        `json:"warnings"`
+	Applied  bool            `json:"applied"`
+}
+
+// ImportZoneFile parses a BIND zone file and diffs it against the local records,
+// replacing the static records with its contents when apply is set
+func (h *DNSHandler) ImportZoneFile(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ImportZoneFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+
+	var domain models.DNSManagedDomain
+	err = h.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
+	if err != nil {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	parsed, warnings, err := dns.ParseZoneFile(domain.FQDN, req.Content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dbRecords []models.DNSRecord
+	h.db.Select(&dbRecords, "SELECT * FROM dns_records WHERE dns_domain_id = $1", domainID)
+
+	// Dynamic records belong to passthrough pools and are left alone, including the
+	// extra answers of multi-answer pools
+	dynamic := make(map[string]bool)
+	for _, r := range dbRecords {
+		if r.Mode == "dynamic" || r.Mode == "dynamic_extra" {
+			dynamic[r.Name+"/"+r.RecordType] = true
+		}
+	}
+	var current []dns.Record
+	for _, r := range dbRecords {
+		if dynamic[r.Name+"/"+r.RecordType] {
+			continue
+		}
+		current = append(current, services.DNSRecordFromModel(r))
+	}
+
+	records := []dns.Record{}
+	for _, rec := range parsed {
+		if dynamic[rec.Name+"/"+rec.Type] {
+			warnings = append(warnings, fmt.Sprintf("skipped %s %s: managed by a passthrough pool", rec.Name, rec.Type))
+			continue
+		}
+		if err := dns.ValidateRecord(rec); err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipped %s %s: %v", rec.Name, rec.Type, err))
+			continue
+		}
+		// Index-based IDs map diff entries back to the parsed record
+		rec.ID = fmt.Sprintf("zonefile:%d", len(records))
+		records = append(records, rec)
+	}
+
+	result := ImportZoneFileResult{
+		Records:  records,
+		Diff:     h.syncService.Compare(records, current),
+		Warnings: warnings,
+	}
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+
+	if req.Apply && !result.Diff.InSync {
+		if err := h.applyZoneFileDiff(domainID, records, result.Diff); err != nil {
+			log.Printf("Failed to apply zone file: %v", err)
+			http.Error(w, "Failed to apply zone file: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Applied = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// applyZoneFileDiff writes a zone file diff to the local records in one transaction.
+// Changed records are marked pending so the next sync pushes them to the provider.
+func (h *DNSHandler) applyZoneFileDiff(domainID uuid.UUID, records []dns.Record, diff *dns.SyncResult) error {
+	byID := make(map[string]dns.Record, len(records))
+	for _, rec := range records {
+		byID[rec.ID] = rec
+	}
+
+	tx, err := h.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rec := range diff.Deleted {
+		if _, err := tx.Exec("DELETE FROM dns_records WHERE id = $1 AND dns_domain_id = $2", rec.ID, domainID); err != nil {
+			return fmt.Errorf("delete %s %s: %w", rec.Name, rec.Type, err)
+		}
+	}
+
+	for _, c := range diff.Conflicts {
+		rec := byID[c.LocalID]
+		_, err := tx.Exec(`
+			UPDATE dns_records SET
+				value = $1, ttl = $2, priority = $3,
+				weight = $4, port = $5, flags = $6, tag = $7,
+				tlsa_usage = $8, tlsa_selector = $9, tlsa_matching_type = $10,
+				sync_status = 'pending', updated_at = NOW()
+			WHERE id = $11 AND dns_domain_id = $12
+		`, append(append([]interface{}{rec.Value, rec.TTL, zonePriority(rec)}, typeFields(rec)...), c.RemoteID, domainID)...)
+		if err != nil {
+			return fmt.Errorf("update %s %s: %w", rec.Name, rec.Type, err)
+		}
+	}
+
+	for _, rec := range diff.Created {
+		_, err := tx.Exec(`
+			INSERT INTO dns_records (
+				dns_domain_id, name, record_type, value, ttl, priority, proxied, sync_status,
+				weight, port, flags, tag, tlsa_usage, tlsa_selector, tlsa_matching_type
+			) VALUES ($1, $2, $3, $4, $5, $6, false, 'pending', $7, $8, $9, $10, $11, $12, $13)
+		`, append([]interface{}{domainID, rec.Name, rec.Type, rec.Value, rec.TTL, zonePriority(rec)}, typeFields(rec)...)...)
+		if err != nil {
+			return fmt.Errorf("insert %s %s: %w", rec.Name, rec.Type, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// zonePriority returns the priority column value (only MX and SRV carry one)
+func zonePriority(r dns.Record) interface{} {
+	if r.Type == "MX" || r.Type == "SRV" {
+		return r.Priority
+	}
+	return nil
+}
+
 // ==================== List Remote Records ====================
 
 // ListRemoteRecords fetches all records directly from the DNS provider
@@ -1252,6 +1794,46 @@ type DNSLookupResult struct {
 	Error   string   `json:"error,omitempty"`
 }
 
+// CheckPropagation queries every authoritative nameserver of the domain directly and
+// reports per-server SOA serials and answers for one record
+func (h *DNSHandler) CheckPropagation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	vars := mux.Vars(r)
+	domainID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid domain ID", http.StatusBadRequest)
+		return
+	}
+
+	var fqdn string
+	err = h.db.Get(&fqdn, "SELECT fqdn FROM dns_managed_domains WHERE id = $1 AND owner_id = $2", domainID, userID)
+	if err != nil {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	// name defaults to the apex, type to A; value is the answer every server should give
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "@"
+	}
+	recordType := r.URL.Query().Get("type")
+	if recordType == "" {
+		recordType = "A"
+	}
+	expected := r.URL.Query().Get("value")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	result := dns.CheckPropagation(ctx, fqdn, name, recordType, expected)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // LookupDNS performs a public DNS lookup for debugging
 func (h *DNSHandler) LookupDNS(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("claims").(*auth.Claims)
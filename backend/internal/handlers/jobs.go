@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"configuratix/backend/internal/auth"
 	"configuratix/backend/internal/database"
 	"configuratix/backend/internal/models"
+	"configuratix/backend/internal/services"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -79,9 +85,10 @@ func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 }
 
 type CreateJobRequest struct {
-	AgentID uuid.UUID       `json:"agent_id"`
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload"`
+	AgentID     uuid.UUID       `json:"agent_id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	MaxAttempts int             `json:"max_attempts"` // Optional, default 3
 }
 
 // CreateJob creates a new job for an agent
@@ -96,13 +103,20 @@ func (h *JobsHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Job type is required", http.StatusBadRequest)
 		return
 	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = 3
+	}
+	if req.MaxAttempts < 1 || req.MaxAttempts > 10 {
+		http.Error(w, "max_attempts must be between 1 and 10", http.StatusBadRequest)
+		return
+	}
 
 	var job models.Job
 	err := h.db.Get(&job, `
-		INSERT INTO jobs (agent_id, type, payload_json, status)
-		VALUES ($1, $2, $3, 'pending')
+		INSERT INTO jobs (agent_id, type, payload_json, status, max_attempts)
+		VALUES ($1, $2, $3, 'pending', $4)
 		RETURNING *
-	`, req.AgentID, req.Type, req.Payload)
+	`, req.AgentID, req.Type, req.Payload, req.MaxAttempts)
 	if err != nil {
 		log.Printf("Failed to create job: %v", err)
 		http.Error(w, "Failed to create job", http.StatusInternalServerError)
@@ -114,6 +128,209 @@ func (h *JobsHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(job)
 }
 
+// CancelJob cancels a pending or running job. The agent stops a running job at its
+// next lease renewal.
+func (h *JobsHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value("claims").(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	if !h.verifyJobAccess(w, id, claims) {
+		return
+	}
+
+	cancelled, err := services.CancelJob(h.db, id, "Cancelled by "+claims.Email)
+	if err != nil {
+		log.Printf("Failed to cancel job %s: %v", id, err)
+		http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		http.Error(w, "Job already finished", http.StatusConflict)
+		return
+	}
+
+	var job models.Job
+	if err := h.db.Get(&job, "SELECT * FROM jobs WHERE id = $1", id); err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetJobLogs returns the output streamed for a job. Query: after (chunk ID cursor),
+// limit. With follow=true or Accept: text/event-stream the output is tailed as
+// server-sent events until the job finishes.
+func (h *JobsHandler) GetJobLogs(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value("claims").(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	if !h.verifyJobAccess(w, id, claims) {
+		return
+	}
+
+	after, _ := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		after, _ = strconv.ParseInt(lastID, 10, 64)
+	}
+
+	if r.URL.Query().Get("follow") == "true" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		h.tailJobLogs(w, r, id, after)
+		return
+	}
+
+	limit := 500
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 2000 {
+		limit = v
+	}
+
+	var job models.Job
+	if err := h.db.Get(&job, "SELECT * FROM jobs WHERE id = $1", id); err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	chunks, err := services.ListJobLogChunks(h.db, id, after, limit)
+	if err != nil {
+		log.Printf("Failed to list logs of job %s: %v", id, err)
+		http.Error(w, "Failed to get job logs", http.StatusInternalServerError)
+		return
+	}
+	if chunks == nil {
+		chunks = []models.JobLogChunk{}
+	}
+	next := after
+	if len(chunks) > 0 {
+		next = chunks[len(chunks)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":            id,
+		"status":            job.Status,
+		"chunks":            chunks,
+		"next_after":        next,
+		"log_bytes":         job.LogBytes,
+		"log_dropped_bytes": job.LogDroppedBytes,
+	})
+}
+
+// tailJobLogs streams new chunks as server-sent events. Chunks are polled from the
+// database, so any backend instance can serve the tail.
+func (h *JobsHandler) tailJobLogs(w http.ResponseWriter, r *http.Request, id uuid.UUID, after int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	flusher.Flush()
+
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+	lastWrite := time.Now()
+
+	for {
+		// Read the status before the chunks, so output written just before the job
+		// finished is sent before the end event
+		var status string
+		if err := h.db.Get(&status, "SELECT status FROM jobs WHERE id = $1", id); err != nil {
+			fmt.Fprintf(w, "event: error\ndata: {\"error\":\"job not found\"}\n\n")
+			flusher.Flush()
+			return
+		}
+
+		chunks, err := services.ListJobLogChunks(h.db, id, after, 500)
+		if err != nil {
+			log.Printf("Failed to tail logs of job %s: %v", id, err)
+			return
+		}
+		for _, chunk := range chunks {
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "id: %d\nevent: chunk\ndata: %s\n\n", chunk.ID, data)
+			after = chunk.ID
+		}
+
+		final := status == "completed" || status == "failed" || status == "cancelled"
+		if final && len(chunks) == 0 {
+			data, _ := json.Marshal(map[string]string{"status": status})
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+
+		if len(chunks) > 0 {
+			flusher.Flush()
+			lastWrite = time.Now()
+			if len(chunks) == 500 {
+				continue
+			}
+		} else if time.Since(lastWrite) > 15*time.Second {
+			// Keep proxies from closing an idle stream
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-poll.C:
+		}
+	}
+}
+
+// verifyJobAccess checks that the user can manage the machine the job runs on
+func (h *JobsHandler) verifyJobAccess(w http.ResponseWriter, jobID uuid.UUID, claims *auth.Claims) bool {
+	var machineID uuid.UUID
+	err := h.db.Get(&machineID, `
+		SELECT m.id FROM jobs j JOIN machines m ON m.agent_id = j.agent_id WHERE j.id = $1
+	`, jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return false
+	}
+	if claims.IsSuperAdmin() {
+		return true
+	}
+
+	var hasAccess bool
+	h.db.Get(&hasAccess, `
+		SELECT EXISTS(
+			SELECT 1 FROM machines WHERE id = $1 AND owner_id = $2
+			UNION
+			SELECT 1 FROM machines m JOIN projects p ON m.project_id = p.id WHERE m.id = $1 AND p.owner_id = $2
+			UNION
+			SELECT 1 FROM machines m JOIN project_members pm ON m.project_id = pm.project_id WHERE m.id = $1 AND pm.user_id = $2 AND pm.status = 'approved'
+		)
+	`, machineID, claims.UserID)
+	if !hasAccess {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // Helper function to create a job (used internally)
 func CreateJobForAgent(db *database.DB, agentID uuid.UUID, jobType string, payload interface{}) (*models.Job, error) {
 	payloadBytes, err := json.Marshal(payload)
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -40,6 +41,7 @@ type MachineWithDetails struct {
 	IPAddress       *string         `db:"ip_address" json:"ip_address"`
 	DetectedIPs     json.RawMessage `db:"detected_ips" json:"detected_ips"`
 	PrimaryIP       *string         `db:"primary_ip" json:"primary_ip"`
+	PrimaryIPv6     *string         `db:"primary_ipv6" json:"primary_ipv6"`
 	UbuntuVersion   *string         `db:"ubuntu_version" json:"ubuntu_version"`
 	NotesMD         *string    `db:"notes_md" json:"notes_md"`
 	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
@@ -221,16 +223,26 @@ func (h *MachinesHandler) UpdateMachine(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		Title     *string    `json:"title"`
-		ProjectID *uuid.UUID `json:"project_id"`
-		NotesMD   *string    `json:"notes_md"`
-		PrimaryIP *string    `json:"primary_ip"`
+		Title       *string    `json:"title"`
+		ProjectID   *uuid.UUID `json:"project_id"`
+		NotesMD     *string    `json:"notes_md"`
+		PrimaryIP   *string    `json:"primary_ip"`
+		PrimaryIPv6 *string    `json:"primary_ipv6"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// Empty primary_ipv6 clears the selection (no AAAA answers for this machine)
+	if req.PrimaryIPv6 != nil && *req.PrimaryIPv6 != "" {
+		ip := net.ParseIP(*req.PrimaryIPv6)
+		if ip == nil || ip.To4() != nil {
+			http.Error(w, "primary_ipv6 must be an IPv6 address", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// If linking to a project, verify user can manage that project
 	if req.ProjectID != nil {
 		if !h.canLinkToProject(userID, *req.ProjectID, claims.IsSuperAdmin()) {
@@ -245,9 +257,10 @@ func (h *MachinesHandler) UpdateMachine(w http.ResponseWriter, r *http.Request)
 			project_id = $2,
 			notes_md = COALESCE($3, notes_md),
 			primary_ip = COALESCE($4, primary_ip),
+			primary_ipv6 = CASE WHEN $6::text IS NULL THEN primary_ipv6 ELSE NULLIF($6, '') END,
 			updated_at = NOW()
 		WHERE id = $5
-	`, req.Title, req.ProjectID, req.NotesMD, req.PrimaryIP, machineID)
+	`, req.Title, req.ProjectID, req.NotesMD, req.PrimaryIP, machineID, req.PrimaryIPv6)
 	if err != nil {
 		http.Error(w, "Failed to update machine", http.StatusInternalServerError)
 		return
@@ -929,7 +942,7 @@ func (h *MachinesHandler) ExecTerminalCommand(w http.ResponseWriter, r *http.Req
 		if err != nil {
 			break
 		}
-		if job.Status == "completed" || job.Status == "failed" {
+		if job.Status == "completed" || job.Status == "failed" || job.Status == "cancelled" {
 			break
 		}
 	}
@@ -939,7 +952,9 @@ func (h *MachinesHandler) ExecTerminalCommand(w http.ResponseWriter, r *http.Req
 	if job.Logs != nil {
 		output = *job.Logs
 	}
-	if job.Status == "failed" {
+	if result := job.ParseResult(); result != nil && len(result.Steps) > 0 {
+		exitCode = result.Steps[len(result.Steps)-1].ExitCode
+	} else if job.Status == "failed" {
 		exitCode = 1
 	}
 
@@ -1268,7 +1283,7 @@ func (h *MachinesHandler) RunSpeedTestAndWait(w http.ResponseWriter, r *http.Req
 		if err != nil {
 			break
 		}
-		if job.Status == "completed" || job.Status == "failed" {
+		if job.Status == "completed" || job.Status == "failed" || job.Status == "cancelled" {
 			break
 		}
 	}
@@ -1284,6 +1299,10 @@ func (h *MachinesHandler) RunSpeedTestAndWait(w http.ResponseWriter, r *http.Req
 	if job.Logs != nil {
 		result["logs"] = *job.Logs
 	}
+	// Measured values declared by the template (e.g. download_mbps), from agents 0.8.0+
+	if jobResult := job.ParseResult(); jobResult != nil {
+		result["outputs"] = jobResult.Outputs
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
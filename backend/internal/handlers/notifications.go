@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"configuratix/backend/internal/auth"
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+	"configuratix/backend/internal/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// redactedSecret replaces secret config values in responses. Sending it back on
+// update keeps the stored value.
+const redactedSecret = "********"
+
+type NotificationsHandler struct {
+	db     *database.DB
+	client *http.Client
+}
+
+func NewNotificationsHandler(db *database.DB) *NotificationsHandler {
+	return &NotificationsHandler{db: db, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// ==================== Channels ====================
+
+type NotificationChannelRequest struct {
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config"`
+	IsEnabled *bool           `json:"is_enabled"`
+}
+
+// ListChannels returns the user's notification channels
+func (h *NotificationsHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	var channels []models.NotificationChannel
+	err := h.db.Select(&channels, "SELECT * FROM notification_channels WHERE owner_id = $1 ORDER BY name", userID)
+	if err != nil {
+		http.Error(w, "Failed to list channels", http.StatusInternalServerError)
+		return
+	}
+	if channels == nil {
+		channels = []models.NotificationChannel{}
+	}
+	for i := range channels {
+		channels[i].Config = redactChannelConfig(channels[i].Type, channels[i].Config)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
+
+// CreateChannel creates a notification channel
+func (h *NotificationsHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	var req NotificationChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateNotificationConfig(req.Type, req.Config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	isEnabled := true
+	if req.IsEnabled != nil {
+		isEnabled = *req.IsEnabled
+	}
+
+	var channel models.NotificationChannel
+	err := h.db.Get(&channel, `
+		INSERT INTO notification_channels (owner_id, name, type, config, is_enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	`, userID, req.Name, req.Type, req.Config, isEnabled)
+	if err != nil {
+		http.Error(w, "Failed to create channel", http.StatusInternalServerError)
+		return
+	}
+	channel.Config = redactChannelConfig(channel.Type, channel.Config)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(channel)
+}
+
+// UpdateChannel updates a channel; redacted secrets in the config keep their stored value
+func (h *NotificationsHandler) UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.getChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var req NotificationChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" {
+		channel.Name = req.Name
+	}
+	if req.IsEnabled != nil {
+		channel.IsEnabled = *req.IsEnabled
+	}
+	if len(req.Config) > 0 {
+		config, err := mergeChannelConfig(channel.Type, channel.Config, req.Config)
+		if err != nil {
+			http.Error(w, "Invalid config", http.StatusBadRequest)
+			return
+		}
+		if err := services.ValidateNotificationConfig(channel.Type, config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		channel.Config = config
+	}
+
+	err := h.db.Get(&channel, `
+		UPDATE notification_channels SET name = $1, config = $2, is_enabled = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING *
+	`, channel.Name, channel.Config, channel.IsEnabled, channel.ID)
+	if err != nil {
+		http.Error(w, "Failed to update channel", http.StatusInternalServerError)
+		return
+	}
+	channel.Config = redactChannelConfig(channel.Type, channel.Config)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channel)
+}
+
+// DeleteChannel deletes a channel with its subscriptions and delivery log
+func (h *NotificationsHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.getChannel(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM notification_channels WHERE id = $1", channel.ID); err != nil {
+		http.Error(w, "Failed to delete channel", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestChannel sends a test notification right away and reports the result
+func (h *NotificationsHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.getChannel(w, r)
+	if !ok {
+		return
+	}
+
+	event := services.NotificationEvent{
+		Type:       services.EventTest,
+		Summary:    "Test notification from Configuratix channel " + channel.Name,
+		OccurredAt: time.Now().UTC(),
+		Data:       map[string]interface{}{"channel_id": channel.ID},
+	}
+	payload, _ := json.Marshal(event)
+
+	// Logged like any other delivery so failures show up in the delivery log
+	var deliveryID uuid.UUID
+	h.db.Get(&deliveryID, `
+		INSERT INTO notification_deliveries (channel_id, event_type, payload, status, attempts)
+		VALUES ($1, $2, $3, 'sending', 1)
+		RETURNING id
+	`, channel.ID, event.Type, payload)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	status, sendErr := services.SendNotification(ctx, h.client, channel, deliveryID, event.Type, payload)
+
+	var responseStatus *int
+	if status > 0 {
+		responseStatus = &status
+	}
+	result := map[string]interface{}{"success": sendErr == nil, "response_status": responseStatus}
+	if sendErr != nil {
+		result["error"] = sendErr.Error()
+		h.db.Exec(`
+			UPDATE notification_deliveries SET status = 'failed', response_status = $1, last_error = $2, updated_at = NOW()
+			WHERE id = $3
+		`, responseStatus, sendErr.Error(), deliveryID)
+	} else {
+		h.db.Exec(`
+			UPDATE notification_deliveries SET status = 'delivered', response_status = $1, delivered_at = NOW(), updated_at = NOW()
+			WHERE id = $2
+		`, responseStatus, deliveryID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// getChannel loads the channel from the URL, writing an error response if the user doesn't own it
+func (h *NotificationsHandler) getChannel(w http.ResponseWriter, r *http.Request) (models.NotificationChannel, bool) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	var channel models.NotificationChannel
+	channelID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid channel ID", http.StatusBadRequest)
+		return channel, false
+	}
+	if err := h.db.Get(&channel, "SELECT * FROM notification_channels WHERE id = $1 AND owner_id = $2", channelID, userID); err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return channel, false
+	}
+	return channel, true
+}
+
+// redactChannelConfig hides the secret fields of a channel config
+func redactChannelConfig(channelType string, config json.RawMessage) json.RawMessage {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return json.RawMessage("{}")
+	}
+	for _, key := range services.NotificationSecretFields[channelType] {
+		if v, ok := fields[key].(string); ok && v != "" {
+			fields[key] = redactedSecret
+		}
+	}
+	redacted, _ := json.Marshal(fields)
+	return redacted
+}
+
+// mergeChannelConfig takes an updated config, keeping stored secrets that came back redacted
+func mergeChannelConfig(channelType string, stored, updated json.RawMessage) (json.RawMessage, error) {
+	var fields, old map[string]interface{}
+	if err := json.Unmarshal(updated, &fields); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(stored, &old)
+	for _, key := range services.NotificationSecretFields[channelType] {
+		if fields[key] == redactedSecret {
+			fields[key] = old[key]
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// ==================== Subscriptions ====================
+
+type NotificationSubscriptionRequest struct {
+	Scope     string   `json:"scope"` // global, project, pool
+	ProjectID string   `json:"project_id"`
+	PoolType  string   `json:"pool_type"` // record, wildcard
+	PoolID    string   `json:"pool_id"`
+	Events    []string `json:"events"` // Empty = all events
+}
+
+// ListSubscriptions returns the subscriptions of a channel
+func (h *NotificationsHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.getChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var subs []models.NotificationSubscription
+	h.db.Select(&subs, "SELECT * FROM notification_subscriptions WHERE channel_id = $1 ORDER BY created_at", channel.ID)
+	if subs == nil {
+		subs = []models.NotificationSubscription{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// CreateSubscription subscribes a channel to events globally, for a project or for a pool
+func (h *NotificationsHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	channel, ok := h.getChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var req NotificationSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range req.Events {
+		valid := false
+		for _, known := range services.NotificationEvents {
+			if event == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			http.Error(w, "Unknown event: "+event, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+
+	var projectID, poolID *uuid.UUID
+	var poolType *string
+	switch req.Scope {
+	case "global":
+	case "project":
+		id, err := uuid.Parse(req.ProjectID)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		var count int
+		h.db.Get(&count, `
+			SELECT COUNT(*) FROM projects
+			WHERE id = $1 AND (
+				owner_id = $2
+				OR id IN (SELECT project_id FROM project_members WHERE user_id = $2 AND status = 'approved')
+			)
+		`, id, userID)
+		if count == 0 {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		projectID = &id
+	case "pool":
+		id, err := uuid.Parse(req.PoolID)
+		if err != nil {
+			http.Error(w, "Invalid pool ID", http.StatusBadRequest)
+			return
+		}
+		var ownerID uuid.UUID
+		switch req.PoolType {
+		case "record":
+			err = h.db.Get(&ownerID, `
+				SELECT d.owner_id FROM dns_passthrough_pools p
+				JOIN dns_records r ON p.dns_record_id = r.id
+				JOIN dns_managed_domains d ON r.dns_domain_id = d.id
+				WHERE p.id = $1
+			`, id)
+		case "wildcard":
+			err = h.db.Get(&ownerID, `
+				SELECT d.owner_id FROM dns_wildcard_pools p
+				JOIN dns_managed_domains d ON p.dns_domain_id = d.id
+				WHERE p.id = $1
+			`, id)
+		default:
+			http.Error(w, "pool_type must be record or wildcard", http.StatusBadRequest)
+			return
+		}
+		if err != nil || ownerID != userID {
+			http.Error(w, "Pool not found", http.StatusNotFound)
+			return
+		}
+		poolID = &id
+		poolType = &req.PoolType
+	default:
+		http.Error(w, "scope must be global, project or pool", http.StatusBadRequest)
+		return
+	}
+
+	var sub models.NotificationSubscription
+	err := h.db.Get(&sub, `
+		INSERT INTO notification_subscriptions (channel_id, scope, project_id, pool_type, pool_id, events)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`, channel.ID, req.Scope, projectID, poolType, poolID, pq.Array(req.Events))
+	if err != nil {
+		http.Error(w, "Failed to create subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteSubscription removes a subscription from one of the user's channels
+func (h *NotificationsHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	subID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		DELETE FROM notification_subscriptions s
+		USING notification_channels c
+		WHERE s.channel_id = c.id AND s.id = $1 AND c.owner_id = $2
+	`, subID, userID)
+	if err != nil {
+		http.Error(w, "Failed to delete subscription", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ==================== Delivery Log ====================
+
+// ListDeliveries returns the delivery log of the user's channels, newest first
+func (h *NotificationsHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	query := `
+		SELECT d.*, c.name as channel_name, c.type as channel_type
+		FROM notification_deliveries d
+		JOIN notification_channels c ON d.channel_id = c.id
+		WHERE c.owner_id = $1
+	`
+	args := []interface{}{userID}
+	if channelID := r.URL.Query().Get("channel_id"); channelID != "" {
+		id, err := uuid.Parse(channelID)
+		if err != nil {
+			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
+			return
+		}
+		query += " AND d.channel_id = $2"
+		args = append(args, id)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND d.status = $" + strconv.Itoa(len(args)+1)
+		args = append(args, status)
+	}
+	query += " ORDER BY d.created_at DESC LIMIT " + strconv.Itoa(limit)
+
+	var deliveries []models.NotificationDeliveryWithChannel
+	if err := h.db.Select(&deliveries, query, args...); err != nil {
+		http.Error(w, "Failed to list deliveries", http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.NotificationDeliveryWithChannel{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// RetryDelivery requeues a failed delivery for immediate sending
+func (h *NotificationsHandler) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
+
+	deliveryID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE notification_deliveries d
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		FROM notification_channels c
+		WHERE d.channel_id = c.id AND d.id = $1 AND c.owner_id = $2 AND d.status = 'failed'
+	`, deliveryID, userID)
+	if err != nil {
+		http.Error(w, "Failed to retry delivery", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Failed delivery not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "pending"})
+}
@@ -1,19 +1,18 @@
 package handlers
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"log"
-	"math/rand"
 	"net/http"
 	"time"
 
 	"configuratix/backend/internal/auth"
 	"configuratix/backend/internal/database"
-	"configuratix/backend/internal/dns"
 	"configuratix/backend/internal/models"
+	"configuratix/backend/internal/services"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -64,10 +63,16 @@ func (h *PassthroughHandler) GetRecordPool(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Show the next planned rotation even before the scheduler has stored it
+	if pool.NextRotationAt == nil && !pool.IsPaused {
+		pool.NextRotationAt, _ = services.RecordPoolSchedule(pool).NextRotation(pool.LastRotatedAt, time.Now())
+	}
+
 	// Get direct members with machine details
 	var members []models.PassthroughMemberWithMachine
 	h.db.Select(&members, `
-		SELECT pm.*, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen
+		SELECT pm.*, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip,
+			COALESCE(m.primary_ipv6, '') as machine_ipv6, a.last_seen
 		FROM dns_passthrough_members pm
 		JOIN machines m ON pm.machine_id = m.id
 		LEFT JOIN agents a ON m.agent_id = a.id
@@ -75,11 +80,11 @@ func (h *PassthroughHandler) GetRecordPool(w http.ResponseWriter, r *http.Reques
 		ORDER BY pm.priority, COALESCE(NULLIF(m.title, ''), m.hostname)
 	`, pool.ID)
 
-	// Compute online status
+	// Compute online status from probe results when health checks are on, otherwise from the agent heartbeat
+	health := services.MemberHealthMap(h.db, "record", pool.ID, pool.HealthCheckEnabled)
 	for i := range members {
-		if members[i].LastSeen != nil {
-			members[i].IsOnline = time.Since(*members[i].LastSeen) < 5*time.Minute
-		}
+		members[i].Health = health[members[i].MachineID]
+		members[i].IsOnline = services.MemberIsHealthy(members[i].Health, members[i].LastSeen)
 	}
 
 	// Get groups info
@@ -139,8 +144,21 @@ func (h *PassthroughHandler) CreateOrUpdateRecordPool(w http.ResponseWriter, r *
 		ScheduledTimes     []string `json:"scheduled_times"`
 		HealthCheckEnabled bool     `json:"health_check_enabled"`
 		ProxyProtocol      *bool    `json:"proxy_protocol"`       // Send PROXY protocol to backend
+		HealthCheckPath    string   `json:"health_check_path"`    // HTTP probe path on port 80, empty = TCP/TLS only
+		HealthCheckHost    string   `json:"health_check_host"`    // SNI/Host used by probes
+		ConfirmPropagation bool     `json:"confirm_propagation"`  // Wait for all nameservers before advancing the rotation
+		FailoverEnabled    bool     `json:"failover_enabled"`     // Also rotate away from an unhealthy current machine
+		PreferredMachineID *string  `json:"preferred_machine_id"` // Fail back to this machine once it recovers
+		AnswerCount        int      `json:"answer_count"`         // Members published at once (multi strategy)
+		CronExpression     string   `json:"cron_expression"`      // Scheduled mode, standard 5-field cron
+		Timezone           string   `json:"timezone"`             // IANA zone for the schedule, default UTC
+		IPv6Enabled        bool     `json:"ipv6_enabled"`         // Also manage an AAAA record from members' primary IPv6
+		WarmupEnabled      bool     `json:"warmup_enabled"`       // Push and confirm nginx config on the target before flipping DNS
+		WarmupProbe        bool     `json:"warmup_probe"`         // Also probe the target before flipping DNS
+		WarmupTimeoutSeconds int    `json:"warmup_timeout_seconds"`
 		MachineIDs         []string `json:"machine_ids"`
 		GroupIDs           []string `json:"group_ids"` // Machine groups for dynamic membership
+		MemberWeights      map[string]int `json:"member_weights"` // machine_id -> weight (weighted strategy)
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -160,12 +178,53 @@ func (h *PassthroughHandler) CreateOrUpdateRecordPool(w http.ResponseWriter, r *
 	if req.RotationStrategy == "" {
 		req.RotationStrategy = "round_robin"
 	}
+	if !services.IsRotationStrategy(req.RotationStrategy) {
+		http.Error(w, "Invalid rotation strategy", http.StatusBadRequest)
+		return
+	}
+	if req.AnswerCount == 0 {
+		req.AnswerCount = 2
+	}
 	if req.RotationMode == "" {
 		req.RotationMode = "interval"
 	}
 	if req.IntervalMinutes == 0 {
 		req.IntervalMinutes = 60
 	}
+	if req.RotationMode != "interval" && req.RotationMode != "scheduled" && req.RotationMode != "failover" {
+		http.Error(w, "Rotation mode must be interval, scheduled or failover", http.StatusBadRequest)
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.WarmupTimeoutSeconds == 0 {
+		req.WarmupTimeoutSeconds = 300
+	}
+	if req.WarmupTimeoutSeconds < 30 || req.WarmupTimeoutSeconds > 1800 {
+		http.Error(w, "Warmup timeout must be between 30 and 1800 seconds", http.StatusBadRequest)
+		return
+	}
+	schedule := services.RotationSchedule{
+		Mode:            req.RotationMode,
+		IntervalMinutes: req.IntervalMinutes,
+		ScheduledTimes:  req.ScheduledTimes,
+		CronExpression:  req.CronExpression,
+		Timezone:        req.Timezone,
+	}
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var preferredMachineID *uuid.UUID
+	if req.PreferredMachineID != nil && *req.PreferredMachineID != "" {
+		id, err := uuid.Parse(*req.PreferredMachineID)
+		if err != nil {
+			http.Error(w, "Invalid preferred machine ID", http.StatusBadRequest)
+			return
+		}
+		preferredMachineID = &id
+	}
 	// Default proxy_protocol to true if not specified
 	proxyProtocol := true
 	if req.ProxyProtocol != nil {
@@ -178,13 +237,19 @@ func (h *PassthroughHandler) CreateOrUpdateRecordPool(w http.ResponseWriter, r *
 	log.Printf("CreateOrUpdateRecordPool: machine_ids=%v, group_ids=%v, groupIDsArray=%v", 
 		req.MachineIDs, req.GroupIDs, groupIDsArray)
 
+	// Remember whether the pool managed an AAAA record before this update
+	var hadIPv6 bool
+	h.db.Get(&hadIPv6, "SELECT ipv6_enabled FROM dns_passthrough_pools WHERE dns_record_id = $1", recordID)
+
 	// Upsert pool
 	var pool models.PassthroughPool
 	err = h.db.Get(&pool, `
 		INSERT INTO dns_passthrough_pools 
 			(dns_record_id, target_ip, target_port, target_port_http, rotation_strategy, rotation_mode, 
-			 interval_minutes, scheduled_times, health_check_enabled, proxy_protocol, group_ids)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			 interval_minutes, scheduled_times, health_check_enabled, proxy_protocol, group_ids, confirm_propagation,
+			 health_check_path, health_check_host, failover_enabled, preferred_machine_id, answer_count,
+			 cron_expression, timezone, ipv6_enabled, warmup_enabled, warmup_probe, warmup_timeout_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 		ON CONFLICT (dns_record_id) DO UPDATE SET
 			target_ip = EXCLUDED.target_ip,
 			target_port = EXCLUDED.target_port,
@@ -196,10 +261,25 @@ func (h *PassthroughHandler) CreateOrUpdateRecordPool(w http.ResponseWriter, r *
 			health_check_enabled = EXCLUDED.health_check_enabled,
 			proxy_protocol = EXCLUDED.proxy_protocol,
 			group_ids = EXCLUDED.group_ids,
+			confirm_propagation = EXCLUDED.confirm_propagation,
+			health_check_path = EXCLUDED.health_check_path,
+			health_check_host = EXCLUDED.health_check_host,
+			failover_enabled = EXCLUDED.failover_enabled,
+			preferred_machine_id = EXCLUDED.preferred_machine_id,
+			answer_count = EXCLUDED.answer_count,
+			cron_expression = EXCLUDED.cron_expression,
+			timezone = EXCLUDED.timezone,
+			ipv6_enabled = EXCLUDED.ipv6_enabled,
+			warmup_enabled = EXCLUDED.warmup_enabled,
+			warmup_probe = EXCLUDED.warmup_probe,
+			warmup_timeout_seconds = EXCLUDED.warmup_timeout_seconds,
+			next_rotation_at = NULL, -- Recomputed by the scheduler from the new settings
 			updated_at = NOW()
 		RETURNING *
 	`, recordID, req.TargetIP, req.TargetPort, req.TargetPortHTTP, req.RotationStrategy, req.RotationMode,
-		req.IntervalMinutes, scheduledTimesJSON, req.HealthCheckEnabled, proxyProtocol, groupIDsArray)
+		req.IntervalMinutes, scheduledTimesJSON, req.HealthCheckEnabled, proxyProtocol, groupIDsArray, req.ConfirmPropagation,
+		req.HealthCheckPath, req.HealthCheckHost, req.FailoverEnabled, preferredMachineID, req.AnswerCount,
+		req.CronExpression, req.Timezone, req.IPv6Enabled, req.WarmupEnabled, req.WarmupProbe, req.WarmupTimeoutSeconds)
 	if err != nil {
 		log.Printf("Failed to upsert pool: %v", err)
 		http.Error(w, "Failed to save pool", http.StatusInternalServerError)
@@ -221,9 +301,9 @@ func (h *PassthroughHandler) CreateOrUpdateRecordPool(w http.ResponseWriter, r *
 			continue
 		}
 		_, err = h.db.Exec(`
-			INSERT INTO dns_passthrough_members (pool_id, machine_id, priority, is_enabled)
-			VALUES ($1, $2, $3, true)
-		`, pool.ID, machineID, i)
+			INSERT INTO dns_passthrough_members (pool_id, machine_id, priority, weight, is_enabled)
+			VALUES ($1, $2, $3, $4, true)
+		`, pool.ID, machineID, i, memberWeight(req.MemberWeights, machineIDStr))
 		if err != nil {
 			log.Printf("CreateOrUpdateRecordPool: failed to insert member %s: %v", machineID, err)
 		} else {
@@ -268,8 +348,23 @@ func (h *PassthroughHandler) CreateOrUpdateRecordPool(w http.ResponseWriter, r *
 		h.db.Exec("UPDATE dns_passthrough_pools SET current_machine_id = $1 WHERE id = $2", firstMachineID, pool.ID)
 		
 		// Update DNS record to point to this machine (syncs to provider)
-		if err := h.updateDNSRecordToMachine(recordID, firstMachineID, "initial"); err != nil {
-			log.Printf("Failed to update DNS record to first machine: %v", err)
+		var firstIP string
+		h.db.Get(&firstIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", firstMachineID)
+		if ipv4 := services.IPv4Answers([]string{firstIP}); len(ipv4) > 0 {
+			if err := services.PublishRecordAnswers(h.db, recordID, ipv4); err != nil {
+				log.Printf("Failed to update DNS record to first machine: %v", err)
+			}
+		}
+	}
+
+	// Publish the AAAA record when IPv6 is first enabled, or withdraw it once disabled
+	if current := initialMachine(pool.CurrentMachineID, allMachineIDs); pool.IPv6Enabled && current != nil && (!hadIPv6 || pool.CurrentMachineID == nil) {
+		if err := services.PublishRecordIPv6(h.db, recordID, []uuid.UUID{*current}); err != nil {
+			log.Printf("Failed to publish AAAA record: %v", err)
+		}
+	} else if hadIPv6 && !pool.IPv6Enabled {
+		if err := services.PublishRecordIPv6(h.db, recordID, nil); err != nil {
+			log.Printf("Failed to withdraw AAAA record: %v", err)
 		}
 	}
 
@@ -322,8 +417,15 @@ func (h *PassthroughHandler) DeleteRecordPool(w http.ResponseWriter, r *http.Req
 
 	// Delete pool (cascade deletes members)
 	h.db.Exec("DELETE FROM dns_passthrough_pools WHERE dns_record_id = $1", recordID)
+	services.DeletePoolHealth(h.db, "record", pool.ID)
 
-	// Switch record back to static mode
+	// Switch record back to static mode, along with the extra answers of a multi pool
+	h.db.Exec(`
+		UPDATE dns_records e SET mode = 'static'
+		FROM dns_records r
+		WHERE r.id = $1 AND e.dns_domain_id = r.dns_domain_id AND e.name = r.name
+		  AND e.record_type = r.record_type AND e.mode = 'dynamic_extra'
+	`, recordID)
 	h.db.Exec("UPDATE dns_records SET mode = 'static' WHERE id = $1", recordID)
 
 	// Regenerate nginx configs for affected machines (pool is now removed)
@@ -353,22 +455,44 @@ func (h *PassthroughHandler) RotateRecordPool(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	nextMachine, err := h.selectNextMachine(pool.ID, pool.RotationStrategy, pool.CurrentIndex, pool.HealthCheckEnabled, "record")
+	selected, newIndex, err := services.PlanRotation(h.db, services.RecordPoolState(pool), "manual")
 	if err != nil {
-		http.Error(w, "No available machines", http.StatusBadRequest)
+		http.Error(w, "No available machines: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Perform rotation
-	err = h.rotateToMachine(pool.ID, pool.DNSRecordID, nextMachine, "record", "manual")
+	// The warmup can take minutes, so the rotation finishes in the background and the
+	// client polls the journaled operation
+	plan, updateDNS := services.RecordPoolRotation(h.db, h.nginx, pool, selected, newIndex, "manual")
+	operationID, err := services.StartRotation(h.db, plan)
 	if err != nil {
-		log.Printf("Rotation failed: %v", err)
+		if errors.Is(err, services.ErrRotationInProgress) {
+			http.Error(w, "Rotation already in progress", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to start rotation: %v", err)
 		http.Error(w, "Rotation failed", http.StatusInternalServerError)
 		return
 	}
+	go func() {
+		if err := services.ContinueRotation(h.db, operationID, plan, updateDNS); err != nil {
+			log.Printf("Rotation of pool %s failed: %v", pool.ID, err)
+		}
+	}()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "rotated"})
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "rotating", "operation_id": operationID})
+}
+
+// SimulateRecordPool predicts the next rotations of a record pool, optionally with
+// overridden settings, without touching DNS or the pool
+func (h *PassthroughHandler) SimulateRecordPool(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownedRecordPool(w, r)
+	if !ok {
+		return
+	}
+	h.simulate(w, r, services.RecordPoolSimulation(pool))
 }
 
 // PauseRecordPool pauses rotation for a pool
@@ -391,7 +515,7 @@ func (h *PassthroughHandler) ResumeRecordPool(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	h.db.Exec("UPDATE dns_passthrough_pools SET is_paused = false, updated_at = NOW() WHERE id = $1", poolID)
+	h.db.Exec("UPDATE dns_passthrough_pools SET is_paused = false, next_rotation_at = NULL, updated_at = NOW() WHERE id = $1", poolID)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -473,10 +597,15 @@ func (h *PassthroughHandler) GetWildcardPool(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if pool.NextRotationAt == nil && !pool.IsPaused {
+		pool.NextRotationAt, _ = services.WildcardPoolSchedule(pool).NextRotation(pool.LastRotatedAt, time.Now())
+	}
+
 	// Get direct members
 	var members []models.WildcardMemberWithMachine
 	h.db.Select(&members, `
-		SELECT wm.*, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen
+		SELECT wm.*, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip,
+			COALESCE(m.primary_ipv6, '') as machine_ipv6, a.last_seen
 		FROM dns_wildcard_pool_members wm
 		JOIN machines m ON wm.machine_id = m.id
 		LEFT JOIN agents a ON m.agent_id = a.id
@@ -484,10 +613,10 @@ func (h *PassthroughHandler) GetWildcardPool(w http.ResponseWriter, r *http.Requ
 		ORDER BY wm.priority, COALESCE(NULLIF(m.title, ''), m.hostname)
 	`, pool.ID)
 
+	health := services.MemberHealthMap(h.db, "wildcard", pool.ID, pool.HealthCheckEnabled)
 	for i := range members {
-		if members[i].LastSeen != nil {
-			members[i].IsOnline = time.Since(*members[i].LastSeen) < 5*time.Minute
-		}
+		members[i].Health = health[members[i].MachineID]
+		members[i].IsOnline = services.MemberIsHealthy(members[i].Health, members[i].LastSeen)
 	}
 
 	// Get groups info
@@ -544,8 +673,21 @@ func (h *PassthroughHandler) CreateOrUpdateWildcardPool(w http.ResponseWriter, r
 		ScheduledTimes     []string `json:"scheduled_times"`
 		HealthCheckEnabled bool     `json:"health_check_enabled"`
 		ProxyProtocol      *bool    `json:"proxy_protocol"`       // Send PROXY protocol to backend
+		HealthCheckPath    string   `json:"health_check_path"`    // HTTP probe path on port 80, empty = TCP/TLS only
+		HealthCheckHost    string   `json:"health_check_host"`    // SNI/Host used by probes
+		ConfirmPropagation bool     `json:"confirm_propagation"`  // Wait for all nameservers before advancing the rotation
+		FailoverEnabled    bool     `json:"failover_enabled"`     // Also rotate away from an unhealthy current machine
+		PreferredMachineID *string  `json:"preferred_machine_id"` // Fail back to this machine once it recovers
+		AnswerCount        int      `json:"answer_count"`         // Members published at once (multi strategy)
+		CronExpression     string   `json:"cron_expression"`      // Scheduled mode, standard 5-field cron
+		Timezone           string   `json:"timezone"`             // IANA zone for the schedule, default UTC
+		IPv6Enabled        bool     `json:"ipv6_enabled"`         // Also manage an AAAA record from members' primary IPv6
+		WarmupEnabled      bool     `json:"warmup_enabled"`       // Push and confirm nginx config on the target before flipping DNS
+		WarmupProbe        bool     `json:"warmup_probe"`         // Also probe the target before flipping DNS
+		WarmupTimeoutSeconds int    `json:"warmup_timeout_seconds"`
 		MachineIDs         []string `json:"machine_ids"`
 		GroupIDs           []string `json:"group_ids"` // Machine groups for dynamic membership
+		MemberWeights      map[string]int `json:"member_weights"` // machine_id -> weight (weighted strategy)
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -565,12 +707,53 @@ func (h *PassthroughHandler) CreateOrUpdateWildcardPool(w http.ResponseWriter, r
 	if req.RotationStrategy == "" {
 		req.RotationStrategy = "round_robin"
 	}
+	if !services.IsRotationStrategy(req.RotationStrategy) {
+		http.Error(w, "Invalid rotation strategy", http.StatusBadRequest)
+		return
+	}
+	if req.AnswerCount == 0 {
+		req.AnswerCount = 2
+	}
 	if req.RotationMode == "" {
 		req.RotationMode = "interval"
 	}
 	if req.IntervalMinutes == 0 {
 		req.IntervalMinutes = 60
 	}
+	if req.RotationMode != "interval" && req.RotationMode != "scheduled" && req.RotationMode != "failover" {
+		http.Error(w, "Rotation mode must be interval, scheduled or failover", http.StatusBadRequest)
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.WarmupTimeoutSeconds == 0 {
+		req.WarmupTimeoutSeconds = 300
+	}
+	if req.WarmupTimeoutSeconds < 30 || req.WarmupTimeoutSeconds > 1800 {
+		http.Error(w, "Warmup timeout must be between 30 and 1800 seconds", http.StatusBadRequest)
+		return
+	}
+	schedule := services.RotationSchedule{
+		Mode:            req.RotationMode,
+		IntervalMinutes: req.IntervalMinutes,
+		ScheduledTimes:  req.ScheduledTimes,
+		CronExpression:  req.CronExpression,
+		Timezone:        req.Timezone,
+	}
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var preferredMachineID *uuid.UUID
+	if req.PreferredMachineID != nil && *req.PreferredMachineID != "" {
+		id, err := uuid.Parse(*req.PreferredMachineID)
+		if err != nil {
+			http.Error(w, "Invalid preferred machine ID", http.StatusBadRequest)
+			return
+		}
+		preferredMachineID = &id
+	}
 	// Default proxy_protocol to true if not specified
 	proxyProtocolWild := true
 	if req.ProxyProtocol != nil {
@@ -580,12 +763,17 @@ func (h *PassthroughHandler) CreateOrUpdateWildcardPool(w http.ResponseWriter, r
 	scheduledTimesJSON, _ := json.Marshal(req.ScheduledTimes)
 	groupIDsArray := pq.StringArray(req.GroupIDs)
 
+	var hadIPv6 bool
+	h.db.Get(&hadIPv6, "SELECT ipv6_enabled FROM dns_wildcard_pools WHERE dns_domain_id = $1", domainID)
+
 	var pool models.WildcardPool
 	err = h.db.Get(&pool, `
 		INSERT INTO dns_wildcard_pools 
 			(dns_domain_id, include_root, target_ip, target_port, target_port_http, rotation_strategy, 
-			 rotation_mode, interval_minutes, scheduled_times, health_check_enabled, proxy_protocol, group_ids)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			 rotation_mode, interval_minutes, scheduled_times, health_check_enabled, proxy_protocol, group_ids, confirm_propagation,
+			 health_check_path, health_check_host, failover_enabled, preferred_machine_id, answer_count,
+			 cron_expression, timezone, ipv6_enabled, warmup_enabled, warmup_probe, warmup_timeout_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
 		ON CONFLICT (dns_domain_id) DO UPDATE SET
 			include_root = EXCLUDED.include_root,
 			target_ip = EXCLUDED.target_ip,
@@ -598,10 +786,25 @@ func (h *PassthroughHandler) CreateOrUpdateWildcardPool(w http.ResponseWriter, r
 			health_check_enabled = EXCLUDED.health_check_enabled,
 			proxy_protocol = EXCLUDED.proxy_protocol,
 			group_ids = EXCLUDED.group_ids,
+			confirm_propagation = EXCLUDED.confirm_propagation,
+			health_check_path = EXCLUDED.health_check_path,
+			health_check_host = EXCLUDED.health_check_host,
+			failover_enabled = EXCLUDED.failover_enabled,
+			preferred_machine_id = EXCLUDED.preferred_machine_id,
+			answer_count = EXCLUDED.answer_count,
+			cron_expression = EXCLUDED.cron_expression,
+			timezone = EXCLUDED.timezone,
+			ipv6_enabled = EXCLUDED.ipv6_enabled,
+			warmup_enabled = EXCLUDED.warmup_enabled,
+			warmup_probe = EXCLUDED.warmup_probe,
+			warmup_timeout_seconds = EXCLUDED.warmup_timeout_seconds,
+			next_rotation_at = NULL, -- Recomputed by the scheduler from the new settings
 			updated_at = NOW()
 		RETURNING *
 	`, domainID, req.IncludeRoot, req.TargetIP, req.TargetPort, req.TargetPortHTTP, req.RotationStrategy,
-		req.RotationMode, req.IntervalMinutes, scheduledTimesJSON, req.HealthCheckEnabled, proxyProtocolWild, groupIDsArray)
+		req.RotationMode, req.IntervalMinutes, scheduledTimesJSON, req.HealthCheckEnabled, proxyProtocolWild, groupIDsArray, req.ConfirmPropagation,
+		req.HealthCheckPath, req.HealthCheckHost, req.FailoverEnabled, preferredMachineID, req.AnswerCount,
+		req.CronExpression, req.Timezone, req.IPv6Enabled, req.WarmupEnabled, req.WarmupProbe, req.WarmupTimeoutSeconds)
 	if err != nil {
 		log.Printf("Failed to upsert wildcard pool: %v", err)
 		http.Error(w, "Failed to save pool", http.StatusInternalServerError)
@@ -620,9 +823,9 @@ func (h *PassthroughHandler) CreateOrUpdateWildcardPool(w http.ResponseWriter, r
 			continue
 		}
 		h.db.Exec(`
-			INSERT INTO dns_wildcard_pool_members (pool_id, machine_id, priority, is_enabled)
-			VALUES ($1, $2, $3, true)
-		`, pool.ID, machineID, i)
+			INSERT INTO dns_wildcard_pool_members (pool_id, machine_id, priority, weight, is_enabled)
+			VALUES ($1, $2, $3, $4, true)
+		`, pool.ID, machineID, i, memberWeight(req.MemberWeights, machineIDStr))
 	}
 
 	// Collect all machines (direct + from groups) to select first one
@@ -662,8 +865,22 @@ func (h *PassthroughHandler) CreateOrUpdateWildcardPool(w http.ResponseWriter, r
 		h.db.Exec("UPDATE dns_wildcard_pools SET current_machine_id = $1 WHERE id = $2", firstMachineID, pool.ID)
 		
 		// Update wildcard DNS record
-		if err := h.updateWildcardDNS(domainID, firstMachineID, pool.IncludeRoot, "initial"); err != nil {
-			log.Printf("Failed to update wildcard DNS to first machine: %v", err)
+		var firstIP string
+		h.db.Get(&firstIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", firstMachineID)
+		if ipv4 := services.IPv4Answers([]string{firstIP}); len(ipv4) > 0 {
+			if err := services.PublishWildcardAnswers(h.db, domainID, ipv4, pool.IncludeRoot); err != nil {
+				log.Printf("Failed to update wildcard DNS to first machine: %v", err)
+			}
+		}
+	}
+
+	if current := initialMachine(pool.CurrentMachineID, allMachineIDs); pool.IPv6Enabled && current != nil && (!hadIPv6 || pool.CurrentMachineID == nil) {
+		if err := services.PublishWildcardIPv6(h.db, domainID, []uuid.UUID{*current}, pool.IncludeRoot); err != nil {
+			log.Printf("Failed to publish wildcard AAAA records: %v", err)
+		}
+	} else if hadIPv6 && !pool.IPv6Enabled {
+		if err := services.PublishWildcardIPv6(h.db, domainID, nil, pool.IncludeRoot); err != nil {
+			log.Printf("Failed to withdraw wildcard AAAA records: %v", err)
 		}
 	}
 
@@ -715,6 +932,7 @@ func (h *PassthroughHandler) DeleteWildcardPool(w http.ResponseWriter, r *http.R
 	}
 
 	h.db.Exec("DELETE FROM dns_wildcard_pools WHERE dns_domain_id = $1", domainID)
+	services.DeletePoolHealth(h.db, "wildcard", pool.ID)
 	h.db.Exec("UPDATE dns_managed_domains SET proxy_mode = 'separate' WHERE id = $1", domainID)
 
 	// Regenerate nginx configs for affected machines (pool is now removed)
@@ -744,22 +962,44 @@ func (h *PassthroughHandler) RotateWildcardPool(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	nextMachine, err := h.selectNextMachineWildcard(pool.ID, pool.RotationStrategy, pool.CurrentIndex, pool.HealthCheckEnabled)
+	selected, newIndex, err := services.PlanRotation(h.db, services.WildcardPoolState(pool), "manual")
 	if err != nil {
-		http.Error(w, "No available machines", http.StatusBadRequest)
+		http.Error(w, "No available machines: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Perform rotation
-	err = h.rotateWildcardToMachine(pool.ID, pool.DNSDomainID, nextMachine, pool.IncludeRoot, "manual")
+	// The warmup can take minutes, so the rotation finishes in the background and the
+	// client polls the journaled operation
+	plan, updateDNS := services.WildcardPoolRotation(h.db, h.nginx, pool, selected, newIndex, "manual")
+	operationID, err := services.StartRotation(h.db, plan)
 	if err != nil {
-		log.Printf("Wildcard rotation failed: %v", err)
+		if errors.Is(err, services.ErrRotationInProgress) {
+			http.Error(w, "Rotation already in progress", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to start rotation: %v", err)
 		http.Error(w, "Rotation failed", http.StatusInternalServerError)
 		return
 	}
+	go func() {
+		if err := services.ContinueRotation(h.db, operationID, plan, updateDNS); err != nil {
+			log.Printf("Wildcard rotation of pool %s failed: %v", pool.ID, err)
+		}
+	}()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "rotated"})
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "rotating", "operation_id": operationID})
+}
+
+// SimulateWildcardPool predicts the next rotations of a wildcard pool, optionally with
+// overridden settings, without touching DNS or the pool
+func (h *PassthroughHandler) SimulateWildcardPool(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownedWildcardPool(w, r)
+	if !ok {
+		return
+	}
+	h.simulate(w, r, services.WildcardPoolSimulation(pool))
 }
 
 // PauseWildcardPool pauses rotation
@@ -772,556 +1012,149 @@ func (h *PassthroughHandler) PauseWildcardPool(w http.ResponseWriter, r *http.Re
 // ResumeWildcardPool resumes rotation
 func (h *PassthroughHandler) ResumeWildcardPool(w http.ResponseWriter, r *http.Request) {
 	poolID, _ := uuid.Parse(mux.Vars(r)["poolId"])
-	h.db.Exec("UPDATE dns_wildcard_pools SET is_paused = false, updated_at = NOW() WHERE id = $1", poolID)
+	h.db.Exec("UPDATE dns_wildcard_pools SET is_paused = false, next_rotation_at = NULL, updated_at = NOW() WHERE id = $1", poolID)
 	w.WriteHeader(http.StatusOK)
 }
 
 // =============== Helper Methods ===============
 
-// selectNextMachine selects the next machine based on strategy
-// Includes both direct members AND machines from groups
-func (h *PassthroughHandler) selectNextMachine(poolID uuid.UUID, strategy string, currentIndex int, healthCheck bool, poolType string) (*models.PassthroughMemberWithMachine, error) {
-	// Get pool to check for group_ids
-	var pool models.PassthroughPool
-	if err := h.db.Get(&pool, "SELECT * FROM dns_passthrough_pools WHERE id = $1", poolID); err != nil {
-		log.Printf("selectNextMachine: failed to get pool %s: %v", poolID, err)
-		return nil, err
-	}
-	log.Printf("selectNextMachine: pool %s has %d group_ids: %v", poolID, len(pool.GroupIDs), pool.GroupIDs)
+// ownedRecordPool loads the record pool of the request's poolId and checks the user
+// owns its domain. Writes the error response and returns false otherwise.
+func (h *PassthroughHandler) ownedRecordPool(w http.ResponseWriter, r *http.Request) (models.PassthroughPool, bool) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
 
-	// Get direct members
-	var members []models.PassthroughMemberWithMachine
-	err := h.db.Select(&members, `
-		SELECT pm.*, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen
-		FROM dns_passthrough_members pm
-		JOIN machines m ON pm.machine_id = m.id
-		LEFT JOIN agents a ON m.agent_id = a.id
-		WHERE pm.pool_id = $1 AND pm.is_enabled = true
-		ORDER BY pm.priority, COALESCE(NULLIF(m.title, ''), m.hostname)
-	`, poolID)
+	var pool models.PassthroughPool
+	poolID, err := uuid.Parse(mux.Vars(r)["poolId"])
 	if err != nil {
-		log.Printf("selectNextMachine: failed to get direct members: %v", err)
-	}
-	log.Printf("selectNextMachine: found %d direct members", len(members))
-
-	// Add machines from groups (deduplicated)
-	if len(pool.GroupIDs) > 0 {
-		var groupMachines []struct {
-			MachineID   uuid.UUID  `db:"machine_id"`
-			MachineName string     `db:"machine_name"`
-			MachineIP   string     `db:"machine_ip"`
-			LastSeen    *time.Time `db:"last_seen"`
-		}
-		err := h.db.Select(&groupMachines, `
-			SELECT DISTINCT m.id as machine_id, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen
-			FROM machine_group_members gm
-			JOIN machines m ON gm.machine_id = m.id
-			LEFT JOIN agents a ON m.agent_id = a.id
-			WHERE gm.group_id = ANY($1::uuid[])
-		`, pool.GroupIDs)
-		if err != nil {
-			log.Printf("selectNextMachine: failed to get group machines: %v", err)
-		}
-		log.Printf("selectNextMachine: found %d machines from groups", len(groupMachines))
-
-		// Add group machines that aren't already direct members
-		existingIDs := make(map[uuid.UUID]bool)
-		for _, m := range members {
-			existingIDs[m.MachineID] = true
-		}
-		for _, gm := range groupMachines {
-			if !existingIDs[gm.MachineID] {
-				members = append(members, models.PassthroughMemberWithMachine{
-					PassthroughMember: models.PassthroughMember{
-						PoolID:    poolID,
-						MachineID: gm.MachineID,
-						IsEnabled: true,
-					},
-					MachineName: gm.MachineName,
-					MachineIP:   gm.MachineIP,
-					LastSeen:    gm.LastSeen,
-				})
-			}
-		}
-	}
-
-	log.Printf("selectNextMachine: total %d machines available", len(members))
-	if len(members) == 0 {
-		return nil, sql.ErrNoRows
-	}
-
-	// Filter by health if enabled
-	if healthCheck {
-		var healthy []models.PassthroughMemberWithMachine
-		for _, m := range members {
-			if m.LastSeen != nil && time.Since(*m.LastSeen) < 5*time.Minute {
-				healthy = append(healthy, m)
-			}
-		}
-		if len(healthy) > 0 {
-			members = healthy
-		}
-		// If no healthy machines, use all as fallback
+		http.Error(w, "Invalid pool ID", http.StatusBadRequest)
+		return pool, false
 	}
-
-	if len(members) == 0 {
-		return nil, sql.ErrNoRows
+	if err := h.db.Get(&pool, "SELECT * FROM dns_passthrough_pools WHERE id = $1", poolID); err != nil {
+		http.Error(w, "Pool not found", http.StatusNotFound)
+		return pool, false
 	}
 
-	var selected *models.PassthroughMemberWithMachine
-	if strategy == "random" {
-		selected = &members[rand.Intn(len(members))]
-	} else {
-		// Round-robin
-		nextIndex := (currentIndex + 1) % len(members)
-		selected = &members[nextIndex]
+	var domainOwnerID uuid.UUID
+	h.db.Get(&domainOwnerID, `
+		SELECT d.owner_id FROM dns_records r
+		JOIN dns_managed_domains d ON r.dns_domain_id = d.id
+		WHERE r.id = $1
+	`, pool.DNSRecordID)
+	if domainOwnerID != userID && !claims.IsSuperAdmin() {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return pool, false
 	}
-
-	return selected, nil
+	return pool, true
 }
 
-// selectNextMachineWildcard is same logic for wildcard pools
-// Includes both direct members AND machines from groups
-func (h *PassthroughHandler) selectNextMachineWildcard(poolID uuid.UUID, strategy string, currentIndex int, healthCheck bool) (*models.WildcardMemberWithMachine, error) {
-	// Get pool to check for group_ids
-	var pool models.WildcardPool
-	if err := h.db.Get(&pool, "SELECT * FROM dns_wildcard_pools WHERE id = $1", poolID); err != nil {
-		return nil, err
-	}
-
-	// Get direct members
-	var members []models.WildcardMemberWithMachine
-	h.db.Select(&members, `
-		SELECT wm.*, m.hostname as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen
-		FROM dns_wildcard_pool_members wm
-		JOIN machines m ON wm.machine_id = m.id
-		LEFT JOIN agents a ON m.agent_id = a.id
-		WHERE wm.pool_id = $1 AND wm.is_enabled = true
-		ORDER BY wm.priority, m.hostname
-	`, poolID)
-
-	// Add machines from groups (deduplicated)
-	if len(pool.GroupIDs) > 0 {
-		var groupMachines []struct {
-			MachineID   uuid.UUID  `db:"machine_id"`
-			MachineName string     `db:"machine_name"`
-			MachineIP   string     `db:"machine_ip"`
-			LastSeen    *time.Time `db:"last_seen"`
-		}
-		h.db.Select(&groupMachines, `
-			SELECT DISTINCT m.id as machine_id, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen
-			FROM machine_group_members gm
-			JOIN machines m ON gm.machine_id = m.id
-			LEFT JOIN agents a ON m.agent_id = a.id
-			WHERE gm.group_id = ANY($1::uuid[])
-		`, pool.GroupIDs)
-
-		// Add group machines that aren't already direct members
-		existingIDs := make(map[uuid.UUID]bool)
-		for _, m := range members {
-			existingIDs[m.MachineID] = true
-		}
-		for _, gm := range groupMachines {
-			if !existingIDs[gm.MachineID] {
-				members = append(members, models.WildcardMemberWithMachine{
-					WildcardPoolMember: models.WildcardPoolMember{
-						PoolID:    poolID,
-						MachineID: gm.MachineID,
-						IsEnabled: true,
-					},
-					MachineName: gm.MachineName,
-					MachineIP:   gm.MachineIP,
-					LastSeen:    gm.LastSeen,
-				})
-			}
-		}
-	}
-
-	if len(members) == 0 {
-		return nil, sql.ErrNoRows
-	}
+// ownedWildcardPool loads the wildcard pool of the request's poolId and checks the
+// user owns its domain. Writes the error response and returns false otherwise.
+func (h *PassthroughHandler) ownedWildcardPool(w http.ResponseWriter, r *http.Request) (models.WildcardPool, bool) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	userID, _ := uuid.Parse(claims.UserID)
 
-	if healthCheck {
-		var healthy []models.WildcardMemberWithMachine
-		for _, m := range members {
-			if m.LastSeen != nil && time.Since(*m.LastSeen) < 5*time.Minute {
-				healthy = append(healthy, m)
-			}
-		}
-		if len(healthy) > 0 {
-			members = healthy
-		}
+	var pool models.WildcardPool
+	poolID, err := uuid.Parse(mux.Vars(r)["poolId"])
+	if err != nil {
+		http.Error(w, "Invalid pool ID", http.StatusBadRequest)
+		return pool, false
 	}
-
-	if len(members) == 0 {
-		return nil, sql.ErrNoRows
+	if err := h.db.Get(&pool, "SELECT * FROM dns_wildcard_pools WHERE id = $1", poolID); err != nil {
+		http.Error(w, "Pool not found", http.StatusNotFound)
+		return pool, false
 	}
 
-	var selected *models.WildcardMemberWithMachine
-	if strategy == "random" {
-		selected = &members[rand.Intn(len(members))]
-	} else {
-		nextIndex := (currentIndex + 1) % len(members)
-		selected = &members[nextIndex]
+	var ownerID uuid.UUID
+	h.db.Get(&ownerID, "SELECT owner_id FROM dns_managed_domains WHERE id = $1", pool.DNSDomainID)
+	if ownerID != userID && !claims.IsSuperAdmin() {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return pool, false
 	}
-
-	return selected, nil
+	return pool, true
 }
 
-// rotateToMachine performs the actual rotation for a record pool
-func (h *PassthroughHandler) rotateToMachine(poolID, recordID uuid.UUID, member *models.PassthroughMemberWithMachine, poolType, trigger string) error {
-	// Get current state for history
-	var pool models.PassthroughPool
-	h.db.Get(&pool, "SELECT * FROM dns_passthrough_pools WHERE id = $1", poolID)
-
-	var fromIP string
-	if pool.CurrentMachineID != nil {
-		h.db.Get(&fromIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", *pool.CurrentMachineID)
-	}
-
-	// Update DNS record
-	err := h.updateDNSRecordToMachine(recordID, member.MachineID, trigger)
-	if err != nil {
-		return err
-	}
-
-	// Get new index for round-robin
-	var newIndex int
-	h.db.Get(&newIndex, `
-		SELECT COALESCE(
-			(SELECT row_number - 1 FROM (
-				SELECT machine_id, ROW_NUMBER() OVER (ORDER BY priority, machine_id) as row_number
-				FROM dns_passthrough_members WHERE pool_id = $1 AND is_enabled = true
-			) t WHERE machine_id = $2),
-			0
-		)
-	`, poolID, member.MachineID)
-
-	// Update pool state
-	h.db.Exec(`
-		UPDATE dns_passthrough_pools 
-		SET current_machine_id = $1, current_index = $2, last_rotated_at = NOW(), updated_at = NOW()
-		WHERE id = $3
-	`, member.MachineID, newIndex, poolID)
-
-	// Log history
-	log.Printf("Inserting rotation history: pool_type=%s, pool_id=%s, from_machine=%v, from_ip=%s, to_machine=%s, to_ip=%s, trigger=%s",
-		poolType, poolID, pool.CurrentMachineID, fromIP, member.MachineID, member.MachineIP, trigger)
-	
-	result, histErr := h.db.Exec(`
-		INSERT INTO dns_rotation_history 
-			(pool_type, pool_id, from_machine_id, from_ip, to_machine_id, to_ip, trigger)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, poolType, poolID, pool.CurrentMachineID, fromIP, member.MachineID, member.MachineIP, trigger)
-	if histErr != nil {
-		log.Printf("Failed to insert rotation history: %v", histErr)
-	} else {
-		rows, _ := result.RowsAffected()
-		log.Printf("Rotation history inserted: %d rows affected", rows)
+// GetRecordPoolRotation returns a rotation operation of a record pool, e.g. one
+// started by RotateRecordPool
+func (h *PassthroughHandler) GetRecordPoolRotation(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownedRecordPool(w, r)
+	if !ok {
+		return
 	}
-
-	return nil
+	h.writeRotation(w, r, "record", pool.ID)
 }
 
-// rotateWildcardToMachine performs rotation for wildcard pool
-func (h *PassthroughHandler) rotateWildcardToMachine(poolID, domainID uuid.UUID, member *models.WildcardMemberWithMachine, includeRoot bool, trigger string) error {
-	var pool models.WildcardPool
-	h.db.Get(&pool, "SELECT * FROM dns_wildcard_pools WHERE id = $1", poolID)
-
-	var fromIP string
-	if pool.CurrentMachineID != nil {
-		h.db.Get(&fromIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", *pool.CurrentMachineID)
-	}
-
-	// Update wildcard DNS
-	err := h.updateWildcardDNS(domainID, member.MachineID, includeRoot, trigger)
-	if err != nil {
-		return err
-	}
-
-	// Update pool state
-	var newIndex int
-	h.db.Get(&newIndex, `
-		SELECT COALESCE(
-			(SELECT row_number - 1 FROM (
-				SELECT machine_id, ROW_NUMBER() OVER (ORDER BY priority, machine_id) as row_number
-				FROM dns_wildcard_pool_members WHERE pool_id = $1 AND is_enabled = true
-			) t WHERE machine_id = $2),
-			0
-		)
-	`, poolID, member.MachineID)
-
-	h.db.Exec(`
-		UPDATE dns_wildcard_pools 
-		SET current_machine_id = $1, current_index = $2, last_rotated_at = NOW(), updated_at = NOW()
-		WHERE id = $3
-	`, member.MachineID, newIndex, poolID)
-
-	// Log history
-	_, histErr := h.db.Exec(`
-		INSERT INTO dns_rotation_history 
-			(pool_type, pool_id, dns_domain_id, from_machine_id, from_ip, to_machine_id, to_ip, trigger)
-		VALUES ('wildcard', $1, $2, $3, $4, $5, $6, $7)
-	`, poolID, domainID, pool.CurrentMachineID, fromIP, member.MachineID, member.MachineIP, trigger)
-	if histErr != nil {
-		log.Printf("Failed to insert wildcard rotation history: %v", histErr)
-	} else {
-		log.Printf("Wildcard rotation history logged: %s -> %s", fromIP, member.MachineIP)
+// GetWildcardPoolRotation returns a rotation operation of a wildcard pool
+func (h *PassthroughHandler) GetWildcardPoolRotation(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownedWildcardPool(w, r)
+	if !ok {
+		return
 	}
-
-	return nil
+	h.writeRotation(w, r, "wildcard", pool.ID)
 }
 
-// updateDNSRecordToMachine updates a DNS record to point to a machine's IP
-func (h *PassthroughHandler) updateDNSRecordToMachine(recordID, machineID uuid.UUID, trigger string) error {
-	// Get machine IP
-	var machineIP string
-	err := h.db.Get(&machineIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", machineID)
-	if err != nil {
-		return fmt.Errorf("failed to get machine IP: %w", err)
-	}
-
-	// Get full record info with domain and account
-	var recordInfo struct {
-		ID           uuid.UUID  `db:"id"`
-		Name         string     `db:"name"`
-		RecordType   string     `db:"record_type"`
-		Value        string     `db:"value"`
-		TTL          int        `db:"ttl"`
-		Priority     int        `db:"priority"`
-		Proxied      bool       `db:"proxied"`
-		ProviderID   *string    `db:"remote_record_id"`
-		DNSDomainID  uuid.UUID  `db:"dns_domain_id"`
-		DomainFQDN   string     `db:"domain_fqdn"`
-		DNSAccountID *uuid.UUID `db:"dns_account_id"`
-	}
-	err = h.db.Get(&recordInfo, `
-		SELECT r.id, r.name, r.record_type, r.value, r.ttl, r.priority, r.proxied,
-			   r.remote_record_id, r.dns_domain_id, d.fqdn as domain_fqdn, d.dns_account_id
-		FROM dns_records r
-		JOIN dns_managed_domains d ON r.dns_domain_id = d.id
-		WHERE r.id = $1
-	`, recordID)
-	if err != nil {
-		return fmt.Errorf("failed to get record info: %w", err)
-	}
-
-	log.Printf("DNS Passthrough: Updating %s.%s from %s to %s (trigger: %s)", 
-		recordInfo.Name, recordInfo.DomainFQDN, recordInfo.Value, machineIP, trigger)
-
-	// Update local record first
-	h.db.Exec("UPDATE dns_records SET value = $1, sync_status = 'pending', updated_at = NOW() WHERE id = $2", machineIP, recordID)
-
-	// If no DNS account, just update locally
-	if recordInfo.DNSAccountID == nil {
-		h.db.Exec("UPDATE dns_records SET sync_status = 'synced' WHERE id = $1", recordID)
-		return nil
-	}
-
-	// Get DNS account and create provider
-	var account models.DNSAccount
-	err = h.db.Get(&account, "SELECT * FROM dns_accounts WHERE id = $1", *recordInfo.DNSAccountID)
+func (h *PassthroughHandler) writeRotation(w http.ResponseWriter, r *http.Request, poolType string, poolID uuid.UUID) {
+	operationID, err := uuid.Parse(mux.Vars(r)["operationId"])
 	if err != nil {
-		h.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", 
-			"DNS account not found", recordID)
-		return fmt.Errorf("failed to get DNS account: %w", err)
+		http.Error(w, "Invalid operation ID", http.StatusBadRequest)
+		return
 	}
 
-	// Build apiID (DNSPod needs it, Cloudflare doesn't)
-	apiID := ""
-	if account.ApiID != nil {
-		apiID = *account.ApiID
-	}
-	provider, err := dns.NewProvider(account.Provider, apiID, account.ApiToken)
+	var op models.RotationOperation
+	err = h.db.Get(&op, `
+		SELECT * FROM dns_rotation_operations
+		WHERE id = $1 AND pool_type = $2 AND pool_id = $3
+	`, operationID, poolType, poolID)
 	if err != nil {
-		h.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", 
-			err.Error(), recordID)
-		return fmt.Errorf("failed to create DNS provider: %w", err)
+		http.Error(w, "Rotation not found", http.StatusNotFound)
+		return
 	}
 
-	// Build record for update
-	providerRecordID := ""
-	if recordInfo.ProviderID != nil {
-		providerRecordID = *recordInfo.ProviderID
-	}
-	
-	dnsRecord := dns.Record{
-		ID:       providerRecordID,
-		Type:     recordInfo.RecordType,
-		Name:     recordInfo.Name,
-		Value:    machineIP,
-		TTL:      recordInfo.TTL,
-		Priority: recordInfo.Priority,
-		Proxied:  recordInfo.Proxied,
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(op)
+}
 
-	ctx := context.Background()
-	
-	// Always try to find the existing record first to get correct ID
-	findAndUpdateExisting := func() (string, error) {
-		remoteRecords, listErr := provider.ListRecords(ctx, recordInfo.DomainFQDN)
-		if listErr != nil {
-			return "", listErr
-		}
-		for _, remote := range remoteRecords {
-			// Match by name and type (Cloudflare returns full name like "amelle.domain.com")
-			remoteName := remote.Name
-			if remoteName == recordInfo.Name+"."+recordInfo.DomainFQDN || remoteName == recordInfo.Name {
-				if remote.Type == recordInfo.RecordType {
-					log.Printf("Found existing record %s (ID: %s, value: %s) - updating to %s", 
-						remoteName, remote.ID, remote.Value, machineIP)
-					dnsRecord.ID = remote.ID
-					_, updateErr := provider.UpdateRecord(ctx, recordInfo.DomainFQDN, remote.ID, dnsRecord)
-					if updateErr != nil {
-						return "", updateErr
-					}
-					return remote.ID, nil
-				}
-			}
-		}
-		return "", fmt.Errorf("record not found on provider")
+// simulate decodes a simulation request and writes the simulated rotations
+func (h *PassthroughHandler) simulate(w http.ResponseWriter, r *http.Request, pool services.SimulationPool) {
+	var req services.SimulationRequest
+	// An empty body simulates the pool as saved
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	// Try to update existing record, or create if it doesn't exist
-	if providerRecordID != "" {
-		// Update existing record
-		_, err = provider.UpdateRecord(ctx, recordInfo.DomainFQDN, providerRecordID, dnsRecord)
-		if err != nil {
-			log.Printf("Failed to update record on provider (stale ID?), searching for existing: %v", err)
-			// The stored ID is probably stale - find the actual record
-			foundID, findErr := findAndUpdateExisting()
-			if findErr != nil {
-				// Try to create new
-				createdRecord, createErr := provider.CreateRecord(ctx, recordInfo.DomainFQDN, dnsRecord)
-				if createErr != nil {
-					h.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", 
-						createErr.Error(), recordID)
-					return fmt.Errorf("failed to sync DNS record: %w", createErr)
-				}
-				providerRecordID = createdRecord.ID
-			} else {
-				providerRecordID = foundID
-			}
-		}
-	} else {
-		// No stored ID - try to find existing or create new
-		foundID, findErr := findAndUpdateExisting()
-		if findErr != nil {
-			// Create new record
-			createdRecord, createErr := provider.CreateRecord(ctx, recordInfo.DomainFQDN, dnsRecord)
-			if createErr != nil {
-				// If create fails, try find again (race condition)
-				foundID2, findErr2 := findAndUpdateExisting()
-				if findErr2 != nil {
-					h.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", 
-						createErr.Error(), recordID)
-					return fmt.Errorf("failed to sync DNS record: %w", createErr)
-				}
-				providerRecordID = foundID2
-			} else {
-				providerRecordID = createdRecord.ID
-			}
-		} else {
-			providerRecordID = foundID
+	result, err := services.SimulateRotations(h.db, pool, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSimulation) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		log.Printf("Simulation of pool %s failed: %v", pool.State.PoolID, err)
+		http.Error(w, "Simulation failed", http.StatusInternalServerError)
+		return
 	}
 
-	// Update local record with provider ID and mark as synced
-	h.db.Exec("UPDATE dns_records SET remote_record_id = $1, sync_status = 'synced', sync_error = NULL WHERE id = $2", 
-		providerRecordID, recordID)
-
-	log.Printf("DNS Passthrough: Successfully synced %s.%s = %s", recordInfo.Name, recordInfo.DomainFQDN, machineIP)
-	return nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
 
-// updateWildcardDNS updates wildcard DNS records
-func (h *PassthroughHandler) updateWildcardDNS(domainID, machineID uuid.UUID, includeRoot bool, trigger string) error {
-	var machineIP string
-	h.db.Get(&machineIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", machineID)
-
-	var domain struct {
-		Name         string    `db:"name"`
-		DNSAccountID uuid.UUID `db:"dns_account_id"`
-	}
-	h.db.Get(&domain, "SELECT name, dns_account_id FROM dns_managed_domains WHERE id = $1", domainID)
-
-	log.Printf("DNS Passthrough: Updating *.%s to %s (trigger: %s, include_root: %v)", domain.Name, machineIP, trigger, includeRoot)
-
-	// Update or create wildcard record
-	h.db.Exec(`
-		INSERT INTO dns_records (dns_domain_id, name, type, value, mode, sync_status)
-		VALUES ($1, '*', 'A', $2, 'dynamic', 'pending')
-		ON CONFLICT (dns_domain_id, name, type) DO UPDATE SET
-			value = $2, sync_status = 'pending', updated_at = NOW()
-	`, domainID, machineIP)
-
-	if includeRoot {
-		h.db.Exec(`
-			INSERT INTO dns_records (dns_domain_id, name, type, value, mode, sync_status)
-			VALUES ($1, '@', 'A', $2, 'dynamic', 'pending')
-			ON CONFLICT (dns_domain_id, name, type) DO UPDATE SET
-				value = $2, sync_status = 'pending', updated_at = NOW()
-		`, domainID, machineIP)
-	}
-
-	// Sync wildcard records to DNS provider
-	var domainInfo struct {
-		FQDN         string     `db:"fqdn"`
-		DNSAccountID *uuid.UUID `db:"dns_account_id"`
-	}
-	h.db.Get(&domainInfo, "SELECT fqdn, dns_account_id FROM dns_managed_domains WHERE id = $1", domainID)
-
-	if domainInfo.DNSAccountID == nil {
-		// No DNS account, just mark as synced
-		h.db.Exec("UPDATE dns_records SET sync_status = 'synced' WHERE dns_domain_id = $1 AND mode = 'dynamic'", domainID)
-		return nil
+// initialMachine returns the pool's current machine, or the first member a new pool starts on
+func initialMachine(currentID *uuid.UUID, machineIDs []uuid.UUID) *uuid.UUID {
+	if currentID != nil {
+		return currentID
 	}
-
-	// Get DNS account and create provider
-	var account models.DNSAccount
-	if err := h.db.Get(&account, "SELECT * FROM dns_accounts WHERE id = $1", *domainInfo.DNSAccountID); err != nil {
-		log.Printf("Failed to get DNS account for wildcard sync: %v", err)
-		h.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE dns_domain_id = $2 AND mode = 'dynamic'",
-			"DNS account not found", domainID)
-		return nil
-	}
-
-	// Build apiID (DNSPod needs it, others don't)
-	apiID := ""
-	if account.ApiID != nil {
-		apiID = *account.ApiID
-	}
-	provider, err := dns.NewProvider(account.Provider, apiID, account.ApiToken)
-	if err != nil {
-		log.Printf("Failed to create DNS provider for wildcard sync: %v", err)
-		h.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE dns_domain_id = $2 AND mode = 'dynamic'",
-			err.Error(), domainID)
-		return nil
-	}
-
-	ctx := context.Background()
-
-	// Sync wildcard record
-	wildcardRecord := dns.Record{Name: "*", Type: "A", Value: machineIP, TTL: 60}
-	if _, createErr := provider.CreateRecord(ctx, domainInfo.FQDN, wildcardRecord); createErr != nil {
-		// Try update if create fails
-		if _, updateErr := provider.UpdateRecord(ctx, domainInfo.FQDN, "", wildcardRecord); updateErr != nil {
-			log.Printf("Failed to sync wildcard record: %v", updateErr)
-		}
+	if len(machineIDs) > 0 {
+		return &machineIDs[0]
 	}
+	return nil
+}
 
-	// Sync root record if included
-	if includeRoot {
-		rootRecord := dns.Record{Name: "@", Type: "A", Value: machineIP, TTL: 60}
-		if _, createErr := provider.CreateRecord(ctx, domainInfo.FQDN, rootRecord); createErr != nil {
-			if _, updateErr := provider.UpdateRecord(ctx, domainInfo.FQDN, "", rootRecord); updateErr != nil {
-				log.Printf("Failed to sync root record: %v", updateErr)
-			}
-		}
+// memberWeight returns the requested weight of a member, defaulting to 1
+func memberWeight(weights map[string]int, machineID string) int {
+	if w, ok := weights[machineID]; ok && w > 0 {
+		return w
 	}
-
-	h.db.Exec("UPDATE dns_records SET sync_status = 'synced', sync_error = NULL WHERE dns_domain_id = $1 AND mode = 'dynamic'", domainID)
-	return nil
+	return 1
 }
 
 // GetDomainProxyMode gets the proxy mode for a domain
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"net"
+	"strconv"
 	"strings"
 
 	"configuratix/backend/internal/database"
@@ -205,6 +209,13 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 	`, machineID)
 	proxyProtocolEnabled = proxyProtocolCount > 0
 
+	// Also listen on IPv6 when the machine has an IPv6 address (pools may publish AAAA records for it)
+	var ipv6Enabled bool
+	g.db.Get(&ipv6Enabled, `
+		SELECT COALESCE(primary_ipv6, '') <> '' OR COALESCE(detected_ips, '[]'::jsonb) @> '[{"is_ipv6": true}]'::jsonb
+		FROM machines WHERE id = $1
+	`, machineID)
+
 	// Generate config
 	// NOTE: This file is included FROM WITHIN a stream{} block in nginx.conf
 	// So we do NOT wrap with stream{} here - only the inner directives
@@ -223,14 +234,14 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 		if pool.RecordName != "@" {
 			fullDomain = pool.RecordName + "." + pool.DomainFQDN
 		}
-		config.WriteString(fmt.Sprintf("    %s %s:%d;\n", fullDomain, pool.TargetIP, pool.TargetPort))
+		config.WriteString(fmt.Sprintf("    %s %s;\n", fullDomain, targetAddr(pool.TargetIP, pool.TargetPort)))
 	}
 
 	for _, pool := range wildcardPools {
-		config.WriteString(fmt.Sprintf("    ~^.+\\.%s$ %s:%d;\n",
-			strings.ReplaceAll(pool.DomainFQDN, ".", "\\."), pool.TargetIP, pool.TargetPort))
+		config.WriteString(fmt.Sprintf("    ~^.+\\.%s$ %s;\n",
+			strings.ReplaceAll(pool.DomainFQDN, ".", "\\."), targetAddr(pool.TargetIP, pool.TargetPort)))
 		if pool.IncludeRoot {
-			config.WriteString(fmt.Sprintf("    %s %s:%d;\n", pool.DomainFQDN, pool.TargetIP, pool.TargetPort))
+			config.WriteString(fmt.Sprintf("    %s %s;\n", pool.DomainFQDN, targetAddr(pool.TargetIP, pool.TargetPort)))
 		}
 	}
 	config.WriteString("}\n\n")
@@ -248,6 +259,9 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 	config.WriteString("# HTTPS Passthrough (TLS SNI-based routing)\n")
 	config.WriteString("server {\n")
 	config.WriteString("    listen 443;\n")
+	if ipv6Enabled {
+		config.WriteString("    listen [::]:443;\n")
+	}
 	config.WriteString("    ssl_preread on;\n")
 	config.WriteString("    proxy_pass $backend_https;\n")
 	if proxyProtocolEnabled {
@@ -271,13 +285,13 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 		if pool.RecordName != "@" {
 			fullDomain = pool.RecordName + "." + pool.DomainFQDN
 		}
-		httpTargets[fullDomain] = fmt.Sprintf("%s:%d", pool.TargetIP, pool.TargetPortHTTP)
+		httpTargets[fullDomain] = targetAddr(pool.TargetIP, pool.TargetPortHTTP)
 	}
 	for _, pool := range wildcardPools {
 		// For wildcards, just use the root domain as key
-		httpTargets["wildcard_"+pool.DomainFQDN] = fmt.Sprintf("%s:%d", pool.TargetIP, pool.TargetPortHTTP)
+		httpTargets["wildcard_"+pool.DomainFQDN] = targetAddr(pool.TargetIP, pool.TargetPortHTTP)
 		if pool.IncludeRoot {
-			httpTargets[pool.DomainFQDN] = fmt.Sprintf("%s:%d", pool.TargetIP, pool.TargetPortHTTP)
+			httpTargets[pool.DomainFQDN] = targetAddr(pool.TargetIP, pool.TargetPortHTTP)
 		}
 	}
 
@@ -298,6 +312,9 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 		config.WriteString("# HTTP Passthrough (all traffic to single target)\n")
 		config.WriteString("server {\n")
 		config.WriteString("    listen 80;\n")
+		if ipv6Enabled {
+			config.WriteString("    listen [::]:80;\n")
+		}
 		config.WriteString(fmt.Sprintf("    proxy_pass %s;\n", target))
 		config.WriteString("    proxy_connect_timeout 10s;\n")
 		config.WriteString("    proxy_timeout 30m;\n")
@@ -315,6 +332,9 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 		config.WriteString("# All HTTP traffic goes to default target. Target server handles Host routing.\n")
 		config.WriteString("server {\n")
 		config.WriteString("    listen 80;\n")
+		if ipv6Enabled {
+			config.WriteString("    listen [::]:80;\n")
+		}
 		config.WriteString(fmt.Sprintf("    proxy_pass %s;\n", defaultTarget))
 		config.WriteString("    proxy_connect_timeout 10s;\n")
 		config.WriteString("    proxy_timeout 30m;\n")
@@ -324,6 +344,11 @@ func (g *PassthroughNginxGenerator) GenerateForMachine(machineID uuid.UUID) (str
 	return config.String(), nil
 }
 
+// targetAddr formats a backend address for nginx, bracketing IPv6 targets
+func targetAddr(ip string, port int) string {
+	return net.JoinHostPort(ip, strconv.Itoa(port))
+}
+
 // ApplyToMachine sends a job to apply the config on a machine
 func (g *PassthroughNginxGenerator) ApplyToMachine(machineID uuid.UUID) error {
 	config, err := g.GenerateForMachine(machineID)
@@ -336,6 +361,48 @@ func (g *PassthroughNginxGenerator) ApplyToMachine(machineID uuid.UUID) error {
 		return nil
 	}
 
+	_, err = g.queueConfigJob(machineID, config)
+	return err
+}
+
+// EnsurePassthroughConfig pushes the machine's current config unless the machine already
+// runs it, returning the job to wait for (uuid.Nil if there is nothing to wait for).
+// Used by the pre-rotation warmup.
+func (g *PassthroughNginxGenerator) EnsurePassthroughConfig(machineID uuid.UUID) (uuid.UUID, error) {
+	config, err := g.GenerateForMachine(machineID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if config == "" {
+		return uuid.Nil, fmt.Errorf("machine %s is not in any passthrough pool", machineID)
+	}
+
+	var deployed struct {
+		JobID      *uuid.UUID `db:"job_id"`
+		ConfigHash string     `db:"config_hash"`
+		Status     string     `db:"status"`
+	}
+	err = g.db.Get(&deployed, "SELECT job_id, config_hash, status FROM machine_passthrough_configs WHERE machine_id = $1", machineID)
+	if err == nil && deployed.ConfigHash == configHash(config) {
+		if deployed.Status == "applied" {
+			return uuid.Nil, nil
+		}
+		if deployed.Status == "pending" && deployed.JobID != nil {
+			return *deployed.JobID, nil // Same config already on its way
+		}
+	}
+
+	return g.queueConfigJob(machineID, config)
+}
+
+// configHash identifies a generated config
+func configHash(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])
+}
+
+// queueConfigJob creates the job that installs a generated config and returns its ID
+func (g *PassthroughNginxGenerator) queueConfigJob(machineID uuid.UUID, config string) (uuid.UUID, error) {
 	// Create a job to write the config
 	// Note: stream blocks must be in a file included by nginx.conf, not in conf.d
 	// The config goes to /etc/nginx/stream.d/ or /etc/nginx/conf.d/stream/
@@ -343,9 +410,9 @@ func (g *PassthroughNginxGenerator) ApplyToMachine(machineID uuid.UUID) error {
 	
 	// Get agent_id for this machine
 	var agentID *uuid.UUID
-	err = g.db.Get(&agentID, "SELECT agent_id FROM machines WHERE id = $1", machineID)
+	err := g.db.Get(&agentID, "SELECT agent_id FROM machines WHERE id = $1", machineID)
 	if err != nil || agentID == nil {
-		return fmt.Errorf("machine %s has no agent", machineID)
+		return uuid.Nil, fmt.Errorf("machine %s has no agent", machineID)
 	}
 	
 	// Use 'run' job with multiple steps:
@@ -488,30 +555,39 @@ echo "Nginx is running successfully"
 		"on_error": "stop"
 	}`, setupScript, configPath, config, restartScript)
 	
-	_, err = g.db.Exec(`
+	var jobID uuid.UUID
+	err = g.db.Get(&jobID, `
 		INSERT INTO jobs (agent_id, type, payload_json, status)
 		VALUES ($1, 'run', $2::jsonb, 'pending')
+		RETURNING id
 	`, agentID, payload)
 
 	if err != nil {
-		return fmt.Errorf("failed to create job: %w", err)
+		return uuid.Nil, fmt.Errorf("failed to create job: %w", err)
 	}
 
-	// Mark nginx config as applied for this machine in all its pools
+	// Track the job; the config counts as applied once the agent reports it completed
+	g.db.Exec(`
+		INSERT INTO machine_passthrough_configs (machine_id, job_id, config_hash, status)
+		VALUES ($1, $2, $3, 'pending')
+		ON CONFLICT (machine_id) DO UPDATE SET
+			job_id = EXCLUDED.job_id, config_hash = EXCLUDED.config_hash, status = 'pending', updated_at = NOW()
+	`, machineID, jobID, configHash(config))
+
 	g.db.Exec(`
 		UPDATE dns_passthrough_members 
-		SET nginx_config_applied = true 
+		SET nginx_config_applied = false 
 		WHERE machine_id = $1
 	`, machineID)
 
 	g.db.Exec(`
 		UPDATE dns_wildcard_pool_members 
-		SET nginx_config_applied = true 
+		SET nginx_config_applied = false 
 		WHERE machine_id = $1
 	`, machineID)
 
-	log.Printf("Created passthrough config job for machine %s", machineID)
-	return nil
+	log.Printf("Created passthrough config job %s for machine %s", jobID, machineID)
+	return jobID, nil
 }
 
 // RemoveFromMachine removes passthrough config and re-enables disabled sites
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"configuratix/backend/internal/services"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultReportPeriod = 30 * 24 * time.Hour
+	maxReportPeriod     = 366 * 24 * time.Hour
+)
+
+// GetRecordPoolReport returns rotation analytics for a record pool.
+// Query: from, to (RFC3339 or YYYY-MM-DD, default last 30 days), format=json|csv,
+// section=machines|gaps|triggers (CSV only).
+func (h *PassthroughHandler) GetRecordPoolReport(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownedRecordPool(w, r)
+	if !ok {
+		return
+	}
+	h.rotationReport(w, r, "record", pool.ID, pool.CreatedAt, pool.CurrentMachineID)
+}
+
+// GetWildcardPoolReport returns rotation analytics for a wildcard pool
+func (h *PassthroughHandler) GetWildcardPoolReport(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownedWildcardPool(w, r)
+	if !ok {
+		return
+	}
+	h.rotationReport(w, r, "wildcard", pool.ID, pool.CreatedAt, pool.CurrentMachineID)
+}
+
+func (h *PassthroughHandler) rotationReport(w http.ResponseWriter, r *http.Request, poolType string, poolID uuid.UUID, createdAt time.Time, currentMachineID *uuid.UUID) {
+	from, to, err := parseReportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "Format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	report, err := services.BuildRotationReport(h.db, poolType, poolID, createdAt, currentMachineID, from, to)
+	if err != nil {
+		log.Printf("Failed to build rotation report for pool %s: %v", poolID, err)
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+
+	if format != "csv" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	section := r.URL.Query().Get("section")
+	if section == "" {
+		section = "machines"
+	}
+	var rows [][]string
+	switch section {
+	case "machines":
+		rows = append(rows, []string{"machine_id", "machine_name", "rotations_to", "current_seconds", "serving_seconds", "node_hours", "offline_seconds", "availability"})
+		for _, m := range report.Machines {
+			rows = append(rows, []string{
+				m.MachineID.String(), m.MachineName, strconv.Itoa(m.RotationsTo),
+				strconv.FormatInt(m.CurrentSeconds, 10), strconv.FormatInt(m.ServingSeconds, 10),
+				strconv.FormatFloat(m.NodeHours, 'f', 3, 64), strconv.FormatInt(m.OfflineSeconds, 10),
+				strconv.FormatFloat(m.Availability, 'f', 5, 64),
+			})
+		}
+	case "gaps":
+		rows = append(rows, []string{"machine_id", "machine_name", "start", "end", "seconds", "sources", "reason", "ongoing"})
+		for _, g := range report.Gaps {
+			rows = append(rows, []string{
+				g.MachineID.String(), g.MachineName, g.Start.Format(time.RFC3339), g.End.Format(time.RFC3339),
+				strconv.FormatInt(g.Seconds, 10), strings.Join(g.Sources, ","), g.Reason, strconv.FormatBool(g.Ongoing),
+			})
+		}
+	case "triggers":
+		rows = append(rows, []string{"trigger", "rotations"})
+		triggers := make([]string, 0, len(report.Triggers))
+		for trigger := range report.Triggers {
+			triggers = append(triggers, trigger)
+		}
+		sort.Strings(triggers)
+		for _, trigger := range triggers {
+			rows = append(rows, []string{trigger, strconv.Itoa(report.Triggers[trigger])})
+		}
+	default:
+		http.Error(w, "Section must be machines, gaps or triggers", http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-pool-%s-%s-%s-%s.csv", poolType, poolID, section, from.Format("20060102"), to.Format("20060102"))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	cw := csv.NewWriter(w)
+	cw.WriteAll(rows)
+}
+
+// parseReportPeriod reads the from/to query parameters. Dates are whole UTC days,
+// with to inclusive.
+func parseReportPeriod(r *http.Request) (time.Time, time.Time, error) {
+	parse := func(value string, endOfDay bool) (time.Time, error) {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.UTC(), nil
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or YYYY-MM-DD", value)
+		}
+		if endOfDay {
+			t = t.Add(24 * time.Hour)
+		}
+		return t, nil
+	}
+
+	to := time.Now().UTC()
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := parse(v, true)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+	from := to.Add(-defaultReportPeriod)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := parse(v, false)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > maxReportPeriod {
+		return time.Time{}, time.Time{}, fmt.Errorf("report period is limited to 366 days")
+	}
+	return from, to, nil
+}
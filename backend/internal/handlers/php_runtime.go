@@ -195,16 +195,13 @@ func (h *PHPRuntimeHandler) watchJobCompletion(jobID, machineID uuid.UUID, versi
 			`, machineID)
 			return
 		case <-ticker.C:
-			var status string
-			var logs sql.NullString
-			err := h.db.QueryRow(`
-				SELECT status, logs FROM jobs WHERE id = $1
-			`, jobID).Scan(&status, &logs)
+			var job models.Job
+			err := h.db.Get(&job, "SELECT * FROM jobs WHERE id = $1", jobID)
 			if err != nil {
 				continue
 			}
 
-			switch status {
+			switch job.Status {
 			case "completed":
 				socketPath := models.GetPHPSocketPath(version)
 				h.db.Exec(`
@@ -214,12 +211,9 @@ func (h *PHPRuntimeHandler) watchJobCompletion(jobID, machineID uuid.UUID, versi
 				`, socketPath, machineID)
 				return
 			case "failed":
-				errorMsg := "Installation failed"
-				if logs.Valid {
-					errorMsg = logs.String
-					if len(errorMsg) > 1000 {
-						errorMsg = errorMsg[len(errorMsg)-1000:]
-					}
+				errorMsg := job.FailureMessage(1000)
+				if errorMsg == "" {
+					errorMsg = "Installation failed"
 				}
 				h.db.Exec(`
 					UPDATE php_runtimes 
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -33,8 +34,16 @@ type DNSManagedDomain struct {
 	NSExpected   pq.StringArray `db:"ns_expected" json:"ns_expected"`
 	NSActual     pq.StringArray `db:"ns_actual" json:"ns_actual"`
 	NotesMD      *string        `db:"notes_md" json:"notes_md"`
-	CreatedAt    time.Time      `db:"created_at" json:"created_at"`
-	UpdatedAt    time.Time      `db:"updated_at" json:"updated_at"`
+
+	// Drift detection
+	DriftEnforce   bool            `db:"drift_enforce" json:"drift_enforce"` // Re-apply local state when drift is found
+	DriftStatus    string          `db:"drift_status" json:"drift_status"`   // unknown, in_sync, drifted, enforced, error
+	DriftResult    json.RawMessage `db:"drift_result" json:"drift_result"`   // Last dns.SyncResult
+	DriftError     *string         `db:"drift_error" json:"drift_error"`
+	DriftCheckedAt *time.Time      `db:"drift_checked_at" json:"drift_checked_at"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // DNSManagedDomainWithAccount includes account info for display
@@ -44,17 +53,47 @@ type DNSManagedDomainWithAccount struct {
 	DNSAccountProvider *string `db:"dns_account_provider" json:"dns_account_provider"`
 }
 
+// DNSZoneMigration tracks moving a managed domain to another DNS account
+type DNSZoneMigration struct {
+	ID            uuid.UUID      `db:"id" json:"id"`
+	DNSDomainID   uuid.UUID      `db:"dns_domain_id" json:"dns_domain_id"`
+	FromAccountID *uuid.UUID     `db:"from_account_id" json:"from_account_id"`
+	ToAccountID   uuid.UUID      `db:"to_account_id" json:"to_account_id"`
+	Status        string         `db:"status" json:"status"` // copying, awaiting_ns, completed, failed, cancelled
+	Nameservers   pq.StringArray `db:"nameservers" json:"nameservers"`
+	NSActual      pq.StringArray `db:"ns_actual" json:"ns_actual"`
+	RecordsCopied int            `db:"records_copied" json:"records_copied"`
+	Error         *string        `db:"error" json:"error"`
+	LastCheckedAt *time.Time     `db:"last_checked_at" json:"last_checked_at"`
+	DetachedAt    *time.Time     `db:"detached_at" json:"detached_at"`
+	CreatedAt     time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at" json:"updated_at"`
+}
+
 // DNSRecord represents a DNS record stored in our database
 type DNSRecord struct {
 	ID          uuid.UUID `db:"id" json:"id"`
 	DNSDomainID uuid.UUID `db:"dns_domain_id" json:"dns_domain_id"` // References dns_managed_domains
 	Name        string    `db:"name" json:"name"`                   // Subdomain: www, @, *
-	RecordType  string    `db:"record_type" json:"record_type"`     // A, AAAA, CNAME, TXT, MX
+	RecordType  string    `db:"record_type" json:"record_type"`     // A, AAAA, CNAME, TXT, MX, NS, SRV, CAA, PTR, TLSA
 	Value       string    `db:"value" json:"value"`
 	TTL         int       `db:"ttl" json:"ttl"`
-	Priority    *int      `db:"priority" json:"priority"`
-	Proxied     bool      `db:"proxied" json:"proxied"` // CF orange cloud
-	Mode        string    `db:"mode" json:"mode"`       // static, dynamic
+	Priority    *int      `db:"priority" json:"priority"` // MX, SRV
+	Proxied     bool      `db:"proxied" json:"proxied"`   // CF orange cloud
+	Mode        string    `db:"mode" json:"mode"`         // static, dynamic
+
+	// SRV
+	Weight *int `db:"weight" json:"weight"`
+	Port   *int `db:"port" json:"port"`
+
+	// CAA
+	Flags *int    `db:"flags" json:"flags"`
+	Tag   *string `db:"tag" json:"tag"` // issue, issuewild, iodef
+
+	// TLSA
+	TLSAUsage        *int `db:"tlsa_usage" json:"tlsa_usage"`
+	TLSASelector     *int `db:"tlsa_selector" json:"tlsa_selector"`
+	TLSAMatchingType *int `db:"tlsa_matching_type" json:"tlsa_matching_type"`
 
 	// Port overrides for nginx
 	HTTPIncomingPort  *int `db:"http_incoming_port" json:"http_incoming_port"`
@@ -2,7 +2,10 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -12,11 +15,113 @@ type Job struct {
 	AgentID    uuid.UUID       `db:"agent_id" json:"agent_id"`
 	Type       string           `db:"type" json:"type"`
 	PayloadJSON json.RawMessage `db:"payload_json" json:"payload"`
-	Status     string           `db:"status" json:"status"` // pending, running, completed, failed
+	Status     string           `db:"status" json:"status"` // pending, running, completed, failed, cancelled
 	Logs       *string          `db:"logs" json:"logs"`
 	StartedAt  *time.Time       `db:"started_at" json:"started_at"`
 	FinishedAt *time.Time       `db:"finished_at" json:"finished_at"`
 	CreatedAt  time.Time        `db:"created_at" json:"created_at"`
 	UpdatedAt  time.Time        `db:"updated_at" json:"updated_at"`
+	Attempts       int        `db:"attempts" json:"attempts"`         // Times the job was claimed
+	MaxAttempts    int        `db:"max_attempts" json:"max_attempts"` // Claims allowed before an expired lease fails the job
+	LeaseOwner     *string    `db:"lease_owner" json:"lease_owner"`   // Agent process running the job
+	LeaseExpiresAt *time.Time `db:"lease_expires_at" json:"lease_expires_at"`
+	LogBytes        int64 `db:"log_bytes" json:"log_bytes"`                 // Streamed output stored
+	LogDroppedBytes int64 `db:"log_dropped_bytes" json:"log_dropped_bytes"` // Streamed output dropped after the cap
+	Result          *json.RawMessage `db:"result" json:"result"`              // JobResult of run jobs
+}
+
+// JobResult is the structured outcome an agent reports for a run job
+type JobResult struct {
+	Steps      []JobStepResult   `json:"steps"`
+	Outputs    map[string]string `json:"outputs"` // Named outputs declared by the command template
+	RolledBack bool              `json:"rolled_back"`
+	Changed    bool              `json:"changed"` // Any step changed the host
+	Check      bool              `json:"check"`   // Check mode: changes were reported, not applied
+}
+
+// JobStepResult is the outcome of one step of a run job
+type JobStepResult struct {
+	Step            int               `json:"step"`
+	ID              string            `json:"id,omitempty"`
+	Action          string            `json:"action"`
+	ExitCode        int               `json:"exit_code"`
+	Stdout          string            `json:"stdout"`
+	Stderr          string            `json:"stderr"`
+	StdoutTruncated bool              `json:"stdout_truncated,omitempty"`
+	DurationMs      int64             `json:"duration_ms"`
+	FilesChanged    []string          `json:"files_changed,omitempty"`
+	Outputs         map[string]string `json:"outputs,omitempty"`
+	RolledBack      bool              `json:"rolled_back"`
+	Error           string            `json:"error,omitempty"`
+	Skipped         bool              `json:"skipped,omitempty"`  // The step's when condition was false
+	Attempts        int               `json:"attempts,omitempty"` // Runs including retries
+	Changed         bool              `json:"changed"`            // The step changed the host, or would in check mode
+	Unknown         bool              `json:"unknown,omitempty"`  // Check mode: not run, so its outcome is unknown
+}
+
+// ParseResult decodes the structured result. It returns nil when the agent didn't
+// report one.
+func (j *Job) ParseResult() *JobResult {
+	if j.Result == nil || string(*j.Result) == "null" {
+		return nil
+	}
+	var result JobResult
+	if err := json.Unmarshal(*j.Result, &result); err != nil {
+		return nil
+	}
+	return &result
+}
+
+// FailedStep returns the last step that failed, or nil
+func (r *JobResult) FailedStep() *JobStepResult {
+	for i := len(r.Steps) - 1; i >= 0; i-- {
+		if r.Steps[i].ExitCode != 0 || r.Steps[i].Error != "" {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// FailureMessage describes why the job failed: the failed step's error and stderr
+// when the agent reported a result, else the end of the logs. It keeps the last
+// limit bytes.
+func (j *Job) FailureMessage(limit int) string {
+	var msg string
+	if result := j.ParseResult(); result != nil {
+		if step := result.FailedStep(); step != nil {
+			reason := step.Error
+			if reason == "" {
+				reason = fmt.Sprintf("exit code %d", step.ExitCode)
+			}
+			msg = fmt.Sprintf("Step %d (%s) failed: %s", step.Step, step.Action, reason)
+			if stderr := strings.TrimSpace(step.Stderr); stderr != "" {
+				msg += "\n" + stderr
+			}
+		}
+	}
+	if msg == "" && j.Logs != nil {
+		msg = *j.Logs
+	}
+
+	if len(msg) > limit {
+		msg = msg[len(msg)-limit:]
+		for len(msg) > 0 && !utf8.RuneStart(msg[0]) {
+			msg = msg[1:]
+		}
+	}
+	return msg
+}
+
+// JobLogChunk is a piece of output streamed by the agent while a job runs
+type JobLogChunk struct {
+	ID        int64     `db:"id" json:"id"`
+	JobID     uuid.UUID `db:"job_id" json:"job_id"`
+	Attempt   int       `db:"attempt" json:"attempt"`
+	Seq       int       `db:"seq" json:"seq"`
+	Step      int       `db:"step" json:"step"`
+	Kind      string    `db:"kind" json:"kind"` // output, step_start, step_end, truncated
+	Content   string    `db:"content" json:"content"`
+	ExitCode  *int      `db:"exit_code" json:"exit_code"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
@@ -17,6 +17,7 @@ type Machine struct {
 	IPAddress     *string    `db:"ip_address" json:"ip_address"`
 	DetectedIPs   json.RawMessage `db:"detected_ips" json:"detected_ips"` // All IPs from interfaces
 	PrimaryIP     *string    `db:"primary_ip" json:"primary_ip"`          // Selected IP for passthrough
+	PrimaryIPv6   *string    `db:"primary_ipv6" json:"primary_ipv6"`      // Selected IPv6 for passthrough AAAA records
 	UbuntuVersion *string    `db:"ubuntu_version" json:"ubuntu_version"`
 	NotesMD       *string    `db:"notes_md" json:"notes_md"`
 	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
@@ -59,6 +60,7 @@ type MachineWithDetails struct {
 	IPAddress       *string         `db:"ip_address" json:"ip_address"`
 	DetectedIPs     json.RawMessage `db:"detected_ips" json:"detected_ips"`
 	PrimaryIP       *string         `db:"primary_ip" json:"primary_ip"`
+	PrimaryIPv6     *string         `db:"primary_ipv6" json:"primary_ipv6"`
 	UbuntuVersion   *string         `db:"ubuntu_version" json:"ubuntu_version"`
 	NotesMD         *string    `db:"notes_md" json:"notes_md"`
 	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// NotificationChannel is a destination for event notifications
+type NotificationChannel struct {
+	ID        uuid.UUID       `db:"id" json:"id"`
+	OwnerID   uuid.UUID       `db:"owner_id" json:"owner_id"`
+	Name      string          `db:"name" json:"name"`
+	Type      string          `db:"type" json:"type"`     // webhook, telegram, smtp
+	Config    json.RawMessage `db:"config" json:"config"` // Secrets are redacted in API responses
+	IsEnabled bool            `db:"is_enabled" json:"is_enabled"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// NotificationSubscription selects which events a channel receives
+type NotificationSubscription struct {
+	ID        uuid.UUID      `db:"id" json:"id"`
+	ChannelID uuid.UUID      `db:"channel_id" json:"channel_id"`
+	Scope     string         `db:"scope" json:"scope"` // global, project, pool
+	ProjectID *uuid.UUID     `db:"project_id" json:"project_id"`
+	PoolType  *string        `db:"pool_type" json:"pool_type"` // 'record' or 'wildcard'
+	PoolID    *uuid.UUID     `db:"pool_id" json:"pool_id"`
+	Events    pq.StringArray `db:"events" json:"events"` // Empty = all events
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
+
+// NotificationDelivery is one queued or attempted notification (the delivery log)
+type NotificationDelivery struct {
+	ID             uuid.UUID       `db:"id" json:"id"`
+	ChannelID      uuid.UUID       `db:"channel_id" json:"channel_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"` // pending, sending, delivered, failed
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	ResponseStatus *int            `db:"response_status" json:"response_status"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// NotificationDeliveryWithChannel includes the channel name for display
+type NotificationDeliveryWithChannel struct {
+	NotificationDelivery
+	ChannelName string `db:"channel_name" json:"channel_name"`
+	ChannelType string `db:"channel_type" json:"channel_type"`
+}
@@ -44,8 +44,8 @@ type PassthroughPool struct {
 	TargetIP           string          `db:"target_ip" json:"target_ip"`
 	TargetPort         int             `db:"target_port" json:"target_port"`
 	TargetPortHTTP     int             `db:"target_port_http" json:"target_port_http"`       // Port for HTTP (80) passthrough
-	RotationStrategy   string          `db:"rotation_strategy" json:"rotation_strategy"`     // round_robin, random
-	RotationMode       string          `db:"rotation_mode" json:"rotation_mode"`             // interval, scheduled
+	RotationStrategy   string          `db:"rotation_strategy" json:"rotation_strategy"`     // round_robin, random, weighted, least_recently_used, multi
+	RotationMode       string          `db:"rotation_mode" json:"rotation_mode"`             // interval, scheduled, failover
 	IntervalMinutes    int             `db:"interval_minutes" json:"interval_minutes"`
 	ScheduledTimes     JSONStringArray `db:"scheduled_times" json:"scheduled_times"`         // JSON array
 	HealthCheckEnabled bool            `db:"health_check_enabled" json:"health_check_enabled"`
@@ -55,6 +55,23 @@ type PassthroughPool struct {
 	IsPaused           bool            `db:"is_paused" json:"is_paused"`
 	LastRotatedAt      *time.Time      `db:"last_rotated_at" json:"last_rotated_at"`
 	GroupIDs           pq.StringArray  `db:"group_ids" json:"group_ids"`                     // Machine groups for dynamic membership (UUID[])
+	HealthCheckPath    string          `db:"health_check_path" json:"health_check_path"`     // HTTP probe path, empty = TCP/TLS only
+	HealthCheckHost    string          `db:"health_check_host" json:"health_check_host"`     // SNI/Host for probes, empty = record FQDN
+	ConfirmPropagation bool            `db:"confirm_propagation" json:"confirm_propagation"` // Wait for all nameservers before advancing last_rotated_at
+	PropagationStatus  string          `db:"propagation_status" json:"propagation_status"`   // none, pending, confirmed, timeout
+	PropagationPendingSince *time.Time `db:"propagation_pending_since" json:"propagation_pending_since"`
+	FailoverEnabled    bool            `db:"failover_enabled" json:"failover_enabled"`         // Rotate away from an unhealthy current machine
+	PreferredMachineID *uuid.UUID      `db:"preferred_machine_id" json:"preferred_machine_id"` // Fail back here once healthy
+	AnswerCount        int             `db:"answer_count" json:"answer_count"`                 // Members published at once by the multi strategy
+	CronExpression     string          `db:"cron_expression" json:"cron_expression"`           // Scheduled mode, e.g. "0 */6 * * mon-fri"
+	Timezone           string          `db:"timezone" json:"timezone"`                         // IANA zone for cron_expression/scheduled_times
+	NextRotationAt     *time.Time      `db:"next_rotation_at" json:"next_rotation_at"`         // Next planned scheduled/interval rotation
+	IPv6Enabled        bool            `db:"ipv6_enabled" json:"ipv6_enabled"`                 // Also manage an AAAA record from members' primary_ipv6
+	WarmupEnabled      bool            `db:"warmup_enabled" json:"warmup_enabled"`             // Push and confirm nginx config on the target before flipping DNS
+	WarmupProbe        bool            `db:"warmup_probe" json:"warmup_probe"`                 // Also probe the target before flipping DNS
+	WarmupTimeoutSeconds int           `db:"warmup_timeout_seconds" json:"warmup_timeout_seconds"`
+	LastRotationError  *string         `db:"last_rotation_error" json:"last_rotation_error"`   // Why the last rotation was aborted
+	LastRotationErrorAt *time.Time     `db:"last_rotation_error_at" json:"last_rotation_error_at"`
 	CreatedAt          time.Time       `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time       `db:"updated_at" json:"updated_at"`
 }
@@ -65,6 +82,7 @@ type PassthroughMember struct {
 	PoolID             uuid.UUID `db:"pool_id" json:"pool_id"`
 	MachineID          uuid.UUID `db:"machine_id" json:"machine_id"`
 	Priority           int       `db:"priority" json:"priority"`
+	Weight             int       `db:"weight" json:"weight"` // Relative share for the weighted strategy
 	IsEnabled          bool      `db:"is_enabled" json:"is_enabled"`
 	NginxConfigApplied bool      `db:"nginx_config_applied" json:"nginx_config_applied"`
 	CreatedAt          time.Time `db:"created_at" json:"created_at"`
@@ -73,10 +91,12 @@ type PassthroughMember struct {
 // PassthroughMemberWithMachine includes machine details
 type PassthroughMemberWithMachine struct {
 	PassthroughMember
-	MachineName string     `db:"machine_name" json:"machine_name"`
-	MachineIP   string     `db:"machine_ip" json:"machine_ip"`
-	LastSeen    *time.Time `db:"last_seen" json:"last_seen"`
-	IsOnline    bool       `json:"is_online"` // Computed
+	MachineName string        `db:"machine_name" json:"machine_name"`
+	MachineIP   string        `db:"machine_ip" json:"machine_ip"`
+	MachineIPv6 string        `db:"machine_ipv6" json:"machine_ipv6"` // Primary IPv6, empty if none
+	LastSeen    *time.Time    `db:"last_seen" json:"last_seen"`
+	IsOnline    bool          `json:"is_online"` // Computed
+	Health      *MemberHealth `db:"-" json:"health,omitempty"`
 }
 
 // WildcardPool represents a wildcard DNS rotation pool for *.domain.com
@@ -98,6 +118,23 @@ type WildcardPool struct {
 	IsPaused           bool            `db:"is_paused" json:"is_paused"`
 	LastRotatedAt      *time.Time      `db:"last_rotated_at" json:"last_rotated_at"`
 	GroupIDs           pq.StringArray  `db:"group_ids" json:"group_ids"`               // Machine groups for dynamic membership (UUID[])
+	HealthCheckPath    string          `db:"health_check_path" json:"health_check_path"`
+	HealthCheckHost    string          `db:"health_check_host" json:"health_check_host"`
+	ConfirmPropagation bool            `db:"confirm_propagation" json:"confirm_propagation"`
+	PropagationStatus  string          `db:"propagation_status" json:"propagation_status"` // none, pending, confirmed, timeout
+	PropagationPendingSince *time.Time `db:"propagation_pending_since" json:"propagation_pending_since"`
+	FailoverEnabled    bool            `db:"failover_enabled" json:"failover_enabled"`
+	PreferredMachineID *uuid.UUID      `db:"preferred_machine_id" json:"preferred_machine_id"`
+	AnswerCount        int             `db:"answer_count" json:"answer_count"`
+	CronExpression     string          `db:"cron_expression" json:"cron_expression"`
+	Timezone           string          `db:"timezone" json:"timezone"`
+	NextRotationAt     *time.Time      `db:"next_rotation_at" json:"next_rotation_at"`
+	IPv6Enabled        bool            `db:"ipv6_enabled" json:"ipv6_enabled"`
+	WarmupEnabled      bool            `db:"warmup_enabled" json:"warmup_enabled"`
+	WarmupProbe        bool            `db:"warmup_probe" json:"warmup_probe"`
+	WarmupTimeoutSeconds int           `db:"warmup_timeout_seconds" json:"warmup_timeout_seconds"`
+	LastRotationError  *string         `db:"last_rotation_error" json:"last_rotation_error"`
+	LastRotationErrorAt *time.Time     `db:"last_rotation_error_at" json:"last_rotation_error_at"`
 	CreatedAt          time.Time       `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time       `db:"updated_at" json:"updated_at"`
 }
@@ -108,6 +145,7 @@ type WildcardPoolMember struct {
 	PoolID             uuid.UUID `db:"pool_id" json:"pool_id"`
 	MachineID          uuid.UUID `db:"machine_id" json:"machine_id"`
 	Priority           int       `db:"priority" json:"priority"`
+	Weight             int       `db:"weight" json:"weight"` // Relative share for the weighted strategy
 	IsEnabled          bool      `db:"is_enabled" json:"is_enabled"`
 	NginxConfigApplied bool      `db:"nginx_config_applied" json:"nginx_config_applied"`
 	CreatedAt          time.Time `db:"created_at" json:"created_at"`
@@ -116,10 +154,30 @@ type WildcardPoolMember struct {
 // WildcardMemberWithMachine includes machine details
 type WildcardMemberWithMachine struct {
 	WildcardPoolMember
-	MachineName string     `db:"machine_name" json:"machine_name"`
-	MachineIP   string     `db:"machine_ip" json:"machine_ip"`
-	LastSeen    *time.Time `db:"last_seen" json:"last_seen"`
-	IsOnline    bool       `json:"is_online"` // Computed
+	MachineName string        `db:"machine_name" json:"machine_name"`
+	MachineIP   string        `db:"machine_ip" json:"machine_ip"`
+	MachineIPv6 string        `db:"machine_ipv6" json:"machine_ipv6"` // Primary IPv6, empty if none
+	LastSeen    *time.Time    `db:"last_seen" json:"last_seen"`
+	IsOnline    bool          `json:"is_online"` // Computed
+	Health      *MemberHealth `db:"-" json:"health,omitempty"`
+}
+
+// MemberHealth is the probed health of a machine within one pool
+type MemberHealth struct {
+	ID                   uuid.UUID  `db:"id" json:"id"`
+	PoolType             string     `db:"pool_type" json:"pool_type"` // 'record' or 'wildcard'
+	PoolID               uuid.UUID  `db:"pool_id" json:"pool_id"`
+	MachineID            uuid.UUID  `db:"machine_id" json:"machine_id"`
+	Status               string     `db:"status" json:"status"` // unknown, healthy, unhealthy
+	ConsecutiveSuccesses int        `db:"consecutive_successes" json:"consecutive_successes"`
+	ConsecutiveFailures  int        `db:"consecutive_failures" json:"consecutive_failures"`
+	TCPOK                bool       `db:"tcp_ok" json:"tcp_ok"`
+	TLSOK                bool       `db:"tls_ok" json:"tls_ok"`
+	HTTPOK               *bool      `db:"http_ok" json:"http_ok"`
+	LatencyMs            int        `db:"latency_ms" json:"latency_ms"`
+	LastError            *string    `db:"last_error" json:"last_error"`
+	LastProbeAt          *time.Time `db:"last_probe_at" json:"last_probe_at"`
+	LastChangeAt         *time.Time `db:"last_change_at" json:"last_change_at"`
 }
 
 // RotationHistory logs each rotation event
@@ -135,6 +193,42 @@ type RotationHistory struct {
 	ToIP          string     `db:"to_ip" json:"to_ip"`
 	Trigger       string     `db:"trigger" json:"trigger"`           // 'scheduled', 'manual', 'health'
 	RotatedAt     time.Time  `db:"rotated_at" json:"rotated_at"`
+	AnswerMachineIDs pq.StringArray `db:"answer_machine_ids" json:"answer_machine_ids"` // All published members, empty before analytics
+}
+
+// RotationOperation journals one rotation so it can be resumed or rolled back after a crash
+type RotationOperation struct {
+	ID               uuid.UUID       `db:"id" json:"id"`
+	PoolType         string          `db:"pool_type" json:"pool_type"` // 'record' or 'wildcard'
+	PoolID           uuid.UUID       `db:"pool_id" json:"pool_id"`
+	DNSDomainID      *uuid.UUID      `db:"dns_domain_id" json:"dns_domain_id"`
+	State            string          `db:"state" json:"state"` // planned, dns_updated, committed, rolled_back, aborted
+	Trigger          string          `db:"trigger" json:"trigger"`
+	FromMachineID    *uuid.UUID      `db:"from_machine_id" json:"from_machine_id"`
+	FromIP           string          `db:"from_ip" json:"from_ip"`
+	ToMachineID      uuid.UUID       `db:"to_machine_id" json:"to_machine_id"`
+	ToIP             string          `db:"to_ip" json:"to_ip"`
+	NewIndex         int             `db:"new_index" json:"new_index"`
+	AwaitPropagation bool            `db:"await_propagation" json:"await_propagation"`
+	ResetSchedule    bool            `db:"reset_schedule" json:"reset_schedule"`
+	PreviousAnswers  json.RawMessage `db:"previous_answers" json:"previous_answers"`
+	Instance         string          `db:"instance" json:"instance"`
+	Error            *string         `db:"error" json:"error"`
+	CreatedAt        time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time       `db:"updated_at" json:"updated_at"`
+	AnswerMachineIDs pq.StringArray  `db:"answer_machine_ids" json:"answer_machine_ids"` // All published members, to_machine_id first
+}
+
+// MachineOutage is a period during which a machine was offline (agent) or failed a pool's probes
+type MachineOutage struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	MachineID uuid.UUID  `db:"machine_id" json:"machine_id"`
+	Source    string     `db:"source" json:"source"` // agent, probe
+	PoolType  *string    `db:"pool_type" json:"pool_type"`
+	PoolID    *uuid.UUID `db:"pool_id" json:"pool_id"`
+	Reason    string     `db:"reason" json:"reason"`
+	StartedAt time.Time  `db:"started_at" json:"started_at"`
+	EndedAt   *time.Time `db:"ended_at" json:"ended_at"` // nil while ongoing
 }
 
 // RotationHistoryWithDetails includes machine names
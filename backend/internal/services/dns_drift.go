@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"configuratix/backend/internal/audit"
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/dns"
+	"configuratix/backend/internal/models"
+)
+
+// DNSDriftDetector periodically compares local DNS records with the provider
+// and stores the result on each managed domain. With several backend instances
+// only the holder of the leader lock checks, so corrections aren't pushed twice.
+type DNSDriftDetector struct {
+	db          *database.DB
+	syncService *dns.SyncService
+	interval    time.Duration
+	stop        chan struct{}
+	leader      *LeaderLock
+}
+
+// NewDNSDriftDetector creates a new drift detector
+func NewDNSDriftDetector(db *database.DB, intervalMinutes int) *DNSDriftDetector {
+	if intervalMinutes < 1 {
+		intervalMinutes = 30
+	}
+	return &DNSDriftDetector{
+		db:          db,
+		syncService: dns.NewSyncService(),
+		interval:    time.Duration(intervalMinutes) * time.Minute,
+		stop:        make(chan struct{}),
+		leader:      NewLeaderLock(db, "dns-drift-detector"),
+	}
+}
+
+// Start begins the detection loop
+func (d *DNSDriftDetector) Start() {
+	log.Printf("DNS drift detector started (every %s)", d.interval)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if d.leader.IsLeader() {
+				d.checkAll()
+			}
+		case <-d.stop:
+			d.leader.Release()
+			log.Println("DNS drift detector stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the detector
+func (d *DNSDriftDetector) Stop() {
+	close(d.stop)
+}
+
+// checkAll checks every domain that has a DNS account
+func (d *DNSDriftDetector) checkAll() {
+	var domains []models.DNSManagedDomain
+	err := d.db.Select(&domains, "SELECT * FROM dns_managed_domains WHERE dns_account_id IS NOT NULL")
+	if err != nil {
+		log.Printf("DNS drift detector: failed to get domains: %v", err)
+		return
+	}
+
+	for _, domain := range domains {
+		d.CheckDomain(domain)
+	}
+}
+
+// CheckDomain compares one domain against its provider, re-applying local state
+// when the domain is flagged for enforcement, and stores the outcome
+func (d *DNSDriftDetector) CheckDomain(domain models.DNSManagedDomain) {
+	var account models.DNSAccount
+	if err := d.db.Get(&account, "SELECT * FROM dns_accounts WHERE id = $1", domain.DNSAccountID); err != nil {
+		d.saveError(domain, "DNS account not found")
+		return
+	}
+
+	provider, err := ProviderForAccount(account)
+	if err != nil {
+		d.saveError(domain, err.Error())
+		return
+	}
+
+	var localDBRecords []models.DNSRecord
+	d.db.Select(&localDBRecords, "SELECT * FROM dns_records WHERE dns_domain_id = $1", domain.ID)
+
+	localRecords := make([]dns.Record, len(localDBRecords))
+	for i, r := range localDBRecords {
+		localRecords[i] = DNSRecordFromModel(r)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	remoteRecords, err := provider.ListRecords(ctx, domain.FQDN)
+	if err != nil {
+		d.saveError(domain, "failed to fetch remote records: "+err.Error())
+		return
+	}
+
+	result := d.syncService.Compare(localRecords, remoteRecords)
+	if result.InSync {
+		d.save(domain, "in_sync", result, nil)
+		return
+	}
+
+	// Only alert when the domain goes from clean to drifted, not on every pass
+	if domain.DriftStatus != "drifted" {
+		audit.Log(audit.EventDNSDriftDetected, "system", domain.ID.String(), map[string]interface{}{
+			"fqdn":      domain.FQDN,
+			"missing":   len(result.Created),
+			"extra":     len(result.Deleted),
+			"conflicts": len(result.Conflicts),
+		})
+	}
+
+	if !domain.DriftEnforce {
+		d.save(domain, "drifted", result, nil)
+		return
+	}
+
+	applied, err := d.syncService.ApplyToRemote(ctx, provider, domain.FQDN, localRecords, remoteRecords)
+	if err != nil {
+		msg := "enforce failed: " + err.Error()
+		d.save(domain, "drifted", result, &msg)
+		return
+	}
+	UpdateRecordSyncStatus(d.db, localDBRecords, applied)
+	if len(applied.Errors) > 0 {
+		msg := "enforce failed: " + applied.Errors[0]
+		d.save(domain, "drifted", result, &msg)
+		return
+	}
+
+	audit.Log(audit.EventDNSDriftEnforced, "system", domain.ID.String(), map[string]interface{}{
+		"fqdn": domain.FQDN,
+	})
+	d.save(domain, "enforced", result, nil)
+}
+
+func (d *DNSDriftDetector) save(domain models.DNSManagedDomain, status string, result *dns.SyncResult, driftErr *string) {
+	resultJSON, _ := json.Marshal(result)
+	_, err := d.db.Exec(`
+		UPDATE dns_managed_domains
+		SET drift_status = $1, drift_result = $2, drift_error = $3, drift_checked_at = NOW()
+		WHERE id = $4
+	`, status, resultJSON, driftErr, domain.ID)
+	if err != nil {
+		log.Printf("DNS drift detector: failed to save result for %s: %v", domain.FQDN, err)
+	}
+}
+
+func (d *DNSDriftDetector) saveError(domain models.DNSManagedDomain, msg string) {
+	log.Printf("DNS drift detector: %s: %s", domain.FQDN, msg)
+	d.db.Exec(`
+		UPDATE dns_managed_domains
+		SET drift_status = 'error', drift_error = $1, drift_checked_at = NOW()
+		WHERE id = $2
+	`, msg, domain.ID)
+}
+
+// DNSRecordFromModel converts a local DB record into a provider record
+func DNSRecordFromModel(r models.DNSRecord) dns.Record {
+	return dns.Record{
+		ID:           r.ID.String(),
+		Name:         r.Name,
+		Type:         r.RecordType,
+		Value:        r.Value,
+		TTL:          r.TTL,
+		Priority:     intValue(r.Priority),
+		Weight:       intValue(r.Weight),
+		Port:         intValue(r.Port),
+		Flags:        intValue(r.Flags),
+		Tag:          stringValue(r.Tag),
+		Usage:        intValue(r.TLSAUsage),
+		Selector:     intValue(r.TLSASelector),
+		MatchingType: intValue(r.TLSAMatchingType),
+		Proxied:      r.Proxied,
+	}
+}
+
+// UpdateRecordSyncStatus stores the per-record outcome of an ApplyToRemote run
+func UpdateRecordSyncStatus(db *database.DB, localDBRecords []models.DNSRecord, result *dns.SyncResult) {
+	now := time.Now()
+	changed := make(map[string]dns.ChangeResult)
+	for _, cr := range result.Changes {
+		if cr.Change.LocalID != "" {
+			changed[cr.Change.LocalID] = cr
+		}
+	}
+	for _, r := range localDBRecords {
+		cr, ok := changed[r.ID.String()]
+		if !ok {
+			// Already in sync, nothing was sent for this record
+			db.Exec(`
+				UPDATE dns_records
+				SET sync_status = 'synced', last_synced_at = $1
+				WHERE id = $2
+			`, now, r.ID)
+			continue
+		}
+
+		switch cr.Status {
+		case dns.ChangeStatusApplied:
+			db.Exec(`
+				UPDATE dns_records
+				SET sync_status = 'synced', sync_error = NULL, remote_record_id = $1, last_synced_at = $2
+				WHERE id = $3
+			`, cr.Record.ID, now, r.ID)
+		case dns.ChangeStatusFailed, dns.ChangeStatusRollbackFailed:
+			db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", cr.Error, r.ID)
+		default:
+			// Rolled back or skipped because another change failed
+			db.Exec("UPDATE dns_records SET sync_status = 'pending' WHERE id = $1", r.ID)
+		}
+	}
+}
+
+func intValue(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func stringValue(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
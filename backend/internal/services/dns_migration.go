@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/dns"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// DNSZoneMigrator moves managed domains between DNS accounts. Records are copied
+// to the target zone and kept in sync until the registrar delegation points at the
+// target nameservers, then the domain is switched over to the target account.
+// With several backend instances only the holder of the leader lock polls.
+type DNSZoneMigrator struct {
+	db          *database.DB
+	syncService *dns.SyncService
+	leader      *LeaderLock
+	interval    time.Duration
+	stop        chan struct{}
+}
+
+// NewDNSZoneMigrator creates a new zone migrator
+func NewDNSZoneMigrator(db *database.DB) *DNSZoneMigrator {
+	return &DNSZoneMigrator{
+		db:          db,
+		syncService: dns.NewSyncService(),
+		leader:      NewLeaderLock(db, "dns-zone-migrator"),
+		interval:    1 * time.Minute, // Check delegation every minute
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start begins polling migrations that are waiting for the delegation to flip
+func (m *DNSZoneMigrator) Start() {
+	log.Println("DNS zone migrator started")
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if m.leader.IsLeader() {
+				m.tick()
+			}
+		case <-m.stop:
+			m.leader.Release()
+			log.Println("DNS zone migrator stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the migrator
+func (m *DNSZoneMigrator) Stop() {
+	close(m.stop)
+}
+
+func (m *DNSZoneMigrator) tick() {
+	var migrations []models.DNSZoneMigration
+	err := m.db.Select(&migrations, "SELECT * FROM dns_zone_migrations WHERE status = 'awaiting_ns'")
+	if err != nil {
+		log.Printf("DNS zone migrator: failed to get migrations: %v", err)
+		return
+	}
+
+	for _, migration := range migrations {
+		m.Poll(migration)
+	}
+}
+
+// Begin creates the zone on the target account, copies the domain's records to it
+// and returns the migration, now waiting for the delegation to change
+func (m *DNSZoneMigrator) Begin(domain models.DNSManagedDomain, target models.DNSAccount) (*models.DNSZoneMigration, error) {
+	if domain.DNSAccountID != nil && *domain.DNSAccountID == target.ID {
+		return nil, fmt.Errorf("domain already uses this DNS account")
+	}
+
+	provider, err := ProviderForAccount(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrationID uuid.UUID
+	err = m.db.Get(&migrationID, `
+		INSERT INTO dns_zone_migrations (dns_domain_id, from_account_id, to_account_id)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, domain.ID, domain.DNSAccountID, target.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "idx_dns_zone_migrations_active") {
+			return nil, fmt.Errorf("a migration is already in progress for this domain")
+		}
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	nameservers, err := provider.GetOrCreateZone(ctx, domain.FQDN)
+	if err != nil {
+		m.fail(migrationID, "failed to create zone on target: "+err.Error())
+		return m.Get(migrationID)
+	}
+	if len(nameservers) == 0 {
+		// Without them the delegation check can't tell when to cut over
+		m.fail(migrationID, "target zone has no nameservers; configure its NS records on the target first")
+		return m.Get(migrationID)
+	}
+
+	copied, _, err := m.copyRecords(ctx, domain, provider, target.Provider)
+	if err != nil {
+		m.fail(migrationID, "failed to copy records: "+err.Error())
+		return m.Get(migrationID)
+	}
+
+	m.db.Exec(`
+		UPDATE dns_zone_migrations
+		SET status = 'awaiting_ns', nameservers = $1, records_copied = $2, updated_at = NOW()
+		WHERE id = $3
+	`, pq.Array(nameservers), copied, migrationID)
+
+	log.Printf("DNS zone migrator: copied %d records of %s to %s account, waiting for NS %v",
+		copied, domain.FQDN, target.Provider, nameservers)
+	return m.Get(migrationID)
+}
+
+// Get returns a migration by ID
+func (m *DNSZoneMigrator) Get(id uuid.UUID) (*models.DNSZoneMigration, error) {
+	var migration models.DNSZoneMigration
+	if err := m.db.Get(&migration, "SELECT * FROM dns_zone_migrations WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+// Cancel stops an in-flight migration. The target zone is left in place.
+func (m *DNSZoneMigrator) Cancel(id uuid.UUID) error {
+	result, err := m.db.Exec(`
+		UPDATE dns_zone_migrations SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1 AND status IN ('copying', 'awaiting_ns')
+	`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("migration is not in progress")
+	}
+	return nil
+}
+
+// Poll re-copies local state to the target (so passthrough rotations made on the
+// old account are mirrored) and switches the domain over once the delegation flips
+func (m *DNSZoneMigrator) Poll(migration models.DNSZoneMigration) {
+	if migration.Status != "awaiting_ns" {
+		return
+	}
+	if len(migration.Nameservers) == 0 {
+		m.fail(migration.ID, "target zone has no nameservers")
+		return
+	}
+
+	var domain models.DNSManagedDomain
+	if err := m.db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1", migration.DNSDomainID); err != nil {
+		m.fail(migration.ID, "domain not found")
+		return
+	}
+
+	var target models.DNSAccount
+	if err := m.db.Get(&target, "SELECT * FROM dns_accounts WHERE id = $1", migration.ToAccountID); err != nil {
+		m.fail(migration.ID, "target DNS account not found")
+		return
+	}
+
+	provider, err := ProviderForAccount(target)
+	if err != nil {
+		m.fail(migration.ID, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	copied, localDBRecords, err := m.copyRecords(ctx, domain, provider, target.Provider)
+	if err != nil {
+		// Transient provider errors should not abort the migration
+		msg := "failed to mirror records: " + err.Error()
+		m.db.Exec("UPDATE dns_zone_migrations SET error = $1, last_checked_at = NOW(), updated_at = NOW() WHERE id = $2", msg, migration.ID)
+		return
+	}
+
+	status := dns.CheckNameservers(domain.FQDN, migration.Nameservers)
+	m.db.Exec(`
+		UPDATE dns_zone_migrations
+		SET ns_actual = $1, records_copied = $2, error = NULL, last_checked_at = NOW(), updated_at = NOW()
+		WHERE id = $3
+	`, pq.Array(status.Actual), copied, migration.ID)
+
+	if !status.Valid {
+		return
+	}
+
+	m.cutover(ctx, migration, domain, provider, localDBRecords, status)
+}
+
+// cutover points the domain at the target account and adopts the target's record IDs,
+// so passthrough rotation updates the right records from now on
+func (m *DNSZoneMigrator) cutover(ctx context.Context, migration models.DNSZoneMigration, domain models.DNSManagedDomain, provider dns.Provider, localDBRecords []models.DNSRecord, status *dns.NSStatus) {
+	remoteRecords, err := provider.ListRecords(ctx, domain.FQDN)
+	if err != nil {
+		m.db.Exec("UPDATE dns_zone_migrations SET error = $1, updated_at = NOW() WHERE id = $2",
+			"failed to fetch target records: "+err.Error(), migration.ID)
+		return
+	}
+
+	localRecords := make([]dns.Record, len(localDBRecords))
+	for i, r := range localDBRecords {
+		localRecords[i] = DNSRecordFromModel(r)
+	}
+	remoteIDs := dns.MatchRecords(localRecords, remoteRecords)
+
+	tx, err := m.db.Beginx()
+	if err != nil {
+		log.Printf("DNS zone migrator: failed to start cutover for %s: %v", domain.FQDN, err)
+		return
+	}
+	defer tx.Rollback()
+
+	cutoverFailed := func(err error) {
+		log.Printf("DNS zone migrator: cutover of %s failed: %v", domain.FQDN, err)
+		m.db.Exec("UPDATE dns_zone_migrations SET error = $1, updated_at = NOW() WHERE id = $2",
+			"cutover failed: "+err.Error(), migration.ID)
+	}
+
+	for _, r := range localDBRecords {
+		remoteID, ok := remoteIDs[r.ID.String()]
+		if !ok {
+			_, err = tx.Exec("UPDATE dns_records SET remote_record_id = NULL, sync_status = 'pending' WHERE id = $1", r.ID)
+		} else {
+			_, err = tx.Exec(`
+				UPDATE dns_records
+				SET remote_record_id = $1, sync_status = 'synced', sync_error = NULL, last_synced_at = NOW()
+				WHERE id = $2
+			`, remoteID, r.ID)
+		}
+		if err != nil {
+			cutoverFailed(err)
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE dns_managed_domains
+		SET dns_account_id = $1, ns_status = 'valid', ns_last_check = NOW(), ns_expected = $2, ns_actual = $3, updated_at = NOW()
+		WHERE id = $4
+	`, migration.ToAccountID, pq.Array(migration.Nameservers), pq.Array(status.Actual), domain.ID); err != nil {
+		cutoverFailed(err)
+		return
+	}
+
+	// Only an awaiting migration completes; a cancel in the meantime wins
+	result, err := tx.Exec(`
+		UPDATE dns_zone_migrations
+		SET status = 'completed', detached_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'awaiting_ns'
+	`, migration.ID)
+	if err != nil {
+		cutoverFailed(err)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		cutoverFailed(err)
+		return
+	}
+	NotifyNSStatus(m.db, domain, "valid", migration.Nameservers, status.Actual)
+
+	log.Printf("DNS zone migrator: %s now served by account %s", domain.FQDN, migration.ToAccountID)
+}
+
+// copyRecords pushes the domain's local records to the target provider and returns
+// how many were copied along with the local records used
+func (m *DNSZoneMigrator) copyRecords(ctx context.Context, domain models.DNSManagedDomain, provider dns.Provider, providerName string) (int, []models.DNSRecord, error) {
+	var localDBRecords []models.DNSRecord
+	if err := m.db.Select(&localDBRecords, "SELECT * FROM dns_records WHERE dns_domain_id = $1", domain.ID); err != nil {
+		return 0, nil, err
+	}
+
+	var localRecords []dns.Record
+	for _, r := range localDBRecords {
+		if isApexNS(r.Name, r.RecordType) {
+			continue // The old provider's delegation must not be copied over
+		}
+		localRecords = append(localRecords, translateRecordForProvider(DNSRecordFromModel(r), providerName))
+	}
+
+	remoteRecords, err := provider.ListRecords(ctx, domain.FQDN)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Leave the target's own apex NS records alone
+	var targetRecords []dns.Record
+	for _, r := range remoteRecords {
+		if !isApexNS(r.Name, r.Type) {
+			targetRecords = append(targetRecords, r)
+		}
+	}
+
+	applied, err := m.syncService.ApplyToRemote(ctx, provider, domain.FQDN, localRecords, targetRecords)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(applied.Errors) > 0 {
+		return 0, nil, fmt.Errorf("%s", applied.Errors[0])
+	}
+
+	return len(localRecords), localDBRecords, nil
+}
+
+// translateRecordForProvider adjusts provider-specific fields of a record copied
+// from another provider
+func translateRecordForProvider(r dns.Record, providerName string) dns.Record {
+	if providerName != "cloudflare" {
+		// Only Cloudflare proxies, and its TTL of 1 means "auto"
+		r.Proxied = false
+		if r.TTL <= 1 {
+			r.TTL = 600
+		}
+	}
+	return r
+}
+
+func isApexNS(name, recordType string) bool {
+	return recordType == "NS" && (name == "@" || name == "")
+}
+
+// ProviderForAccount creates the DNS provider client for an account
+func ProviderForAccount(account models.DNSAccount) (dns.Provider, error) {
+	apiID := ""
+	if account.ApiID != nil {
+		apiID = *account.ApiID
+	}
+	return dns.NewProvider(account.Provider, apiID, account.ApiToken)
+}
+
+func (m *DNSZoneMigrator) fail(id uuid.UUID, msg string) {
+	log.Printf("DNS zone migrator: migration %s failed: %s", id, msg)
+	m.db.Exec("UPDATE dns_zone_migrations SET status = 'failed', error = $1, updated_at = NOW() WHERE id = $2", msg, id)
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// JobLeaseDuration is how long a claimed job stays with its agent without a renewal
+const JobLeaseDuration = 90 * time.Second
+
+// LegacyJobLease is the lease of jobs handed to agents that don't renew leases. They
+// can't be told apart from a dead agent, so the lease covers any reasonable job.
+const LegacyJobLease = 6 * time.Hour
+
+// Job lease states returned to a renewing agent
+const (
+	JobLeaseHeld      = "running"
+	JobLeaseCancelled = "cancelled" // The user cancelled the job; stop it
+	JobLeaseLost      = "lost"      // The lease expired and the job was requeued or failed
+)
+
+// ErrJobNotFound is returned for jobs that don't exist or belong to another agent
+var ErrJobNotFound = errors.New("job not found")
+
+// ClaimJobs atomically moves up to limit of an agent's pending jobs, oldest first, to
+// running under a lease held by workerID. SKIP LOCKED keeps two agent processes (or
+// two polls) from claiming the same job. Each claim starts a new attempt, so the
+// result and the log cap counters start over too.
+func ClaimJobs(db *database.DB, agentID uuid.UUID, workerID string, limit int, lease time.Duration) ([]models.Job, error) {
+	var jobs []models.Job
+	err := db.Select(&jobs, `
+		UPDATE jobs SET
+			status = 'running',
+			attempts = attempts + 1,
+			lease_owner = NULLIF($2, ''),
+			lease_expires_at = NOW() + $3 * INTERVAL '1 second',
+			started_at = NOW(),
+			finished_at = NULL,
+			result = NULL,
+			log_bytes = 0,
+			log_dropped_bytes = 0,
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE agent_id = $1 AND status = 'pending'
+			ORDER BY created_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, agentID, workerID, int(lease.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+// RenewJobLease extends the lease of a running job held by workerID. It returns
+// JobLeaseCancelled or JobLeaseLost when the agent must stop the job.
+func RenewJobLease(db *database.DB, agentID, jobID uuid.UUID, workerID string) (string, error) {
+	result, err := db.Exec(`
+		UPDATE jobs SET lease_expires_at = NOW() + $4 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = $1 AND agent_id = $2 AND status = 'running' AND lease_owner = $3
+	`, jobID, agentID, workerID, int(JobLeaseDuration.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return JobLeaseHeld, nil
+	}
+
+	var status string
+	if err := db.Get(&status, "SELECT status FROM jobs WHERE id = $1 AND agent_id = $2", jobID, agentID); err != nil {
+		return "", ErrJobNotFound
+	}
+	if status == "cancelled" {
+		return JobLeaseCancelled, nil
+	}
+	return JobLeaseLost, nil
+}
+
+// CancelJob cancels a pending or running job. A running job is stopped by its agent at
+// the next lease renewal. Returns false if the job already finished.
+func CancelJob(db *database.DB, jobID uuid.UUID, reason string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE jobs SET status = 'cancelled', logs = COALESCE(logs || E'\n' || $2, $2),
+			finished_at = NOW(), lease_expires_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'running')
+	`, jobID, reason)
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+	JobFinished(db, jobID, false)
+	return true, nil
+}
+
+// JobFinished runs the side effects of a job reaching a final state
+func JobFinished(db *database.DB, jobID uuid.UUID, completed bool) {
+	// Passthrough config jobs gate rotations, so track whether they applied
+	PassthroughConfigJobFinished(db, jobID, completed)
+}
+
+// JobLeaseReaper requeues or fails running jobs whose agent stopped renewing the lease
+type JobLeaseReaper struct {
+	db       *database.DB
+	interval time.Duration
+	stop     chan struct{}
+}
+
+// NewJobLeaseReaper creates a new reaper
+func NewJobLeaseReaper(db *database.DB) *JobLeaseReaper {
+	return &JobLeaseReaper{
+		db:       db,
+		interval: 30 * time.Second,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins the reaper loop
+func (r *JobLeaseReaper) Start() {
+	log.Println("Job lease reaper started")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.reap()
+	for {
+		select {
+		case <-ticker.C:
+			r.reap()
+		case <-r.stop:
+			log.Println("Job lease reaper stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the reaper
+func (r *JobLeaseReaper) Stop() {
+	close(r.stop)
+}
+
+// reap handles expired leases in one statement, so several backend instances can run it
+func (r *JobLeaseReaper) reap() {
+	var expired []struct {
+		ID       uuid.UUID `db:"id"`
+		Status   string    `db:"status"`
+		Attempts int       `db:"attempts"`
+	}
+	err := r.db.Select(&expired, `
+		UPDATE jobs SET
+			status = CASE WHEN attempts < max_attempts THEN 'pending' ELSE 'failed' END,
+			logs = COALESCE(logs || E'\n', '') || CASE WHEN attempts < max_attempts
+				THEN 'Lease expired (agent stopped responding), requeued'
+				ELSE 'Lease expired (agent stopped responding) after ' || attempts || ' attempt(s), giving up' END,
+			finished_at = CASE WHEN attempts < max_attempts THEN NULL ELSE NOW() END,
+			lease_owner = NULL,
+			lease_expires_at = NULL,
+			updated_at = NOW()
+		WHERE status = 'running' AND lease_expires_at < NOW()
+		RETURNING id, status, attempts
+	`)
+	if err != nil {
+		log.Printf("Job lease reaper: failed to reap expired leases: %v", err)
+		return
+	}
+
+	for _, job := range expired {
+		log.Printf("Job lease reaper: lease of job %s expired after attempt %d, now %s", job.ID, job.Attempts, job.Status)
+		if job.Status == "failed" {
+			JobFinished(r.db, job.ID, false)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// MaxJobLogBytes caps the streamed output stored per job. The chunk that crosses the
+// cap is stored as a truncation marker and later output is only counted.
+const MaxJobLogBytes = 4 << 20
+
+// MaxJobLogText caps the log text an agent can append to jobs.logs in one update
+const MaxJobLogText = 256 << 10
+
+// maxMarkerContent caps the content of step boundary chunks (step action, error)
+const maxMarkerContent = 1024
+
+// ErrJobLeaseLost is returned when an agent writes to a job it no longer holds
+var ErrJobLeaseLost = errors.New("job is not leased by this agent")
+
+// AppendJobLogChunks stores output streamed by the agent process holding the job. Chunks
+// are numbered by the agent, so a retried batch doesn't duplicate output.
+func AppendJobLogChunks(db *database.DB, agentID, jobID uuid.UUID, workerID string, chunks []models.JobLogChunk) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var job struct {
+		Status          string  `db:"status"`
+		LeaseOwner      *string `db:"lease_owner"`
+		Attempts        int     `db:"attempts"`
+		LogBytes        int64   `db:"log_bytes"`
+		LogDroppedBytes int64   `db:"log_dropped_bytes"`
+	}
+	err = tx.Get(&job, `
+		SELECT status, lease_owner, attempts, log_bytes, log_dropped_bytes
+		FROM jobs WHERE id = $1 AND agent_id = $2 FOR UPDATE
+	`, jobID, agentID)
+	if err == sql.ErrNoRows {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load job: %w", err)
+	}
+
+	// A cancelled job keeps the output of the agent stopping it
+	owner := job.LeaseOwner != nil && *job.LeaseOwner == workerID
+	if !(job.Status == "running" && (workerID == "" || owner)) && !(job.Status == "cancelled" && owner) {
+		return ErrJobLeaseLost
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Seq < chunks[j].Seq })
+
+	logBytes, dropped := job.LogBytes, job.LogDroppedBytes
+	for _, chunk := range chunks {
+		content := strings.ReplaceAll(chunk.Content, "\x00", "")
+		var exitCode *int
+
+		switch chunk.Kind {
+		case "output":
+			remaining := MaxJobLogBytes - logBytes
+			if int64(len(content)) > remaining {
+				if dropped > 0 {
+					// Past the cap and already marked
+					dropped += int64(len(content))
+					continue
+				}
+				kept := truncateUTF8(content, int(max(remaining, 0)))
+				dropped += int64(len(content) - len(kept))
+				content = kept
+				chunk.Kind = "truncated"
+			}
+		case "step_start", "step_end":
+			content = truncateUTF8(content, maxMarkerContent)
+			if chunk.Kind == "step_end" {
+				exitCode = chunk.ExitCode
+			}
+		default:
+			return fmt.Errorf("invalid chunk kind %q", chunk.Kind)
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO job_log_chunks (job_id, attempt, seq, step, kind, content, exit_code)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (job_id, attempt, seq) DO NOTHING
+		`, jobID, job.Attempts, chunk.Seq, chunk.Step, chunk.Kind, content, exitCode)
+		if err != nil {
+			return fmt.Errorf("failed to store log chunk: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 && chunk.Kind != "step_start" && chunk.Kind != "step_end" {
+			logBytes += int64(len(content))
+		}
+	}
+
+	_, err = tx.Exec("UPDATE jobs SET log_bytes = $1, log_dropped_bytes = $2 WHERE id = $3", logBytes, dropped, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to update log size: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListJobLogChunks returns the chunks of a job stored after the chunk with ID after
+func ListJobLogChunks(db *database.DB, jobID uuid.UUID, after int64, limit int) ([]models.JobLogChunk, error) {
+	var chunks []models.JobLogChunk
+	err := db.Select(&chunks, `
+		SELECT * FROM job_log_chunks
+		WHERE job_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, jobID, after, limit)
+	return chunks, err
+}
+
+// TruncateLogText keeps the start and the end of a log that exceeds limit bytes, with a
+// marker in place of the middle
+func TruncateLogText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	head := truncateUTF8(text, limit/4)
+	tail := text[len(text)-(limit-len(head)):]
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", head, len(text)-len(head)-len(tail), tail)
+}
+
+// NormalizeJobResult decodes a result reported by an agent and re-encodes it without
+// NUL characters, which Postgres doesn't accept in JSONB
+func NormalizeJobResult(raw json.RawMessage) (json.RawMessage, error) {
+	var result models.JobResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+
+	clean := func(s string) string { return strings.ReplaceAll(s, "\x00", "") }
+	cleanMap := func(m map[string]string) {
+		for k, v := range m {
+			m[k] = clean(v)
+		}
+	}
+	for i := range result.Steps {
+		step := &result.Steps[i]
+		step.Stdout, step.Stderr, step.Error = clean(step.Stdout), clean(step.Stderr), clean(step.Error)
+		cleanMap(step.Outputs)
+	}
+	cleanMap(result.Outputs)
+
+	return json.Marshal(result)
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
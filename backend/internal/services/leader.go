@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"configuratix/backend/internal/database"
+)
+
+// LeaderLock elects one backend instance to run a background job. It holds a Postgres
+// session-level advisory lock on a dedicated connection, so leadership is released
+// automatically when the instance dies or loses its database connection.
+type LeaderLock struct {
+	db   *database.DB
+	name string
+	key  int64
+	conn *sql.Conn
+}
+
+// NewLeaderLock creates a lock for the named job; instances using the same name compete
+func NewLeaderLock(db *database.DB, name string) *LeaderLock {
+	h := fnv.New64a()
+	h.Write([]byte("configuratix:" + name))
+	return &LeaderLock{db: db, name: name, key: int64(h.Sum64())}
+}
+
+// IsLeader reports whether this instance holds the lock, trying to acquire it if not
+func (l *LeaderLock) IsLeader() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		// The lock went away with the connection
+		log.Printf("Leader: lost %s leadership (connection closed)", l.name)
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Leader: failed to get connection for %s: %v", l.name, err)
+		return false
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil || !acquired {
+		conn.Close()
+		return false
+	}
+
+	l.conn = conn
+	log.Printf("Leader: this instance (%s) is now the %s leader", instanceName, l.name)
+	return true
+}
+
+// Release gives up leadership so another instance can take over immediately
+func (l *LeaderLock) Release() {
+	if l.conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+	l.conn = nil
+}
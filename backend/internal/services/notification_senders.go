@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// WebhookConfig is the config of a webhook channel. Requests are signed with
+// X-Configuratix-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body)).
+type WebhookConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// TelegramConfig is the config of a Telegram bot channel
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+	APIURL   string `json:"api_url,omitempty"` // Defaults to https://api.telegram.org
+}
+
+// SMTPConfig is the config of an email channel
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// NotificationSecretFields lists the config fields of each channel type that are never returned by the API
+var NotificationSecretFields = map[string][]string{
+	"webhook":  {"secret"},
+	"telegram": {"bot_token"},
+	"smtp":     {"password"},
+}
+
+// ValidateNotificationConfig checks that a channel config has the fields its type needs
+func ValidateNotificationConfig(channelType string, config json.RawMessage) error {
+	switch channelType {
+	case "webhook":
+		var c WebhookConfig
+		if err := json.Unmarshal(config, &c); err != nil {
+			return fmt.Errorf("invalid webhook config: %w", err)
+		}
+		if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+			return fmt.Errorf("webhook url must be http(s)")
+		}
+		if c.Secret == "" {
+			return fmt.Errorf("webhook secret is required")
+		}
+	case "telegram":
+		var c TelegramConfig
+		if err := json.Unmarshal(config, &c); err != nil {
+			return fmt.Errorf("invalid telegram config: %w", err)
+		}
+		if c.BotToken == "" || c.ChatID == "" {
+			return fmt.Errorf("telegram bot_token and chat_id are required")
+		}
+	case "smtp":
+		var c SMTPConfig
+		if err := json.Unmarshal(config, &c); err != nil {
+			return fmt.Errorf("invalid smtp config: %w", err)
+		}
+		if c.Host == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("smtp host, from and to are required")
+		}
+	default:
+		return fmt.Errorf("unknown channel type %q", channelType)
+	}
+	return nil
+}
+
+// SendNotification delivers one payload through a channel. It returns the HTTP status
+// of the remote end when there is one; any error means the attempt should be retried.
+func SendNotification(ctx context.Context, client *http.Client, channel models.NotificationChannel, deliveryID uuid.UUID, eventType string, payload json.RawMessage) (int, error) {
+	switch channel.Type {
+	case "webhook":
+		var c WebhookConfig
+		json.Unmarshal(channel.Config, &c)
+		return sendWebhook(ctx, client, c, deliveryID, eventType, payload)
+	case "telegram":
+		var c TelegramConfig
+		json.Unmarshal(channel.Config, &c)
+		return sendTelegram(ctx, client, c, payload)
+	case "smtp":
+		var c SMTPConfig
+		json.Unmarshal(channel.Config, &c)
+		return 0, sendEmail(c, eventType, payload)
+	}
+	return 0, fmt.Errorf("unknown channel type %q", channel.Type)
+}
+
+// SignWebhook returns the signature header value for a webhook body
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(ctx context.Context, client *http.Client, c WebhookConfig, deliveryID uuid.UUID, eventType string, payload json.RawMessage) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Configuratix-Webhook")
+	req.Header.Set("X-Configuratix-Event", eventType)
+	req.Header.Set("X-Configuratix-Delivery", deliveryID.String())
+	req.Header.Set("X-Configuratix-Timestamp", timestamp)
+	req.Header.Set("X-Configuratix-Signature", SignWebhook(c.Secret, timestamp, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+
+func sendTelegram(ctx context.Context, client *http.Client, c TelegramConfig, payload json.RawMessage) (int, error) {
+	apiURL := strings.TrimSuffix(c.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"chat_id":                  c.ChatID,
+		"text":                     notificationText(payload),
+		"disable_web_page_preview": true,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL+"/bot"+c.BotToken+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL contains the bot token; don't let it end up in the delivery log
+		return 0, fmt.Errorf("telegram request failed: %v", strings.ReplaceAll(err.Error(), c.BotToken, "********"))
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result)
+	if resp.StatusCode != http.StatusOK || !result.OK {
+		return resp.StatusCode, fmt.Errorf("telegram returned %d: %s", resp.StatusCode, result.Description)
+	}
+	return resp.StatusCode, nil
+}
+
+func sendEmail(c SMTPConfig, eventType string, payload json.RawMessage) error {
+	port := c.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + c.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(c.To, ", ") + "\r\n")
+	msg.WriteString("Subject: [Configuratix] " + notificationSubject(eventType, payload) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(notificationText(payload), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return smtp.SendMail(addr, auth, c.From, c.To, []byte(msg.String()))
+}
+
+// notificationSubject returns the event summary, falling back to the event type
+func notificationSubject(eventType string, payload json.RawMessage) string {
+	var event NotificationEvent
+	json.Unmarshal(payload, &event)
+	if event.Summary != "" {
+		return strings.NewReplacer("\r", " ", "\n", " ").Replace(event.Summary)
+	}
+	return eventType
+}
+
+// notificationText renders a payload as plain text for chat and email
+func notificationText(payload json.RawMessage) string {
+	var event NotificationEvent
+	json.Unmarshal(payload, &event)
+
+	var b strings.Builder
+	b.WriteString(event.Summary)
+	b.WriteString("\n\nEvent: " + event.Type)
+	b.WriteString("\nTime: " + event.OccurredAt.UTC().Format(time.RFC3339))
+	if len(event.Data) > 0 {
+		data, _ := json.MarshalIndent(event.Data, "", "  ")
+		b.WriteString("\n\n" + string(data))
+	}
+	return b.String()
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func testNotificationPayload(t *testing.T) json.RawMessage {
+	payload, err := json.Marshal(NotificationEvent{
+		Type:       EventRotation,
+		Summary:    "www.example.com rotated from 192.0.2.1 to 192.0.2.2 (scheduled)",
+		OccurredAt: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Data:       map[string]interface{}{"to_ip": "192.0.2.2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func testChannel(t *testing.T, channelType string, config interface{}) models.NotificationChannel {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateNotificationConfig(channelType, raw); err != nil {
+		t.Fatalf("test %s config is invalid: %v", channelType, err)
+	}
+	return models.NotificationChannel{ID: uuid.New(), Type: channelType, Config: raw, IsEnabled: true}
+}
+
+func TestSignWebhook(t *testing.T) {
+	got := SignWebhook("s3cret", "1700000000", []byte(`{"event":"test"}`))
+	want := "sha256=1c5b24400e91c3c2a54a5fc594c52fc115eb8e1d8d6b200dc40d90fa6ef6e273"
+	if got != want {
+		t.Errorf("SignWebhook = %s, want %s", got, want)
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var got *http.Request
+	var gotBody []byte
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		fmt.Fprint(w, "  receiver says no  ")
+	}))
+	defer srv.Close()
+
+	channel := testChannel(t, "webhook", WebhookConfig{URL: srv.URL + "/hook", Secret: "s3cret"})
+	payload := testNotificationPayload(t)
+	deliveryID := uuid.New()
+
+	code, err := SendNotification(context.Background(), srv.Client(), channel, deliveryID, EventRotation, payload)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("SendNotification = %d, %v", code, err)
+	}
+
+	mu.Lock()
+	if got.Method != "POST" || got.URL.Path != "/hook" {
+		t.Errorf("request %s %s", got.Method, got.URL.Path)
+	}
+	if string(gotBody) != string(payload) {
+		t.Errorf("body = %s, want the payload", gotBody)
+	}
+	headers := map[string]string{
+		"Content-Type":            "application/json",
+		"X-Configuratix-Event":    EventRotation,
+		"X-Configuratix-Delivery": deliveryID.String(),
+	}
+	for k, v := range headers {
+		if got.Header.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, got.Header.Get(k), v)
+		}
+	}
+	timestamp := got.Header.Get("X-Configuratix-Timestamp")
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("X-Configuratix-Timestamp = %q", timestamp)
+	}
+	if sig := got.Header.Get("X-Configuratix-Signature"); sig != SignWebhook("s3cret", timestamp, payload) {
+		t.Errorf("X-Configuratix-Signature = %q doesn't match the body", sig)
+	}
+	status = http.StatusBadGateway
+	mu.Unlock()
+
+	// A non-2xx answer is an error to retry, with the status for the delivery log
+	code, err = SendNotification(context.Background(), srv.Client(), channel, deliveryID, EventRotation, payload)
+	if code != http.StatusBadGateway || err == nil || err.Error() != "webhook returned 502: receiver says no" {
+		t.Errorf("SendNotification to a failing webhook = %d, %v", code, err)
+	}
+}
+
+func TestSendTelegram(t *testing.T) {
+	var mu sync.Mutex
+	var path string
+	var message map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		message = nil
+		json.NewDecoder(r.Body).Decode(&message)
+		if message["chat_id"] != "-100123" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"ok":false,"description":"Bad Request: chat not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer srv.Close()
+
+	channel := testChannel(t, "telegram", TelegramConfig{BotToken: "123:token", ChatID: "-100123", APIURL: srv.URL + "/"})
+	code, err := SendNotification(context.Background(), srv.Client(), channel, uuid.New(), EventRotation, testNotificationPayload(t))
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("SendNotification = %d, %v", code, err)
+	}
+
+	mu.Lock()
+	if path != "/bot123:token/sendMessage" {
+		t.Errorf("path = %s", path)
+	}
+	text, _ := message["text"].(string)
+	for _, want := range []string{
+		"www.example.com rotated from 192.0.2.1 to 192.0.2.2 (scheduled)\n\n",
+		"Event: rotation\n",
+		"Time: 2026-10-16T12:00:00Z",
+		`"to_ip": "192.0.2.2"`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text %q doesn't contain %q", text, want)
+		}
+	}
+	mu.Unlock()
+
+	channel = testChannel(t, "telegram", TelegramConfig{BotToken: "123:token", ChatID: "-1", APIURL: srv.URL})
+	code, err = SendNotification(context.Background(), srv.Client(), channel, uuid.New(), EventRotation, testNotificationPayload(t))
+	if code != http.StatusBadRequest || err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("SendNotification to an unknown chat = %d, %v", code, err)
+	}
+
+	// The bot token is part of the URL and must not leak into errors
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := "http://" + l.Addr().String()
+	l.Close()
+	channel = testChannel(t, "telegram", TelegramConfig{BotToken: "123:token", ChatID: "-100123", APIURL: closed})
+	_, err = SendNotification(context.Background(), srv.Client(), channel, uuid.New(), EventRotation, testNotificationPayload(t))
+	if err == nil || strings.Contains(err.Error(), "123:token") {
+		t.Errorf("SendNotification to an unreachable API: %v", err)
+	}
+}
+
+// smtpStub accepts mail on a local port and keeps the last message
+type smtpStub struct {
+	addr string
+
+	mu   sync.Mutex
+	auth string // Decoded AUTH PLAIN response
+	from string
+	rcpt []string
+	data string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &smtpStub{addr: l.Addr().String()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer conn.Close()
+	r := textproto.NewReader(bufio.NewReader(conn))
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			fmt.Fprintf(conn, "%s\r\n", line)
+		}
+	}
+
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+
+		s.mu.Lock()
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-stub", "250-AUTH PLAIN", "250 8BITMIME")
+		case strings.HasPrefix(cmd, "AUTH PLAIN "):
+			decoded, _ := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			s.auth = string(decoded)
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from, s.rcpt = smtpPath(line), nil
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpt := smtpPath(line)
+			if strings.HasPrefix(rcpt, "unknown@") {
+				reply("550 5.1.1 No such user")
+				break
+			}
+			s.rcpt = append(s.rcpt, rcpt)
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			s.mu.Unlock()
+			lines, err := r.ReadDotLines()
+			s.mu.Lock()
+			if err != nil {
+				s.mu.Unlock()
+				return
+			}
+			s.data = strings.Join(lines, "\n")
+			reply("250 OK: queued")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("250 OK")
+		}
+		s.mu.Unlock()
+	}
+}
+
+// smtpPath returns the address in angle brackets of a MAIL or RCPT command
+func smtpPath(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestSendEmail(t *testing.T) {
+	stub := newSMTPStub(t)
+	host, portStr, _ := net.SplitHostPort(stub.addr)
+	port, _ := strconv.Atoi(portStr)
+
+	channel := testChannel(t, "smtp", SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: "alerts",
+		Password: "pw",
+		From:     "configuratix@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+	})
+	code, err := SendNotification(context.Background(), nil, channel, uuid.New(), EventRotation, testNotificationPayload(t))
+	if err != nil || code != 0 {
+		t.Fatalf("SendNotification = %d, %v", code, err)
+	}
+
+	stub.mu.Lock()
+	if stub.auth != "\x00alerts\x00pw" {
+		t.Errorf("AUTH PLAIN = %q", stub.auth)
+	}
+	if stub.from != "configuratix@example.com" || strings.Join(stub.rcpt, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("envelope from %s to %v", stub.from, stub.rcpt)
+	}
+	for _, want := range []string{
+		"From: configuratix@example.com\n",
+		"To: ops@example.com, oncall@example.com\n",
+		"Subject: [Configuratix] www.example.com rotated from 192.0.2.1 to 192.0.2.2 (scheduled)\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		"\n\nwww.example.com rotated from 192.0.2.1 to 192.0.2.2 (scheduled)\n\nEvent: rotation\n",
+	} {
+		if !strings.Contains(stub.data, want) {
+			t.Errorf("message doesn't contain %q:\n%s", want, stub.data)
+		}
+	}
+	stub.mu.Unlock()
+
+	// A rejected recipient fails the send so it is retried
+	channel = testChannel(t, "smtp", SMTPConfig{Host: host, Port: port, From: "configuratix@example.com", To: []string{"unknown@example.com"}})
+	if _, err := SendNotification(context.Background(), nil, channel, uuid.New(), EventRotation, testNotificationPayload(t)); err == nil || !strings.Contains(err.Error(), "No such user") {
+		t.Errorf("SendNotification to a rejected recipient: %v", err)
+	}
+}
+
+func TestNotificationSubject(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"event":"rotation","summary":"www rotated"}`, "www rotated"},
+		{`{"event":"rotation","summary":"two\r\nlines"}`, "two  lines"},
+		{`{"event":"rotation"}`, EventTest},
+		{`not json`, EventTest},
+	}
+	for _, tt := range tests {
+		if got := notificationSubject(EventTest, json.RawMessage(tt.payload)); got != tt.want {
+			t.Errorf("notificationSubject(%s) = %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestValidateNotificationConfig(t *testing.T) {
+	tests := []struct {
+		channelType string
+		config      string
+		valid       bool
+	}{
+		{"webhook", `{"url":"https://example.com/hook","secret":"s"}`, true},
+		{"webhook", `{"url":"ftp://example.com/hook","secret":"s"}`, false},
+		{"webhook", `{"url":"https://example.com/hook"}`, false},
+		{"telegram", `{"bot_token":"123:abc","chat_id":"42"}`, true},
+		{"telegram", `{"bot_token":"123:abc"}`, false},
+		{"smtp", `{"host":"mail.example.com","from":"a@example.com","to":["b@example.com"]}`, true},
+		{"smtp", `{"host":"mail.example.com","from":"a@example.com","to":[]}`, false},
+		{"smtp", `{"host":"mail.example.com","port":"25"}`, false},
+		{"pager", `{}`, false},
+	}
+	for _, tt := range tests {
+		err := ValidateNotificationConfig(tt.channelType, json.RawMessage(tt.config))
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateNotificationConfig(%s, %s) = %v, want valid %v", tt.channelType, tt.config, err, tt.valid)
+		}
+	}
+}
+
+func TestNotificationBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := notificationBackoff(tt.attempts); got != tt.want {
+			t.Errorf("notificationBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
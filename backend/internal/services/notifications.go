@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Notification event types
+const (
+	EventRotation     = "rotation"
+	EventFailover     = "failover"
+	EventNSStatus     = "ns_status"
+	EventAgentOffline = "agent_offline"
+	EventTest         = "test"
+)
+
+// NotificationEvents lists the event types channels can subscribe to
+var NotificationEvents = []string{EventRotation, EventFailover, EventNSStatus, EventAgentOffline}
+
+const (
+	notificationMaxAttempts = 8
+	notificationBaseBackoff = 30 * time.Second
+	notificationMaxBackoff  = 1 * time.Hour
+	agentOfflineAfter       = 5 * time.Minute // Same threshold as the machine online status
+)
+
+// NotificationEvent is something subscribers may want to hear about. The routing
+// fields decide which subscriptions match and are not part of the payload.
+type NotificationEvent struct {
+	Type       string                 `json:"event"`
+	Summary    string                 `json:"summary"` // One line for chat and email
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data"`
+
+	OwnerID    *uuid.UUID  `json:"-"` // Matches global subscriptions of this user's channels
+	ProjectIDs []uuid.UUID `json:"-"`
+	PoolID     *uuid.UUID  `json:"-"`
+}
+
+// notifyWake lets Notify kick the dispatcher instead of waiting for its next poll
+var notifyWake = make(chan struct{}, 1)
+
+// Notify queues a delivery of the event for every enabled channel with a matching
+// subscription. Sending happens in the NotificationDispatcher.
+func Notify(db *database.DB, event NotificationEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+	projectIDs := make([]string, len(event.ProjectIDs))
+	for i, id := range event.ProjectIDs {
+		projectIDs[i] = id.String()
+	}
+
+	var channelIDs []uuid.UUID
+	err := db.Select(&channelIDs, `
+		SELECT DISTINCT c.id
+		FROM notification_subscriptions s
+		JOIN notification_channels c ON s.channel_id = c.id
+		WHERE c.is_enabled = true
+		  AND (cardinality(s.events) = 0 OR $1 = ANY(s.events))
+		  AND (
+			(s.scope = 'global' AND c.owner_id = $2)
+			OR (s.scope = 'project' AND s.project_id = ANY($3::uuid[]))
+			OR (s.scope = 'pool' AND s.pool_id = $4)
+		  )
+	`, event.Type, event.OwnerID, pq.StringArray(projectIDs), event.PoolID)
+	if err != nil {
+		log.Printf("Notifications: failed to match subscriptions for %s: %v", event.Type, err)
+		return
+	}
+	if len(channelIDs) == 0 {
+		return
+	}
+
+	payload, _ := json.Marshal(event)
+	for _, channelID := range channelIDs {
+		if _, err := db.Exec(`
+			INSERT INTO notification_deliveries (channel_id, event_type, payload)
+			VALUES ($1, $2, $3)
+		`, channelID, event.Type, payload); err != nil {
+			log.Printf("Notifications: failed to queue %s for channel %s: %v", event.Type, channelID, err)
+		}
+	}
+
+	select {
+	case notifyWake <- struct{}{}:
+	default:
+	}
+}
+
+// NotificationDispatcher sends queued notifications with retries and detects offline agents
+type NotificationDispatcher struct {
+	db       *database.DB
+	interval time.Duration
+	client   *http.Client
+	stop     chan struct{}
+}
+
+// NewNotificationDispatcher creates a new dispatcher
+func NewNotificationDispatcher(db *database.DB) *NotificationDispatcher {
+	return &NotificationDispatcher{
+		db:       db,
+		interval: 15 * time.Second,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins the dispatch loop
+func (d *NotificationDispatcher) Start() {
+	log.Println("Notification dispatcher started")
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.checkAgents()
+			d.dispatch()
+		case <-notifyWake:
+			d.dispatch()
+		case <-d.stop:
+			log.Println("Notification dispatcher stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the dispatcher
+func (d *NotificationDispatcher) Stop() {
+	close(d.stop)
+}
+
+// dispatch claims due deliveries and sends them. Claiming with SKIP LOCKED keeps
+// several backend instances from sending the same delivery; deliveries stuck in
+// sending (instance died mid-send) are picked up again after a while.
+func (d *NotificationDispatcher) dispatch() {
+	var deliveries []models.NotificationDelivery
+	err := d.db.Select(&deliveries, `
+		UPDATE notification_deliveries SET status = 'sending', attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM notification_deliveries
+			WHERE (status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'sending' AND updated_at < NOW() - INTERVAL '5 minutes')
+			ORDER BY created_at
+			LIMIT 50
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`)
+	if err != nil {
+		log.Printf("Notifications: failed to claim deliveries: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		var channel models.NotificationChannel
+		if err := d.db.Get(&channel, "SELECT * FROM notification_channels WHERE id = $1", delivery.ChannelID); err != nil {
+			continue // Channel deleted; the delivery went with it
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		status, sendErr := SendNotification(ctx, d.client, channel, delivery.ID, delivery.EventType, delivery.Payload)
+		cancel()
+		d.recordAttempt(delivery, status, sendErr)
+	}
+}
+
+// recordAttempt stores the outcome of a send and schedules a retry with exponential backoff
+func (d *NotificationDispatcher) recordAttempt(delivery models.NotificationDelivery, status int, sendErr error) {
+	var responseStatus *int
+	if status > 0 {
+		responseStatus = &status
+	}
+
+	if sendErr == nil {
+		d.db.Exec(`
+			UPDATE notification_deliveries
+			SET status = 'delivered', response_status = $1, last_error = NULL, delivered_at = NOW(), updated_at = NOW()
+			WHERE id = $2
+		`, responseStatus, delivery.ID)
+		return
+	}
+
+	if delivery.Attempts >= notificationMaxAttempts {
+		log.Printf("Notifications: giving up on delivery %s after %d attempts: %v", delivery.ID, delivery.Attempts, sendErr)
+		d.db.Exec(`
+			UPDATE notification_deliveries
+			SET status = 'failed', response_status = $1, last_error = $2, updated_at = NOW()
+			WHERE id = $3
+		`, responseStatus, sendErr.Error(), delivery.ID)
+		return
+	}
+
+	d.db.Exec(`
+		UPDATE notification_deliveries
+		SET status = 'pending', response_status = $1, last_error = $2, next_attempt_at = $3, updated_at = NOW()
+		WHERE id = $4
+	`, responseStatus, sendErr.Error(), time.Now().Add(notificationBackoff(delivery.Attempts)), delivery.ID)
+}
+
+// notificationBackoff returns the delay before the next attempt: 30s, 1m, 2m, ... capped at 1h
+func notificationBackoff(attempts int) time.Duration {
+	backoff := time.Duration(float64(notificationBaseBackoff) * math.Pow(2, float64(attempts-1)))
+	if backoff > notificationMaxBackoff || backoff <= 0 {
+		return notificationMaxBackoff
+	}
+	return backoff
+}
+
+// checkAgents emits agent_offline once per outage and opens the machine's outage
+// record. The flag is claimed atomically, so with several backend instances each
+// outage is reported once.
+func (d *NotificationDispatcher) checkAgents() {
+	var offline []struct {
+		AgentID     uuid.UUID  `db:"agent_id"`
+		AgentName   string     `db:"agent_name"`
+		LastSeen    *time.Time `db:"last_seen"`
+		MachineID   *uuid.UUID `db:"machine_id"`
+		MachineName *string    `db:"machine_name"`
+		OwnerID     *uuid.UUID `db:"owner_id"`
+		ProjectID   *uuid.UUID `db:"project_id"`
+	}
+	err := d.db.Select(&offline, `
+		WITH claimed AS (
+			UPDATE agents SET offline_notified = true
+			WHERE offline_notified = false AND last_seen < $1
+			RETURNING id, name, last_seen
+		)
+		SELECT c.id as agent_id, c.name as agent_name, c.last_seen,
+			m.id as machine_id, COALESCE(NULLIF(m.title, ''), m.hostname) as machine_name, m.owner_id, m.project_id
+		FROM claimed c
+		LEFT JOIN machines m ON m.agent_id = c.id
+	`, time.Now().Add(-agentOfflineAfter))
+	if err != nil {
+		log.Printf("Notifications: failed to check agents: %v", err)
+		return
+	}
+
+	for _, a := range offline {
+		if a.MachineID != nil && a.LastSeen != nil {
+			// Outage history for rotation reports; the machine went silent at its last heartbeat
+			openOutage(d.db, *a.MachineID, "agent", "", nil, *a.LastSeen, "agent stopped sending heartbeats")
+		}
+
+		name := a.AgentName
+		if a.MachineName != nil && *a.MachineName != "" {
+			name = *a.MachineName
+		}
+		event := NotificationEvent{
+			Type:    EventAgentOffline,
+			Summary: fmt.Sprintf("Agent on %s is offline", name),
+			Data: map[string]interface{}{
+				"agent_id":     a.AgentID,
+				"machine_id":   a.MachineID,
+				"machine_name": name,
+				"last_seen":    a.LastSeen,
+			},
+			OwnerID: a.OwnerID,
+		}
+		if a.ProjectID != nil {
+			event.ProjectIDs = []uuid.UUID{*a.ProjectID}
+		}
+		Notify(d.db, event)
+	}
+}
+
+// NotifyRotation emits a rotation (or failover, for failover/failback triggers) event
+// for a committed rotation
+func NotifyRotation(db *database.DB, op models.RotationOperation) {
+	var info struct {
+		OwnerID *uuid.UUID `db:"owner_id"`
+		FQDN    string     `db:"fqdn"`
+		Name    string     `db:"name"`
+	}
+	if op.PoolType == "wildcard" {
+		db.Get(&info, `
+			SELECT d.owner_id, d.fqdn, '*' as name
+			FROM dns_wildcard_pools p JOIN dns_managed_domains d ON p.dns_domain_id = d.id
+			WHERE p.id = $1
+		`, op.PoolID)
+	} else {
+		db.Get(&info, `
+			SELECT d.owner_id, d.fqdn, r.name
+			FROM dns_passthrough_pools p
+			JOIN dns_records r ON p.dns_record_id = r.id
+			JOIN dns_managed_domains d ON r.dns_domain_id = d.id
+			WHERE p.id = $1
+		`, op.PoolID)
+	}
+	hostname := info.FQDN
+	if info.Name != "@" && info.Name != "" {
+		hostname = info.Name + "." + info.FQDN
+	}
+
+	machineIDs := []uuid.UUID{op.ToMachineID}
+	if op.FromMachineID != nil {
+		machineIDs = append(machineIDs, *op.FromMachineID)
+	}
+	ids := make([]string, len(machineIDs))
+	for i, id := range machineIDs {
+		ids[i] = id.String()
+	}
+	var projectIDs []uuid.UUID
+	db.Select(&projectIDs, `
+		SELECT DISTINCT project_id FROM machines WHERE id = ANY($1::uuid[]) AND project_id IS NOT NULL
+	`, pq.StringArray(ids))
+
+	eventType := EventRotation
+	if op.Trigger == "failover" || op.Trigger == "failback" {
+		eventType = EventFailover
+	}
+	poolID := op.PoolID
+	Notify(db, NotificationEvent{
+		Type:    eventType,
+		Summary: fmt.Sprintf("%s rotated from %s to %s (%s)", hostname, orNone(op.FromIP), orNone(op.ToIP), op.Trigger),
+		Data: map[string]interface{}{
+			"pool_type":       op.PoolType,
+			"pool_id":         op.PoolID,
+			"hostname":        hostname,
+			"trigger":         op.Trigger,
+			"from_machine_id": op.FromMachineID,
+			"from_ip":         op.FromIP,
+			"to_machine_id":   op.ToMachineID,
+			"to_ip":           op.ToIP,
+		},
+		OwnerID:    info.OwnerID,
+		ProjectIDs: projectIDs,
+		PoolID:     &poolID,
+	})
+}
+
+// NotifyNSStatus emits an ns_status event when a domain's delegation status changes
+func NotifyNSStatus(db *database.DB, domain models.DNSManagedDomain, newStatus string, expected, actual []string) {
+	if newStatus == domain.NSStatus {
+		return
+	}
+	ownerID := domain.OwnerID
+	Notify(db, NotificationEvent{
+		Type:    EventNSStatus,
+		Summary: fmt.Sprintf("Nameserver status of %s changed from %s to %s", domain.FQDN, orNone(domain.NSStatus), newStatus),
+		Data: map[string]interface{}{
+			"dns_domain_id":   domain.ID,
+			"domain":          domain.FQDN,
+			"previous_status": domain.NSStatus,
+			"status":          newStatus,
+			"expected":        expected,
+			"actual":          actual,
+		},
+		OwnerID: &ownerID,
+	})
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
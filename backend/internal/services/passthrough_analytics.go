@@ -0,0 +1,340 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// openOutage records the start of a machine outage. An ongoing outage from the same
+// source (and pool) is kept, so repeated detections don't move its start.
+func openOutage(db *database.DB, machineID uuid.UUID, source, poolType string, poolID *uuid.UUID, startedAt time.Time, reason string) {
+	var poolTypeArg *string
+	if poolID != nil {
+		poolTypeArg = &poolType
+	}
+	_, err := db.Exec(`
+		INSERT INTO machine_outages (machine_id, source, pool_type, pool_id, reason, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+	`, machineID, source, poolTypeArg, poolID, reason, startedAt)
+	if err != nil {
+		log.Printf("Failed to record %s outage of machine %s: %v", source, machineID, err)
+	}
+}
+
+// closeProbeOutage ends a machine's ongoing probe outage in a pool
+func closeProbeOutage(db *database.DB, machineID uuid.UUID, poolType string, poolID uuid.UUID) {
+	db.Exec(`
+		UPDATE machine_outages SET ended_at = NOW()
+		WHERE machine_id = $1 AND source = 'probe' AND pool_type = $2 AND pool_id = $3 AND ended_at IS NULL
+	`, machineID, poolType, poolID)
+}
+
+// CloseAgentOutages ends the agent outage of the machine run by an agent. Called on
+// every heartbeat; only a recovering agent has an ongoing outage.
+func CloseAgentOutages(db *database.DB, agentID uuid.UUID) {
+	db.Exec(`
+		UPDATE machine_outages SET ended_at = NOW()
+		WHERE source = 'agent' AND ended_at IS NULL
+		  AND machine_id IN (SELECT id FROM machines WHERE agent_id = $1)
+	`, agentID)
+}
+
+// RotationReport aggregates a pool's rotation history over a period
+type RotationReport struct {
+	PoolType         string                `json:"pool_type"`
+	PoolID           uuid.UUID             `json:"pool_id"`
+	From             time.Time             `json:"from"`
+	To               time.Time             `json:"to"`
+	Rotations        int                   `json:"rotations"`
+	Triggers         map[string]int        `json:"triggers"` // Rotations by trigger: scheduled, manual, failover, failback...
+	Machines         []MachineServiceStats `json:"machines"`
+	Gaps             []ServiceGap          `json:"gaps"`
+	UntrackedSeconds int64                 `json:"untracked_seconds"` // Time in the period without a known current machine
+}
+
+// MachineServiceStats is how long a machine served a pool within a report period
+type MachineServiceStats struct {
+	MachineID      uuid.UUID `json:"machine_id"`
+	MachineName    string    `json:"machine_name"`
+	RotationsTo    int       `json:"rotations_to"`    // Rotations that made it the current machine
+	CurrentSeconds int64     `json:"current_seconds"` // Time as the pool's current machine
+	ServingSeconds int64     `json:"serving_seconds"` // Time in the published answers, current or extra (multi)
+	NodeHours      float64   `json:"node_hours"`      // ServingSeconds in hours
+	OfflineSeconds int64     `json:"offline_seconds"` // Time offline while current
+	Availability   float64   `json:"availability"`    // Share of CurrentSeconds not offline, 1 if never current
+}
+
+// ServiceGap is a period during which the pool's current machine was offline
+type ServiceGap struct {
+	MachineID   uuid.UUID `json:"machine_id"`
+	MachineName string    `json:"machine_name"`
+	Sources     []string  `json:"sources"` // agent, probe
+	Reason      string    `json:"reason"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Seconds     int64     `json:"seconds"`
+	Ongoing     bool      `json:"ongoing"` // Still offline at the end of the period
+}
+
+// servingSegment is a period with a fixed set of published members
+type servingSegment struct {
+	start, end time.Time
+	answers    []uuid.UUID // Current machine first; empty when unknown
+}
+
+// outageSpan is the union of overlapping outages of one machine
+type outageSpan struct {
+	start, end time.Time
+	ongoing    bool
+	sources    []string
+	reason     string
+}
+
+// BuildRotationReport replays a pool's rotation history over [from, to). The pool is
+// assumed to serve its current machine since creation when it never rotated. Service
+// time before answer_machine_ids was recorded counts the current machine only.
+func BuildRotationReport(db *database.DB, poolType string, poolID uuid.UUID, createdAt time.Time, currentMachineID *uuid.UUID, from, to time.Time) (*RotationReport, error) {
+	report := &RotationReport{
+		PoolType: poolType,
+		PoolID:   poolID,
+		From:     from,
+		To:       to,
+		Triggers: map[string]int{},
+		Machines: []MachineServiceStats{},
+		Gaps:     []ServiceGap{},
+	}
+
+	start, end := from, to
+	if createdAt.After(start) {
+		start = createdAt
+	}
+	if now := time.Now().UTC(); now.Before(end) {
+		end = now
+	}
+	if !start.Before(end) {
+		return report, nil
+	}
+
+	var previous []models.RotationHistory
+	err := db.Select(&previous, `
+		SELECT * FROM dns_rotation_history
+		WHERE pool_type = $1 AND pool_id = $2 AND rotated_at < $3
+		ORDER BY rotated_at DESC LIMIT 1
+	`, poolType, poolID, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rotation history: %w", err)
+	}
+	var rows []models.RotationHistory
+	err = db.Select(&rows, `
+		SELECT * FROM dns_rotation_history
+		WHERE pool_type = $1 AND pool_id = $2 AND rotated_at >= $3 AND rotated_at < $4
+		ORDER BY rotated_at
+	`, poolType, poolID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rotation history: %w", err)
+	}
+
+	// Who served at the start of the period
+	var answers []uuid.UUID
+	switch {
+	case len(previous) > 0:
+		answers = historyAnswers(previous[0])
+	case len(rows) > 0 && rows[0].FromMachineID != nil:
+		answers = []uuid.UUID{*rows[0].FromMachineID}
+	case len(rows) == 0 && currentMachineID != nil:
+		answers = []uuid.UUID{*currentMachineID}
+	}
+
+	stats := map[uuid.UUID]*MachineServiceStats{}
+	machine := func(id uuid.UUID) *MachineServiceStats {
+		if stats[id] == nil {
+			stats[id] = &MachineServiceStats{MachineID: id}
+		}
+		return stats[id]
+	}
+
+	var segments []servingSegment
+	cursor := start
+	for _, row := range rows {
+		if row.RotatedAt.After(cursor) {
+			segments = append(segments, servingSegment{cursor, row.RotatedAt, answers})
+			cursor = row.RotatedAt
+		}
+		answers = historyAnswers(row)
+		report.Rotations++
+		report.Triggers[row.Trigger]++
+		if row.ToMachineID != nil {
+			machine(*row.ToMachineID).RotationsTo++
+		}
+	}
+	segments = append(segments, servingSegment{cursor, end, answers})
+
+	for _, seg := range segments {
+		seconds := int64(seg.end.Sub(seg.start).Seconds())
+		if len(seg.answers) == 0 {
+			report.UntrackedSeconds += seconds
+			continue
+		}
+		machine(seg.answers[0]).CurrentSeconds += seconds
+		for _, id := range seg.answers {
+			machine(id).ServingSeconds += seconds
+		}
+	}
+
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id.String())
+	}
+	outages, err := machineOutageSpans(db, ids, poolType, poolID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gaps are outages of the machine that was current at the time
+	for _, seg := range segments {
+		if len(seg.answers) == 0 {
+			continue
+		}
+		current := seg.answers[0]
+		for _, span := range outages[current] {
+			gapStart, gapEnd := span.start, span.end
+			if seg.start.After(gapStart) {
+				gapStart = seg.start
+			}
+			if seg.end.Before(gapEnd) {
+				gapEnd = seg.end
+			}
+			if !gapStart.Before(gapEnd) {
+				continue
+			}
+			seconds := int64(gapEnd.Sub(gapStart).Seconds())
+			machine(current).OfflineSeconds += seconds
+			report.Gaps = append(report.Gaps, ServiceGap{
+				MachineID: current,
+				Sources:   span.sources,
+				Reason:    span.reason,
+				Start:     gapStart,
+				End:       gapEnd,
+				Seconds:   seconds,
+				Ongoing:   span.ongoing && gapEnd.Equal(end),
+			})
+		}
+	}
+
+	names := machineNames(db, ids)
+	for _, s := range stats {
+		s.MachineName = names[s.MachineID]
+		s.NodeHours = float64(s.ServingSeconds) / 3600
+		s.Availability = 1
+		if s.CurrentSeconds > 0 {
+			s.Availability = 1 - float64(s.OfflineSeconds)/float64(s.CurrentSeconds)
+		}
+		report.Machines = append(report.Machines, *s)
+	}
+	sort.Slice(report.Machines, func(i, j int) bool {
+		if report.Machines[i].ServingSeconds != report.Machines[j].ServingSeconds {
+			return report.Machines[i].ServingSeconds > report.Machines[j].ServingSeconds
+		}
+		return report.Machines[i].MachineName < report.Machines[j].MachineName
+	})
+	for i := range report.Gaps {
+		report.Gaps[i].MachineName = names[report.Gaps[i].MachineID]
+	}
+	return report, nil
+}
+
+// historyAnswers returns the members a rotation published, current machine first
+func historyAnswers(row models.RotationHistory) []uuid.UUID {
+	var answers []uuid.UUID
+	for _, s := range row.AnswerMachineIDs {
+		if id, err := uuid.Parse(s); err == nil {
+			answers = append(answers, id)
+		}
+	}
+	if len(answers) == 0 && row.ToMachineID != nil {
+		answers = []uuid.UUID{*row.ToMachineID}
+	}
+	return answers
+}
+
+// machineOutageSpans returns each machine's agent outages and probe outages in the pool
+// overlapping [start, end), merged into non-overlapping spans and clipped to end
+func machineOutageSpans(db *database.DB, machineIDs []string, poolType string, poolID uuid.UUID, start, end time.Time) (map[uuid.UUID][]outageSpan, error) {
+	spans := map[uuid.UUID][]outageSpan{}
+	if len(machineIDs) == 0 {
+		return spans, nil
+	}
+
+	var outages []models.MachineOutage
+	err := db.Select(&outages, `
+		SELECT * FROM machine_outages
+		WHERE machine_id = ANY($1::uuid[])
+		  AND started_at < $2 AND (ended_at IS NULL OR ended_at > $3)
+		  AND (source = 'agent' OR (pool_type = $4 AND pool_id = $5))
+		ORDER BY machine_id, started_at
+	`, pq.StringArray(machineIDs), end, start, poolType, poolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine outages: %w", err)
+	}
+
+	for _, o := range outages {
+		oEnd, ongoing := end, true
+		if o.EndedAt != nil && o.EndedAt.Before(end) {
+			oEnd, ongoing = *o.EndedAt, false
+		}
+		list := spans[o.MachineID]
+		if n := len(list); n > 0 && !o.StartedAt.After(list[n-1].end) {
+			last := &list[n-1]
+			if oEnd.After(last.end) {
+				last.end, last.ongoing = oEnd, ongoing
+			}
+			if !containsString(last.sources, o.Source) {
+				last.sources = append(last.sources, o.Source)
+			}
+			if o.Reason != "" && !strings.Contains(last.reason, o.Reason) {
+				last.reason = strings.TrimPrefix(last.reason+"; "+o.Reason, "; ")
+			}
+			continue
+		}
+		spans[o.MachineID] = append(list, outageSpan{start: o.StartedAt, end: oEnd, ongoing: ongoing, sources: []string{o.Source}, reason: o.Reason})
+	}
+	return spans, nil
+}
+
+// machineNames returns display names by machine ID; deleted machines are missing
+func machineNames(db *database.DB, machineIDs []string) map[uuid.UUID]string {
+	names := map[uuid.UUID]string{}
+	if len(machineIDs) == 0 {
+		return names
+	}
+	var rows []struct {
+		ID   uuid.UUID `db:"id"`
+		Name string    `db:"name"`
+	}
+	db.Select(&rows, `
+		SELECT id, COALESCE(NULLIF(title, ''), hostname, '') as name FROM machines WHERE id = ANY($1::uuid[])
+	`, pq.StringArray(machineIDs))
+	for _, r := range rows {
+		names[r.ID] = r.Name
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Hysteresis: a member must pass/fail this many probes in a row before its status flips
+const (
+	healthRiseThreshold = 2
+	healthFallThreshold = 3
+)
+
+// PassthroughProber actively probes passthrough pool members: TCP connect and TLS
+// handshake on 443 with the pool's SNI, and an optional HTTP request on 80.
+// With several backend instances only the holder of the leader lock probes, so the
+// consecutive success/failure counters see each probe round exactly once.
+type PassthroughProber struct {
+	db       *database.DB
+	leader   *LeaderLock
+	interval time.Duration
+	timeout  time.Duration
+	stop     chan struct{}
+}
+
+// NewPassthroughProber creates a new prober
+func NewPassthroughProber(db *database.DB) *PassthroughProber {
+	return &PassthroughProber{
+		db:       db,
+		leader:   NewLeaderLock(db, "passthrough-prober"),
+		interval: 30 * time.Second,
+		timeout:  5 * time.Second,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins the probe loop
+func (p *PassthroughProber) Start() {
+	log.Println("Passthrough prober started")
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if p.leader.IsLeader() {
+				p.tick()
+			}
+		case <-p.stop:
+			p.leader.Release()
+			log.Println("Passthrough prober stopped")
+			return
+		}
+	}
+}
+
+// Stop stops the prober
+func (p *PassthroughProber) Stop() {
+	close(p.stop)
+}
+
+// probeTarget is one member to probe
+type probeTarget struct {
+	MachineID uuid.UUID `db:"machine_id"`
+	MachineIP string    `db:"machine_ip"`
+}
+
+// probeOptions describes how members of a pool are probed
+type probeOptions struct {
+	Host     string // SNI and HTTP Host
+	HTTPPath string // Empty = no HTTP check
+}
+
+// probeResult is the outcome of one probe
+type probeResult struct {
+	TCPOK   bool
+	TLSOK   bool
+	HTTPOK  *bool
+	Latency time.Duration
+	Err     error
+}
+
+func (r probeResult) ok() bool {
+	return r.TCPOK && r.TLSOK && (r.HTTPOK == nil || *r.HTTPOK)
+}
+
+func (p *PassthroughProber) tick() {
+	p.purgeHealth()
+
+	var recordPools []struct {
+		ID         uuid.UUID      `db:"id"`
+		GroupIDs   pq.StringArray `db:"group_ids"`
+		Path       string         `db:"health_check_path"`
+		Host       string         `db:"health_check_host"`
+		RecordName string         `db:"record_name"`
+		DomainFQDN string         `db:"domain_fqdn"`
+	}
+	err := p.db.Select(&recordPools, `
+		SELECT pp.id, pp.group_ids, COALESCE(pp.health_check_path, '') as health_check_path,
+		       COALESCE(pp.health_check_host, '') as health_check_host, r.name as record_name, d.fqdn as domain_fqdn
+		FROM dns_passthrough_pools pp
+		JOIN dns_records r ON pp.dns_record_id = r.id
+		JOIN dns_managed_domains d ON r.dns_domain_id = d.id
+		WHERE pp.health_check_enabled = true
+	`)
+	if err != nil {
+		log.Printf("Passthrough prober: failed to get record pools: %v", err)
+	}
+	for _, pool := range recordPools {
+		host := pool.Host
+		if host == "" {
+			host = pool.DomainFQDN
+			if pool.RecordName != "@" {
+				host = pool.RecordName + "." + pool.DomainFQDN
+			}
+		}
+		targets := poolMembers(p.db, "dns_passthrough_members", pool.ID, pool.GroupIDs)
+		p.probePool("record", pool.ID, targets, probeOptions{Host: host, HTTPPath: pool.Path})
+	}
+
+	var wildcardPools []struct {
+		ID         uuid.UUID      `db:"id"`
+		GroupIDs   pq.StringArray `db:"group_ids"`
+		Path       string         `db:"health_check_path"`
+		Host       string         `db:"health_check_host"`
+		DomainFQDN string         `db:"domain_fqdn"`
+	}
+	err = p.db.Select(&wildcardPools, `
+		SELECT wp.id, wp.group_ids, COALESCE(wp.health_check_path, '') as health_check_path,
+		       COALESCE(wp.health_check_host, '') as health_check_host, d.fqdn as domain_fqdn
+		FROM dns_wildcard_pools wp
+		JOIN dns_managed_domains d ON wp.dns_domain_id = d.id
+		WHERE wp.health_check_enabled = true
+	`)
+	if err != nil {
+		log.Printf("Passthrough prober: failed to get wildcard pools: %v", err)
+	}
+	for _, pool := range wildcardPools {
+		host := pool.Host
+		if host == "" {
+			// Any subdomain matches the wildcard SNI map on the members
+			host = "www." + pool.DomainFQDN
+		}
+		targets := poolMembers(p.db, "dns_wildcard_pool_members", pool.ID, pool.GroupIDs)
+		p.probePool("wildcard", pool.ID, targets, probeOptions{Host: host, HTTPPath: pool.Path})
+	}
+}
+
+// purgeHealth removes the probe results of pools that were deleted or had their health
+// checks turned off. Pools deleted through their DNS record or domain leave no trace
+// in the delete handlers, so this catches every path.
+func (p *PassthroughProber) purgeHealth() {
+	_, err := p.db.Exec(`
+		DELETE FROM dns_passthrough_health h
+		WHERE (h.pool_type = 'record' AND NOT EXISTS (
+				SELECT 1 FROM dns_passthrough_pools pp WHERE pp.id = h.pool_id AND pp.health_check_enabled = true))
+		   OR (h.pool_type = 'wildcard' AND NOT EXISTS (
+				SELECT 1 FROM dns_wildcard_pools wp WHERE wp.id = h.pool_id AND wp.health_check_enabled = true))
+	`)
+	if err != nil {
+		log.Printf("Passthrough prober: failed to purge stale health: %v", err)
+	}
+}
+
+// DeletePoolHealth removes the probe results of a pool, e.g. when it is deleted
+func DeletePoolHealth(db *database.DB, poolType string, poolID uuid.UUID) {
+	db.Exec("DELETE FROM dns_passthrough_health WHERE pool_type = $1 AND pool_id = $2", poolType, poolID)
+}
+
+// poolMembers returns the enabled direct members of a pool plus the machines of its groups
+func poolMembers(db *database.DB, membersTable string, poolID uuid.UUID, groupIDs pq.StringArray) []probeTarget {
+	var targets []probeTarget
+	db.Select(&targets, `
+		SELECT pm.machine_id, COALESCE(m.primary_ip, m.ip_address) as machine_ip
+		FROM `+membersTable+` pm
+		JOIN machines m ON pm.machine_id = m.id
+		WHERE pm.pool_id = $1 AND pm.is_enabled = true
+	`, poolID)
+
+	if len(groupIDs) > 0 {
+		var groupTargets []probeTarget
+		db.Select(&groupTargets, `
+			SELECT DISTINCT m.id as machine_id, COALESCE(m.primary_ip, m.ip_address) as machine_ip
+			FROM machine_group_members gm
+			JOIN machines m ON gm.machine_id = m.id
+			WHERE gm.group_id = ANY($1::uuid[])
+		`, groupIDs)
+
+		seen := make(map[uuid.UUID]bool)
+		for _, t := range targets {
+			seen[t.MachineID] = true
+		}
+		for _, t := range groupTargets {
+			if !seen[t.MachineID] {
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets
+}
+
+// probePool probes all members of a pool concurrently and stores the results
+func (p *PassthroughProber) probePool(poolType string, poolID uuid.UUID, targets []probeTarget, opts probeOptions) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		if target.MachineIP == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(target probeTarget) {
+			defer wg.Done()
+			result := p.probe(target.MachineIP, opts)
+			p.record(poolType, poolID, target.MachineID, result)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// probe runs the TCP, TLS and optional HTTP checks against one member
+func (p *PassthroughProber) probe(ip string, opts probeOptions) probeResult {
+	var result probeResult
+	start := time.Now()
+
+	dialer := &net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(ip, "443"))
+	if err != nil {
+		result.Err = fmt.Errorf("tcp 443: %w", err)
+		return result
+	}
+	result.TCPOK = true
+
+	// The member forwards by SNI, so a completed handshake means the backend answered.
+	// Certificate validity is not the member's concern.
+	conn.SetDeadline(time.Now().Add(p.timeout))
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: opts.Host, InsecureSkipVerify: true})
+	err = tlsConn.Handshake()
+	tlsConn.Close()
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = fmt.Errorf("tls handshake (%s): %w", opts.Host, err)
+		return result
+	}
+	result.TLSOK = true
+
+	if opts.HTTPPath != "" {
+		ok := false
+		result.HTTPOK = &ok
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://"+net.JoinHostPort(ip, "80")+opts.HTTPPath, nil)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		req.Host = opts.Host
+
+		client := &http.Client{
+			// A redirect to HTTPS still proves the member forwards port 80
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			result.Err = fmt.Errorf("http 80: %w", err)
+			return result
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			result.Err = fmt.Errorf("http 80: status %d", resp.StatusCode)
+			return result
+		}
+		ok = true
+	}
+
+	return result
+}
+
+// record stores a probe result, flipping the member's status only once the
+// rise/fall threshold is reached
+func (p *PassthroughProber) record(poolType string, poolID, machineID uuid.UUID, result probeResult) {
+	var current models.MemberHealth
+	err := p.db.Get(&current, `
+		SELECT * FROM dns_passthrough_health WHERE pool_type = $1 AND pool_id = $2 AND machine_id = $3
+	`, poolType, poolID, machineID)
+	if err != nil {
+		current = models.MemberHealth{Status: "unknown"}
+	}
+
+	successes, failures := 0, 0
+	if result.ok() {
+		successes = current.ConsecutiveSuccesses + 1
+	} else {
+		failures = current.ConsecutiveFailures + 1
+	}
+
+	status := current.Status
+	if successes >= healthRiseThreshold || (status == "unknown" && successes > 0) {
+		status = "healthy"
+	}
+	if failures >= healthFallThreshold {
+		status = "unhealthy"
+	}
+	if status != current.Status && current.Status != "unknown" {
+		log.Printf("Passthrough prober: %s pool %s member %s is now %s", poolType, poolID, machineID, status)
+	}
+
+	var lastError *string
+	if result.Err != nil {
+		msg := result.Err.Error()
+		lastError = &msg
+	}
+
+	// Outage history for rotation reports
+	if status != current.Status {
+		switch status {
+		case "unhealthy":
+			reason := "probe failed"
+			if lastError != nil {
+				reason = *lastError
+			}
+			openOutage(p.db, machineID, "probe", poolType, &poolID, time.Now().UTC(), reason)
+		case "healthy":
+			closeProbeOutage(p.db, machineID, poolType, poolID)
+		}
+	}
+
+	_, err = p.db.Exec(`
+		INSERT INTO dns_passthrough_health
+			(pool_type, pool_id, machine_id, status, consecutive_successes, consecutive_failures,
+			 tcp_ok, tls_ok, http_ok, latency_ms, last_error, last_probe_at, last_change_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		ON CONFLICT (pool_type, pool_id, machine_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			consecutive_successes = EXCLUDED.consecutive_successes,
+			consecutive_failures = EXCLUDED.consecutive_failures,
+			tcp_ok = EXCLUDED.tcp_ok,
+			tls_ok = EXCLUDED.tls_ok,
+			http_ok = EXCLUDED.http_ok,
+			latency_ms = EXCLUDED.latency_ms,
+			last_error = EXCLUDED.last_error,
+			last_probe_at = NOW(),
+			last_change_at = CASE WHEN dns_passthrough_health.status <> EXCLUDED.status
+				THEN NOW() ELSE dns_passthrough_health.last_change_at END
+	`, poolType, poolID, machineID, status, successes, failures,
+		result.TCPOK, result.TLSOK, result.HTTPOK, result.Latency.Milliseconds(), lastError)
+	if err != nil {
+		log.Printf("Passthrough prober: failed to store health for %s: %v", machineID, err)
+	}
+}
+
+// MemberHealthMap returns the probed health of every member of a pool by machine ID.
+// Probe results only count while the pool has health checks enabled; otherwise the
+// map is empty and members are judged by their agent heartbeat.
+func MemberHealthMap(db *database.DB, poolType string, poolID uuid.UUID, healthCheckEnabled bool) map[uuid.UUID]*models.MemberHealth {
+	if !healthCheckEnabled {
+		return map[uuid.UUID]*models.MemberHealth{}
+	}
+
+	var rows []models.MemberHealth
+	db.Select(&rows, "SELECT * FROM dns_passthrough_health WHERE pool_type = $1 AND pool_id = $2", poolType, poolID)
+
+	health := make(map[uuid.UUID]*models.MemberHealth, len(rows))
+	for i := range rows {
+		health[rows[i].MachineID] = &rows[i]
+	}
+	return health
+}
+
+// MemberIsHealthy decides whether a member may receive traffic. Probe results win;
+// members that have not been probed yet fall back to the agent heartbeat.
+func MemberIsHealthy(health *models.MemberHealth, lastSeen *time.Time) bool {
+	if health != nil && health.Status != "unknown" {
+		return health.Status == "healthy"
+	}
+	return lastSeen != nil && time.Since(*lastSeen) < 5*time.Minute
+}
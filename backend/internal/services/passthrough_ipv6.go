@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"net"
+
+	"configuratix/backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// IsIPv4 reports whether ip is a valid IPv4 address (A record value)
+func IsIPv4(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() != nil
+}
+
+// IPv4Answers drops values that cannot be published as A records, such as the
+// IPv6 connection address of a v6-only host
+func IPv4Answers(ips []string) []string {
+	var answers []string
+	for _, ip := range ips {
+		if IsIPv4(ip) {
+			answers = append(answers, ip)
+		}
+	}
+	return answers
+}
+
+// MachineIPv6Answers returns the primary IPv6 of each machine, in the given order.
+// Machines without a primary IPv6 are skipped.
+func MachineIPv6Answers(db *database.DB, machineIDs []uuid.UUID) []string {
+	ids := make([]string, len(machineIDs))
+	for i, id := range machineIDs {
+		ids[i] = id.String()
+	}
+
+	var rows []struct {
+		ID          uuid.UUID `db:"id"`
+		PrimaryIPv6 string    `db:"primary_ipv6"`
+	}
+	db.Select(&rows, `
+		SELECT id, primary_ipv6 FROM machines
+		WHERE id = ANY($1::uuid[]) AND COALESCE(primary_ipv6, '') <> ''
+	`, pq.StringArray(ids))
+
+	byMachine := make(map[uuid.UUID]string, len(rows))
+	for _, r := range rows {
+		byMachine[r.ID] = r.PrimaryIPv6
+	}
+
+	var answers []string
+	seen := make(map[string]bool)
+	for _, id := range machineIDs {
+		if ip, ok := byMachine[id]; ok && !seen[ip] {
+			seen[ip] = true
+			answers = append(answers, ip)
+		}
+	}
+	return answers
+}
+
+// PublishRecordIPv6 points the AAAA record sharing a pool record's name at the
+// primary IPv6 of the selected machines, withdrawing it when none has one
+func PublishRecordIPv6(db *database.DB, recordID uuid.UUID, machineIDs []uuid.UUID) error {
+	var record struct {
+		DNSDomainID uuid.UUID `db:"dns_domain_id"`
+		Name        string    `db:"name"`
+	}
+	if err := db.Get(&record, "SELECT dns_domain_id, name FROM dns_records WHERE id = $1", recordID); err != nil {
+		return fmt.Errorf("failed to get record: %w", err)
+	}
+	return PublishNameAnswers(db, record.DNSDomainID, record.Name, "AAAA", MachineIPv6Answers(db, machineIDs))
+}
+
+// PublishWildcardIPv6 is PublishRecordIPv6 for the wildcard (and optionally root) names
+func PublishWildcardIPv6(db *database.DB, domainID uuid.UUID, machineIDs []uuid.UUID, includeRoot bool) error {
+	ips := MachineIPv6Answers(db, machineIDs)
+	names := []string{"*"}
+	if includeRoot {
+		names = append(names, "@")
+	}
+	for _, name := range names {
+		if err := PublishNameAnswers(db, domainID, name, "AAAA", ips); err != nil {
+			return err
+		}
+	}
+	return nil
+}
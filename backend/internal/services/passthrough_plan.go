@@ -0,0 +1,318 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNoPoolMembers is returned when a pool has neither direct nor group members
+	ErrNoPoolMembers = errors.New("pool has no members")
+	// ErrNoEligibleMembers is returned when no member may be published (unhealthy or unaddressable)
+	ErrNoEligibleMembers = errors.New("no member can be published")
+	// ErrPreferredNotEligible is returned on failback when the preferred machine is not (or no longer) eligible
+	ErrPreferredNotEligible = errors.New("preferred machine is not an eligible member")
+)
+
+// PoolMember is a machine that belongs to a pool, directly or through one of its groups
+type PoolMember struct {
+	MachineID   uuid.UUID  `db:"machine_id" json:"machine_id"`
+	MachineName string     `db:"machine_name" json:"machine_name"`
+	MachineIP   string     `db:"machine_ip" json:"machine_ip"`
+	LastSeen    *time.Time `db:"last_seen" json:"last_seen"`
+	Priority    int        `db:"priority" json:"priority"`
+	Weight      int        `db:"weight" json:"weight"`
+	Source      string     `db:"source" json:"source"` // direct, group
+}
+
+// PoolRotationState is the part of a pool a rotation decision depends on
+type PoolRotationState struct {
+	PoolType           string // record, wildcard
+	PoolID             uuid.UUID
+	GroupIDs           pq.StringArray
+	Strategy           string
+	CurrentIndex       int
+	AnswerCount        int
+	HealthCheckEnabled bool
+	IPv6Enabled        bool
+	CurrentMachineID   *uuid.UUID
+	PreferredMachineID *uuid.UUID
+}
+
+// RecordPoolState returns the rotation state of a record pool
+func RecordPoolState(pool models.PassthroughPool) PoolRotationState {
+	return PoolRotationState{
+		PoolType:           "record",
+		PoolID:             pool.ID,
+		GroupIDs:           pool.GroupIDs,
+		Strategy:           pool.RotationStrategy,
+		CurrentIndex:       pool.CurrentIndex,
+		AnswerCount:        pool.AnswerCount,
+		HealthCheckEnabled: pool.HealthCheckEnabled,
+		IPv6Enabled:        pool.IPv6Enabled,
+		CurrentMachineID:   pool.CurrentMachineID,
+		PreferredMachineID: pool.PreferredMachineID,
+	}
+}
+
+// WildcardPoolState returns the rotation state of a wildcard pool
+func WildcardPoolState(pool models.WildcardPool) PoolRotationState {
+	return PoolRotationState{
+		PoolType:           "wildcard",
+		PoolID:             pool.ID,
+		GroupIDs:           pool.GroupIDs,
+		Strategy:           pool.RotationStrategy,
+		CurrentIndex:       pool.CurrentIndex,
+		AnswerCount:        pool.AnswerCount,
+		HealthCheckEnabled: pool.HealthCheckEnabled,
+		IPv6Enabled:        pool.IPv6Enabled,
+		CurrentMachineID:   pool.CurrentMachineID,
+		PreferredMachineID: pool.PreferredMachineID,
+	}
+}
+
+// PlanRotation decides which member(s) a rotation publishes and the pool's new index.
+// It only reads the database; the scheduler and manual rotations both go through it.
+func PlanRotation(db *database.DB, state PoolRotationState, trigger string) ([]RotationCandidate, int, error) {
+	members, err := ResolvePoolMembers(db, state.PoolType, state.PoolID, state.GroupIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	health := MemberHealthMap(db, state.PoolType, state.PoolID, state.HealthCheckEnabled)
+	candidates, _, err := EvaluateMembers(state, members, health, trigger)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var lastUsed map[uuid.UUID]time.Time
+	if state.Strategy == "least_recently_used" {
+		lastUsed = lastRotatedTo(db, state.PoolType, state.PoolID)
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return chooseCandidates(state, candidates, trigger, lastUsed, rng)
+}
+
+// ResolvePoolMembers returns the enabled direct members of a pool by priority, followed
+// by the machines of its groups that are not direct members
+func ResolvePoolMembers(db *database.DB, poolType string, poolID uuid.UUID, groupIDs pq.StringArray) ([]PoolMember, error) {
+	membersTable := "dns_passthrough_members"
+	if poolType == "wildcard" {
+		membersTable = "dns_wildcard_pool_members"
+	}
+
+	var members []PoolMember
+	err := db.Select(&members, `
+		SELECT pm.machine_id, COALESCE(NULLIF(m.title, ''), m.hostname, '') as machine_name,
+			COALESCE(m.primary_ip, m.ip_address, '') as machine_ip, a.last_seen, pm.priority, pm.weight, 'direct' as source
+		FROM `+membersTable+` pm
+		JOIN machines m ON pm.machine_id = m.id
+		LEFT JOIN agents a ON m.agent_id = a.id
+		WHERE pm.pool_id = $1 AND pm.is_enabled = true
+		ORDER BY pm.priority, machine_name
+	`, poolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool members: %w", err)
+	}
+	return appendGroupMembers(db, members, groupIDs)
+}
+
+// appendGroupMembers adds the machines of the given groups that aren't members yet
+func appendGroupMembers(db *database.DB, members []PoolMember, groupIDs pq.StringArray) ([]PoolMember, error) {
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+
+	var groupMembers []PoolMember
+	err := db.Select(&groupMembers, `
+		SELECT DISTINCT m.id as machine_id, COALESCE(NULLIF(m.title, ''), m.hostname, '') as machine_name,
+			COALESCE(m.primary_ip, m.ip_address, '') as machine_ip, a.last_seen, 100 as priority, 1 as weight, 'group' as source
+		FROM machine_group_members gm
+		JOIN machines m ON gm.machine_id = m.id
+		LEFT JOIN agents a ON m.agent_id = a.id
+		WHERE gm.group_id = ANY($1::uuid[])
+		ORDER BY machine_name, machine_id
+	`, groupIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(members))
+	for _, m := range members {
+		seen[m.MachineID] = true
+	}
+	for _, gm := range groupMembers {
+		if !seen[gm.MachineID] {
+			members = append(members, gm)
+			seen[gm.MachineID] = true
+		}
+	}
+	return members, nil
+}
+
+// MemberEvaluation explains whether a rotation may publish a member
+type MemberEvaluation struct {
+	PoolMember
+	Healthy  bool   `json:"healthy"`
+	Eligible bool   `json:"eligible"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// EvaluateMembers returns the members a rotation with the given trigger may publish.
+// Unhealthy members are skipped when health checks are on, and always on failover and
+// failback; the failing current machine is skipped on failover; members without an
+// IPv4 address are skipped unless the pool also manages AAAA. When health filtering
+// leaves nobody, scheduled and manual rotations fall back to all members.
+func EvaluateMembers(state PoolRotationState, members []PoolMember, health map[uuid.UUID]*models.MemberHealth, trigger string) ([]RotationCandidate, []MemberEvaluation, error) {
+	if len(members) == 0 {
+		return nil, nil, ErrNoPoolMembers
+	}
+
+	requireHealthy := trigger == "failover" || trigger == "failback"
+	filterHealth := state.HealthCheckEnabled || requireHealthy
+
+	evals := make([]MemberEvaluation, len(members))
+	anyEligible := false
+	for i, m := range members {
+		ev := MemberEvaluation{PoolMember: m, Healthy: MemberIsHealthy(health[m.MachineID], m.LastSeen), Eligible: true}
+		if trigger == "failover" && state.CurrentMachineID != nil && m.MachineID == *state.CurrentMachineID {
+			ev.Eligible, ev.Reason = false, "current machine is failing over"
+		} else if filterHealth && !ev.Healthy {
+			ev.Eligible, ev.Reason = false, "unhealthy"
+		}
+		anyEligible = anyEligible || ev.Eligible
+		evals[i] = ev
+	}
+	if !anyEligible {
+		if requireHealthy {
+			return nil, evals, ErrNoEligibleMembers
+		}
+		for i := range evals {
+			evals[i].Eligible, evals[i].Reason = true, "no healthy members, using all"
+		}
+	}
+
+	var candidates []RotationCandidate
+	for i := range evals {
+		ev := &evals[i]
+		if !ev.Eligible {
+			continue
+		}
+		if !state.IPv6Enabled && !IsIPv4(ev.MachineIP) {
+			ev.Eligible, ev.Reason = false, "no IPv4 address"
+			continue
+		}
+		candidates = append(candidates, RotationCandidate{MachineID: ev.MachineID, MachineIP: ev.MachineIP, Weight: ev.Weight})
+	}
+	if len(candidates) == 0 {
+		return nil, evals, ErrNoEligibleMembers
+	}
+	return candidates, evals, nil
+}
+
+// chooseCandidates applies the pool's strategy, or for a failback starts from the
+// preferred machine
+func chooseCandidates(state PoolRotationState, candidates []RotationCandidate, trigger string, lastUsed map[uuid.UUID]time.Time, rng *rand.Rand) ([]RotationCandidate, int, error) {
+	if trigger != "failback" || state.PreferredMachineID == nil {
+		selected, newIndex := selectRotation(state.Strategy, candidates, state.CurrentIndex, state.AnswerCount, lastUsed, rng)
+		return selected, newIndex, nil
+	}
+
+	for i, c := range candidates {
+		if c.MachineID != *state.PreferredMachineID {
+			continue
+		}
+		if state.Strategy == "multi" {
+			// Window starting at the preferred machine
+			selected, newIndex := selectRotation(state.Strategy, candidates, i-1+len(candidates), state.AnswerCount, lastUsed, rng)
+			return selected, newIndex, nil
+		}
+		return candidates[i : i+1], i, nil
+	}
+	return nil, 0, ErrPreferredNotEligible
+}
+
+// RecordPoolRotation returns the journaled plan and the DNS update that rotate a record
+// pool to the selected members. The first member becomes the current machine; the
+// others are published as extra answers. Manual rotations reset the schedule.
+func RecordPoolRotation(db *database.DB, nginx PassthroughConfigApplier, pool models.PassthroughPool, selected []RotationCandidate, newIndex int, trigger string) (RotationPlan, func() error) {
+	plan := newRotationPlan(db, "record", pool.ID, pool.CurrentMachineID, selected, newIndex, trigger, pool.ConfirmPropagation)
+	if pool.WarmupEnabled {
+		plan.Warmup = rotationWarmup(db, nginx, PoolWarmupOptions("record", pool.ID, pool.WarmupProbe, pool.WarmupTimeoutSeconds), selected)
+	}
+
+	return plan, func() error {
+		if ipv4 := IPv4Answers(candidateIPs(selected)); len(ipv4) > 0 {
+			if err := PublishRecordAnswers(db, pool.DNSRecordID, ipv4); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("Passthrough: selected members of pool %s are IPv6-only, keeping A record", pool.ID)
+		}
+		if pool.IPv6Enabled {
+			return PublishRecordIPv6(db, pool.DNSRecordID, candidateMachineIDs(selected))
+		}
+		return nil
+	}
+}
+
+// WildcardPoolRotation is RecordPoolRotation for the wildcard (and optionally root) records of a domain
+func WildcardPoolRotation(db *database.DB, nginx PassthroughConfigApplier, pool models.WildcardPool, selected []RotationCandidate, newIndex int, trigger string) (RotationPlan, func() error) {
+	plan := newRotationPlan(db, "wildcard", pool.ID, pool.CurrentMachineID, selected, newIndex, trigger, pool.ConfirmPropagation)
+	if pool.WarmupEnabled {
+		plan.Warmup = rotationWarmup(db, nginx, PoolWarmupOptions("wildcard", pool.ID, pool.WarmupProbe, pool.WarmupTimeoutSeconds), selected)
+	}
+
+	return plan, func() error {
+		if ipv4 := IPv4Answers(candidateIPs(selected)); len(ipv4) > 0 {
+			if err := PublishWildcardAnswers(db, pool.DNSDomainID, ipv4, pool.IncludeRoot); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("Passthrough: selected members of wildcard pool %s are IPv6-only, keeping A records", pool.ID)
+		}
+		if pool.IPv6Enabled {
+			return PublishWildcardIPv6(db, pool.DNSDomainID, candidateMachineIDs(selected), pool.IncludeRoot)
+		}
+		return nil
+	}
+}
+
+func newRotationPlan(db *database.DB, poolType string, poolID uuid.UUID, currentID *uuid.UUID, selected []RotationCandidate, newIndex int, trigger string, awaitPropagation bool) RotationPlan {
+	var fromIP string
+	if currentID != nil {
+		db.Get(&fromIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", *currentID)
+	}
+	return RotationPlan{
+		PoolType:         poolType,
+		PoolID:           poolID,
+		Trigger:          trigger,
+		FromMachineID:    currentID,
+		FromIP:           fromIP,
+		ToMachineID:      selected[0].MachineID,
+		ToIP:             selected[0].MachineIP,
+		AnswerMachineIDs: candidateMachineIDs(selected),
+		NewIndex:         newIndex,
+		AwaitPropagation: awaitPropagation,
+		ResetSchedule:    trigger == "manual",
+	}
+}
+
+// rotationWarmup returns the warmup of the members about to be published
+func rotationWarmup(db *database.DB, nginx PassthroughConfigApplier, opts WarmupOptions, selected []RotationCandidate) func() error {
+	targets := make([]WarmupTarget, len(selected))
+	for i, c := range selected {
+		targets[i] = WarmupTarget{MachineID: c.MachineID, MachineIP: c.MachineIP}
+	}
+	return func() error {
+		return WarmupTargets(db, nginx, targets, opts)
+	}
+}
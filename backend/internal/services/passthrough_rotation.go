@@ -0,0 +1,374 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Rotation states. A rotation is journaled as planned (with the answers it replaces),
+// becomes dns_updated once the provider accepted the change, and is committed together
+// with the pool state and history. Unfinished operations are recovered by the leader.
+// A rotation whose warmup fails is aborted before DNS is touched.
+const (
+	RotationPlanned    = "planned"
+	RotationDNSUpdated = "dns_updated"
+	RotationCommitted  = "committed"
+	RotationRolledBack = "rolled_back"
+	RotationAborted    = "aborted"
+)
+
+// rotationStaleAfter is how long an unfinished rotation may go without progress before
+// the leader assumes its instance died. A rotation makes at most a handful of provider
+// calls with 30s timeouts each.
+const rotationStaleAfter = 5 * time.Minute
+
+// ErrRotationInProgress is returned when another rotation of the same pool has not finished
+var ErrRotationInProgress = errors.New("rotation already in progress")
+
+// ErrRotationAborted is returned when the warmup of the new members failed and DNS was left alone
+var ErrRotationAborted = errors.New("rotation aborted")
+
+// errRotationTakenOver is returned when the leader recovered an operation while it was still running
+var errRotationTakenOver = errors.New("rotation was recovered by another instance")
+
+// instanceName identifies this backend process in the rotation journal
+var instanceName = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
+// RotationPlan describes a rotation before any DNS change is made
+type RotationPlan struct {
+	PoolType         string // record, wildcard
+	PoolID           uuid.UUID
+	Trigger          string
+	FromMachineID    *uuid.UUID
+	FromIP           string
+	ToMachineID      uuid.UUID
+	ToIP             string
+	AnswerMachineIDs []uuid.UUID // Every member published, ToMachineID first
+	NewIndex         int
+	AwaitPropagation bool // Leave last_rotated_at to the propagation check
+	ResetSchedule    bool // Clear next_rotation_at, e.g. for manual rotations
+
+	// Warmup, if set, prepares the new members before DNS is touched; an error aborts the rotation
+	Warmup func() error
+}
+
+// answerSet is the published values of one name/type, as snapshotted for rollback
+type answerSet struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Values []string `json:"values"`
+}
+
+// StartRotation journals a planned rotation and returns its operation ID, or
+// ErrRotationInProgress. The warmup can take minutes, so callers finish the rotation
+// with ContinueRotation in the background and poll the operation ID.
+func StartRotation(db *database.DB, plan RotationPlan) (uuid.UUID, error) {
+	return beginRotation(db, plan)
+}
+
+// ContinueRotation runs the warmup and DNS update of an operation journaled by
+// StartRotation, applies the DNS change through updateDNS and commits the pool state
+// and history. If the DNS update fails the pool's records are restored to their
+// previous answers and the pool state is left untouched. The outcome is also recorded
+// on the operation (state and error).
+func ContinueRotation(db *database.DB, opID uuid.UUID, plan RotationPlan, updateDNS func() error) error {
+	if plan.Warmup != nil {
+		if err := runWarmup(db, opID, plan.Warmup); err != nil {
+			abortRotation(db, opID, plan, err.Error())
+			return fmt.Errorf("%w: %v", ErrRotationAborted, err)
+		}
+	}
+
+	if err := updateDNS(); err != nil {
+		if rbErr := rollbackRotation(db, opID, err.Error()); rbErr != nil {
+			return fmt.Errorf("DNS update failed (%v), rollback failed: %w", err, rbErr)
+		}
+		return fmt.Errorf("DNS update failed, rolled back: %w", err)
+	}
+
+	if err := advanceRotation(db, opID, RotationPlanned, RotationDNSUpdated); err != nil {
+		return err
+	}
+	return commitRotation(db, opID, RotationDNSUpdated)
+}
+
+// beginRotation snapshots the pool's current answers and journals the planned rotation
+func beginRotation(db *database.DB, plan RotationPlan) (uuid.UUID, error) {
+	domainID, sets, err := snapshotAnswers(db, plan.PoolType, plan.PoolID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	previous, _ := json.Marshal(sets)
+
+	var opID uuid.UUID
+	err = db.Get(&opID, `
+		INSERT INTO dns_rotation_operations
+			(pool_type, pool_id, dns_domain_id, state, trigger, from_machine_id, from_ip, to_machine_id, to_ip,
+			 new_index, await_propagation, reset_schedule, previous_answers, instance, answer_machine_ids)
+		VALUES ($1, $2, $3, 'planned', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, plan.PoolType, plan.PoolID, domainID, plan.Trigger, plan.FromMachineID, plan.FromIP, plan.ToMachineID, plan.ToIP,
+		plan.NewIndex, plan.AwaitPropagation, plan.ResetSchedule, previous, instanceName, answerMachineIDs(plan))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return uuid.Nil, ErrRotationInProgress
+		}
+		return uuid.Nil, fmt.Errorf("failed to journal rotation: %w", err)
+	}
+	return opID, nil
+}
+
+// answerMachineIDs returns the members a plan publishes, as stored in the journal
+func answerMachineIDs(plan RotationPlan) pq.StringArray {
+	ids := plan.AnswerMachineIDs
+	if len(ids) == 0 {
+		ids = []uuid.UUID{plan.ToMachineID}
+	}
+	arr := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		arr[i] = id.String()
+	}
+	return arr
+}
+
+// snapshotAnswers returns the domain and the current values of every RRset a pool manages
+func snapshotAnswers(db *database.DB, poolType string, poolID uuid.UUID) (uuid.UUID, []answerSet, error) {
+	var pool struct {
+		DNSDomainID uuid.UUID `db:"dns_domain_id"`
+		Name        string    `db:"name"`
+		RecordType  string    `db:"record_type"`
+		IncludeRoot bool      `db:"include_root"`
+		IPv6Enabled bool      `db:"ipv6_enabled"`
+	}
+	var err error
+	if poolType == "wildcard" {
+		err = db.Get(&pool, `
+			SELECT dns_domain_id, '*' as name, 'A' as record_type, include_root, ipv6_enabled
+			FROM dns_wildcard_pools WHERE id = $1
+		`, poolID)
+	} else {
+		err = db.Get(&pool, `
+			SELECT r.dns_domain_id, r.name, r.record_type, false as include_root, p.ipv6_enabled
+			FROM dns_passthrough_pools p
+			JOIN dns_records r ON p.dns_record_id = r.id
+			WHERE p.id = $1
+		`, poolID)
+	}
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("failed to get pool records: %w", err)
+	}
+
+	names := []string{pool.Name}
+	if pool.IncludeRoot {
+		names = append(names, "@")
+	}
+	types := []string{pool.RecordType}
+	if pool.IPv6Enabled && pool.RecordType != "AAAA" {
+		types = append(types, "AAAA")
+	}
+
+	var sets []answerSet
+	for _, name := range names {
+		for _, recordType := range types {
+			set := answerSet{Name: name, Type: recordType, Values: []string{}}
+			db.Select(&set.Values, `
+				SELECT value FROM dns_records
+				WHERE dns_domain_id = $1 AND name = $2 AND record_type = $3 AND mode IN ('dynamic', 'dynamic_extra')
+				ORDER BY (mode = 'dynamic') DESC, created_at
+			`, pool.DNSDomainID, name, recordType)
+			sets = append(sets, set)
+		}
+	}
+	return pool.DNSDomainID, sets, nil
+}
+
+// advanceRotation moves an operation between states, failing if someone else already moved it
+func advanceRotation(db *database.DB, opID uuid.UUID, from, to string) error {
+	result, err := db.Exec(`
+		UPDATE dns_rotation_operations SET state = $1, updated_at = NOW()
+		WHERE id = $2 AND state = $3
+	`, to, opID, from)
+	if err != nil {
+		return fmt.Errorf("failed to update rotation state: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errRotationTakenOver
+	}
+	return nil
+}
+
+// commitRotation applies the pool state and history of an operation in one transaction
+func commitRotation(db *database.DB, opID uuid.UUID, from string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var op models.RotationOperation
+	if err := tx.Get(&op, "SELECT * FROM dns_rotation_operations WHERE id = $1 AND state = $2 FOR UPDATE", opID, from); err != nil {
+		if err == sql.ErrNoRows {
+			return errRotationTakenOver
+		}
+		return fmt.Errorf("failed to load rotation: %w", err)
+	}
+
+	table := "dns_passthrough_pools"
+	var historyDomainID *uuid.UUID
+	if op.PoolType == "wildcard" {
+		table = "dns_wildcard_pools"
+		historyDomainID = op.DNSDomainID
+	}
+
+	// With propagation confirmation last_rotated_at waits for the nameservers
+	result, err := tx.Exec(`
+		UPDATE `+table+` SET
+			current_machine_id = $1,
+			current_index = $2,
+			last_rotated_at = CASE WHEN $3 THEN last_rotated_at ELSE NOW() END,
+			propagation_status = CASE WHEN $3 THEN 'pending' ELSE propagation_status END,
+			propagation_pending_since = CASE WHEN $3 THEN NOW() ELSE propagation_pending_since END,
+			next_rotation_at = CASE WHEN $4 THEN NULL ELSE next_rotation_at END,
+			updated_at = NOW()
+		WHERE id = $5
+	`, op.ToMachineID, op.NewIndex, op.AwaitPropagation, op.ResetSchedule, op.PoolID)
+	if err != nil {
+		return fmt.Errorf("failed to update pool state: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Exec("UPDATE dns_rotation_operations SET state = 'rolled_back', error = 'pool deleted', updated_at = NOW() WHERE id = $1", opID)
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO dns_rotation_history
+			(pool_type, pool_id, dns_domain_id, from_machine_id, from_ip, to_machine_id, to_ip, trigger, answer_machine_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, op.PoolType, op.PoolID, historyDomainID, op.FromMachineID, op.FromIP, op.ToMachineID, op.ToIP, op.Trigger, op.AnswerMachineIDs)
+	if err != nil {
+		return fmt.Errorf("failed to insert rotation history: %w", err)
+	}
+
+	tx.Exec("UPDATE "+table+" SET last_rotation_error = NULL, last_rotation_error_at = NULL WHERE id = $1", op.PoolID)
+	tx.Exec("UPDATE dns_rotation_operations SET state = 'committed', updated_at = NOW() WHERE id = $1", opID)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Passthrough: committed %s pool %s rotation to %s (%s), trigger=%s", op.PoolType, op.PoolID, op.ToMachineID, op.ToIP, op.Trigger)
+	NotifyRotation(db, op)
+	return nil
+}
+
+// runWarmup runs a warmup while keeping the planned operation fresh, so the leader
+// doesn't take a long warmup for an abandoned rotation
+func runWarmup(db *database.DB, opID uuid.UUID, warmup func() error) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				db.Exec("UPDATE dns_rotation_operations SET updated_at = NOW() WHERE id = $1 AND state = 'planned'", opID)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return warmup()
+}
+
+// abortRotation ends a planned operation whose warmup failed and records the reason on
+// the pool. DNS was not touched, so there is nothing to restore.
+func abortRotation(db *database.DB, opID uuid.UUID, plan RotationPlan, reason string) {
+	db.Exec(`
+		UPDATE dns_rotation_operations SET state = 'aborted', error = $1, updated_at = NOW()
+		WHERE id = $2 AND state = 'planned'
+	`, reason, opID)
+
+	table := "dns_passthrough_pools"
+	if plan.PoolType == "wildcard" {
+		table = "dns_wildcard_pools"
+	}
+	db.Exec("UPDATE "+table+" SET last_rotation_error = $1, last_rotation_error_at = NOW() WHERE id = $2", reason, plan.PoolID)
+	log.Printf("Passthrough: aborted %s pool %s rotation to %s: %s", plan.PoolType, plan.PoolID, plan.ToMachineID, reason)
+}
+
+// rollbackRotation restores the answers snapshotted when the rotation was planned.
+// If the restore fails the operation stays planned so the leader retries it later.
+func rollbackRotation(db *database.DB, opID uuid.UUID, cause string) error {
+	var op models.RotationOperation
+	if err := db.Get(&op, "SELECT * FROM dns_rotation_operations WHERE id = $1", opID); err != nil {
+		return fmt.Errorf("failed to load rotation: %w", err)
+	}
+
+	var sets []answerSet
+	json.Unmarshal(op.PreviousAnswers, &sets)
+	var restoreErr error
+	if op.DNSDomainID != nil {
+		for _, set := range sets {
+			if err := PublishNameAnswers(db, *op.DNSDomainID, set.Name, set.Type, set.Values); err != nil && restoreErr == nil {
+				restoreErr = err
+			}
+		}
+	}
+
+	if restoreErr != nil {
+		db.Exec("UPDATE dns_rotation_operations SET error = $1, updated_at = NOW() WHERE id = $2",
+			fmt.Sprintf("%s; restore failed: %v", cause, restoreErr), opID)
+		return restoreErr
+	}
+
+	db.Exec(`
+		UPDATE dns_rotation_operations SET state = 'rolled_back', error = $1, updated_at = NOW()
+		WHERE id = $2 AND state = 'planned'
+	`, cause, opID)
+	log.Printf("Passthrough: rolled back %s pool %s rotation to %s: %s", op.PoolType, op.PoolID, op.ToMachineID, cause)
+	return nil
+}
+
+// RecoverRotations finishes operations abandoned by a crashed instance: rotations whose
+// DNS change went through are committed, the rest are rolled back. Only the scheduler
+// leader calls this.
+func RecoverRotations(db *database.DB) {
+	var ops []models.RotationOperation
+	err := db.Select(&ops, `
+		SELECT * FROM dns_rotation_operations
+		WHERE state IN ('planned', 'dns_updated') AND updated_at < $1
+		ORDER BY created_at
+	`, time.Now().Add(-rotationStaleAfter))
+	if err != nil {
+		log.Printf("Passthrough: failed to load unfinished rotations: %v", err)
+		return
+	}
+
+	for _, op := range ops {
+		log.Printf("Passthrough: recovering %s rotation %s of %s pool %s (planned by %s)", op.State, op.ID, op.PoolType, op.PoolID, op.Instance)
+		var err error
+		if op.State == RotationDNSUpdated {
+			err = commitRotation(db, op.ID, RotationDNSUpdated)
+		} else {
+			err = rollbackRotation(db, op.ID, "abandoned by "+op.Instance)
+		}
+		if err != nil {
+			log.Printf("Passthrough: failed to recover rotation %s: %v", op.ID, err)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
 	"time"
 
+	"configuratix/backend/internal/cron"
 	"configuratix/backend/internal/database"
 	"configuratix/backend/internal/dns"
 	"configuratix/backend/internal/models"
@@ -13,11 +15,18 @@ import (
 	"github.com/google/uuid"
 )
 
-// PassthroughScheduler handles automatic DNS rotation
+// propagationTimeout is how long a rotation may wait for every authoritative
+// nameserver to serve the new value before the scheduler gives up on confirming it
+const propagationTimeout = 10 * time.Minute
+
+// PassthroughScheduler handles automatic DNS rotation. With several backend
+// instances only the holder of the leader lock schedules rotations.
 type PassthroughScheduler struct {
 	db       *database.DB
 	interval time.Duration
 	stop     chan struct{}
+	leader   *LeaderLock
+	nginx    PassthroughConfigApplier // For pre-rotation warmup
 }
 
 // NewPassthroughScheduler creates a new scheduler
@@ -26,9 +35,15 @@ func NewPassthroughScheduler(db *database.DB) *PassthroughScheduler {
 		db:       db,
 		interval: 1 * time.Minute, // Check every minute
 		stop:     make(chan struct{}),
+		leader:   NewLeaderLock(db, "passthrough-scheduler"),
 	}
 }
 
+// SetNginxApplier sets the applier used to warm up members before they are published
+func (s *PassthroughScheduler) SetNginxApplier(applier PassthroughConfigApplier) {
+	s.nginx = applier
+}
+
 // Start begins the scheduler loop
 func (s *PassthroughScheduler) Start() {
 	log.Println("Passthrough scheduler started")
@@ -41,6 +56,7 @@ func (s *PassthroughScheduler) Start() {
 		case <-ticker.C:
 			s.tick()
 		case <-s.stop:
+			s.leader.Release()
 			log.Println("Passthrough scheduler stopped")
 			return
 		}
@@ -54,6 +70,13 @@ func (s *PassthroughScheduler) Stop() {
 
 // tick processes all pools that need rotation
 func (s *PassthroughScheduler) tick() {
+	if !s.leader.IsLeader() {
+		return // Another instance schedules rotations
+	}
+
+	// Finish rotations abandoned by a crashed instance before starting new ones
+	RecoverRotations(s.db)
+
 	// Process record pools
 	s.processRecordPools()
 	
@@ -76,8 +99,20 @@ func (s *PassthroughScheduler) processRecordPools() {
 	now := time.Now().UTC()
 
 	for _, pool := range pools {
-		if s.shouldRotate(pool.RotationMode, pool.IntervalMinutes, pool.ScheduledTimes, pool.LastRotatedAt, now) {
-			s.rotateRecordPool(pool)
+		// Failover takes precedence over a pending propagation check
+		schedule := RecordPoolSchedule(pool)
+		if trigger := s.failoverTrigger("record", pool.ID, pool.RotationMode, pool.FailoverEnabled, pool.HealthCheckEnabled, pool.CurrentMachineID, pool.PreferredMachineID); trigger != "" {
+			s.rotateRecordPool(pool, trigger)
+			s.scheduleNext("dns_passthrough_pools", pool.ID, schedule, now)
+			continue
+		}
+		if pool.PropagationPendingSince != nil {
+			s.confirmRecordRotation(pool, now)
+			continue
+		}
+		if s.isDue("dns_passthrough_pools", pool.ID, schedule, pool.LastRotatedAt, pool.NextRotationAt, now) {
+			s.rotateRecordPool(pool, "scheduled")
+			s.scheduleNext("dns_passthrough_pools", pool.ID, schedule, now)
 		}
 	}
 }
@@ -97,482 +132,323 @@ func (s *PassthroughScheduler) processWildcardPools() {
 	now := time.Now().UTC()
 
 	for _, pool := range pools {
-		if s.shouldRotate(pool.RotationMode, pool.IntervalMinutes, pool.ScheduledTimes, pool.LastRotatedAt, now) {
-			s.rotateWildcardPool(pool)
+		schedule := WildcardPoolSchedule(pool)
+		if trigger := s.failoverTrigger("wildcard", pool.ID, pool.RotationMode, pool.FailoverEnabled, pool.HealthCheckEnabled, pool.CurrentMachineID, pool.PreferredMachineID); trigger != "" {
+			s.rotateWildcardPool(pool, trigger)
+			s.scheduleNext("dns_wildcard_pools", pool.ID, schedule, now)
+			continue
+		}
+		if pool.PropagationPendingSince != nil {
+			s.confirmWildcardRotation(pool, now)
+			continue
+		}
+		if s.isDue("dns_wildcard_pools", pool.ID, schedule, pool.LastRotatedAt, pool.NextRotationAt, now) {
+			s.rotateWildcardPool(pool, "scheduled")
+			s.scheduleNext("dns_wildcard_pools", pool.ID, schedule, now)
 		}
 	}
 }
 
-// shouldRotate determines if a pool should be rotated
-func (s *PassthroughScheduler) shouldRotate(mode string, intervalMinutes int, scheduledTimes []string, lastRotated *time.Time, now time.Time) bool {
-	if mode == "interval" {
-		if lastRotated == nil {
-			return true // Never rotated, do it now
-		}
-		return now.Sub(*lastRotated) >= time.Duration(intervalMinutes)*time.Minute
-	}
+// RotationSchedule describes when a pool rotates on its own
+type RotationSchedule struct {
+	Mode            string   // interval, scheduled, failover
+	IntervalMinutes int
+	ScheduledTimes  []string // Legacy "HH:MM" list, evaluated in Timezone
+	CronExpression  string
+	Timezone        string // IANA name, empty = UTC
+}
 
-	// Scheduled mode
-	if len(scheduledTimes) == 0 {
-		return false
-	}
+// RecordPoolSchedule returns the rotation schedule of a record pool
+func RecordPoolSchedule(pool models.PassthroughPool) RotationSchedule {
+	return RotationSchedule{pool.RotationMode, pool.IntervalMinutes, pool.ScheduledTimes, pool.CronExpression, pool.Timezone}
+}
 
-	currentTime := now.Format("15:04")
-	for _, scheduledTime := range scheduledTimes {
-		if currentTime == scheduledTime {
-			// Check if we already rotated in the last minute for this schedule
-			if lastRotated != nil && now.Sub(*lastRotated) < 2*time.Minute {
-				return false
-			}
-			return true
-		}
-	}
+// WildcardPoolSchedule returns the rotation schedule of a wildcard pool
+func WildcardPoolSchedule(pool models.WildcardPool) RotationSchedule {
+	return RotationSchedule{pool.RotationMode, pool.IntervalMinutes, pool.ScheduledTimes, pool.CronExpression, pool.Timezone}
+}
 
-	return false
+// Validate checks the timezone, cron expression and scheduled times
+func (rs RotationSchedule) Validate() error {
+	_, _, err := rs.parse()
+	return err
 }
 
-// rotateRecordPool rotates a record pool to the next machine
-func (s *PassthroughScheduler) rotateRecordPool(pool models.PassthroughPool) {
-	log.Printf("Scheduler: rotateRecordPool pool=%s, group_ids=%v (%d groups)", pool.ID, pool.GroupIDs, len(pool.GroupIDs))
-	
-	// Get available members (direct members + machines from groups)
-	type MemberInfo struct {
-		MachineID uuid.UUID  `db:"machine_id"`
-		MachineIP string     `db:"machine_ip"`
-		LastSeen  *time.Time `db:"last_seen"`
-		Priority  int        `db:"priority"`
-	}
-	var members []MemberInfo
-	
-	// Get direct members
-	err := s.db.Select(&members, `
-		SELECT pm.machine_id, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen, pm.priority
-		FROM dns_passthrough_members pm
-		JOIN machines m ON pm.machine_id = m.id
-		LEFT JOIN agents a ON m.agent_id = a.id
-		WHERE pm.pool_id = $1 AND pm.is_enabled = true
-		ORDER BY pm.priority, m.hostname
-	`, pool.ID)
-	if err != nil {
-		log.Printf("Scheduler: failed to get direct members: %v", err)
-	}
-	log.Printf("Scheduler: found %d direct members", len(members))
-
-	// Also get machines from groups if pool has group_ids
-	if len(pool.GroupIDs) > 0 {
-		var groupMembers []MemberInfo
-		err := s.db.Select(&groupMembers, `
-			SELECT gm.machine_id, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen, 100 as priority
-			FROM machine_group_members gm
-			JOIN machines m ON gm.machine_id = m.id
-			LEFT JOIN agents a ON m.agent_id = a.id
-			WHERE gm.group_id = ANY($1::uuid[])
-		`, pool.GroupIDs)
-		if err != nil {
-			log.Printf("Scheduler: failed to get group members: %v", err)
+// parse returns the pool's timezone and its cron schedules (cron_expression plus one
+// daily schedule per legacy scheduled time)
+func (rs RotationSchedule) parse() (*time.Location, []*cron.Schedule, error) {
+	loc := time.UTC
+	if rs.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(rs.Timezone); err != nil {
+			return nil, nil, fmt.Errorf("invalid timezone %q", rs.Timezone)
 		}
-		log.Printf("Scheduler: found %d machines from groups", len(groupMembers))
-		
-		// Add group members that aren't already in direct members
-		for _, gm := range groupMembers {
-			found := false
-			for _, m := range members {
-				if m.MachineID == gm.MachineID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				members = append(members, gm)
-			}
-		}
-	}
-
-	log.Printf("Scheduler: total %d members available for pool %s", len(members), pool.ID)
-	if len(members) == 0 {
-		log.Printf("Passthrough scheduler: no members in pool %s", pool.ID)
-		return
 	}
 
-	// Filter by health if enabled
-	if pool.HealthCheckEnabled {
-		var healthy []MemberInfo
-		for _, m := range members {
-			if m.LastSeen != nil && time.Since(*m.LastSeen) < 5*time.Minute {
-				healthy = append(healthy, m)
-			}
+	var schedules []*cron.Schedule
+	if rs.CronExpression != "" {
+		schedule, err := cron.Parse(rs.CronExpression)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid cron expression: %w", err)
 		}
-		if len(healthy) > 0 {
-			members = healthy
-		} else {
-			log.Printf("Passthrough scheduler: no healthy members in pool %s, using all", pool.ID)
+		schedules = append(schedules, schedule)
+	}
+	for _, t := range rs.ScheduledTimes {
+		hm, err := time.Parse("15:04", t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid scheduled time %q, expected HH:MM", t)
 		}
+		schedule, _ := cron.Parse(fmt.Sprintf("%d %d * * *", hm.Minute(), hm.Hour()))
+		schedules = append(schedules, schedule)
 	}
+	return loc, schedules, nil
+}
 
-	// Select next machine
-	var nextMachine struct {
-		MachineID uuid.UUID
-		MachineIP string
+// NextRotation returns when the pool should rotate next, or nil when it has no
+// schedule (failover mode, or scheduled mode without times)
+func (rs RotationSchedule) NextRotation(lastRotated *time.Time, now time.Time) (*time.Time, error) {
+	switch rs.Mode {
+	case "failover":
+		return nil, nil
+	case "interval":
+		if lastRotated == nil {
+			return &now, nil // Never rotated, do it now
+		}
+		next := lastRotated.Add(time.Duration(rs.IntervalMinutes) * time.Minute)
+		return &next, nil
 	}
-	var newIndex int
 
-	if pool.RotationStrategy == "random" {
-		idx := rand.Intn(len(members))
-		nextMachine.MachineID = members[idx].MachineID
-		nextMachine.MachineIP = members[idx].MachineIP
-		newIndex = idx
-	} else {
-		// Round-robin
-		newIndex = (pool.CurrentIndex + 1) % len(members)
-		nextMachine.MachineID = members[newIndex].MachineID
-		nextMachine.MachineIP = members[newIndex].MachineIP
+	loc, schedules, err := rs.parse()
+	if err != nil {
+		return nil, err
 	}
-
-	// Skip if same machine
-	if pool.CurrentMachineID != nil && *pool.CurrentMachineID == nextMachine.MachineID {
-		// Still update last_rotated_at to prevent immediate re-trigger
-		s.db.Exec("UPDATE dns_passthrough_pools SET last_rotated_at = NOW() WHERE id = $1", pool.ID)
-		return
+	var next *time.Time
+	for _, schedule := range schedules {
+		t := schedule.Next(now.In(loc))
+		if !t.IsZero() && (next == nil || t.Before(*next)) {
+			next = &t
+		}
 	}
+	return next, nil
+}
 
-	// Get current IP for history
-	var fromIP string
-	if pool.CurrentMachineID != nil {
-		s.db.Get(&fromIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", *pool.CurrentMachineID)
+// isDue reports whether a pool's persisted next rotation time has passed. Pools
+// without one get it computed and stored first. Because the due time is persisted,
+// a rotation missed during downtime fires on the first tick after it.
+func (s *PassthroughScheduler) isDue(table string, poolID uuid.UUID, schedule RotationSchedule, lastRotated, nextRotation *time.Time, now time.Time) bool {
+	if schedule.Mode == "failover" {
+		return false // Only rotates when the current machine fails
 	}
 
-	// Update DNS record
-	s.updateDNSRecord(pool.DNSRecordID, nextMachine.MachineIP)
+	if nextRotation == nil {
+		next, err := schedule.NextRotation(lastRotated, now)
+		if err != nil {
+			log.Printf("Passthrough scheduler: pool %s has an invalid schedule: %v", poolID, err)
+			return false
+		}
+		if next == nil {
+			return false
+		}
+		s.db.Exec("UPDATE "+table+" SET next_rotation_at = $1 WHERE id = $2", *next, poolID)
+		nextRotation = next
+	}
 
-	// Update pool state
-	s.db.Exec(`
-		UPDATE dns_passthrough_pools 
-		SET current_machine_id = $1, current_index = $2, last_rotated_at = NOW(), updated_at = NOW()
-		WHERE id = $3
-	`, nextMachine.MachineID, newIndex, pool.ID)
-
-	// Log history
-	log.Printf("Scheduler: inserting history - pool=%s, from=%v, from_ip=%s, to=%s, to_ip=%s",
-		pool.ID, pool.CurrentMachineID, fromIP, nextMachine.MachineID, nextMachine.MachineIP)
-	
-	result, histErr := s.db.Exec(`
-		INSERT INTO dns_rotation_history 
-			(pool_type, pool_id, from_machine_id, from_ip, to_machine_id, to_ip, trigger)
-		VALUES ('record', $1, $2, $3, $4, $5, 'scheduled')
-	`, pool.ID, pool.CurrentMachineID, fromIP, nextMachine.MachineID, nextMachine.MachineIP)
-	if histErr != nil {
-		log.Printf("Scheduler: failed to insert rotation history: %v", histErr)
-	} else {
-		rows, _ := result.RowsAffected()
-		log.Printf("Scheduler: history inserted, %d rows affected", rows)
-	}
-
-	log.Printf("Passthrough scheduler: rotated pool %s to %s (%s)", pool.ID, nextMachine.MachineID, nextMachine.MachineIP)
+	return !now.Before(*nextRotation)
 }
 
-// rotateWildcardPool rotates a wildcard pool
-func (s *PassthroughScheduler) rotateWildcardPool(pool models.WildcardPool) {
-	type WMemberInfo struct {
-		MachineID uuid.UUID  `db:"machine_id"`
-		MachineIP string     `db:"machine_ip"`
-		LastSeen  *time.Time `db:"last_seen"`
-		Priority  int        `db:"priority"`
-	}
-	var members []WMemberInfo
-	
-	// Get direct members
-	s.db.Select(&members, `
-		SELECT wm.machine_id, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen, wm.priority
-		FROM dns_wildcard_pool_members wm
-		JOIN machines m ON wm.machine_id = m.id
-		LEFT JOIN agents a ON m.agent_id = a.id
-		WHERE wm.pool_id = $1 AND wm.is_enabled = true
-		ORDER BY wm.priority, m.hostname
-	`, pool.ID)
-
-	// Also get machines from groups if pool has group_ids
-	if len(pool.GroupIDs) > 0 {
-		var groupMembers []WMemberInfo
-		s.db.Select(&groupMembers, `
-			SELECT gm.machine_id, COALESCE(m.primary_ip, m.ip_address) as machine_ip, a.last_seen, 100 as priority
-			FROM machine_group_members gm
-			JOIN machines m ON gm.machine_id = m.id
-			LEFT JOIN agents a ON m.agent_id = a.id
-			WHERE gm.group_id = ANY($1::uuid[])
-		`, pool.GroupIDs)
-		
-		// Add group members that aren't already in direct members
-		for _, gm := range groupMembers {
-			found := false
-			for _, m := range members {
-				if m.MachineID == gm.MachineID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				members = append(members, gm)
-			}
-		}
+// scheduleNext persists the pool's next rotation time after it rotated at now
+func (s *PassthroughScheduler) scheduleNext(table string, poolID uuid.UUID, schedule RotationSchedule, now time.Time) {
+	next, err := schedule.NextRotation(&now, now)
+	if err != nil {
+		log.Printf("Passthrough scheduler: pool %s has an invalid schedule: %v", poolID, err)
 	}
+	s.db.Exec("UPDATE "+table+" SET next_rotation_at = $1 WHERE id = $2", next, poolID)
+}
 
-	if len(members) == 0 {
-		return
+// failoverTrigger returns "failover" when the pool's current machine is unhealthy,
+// "failback" when the pool failed over away from the preferred machine and it is
+// healthy again, or "". A scheduled or manual rotation away from the preferred
+// machine is not undone.
+func (s *PassthroughScheduler) failoverTrigger(poolType string, poolID uuid.UUID, mode string, failoverEnabled, healthCheckEnabled bool, currentID, preferredID *uuid.UUID) string {
+	if mode != "failover" && !failoverEnabled {
+		return ""
 	}
-
-	if pool.HealthCheckEnabled {
-		var healthy []WMemberInfo
-		for _, m := range members {
-			if m.LastSeen != nil && time.Since(*m.LastSeen) < 5*time.Minute {
-				healthy = append(healthy, m)
-			}
-		}
-		if len(healthy) > 0 {
-			members = healthy
-		}
+	if currentID == nil {
+		return ""
 	}
 
-	var nextMachine struct {
-		MachineID uuid.UUID
-		MachineIP string
+	health := MemberHealthMap(s.db, poolType, poolID, healthCheckEnabled)
+	if !MemberIsHealthy(health[*currentID], s.machineLastSeen(*currentID)) {
+		return "failover"
 	}
-	var newIndex int
-
-	if pool.RotationStrategy == "random" {
-		idx := rand.Intn(len(members))
-		nextMachine.MachineID = members[idx].MachineID
-		nextMachine.MachineIP = members[idx].MachineIP
-		newIndex = idx
-	} else {
-		newIndex = (pool.CurrentIndex + 1) % len(members)
-		nextMachine.MachineID = members[newIndex].MachineID
-		nextMachine.MachineIP = members[newIndex].MachineIP
+	if preferredID != nil && *preferredID != *currentID && s.lastTrigger(poolType, poolID) == "failover" &&
+		MemberIsHealthy(health[*preferredID], s.machineLastSeen(*preferredID)) {
+		return "failback"
 	}
+	return ""
+}
 
-	if pool.CurrentMachineID != nil && *pool.CurrentMachineID == nextMachine.MachineID {
-		s.db.Exec("UPDATE dns_wildcard_pools SET last_rotated_at = NOW() WHERE id = $1", pool.ID)
-		return
+// lastTrigger returns the trigger of the pool's latest completed rotation
+func (s *PassthroughScheduler) lastTrigger(poolType string, poolID uuid.UUID) string {
+	var trigger string
+	s.db.Get(&trigger, `
+		SELECT trigger FROM dns_rotation_history
+		WHERE pool_type = $1 AND pool_id = $2
+		ORDER BY rotated_at DESC
+		LIMIT 1
+	`, poolType, poolID)
+	return trigger
+}
+
+func candidateIPs(candidates []RotationCandidate) []string {
+	ips := make([]string, len(candidates))
+	for i, c := range candidates {
+		ips[i] = c.MachineIP
 	}
+	return ips
+}
 
-	var fromIP string
-	if pool.CurrentMachineID != nil {
-		s.db.Get(&fromIP, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", *pool.CurrentMachineID)
+func candidateMachineIDs(candidates []RotationCandidate) []uuid.UUID {
+	ids := make([]uuid.UUID, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.MachineID
 	}
+	return ids
+}
 
-	// Update wildcard DNS records
-	s.updateWildcardDNS(pool.DNSDomainID, nextMachine.MachineIP, pool.IncludeRoot)
+// machineLastSeen returns the last heartbeat of a machine's agent
+func (s *PassthroughScheduler) machineLastSeen(machineID uuid.UUID) *time.Time {
+	var lastSeen *time.Time
+	s.db.Get(&lastSeen, `
+		SELECT a.last_seen FROM machines m
+		LEFT JOIN agents a ON m.agent_id = a.id
+		WHERE m.id = $1
+	`, machineID)
+	return lastSeen
+}
 
-	s.db.Exec(`
-		UPDATE dns_wildcard_pools 
-		SET current_machine_id = $1, current_index = $2, last_rotated_at = NOW(), updated_at = NOW()
-		WHERE id = $3
-	`, nextMachine.MachineID, newIndex, pool.ID)
+// rotateRecordPool rotates a record pool to the next machine. trigger is
+// "scheduled", "failover" (skip the failed current machine) or "failback"
+// (return to the preferred machine).
+func (s *PassthroughScheduler) rotateRecordPool(pool models.PassthroughPool, trigger string) {
+	selected, newIndex, err := PlanRotation(s.db, RecordPoolState(pool), trigger)
+	if err != nil {
+		log.Printf("Passthrough scheduler: cannot rotate pool %s (%s): %v", pool.ID, trigger, err)
+		return
+	}
 
-	_, histErr := s.db.Exec(`
-		INSERT INTO dns_rotation_history 
-			(pool_type, pool_id, dns_domain_id, from_machine_id, from_ip, to_machine_id, to_ip, trigger)
-		VALUES ('wildcard', $1, $2, $3, $4, $5, $6, 'scheduled')
-	`, pool.ID, pool.DNSDomainID, pool.CurrentMachineID, fromIP, nextMachine.MachineID, nextMachine.MachineIP)
-	if histErr != nil {
-		log.Printf("Scheduler: failed to insert wildcard rotation history: %v", histErr)
+	// Skip if same machine
+	if len(selected) == 1 && pool.CurrentMachineID != nil && *pool.CurrentMachineID == selected[0].MachineID {
+		// Still update last_rotated_at to prevent immediate re-trigger
+		s.db.Exec("UPDATE dns_passthrough_pools SET last_rotated_at = NOW() WHERE id = $1", pool.ID)
+		return
 	}
 
-	log.Printf("Passthrough scheduler: rotated wildcard pool %s to %s (%s)", pool.ID, nextMachine.MachineID, nextMachine.MachineIP)
+	plan, updateDNS := RecordPoolRotation(s.db, s.nginx, pool, selected, newIndex, trigger)
+	s.startRotation("pool", pool.ID, plan, updateDNS)
 }
 
-// updateDNSRecord updates a DNS record value and syncs to provider
-func (s *PassthroughScheduler) updateDNSRecord(recordID uuid.UUID, newIP string) {
-	// Update local record
-	s.db.Exec("UPDATE dns_records SET value = $1, sync_status = 'pending', updated_at = NOW() WHERE id = $2", newIP, recordID)
-
-	// Get record and account info for provider update
-	var record struct {
-		Name           string     `db:"name"`
-		RecordType     string     `db:"record_type"`
-		TTL            int        `db:"ttl"`
-		Priority       int        `db:"priority"`
-		Proxied        bool       `db:"proxied"`
-		DNSDomainID    uuid.UUID  `db:"dns_domain_id"`
-		DomainFQDN     string     `db:"domain_fqdn"`
-		DNSAccountID   *uuid.UUID `db:"dns_account_id"`
-		RemoteRecordID *string    `db:"remote_record_id"`
-	}
-	err := s.db.Get(&record, `
-		SELECT r.name, r.record_type, r.ttl, r.priority, r.proxied, r.dns_domain_id, 
-		       d.fqdn as domain_fqdn, d.dns_account_id, r.remote_record_id
-		FROM dns_records r
-		JOIN dns_managed_domains d ON r.dns_domain_id = d.id
-		WHERE r.id = $1
-	`, recordID)
+// rotateWildcardPool rotates a wildcard pool
+func (s *PassthroughScheduler) rotateWildcardPool(pool models.WildcardPool, trigger string) {
+	selected, newIndex, err := PlanRotation(s.db, WildcardPoolState(pool), trigger)
 	if err != nil {
-		log.Printf("Scheduler: failed to get record info: %v", err)
-		s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", err.Error(), recordID)
+		log.Printf("Passthrough scheduler: cannot rotate wildcard pool %s (%s): %v", pool.ID, trigger, err)
 		return
 	}
 
-	// If no DNS account, just update locally
-	if record.DNSAccountID == nil {
-		s.db.Exec("UPDATE dns_records SET sync_status = 'synced' WHERE id = $1", recordID)
+	if len(selected) == 1 && pool.CurrentMachineID != nil && *pool.CurrentMachineID == selected[0].MachineID {
+		s.db.Exec("UPDATE dns_wildcard_pools SET last_rotated_at = NOW() WHERE id = $1", pool.ID)
 		return
 	}
 
-	// Get DNS account and create provider
-	var account struct {
-		Provider string  `db:"provider"`
-		ApiID    *string `db:"api_id"`
-		ApiToken string  `db:"api_token"`
-	}
-	err = s.db.Get(&account, "SELECT provider, api_id, api_token FROM dns_accounts WHERE id = $1", *record.DNSAccountID)
+	plan, updateDNS := WildcardPoolRotation(s.db, s.nginx, pool, selected, newIndex, trigger)
+	s.startRotation("wildcard pool", pool.ID, plan, updateDNS)
+}
+
+// startRotation journals a rotation and finishes it in the background, so a pool
+// warming up its new members doesn't hold up the rest of the tick. A pool whose
+// previous rotation is still running is skipped until it finishes.
+func (s *PassthroughScheduler) startRotation(kind string, poolID uuid.UUID, plan RotationPlan, updateDNS func() error) {
+	operationID, err := StartRotation(s.db, plan)
 	if err != nil {
-		log.Printf("Scheduler: failed to get DNS account: %v", err)
-		s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", err.Error(), recordID)
+		if !errors.Is(err, ErrRotationInProgress) {
+			log.Printf("Passthrough scheduler: failed to start rotation of %s %s: %v", kind, poolID, err)
+		}
 		return
 	}
+	go func() {
+		if err := ContinueRotation(s.db, operationID, plan, updateDNS); err != nil {
+			log.Printf("Passthrough scheduler: rotation of %s %s failed: %v", kind, poolID, err)
+		}
+	}()
+}
 
-	apiID := ""
-	if account.ApiID != nil {
-		apiID = *account.ApiID
+// confirmRecordRotation checks that every authoritative nameserver serves the pool's
+// current record value before advancing last_rotated_at
+func (s *PassthroughScheduler) confirmRecordRotation(pool models.PassthroughPool, now time.Time) {
+	var record struct {
+		Name       string `db:"name"`
+		RecordType string `db:"record_type"`
+		Value      string `db:"value"`
+		DomainFQDN string `db:"domain_fqdn"`
 	}
-	provider, err := dns.NewProvider(account.Provider, apiID, account.ApiToken)
+	err := s.db.Get(&record, `
+		SELECT r.name, r.record_type, r.value, d.fqdn as domain_fqdn
+		FROM dns_records r
+		JOIN dns_managed_domains d ON r.dns_domain_id = d.id
+		WHERE r.id = $1
+	`, pool.DNSRecordID)
 	if err != nil {
-		log.Printf("Scheduler: failed to create provider: %v", err)
-		s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", err.Error(), recordID)
+		log.Printf("Scheduler: failed to get record for propagation check: %v", err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	dnsRecord := dns.Record{
-		Type:     record.RecordType,
-		Name:     record.Name,
-		Value:    newIP,
-		TTL:      record.TTL,
-		Priority: record.Priority,
-		Proxied:  record.Proxied,
-	}
-
-	var providerRecordID string
-	if record.RemoteRecordID != nil && *record.RemoteRecordID != "" {
-		dnsRecord.ID = *record.RemoteRecordID
-		_, err = provider.UpdateRecord(ctx, record.DomainFQDN, *record.RemoteRecordID, dnsRecord)
-		if err != nil {
-			log.Printf("Scheduler: failed to update record, trying delete+create: %v", err)
-			provider.DeleteRecord(ctx, record.DomainFQDN, *record.RemoteRecordID)
-			created, createErr := provider.CreateRecord(ctx, record.DomainFQDN, dnsRecord)
-			if createErr != nil {
-				log.Printf("Scheduler: failed to recreate record: %v", createErr)
-				s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", createErr.Error(), recordID)
-				return
-			}
-			providerRecordID = created.ID
-		} else {
-			providerRecordID = *record.RemoteRecordID
-		}
-	} else {
-		created, createErr := provider.CreateRecord(ctx, record.DomainFQDN, dnsRecord)
-		if createErr != nil {
-			// Try to find and update existing
-			records, _ := provider.ListRecords(ctx, record.DomainFQDN)
-			for _, r := range records {
-				if r.Name == record.Name && r.Type == record.RecordType {
-					_, updateErr := provider.UpdateRecord(ctx, record.DomainFQDN, r.ID, dnsRecord)
-					if updateErr == nil {
-						providerRecordID = r.ID
-						createErr = nil
-					}
-					break
-				}
-			}
-			if createErr != nil {
-				log.Printf("Scheduler: failed to create/update record: %v", createErr)
-				s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE id = $2", createErr.Error(), recordID)
-				return
-			}
-		} else {
-			providerRecordID = created.ID
-		}
-	}
-
-	s.db.Exec("UPDATE dns_records SET remote_record_id = $1, sync_status = 'synced', sync_error = NULL WHERE id = $2", 
-		providerRecordID, recordID)
-	log.Printf("Scheduler: synced DNS record %s to %s", record.Name, newIP)
+	result := dns.CheckPropagation(ctx, record.DomainFQDN, record.Name, record.RecordType, record.Value)
+	s.finishPropagation("dns_passthrough_pools", pool.ID, result.Propagated, *pool.PropagationPendingSince, now)
 }
 
-// updateWildcardDNS updates wildcard DNS records
-func (s *PassthroughScheduler) updateWildcardDNS(domainID uuid.UUID, newIP string, includeRoot bool) {
-	// Update wildcard record
-	s.db.Exec(`
-		UPDATE dns_records SET value = $1, sync_status = 'pending', updated_at = NOW()
-		WHERE dns_domain_id = $2 AND name = '*' AND type = 'A'
-	`, newIP, domainID)
-
-	if includeRoot {
-		s.db.Exec(`
-			UPDATE dns_records SET value = $1, sync_status = 'pending', updated_at = NOW()
-			WHERE dns_domain_id = $2 AND name = '@' AND type = 'A'
-		`, newIP, domainID)
-	}
+// confirmWildcardRotation checks the wildcard (and root) records on every authoritative nameserver
+func (s *PassthroughScheduler) confirmWildcardRotation(pool models.WildcardPool, now time.Time) {
+	var fqdn string
+	s.db.Get(&fqdn, "SELECT fqdn FROM dns_managed_domains WHERE id = $1", pool.DNSDomainID)
 
-	// Sync to DNS provider
-	var domainInfo struct {
-		FQDN         string     `db:"fqdn"`
-		DNSAccountID *uuid.UUID `db:"dns_account_id"`
-	}
-	s.db.Get(&domainInfo, "SELECT fqdn, dns_account_id FROM dns_managed_domains WHERE id = $1", domainID)
-
-	if domainInfo.DNSAccountID == nil {
-		// No DNS account, just mark as synced
-		s.db.Exec("UPDATE dns_records SET sync_status = 'synced' WHERE dns_domain_id = $1 AND mode = 'dynamic'", domainID)
-		return
-	}
-
-	// Get DNS account and create provider
-	var account struct {
-		Provider string  `db:"provider"`
-		ApiID    *string `db:"api_id"`
-		ApiToken string  `db:"api_token"`
-	}
-	if err := s.db.Get(&account, "SELECT provider, api_id, api_token FROM dns_accounts WHERE id = $1", *domainInfo.DNSAccountID); err != nil {
-		log.Printf("Scheduler: failed to get DNS account for wildcard sync: %v", err)
-		s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE dns_domain_id = $2 AND mode = 'dynamic'",
-			"DNS account not found", domainID)
-		return
-	}
-
-	apiID := ""
-	if account.ApiID != nil {
-		apiID = *account.ApiID
+	var ip string
+	if pool.CurrentMachineID != nil {
+		s.db.Get(&ip, "SELECT COALESCE(primary_ip, ip_address) FROM machines WHERE id = $1", *pool.CurrentMachineID)
 	}
-	provider, err := dns.NewProvider(account.Provider, apiID, account.ApiToken)
-	if err != nil {
-		log.Printf("Scheduler: failed to create DNS provider for wildcard sync: %v", err)
-		s.db.Exec("UPDATE dns_records SET sync_status = 'error', sync_error = $1 WHERE dns_domain_id = $2 AND mode = 'dynamic'",
-			err.Error(), domainID)
+	if fqdn == "" || ip == "" {
+		s.finishPropagation("dns_wildcard_pools", pool.ID, false, *pool.PropagationPendingSince, now)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Sync wildcard record
-	wildcardRecord := dns.Record{Name: "*", Type: "A", Value: newIP, TTL: 60}
-	if _, createErr := provider.CreateRecord(ctx, domainInfo.FQDN, wildcardRecord); createErr != nil {
-		if _, updateErr := provider.UpdateRecord(ctx, domainInfo.FQDN, "", wildcardRecord); updateErr != nil {
-			log.Printf("Scheduler: failed to sync wildcard record: %v", updateErr)
-		}
+	propagated := dns.CheckPropagation(ctx, fqdn, "*", "A", ip).Propagated
+	if propagated && pool.IncludeRoot {
+		propagated = dns.CheckPropagation(ctx, fqdn, "@", "A", ip).Propagated
 	}
+	s.finishPropagation("dns_wildcard_pools", pool.ID, propagated, *pool.PropagationPendingSince, now)
+}
 
-	// Sync root record if included
-	if includeRoot {
-		rootRecord := dns.Record{Name: "@", Type: "A", Value: newIP, TTL: 60}
-		if _, createErr := provider.CreateRecord(ctx, domainInfo.FQDN, rootRecord); createErr != nil {
-			if _, updateErr := provider.UpdateRecord(ctx, domainInfo.FQDN, "", rootRecord); updateErr != nil {
-				log.Printf("Scheduler: failed to sync root record: %v", updateErr)
-			}
+// finishPropagation records the outcome of a propagation check. A rotation that has
+// not landed within propagationTimeout is given up on so the schedule keeps moving.
+func (s *PassthroughScheduler) finishPropagation(table string, poolID uuid.UUID, propagated bool, pendingSince, now time.Time) {
+	status := "confirmed"
+	if !propagated {
+		if now.Sub(pendingSince) < propagationTimeout {
+			return
 		}
+		status = "timeout"
+		log.Printf("Passthrough scheduler: rotation of pool %s not seen on all nameservers after %s", poolID, propagationTimeout)
 	}
 
-	s.db.Exec("UPDATE dns_records SET sync_status = 'synced', sync_error = NULL WHERE dns_domain_id = $1 AND mode = 'dynamic'", domainID)
+	s.db.Exec(`
+		UPDATE `+table+`
+		SET last_rotated_at = NOW(), propagation_status = $1, propagation_pending_since = NULL, updated_at = NOW()
+		WHERE id = $2
+	`, status, poolID)
 }
-
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultSimulationCount = 5
+	maxSimulationCount     = 50
+)
+
+// ErrInvalidSimulation is returned for simulation requests with invalid overrides
+var ErrInvalidSimulation = errors.New("invalid simulation")
+
+// SimulationOverrides are pool settings a simulation uses instead of the saved ones.
+// Nil fields keep the pool's value.
+type SimulationOverrides struct {
+	RotationStrategy   *string        `json:"rotation_strategy"`
+	AnswerCount        *int           `json:"answer_count"`
+	HealthCheckEnabled *bool          `json:"health_check_enabled"`
+	IPv6Enabled        *bool          `json:"ipv6_enabled"`
+	MachineIDs         []string       `json:"machine_ids"`    // Replaces the direct members, in priority order
+	MemberWeights      map[string]int `json:"member_weights"` // machine_id -> weight, with machine_ids
+	GroupIDs           []string       `json:"group_ids"`
+	RotationMode       *string        `json:"rotation_mode"`
+	IntervalMinutes    *int           `json:"interval_minutes"`
+	ScheduledTimes     []string       `json:"scheduled_times"`
+	CronExpression     *string        `json:"cron_expression"`
+	Timezone           *string        `json:"timezone"`
+}
+
+// SimulationRequest asks for the next Count rotations of a pool
+type SimulationRequest struct {
+	Count     int                 `json:"count"` // Default 5, max 50
+	Seed      *int64              `json:"seed"`  // Random source for random/weighted, to reproduce a run
+	Overrides SimulationOverrides `json:"overrides"`
+}
+
+// SimulatedRotation is one rotation the pool would perform
+type SimulatedRotation struct {
+	Step        int        `json:"step"`
+	At          *time.Time `json:"at"` // nil for failover, which has no schedule
+	Trigger     string     `json:"trigger"`
+	MachineID   uuid.UUID  `json:"machine_id"`
+	MachineName string     `json:"machine_name"`
+	Answers     []string   `json:"answers"` // A record values
+	AnswersIPv6 []string   `json:"answers_ipv6,omitempty"`
+	Index       int        `json:"index"`
+	Unchanged   bool       `json:"unchanged"` // Selected the current machine again; DNS is left as is
+}
+
+// SimulationResult is the outcome of a pool simulation
+type SimulationResult struct {
+	PoolType  string              `json:"pool_type"`
+	PoolID    uuid.UUID           `json:"pool_id"`
+	Strategy  string              `json:"strategy"`
+	Mode      string              `json:"mode"`
+	Seed      int64               `json:"seed"`
+	Members   []MemberEvaluation  `json:"members"`
+	Rotations []SimulatedRotation `json:"rotations"`
+	Warnings  []string            `json:"warnings"`
+}
+
+// SimulationPool is a pool as seen by a simulation
+type SimulationPool struct {
+	State        PoolRotationState
+	Schedule     RotationSchedule
+	IsPaused     bool
+	LastRotated  *time.Time
+	NextRotation *time.Time
+}
+
+// RecordPoolSimulation returns the simulation input of a record pool
+func RecordPoolSimulation(pool models.PassthroughPool) SimulationPool {
+	return SimulationPool{RecordPoolState(pool), RecordPoolSchedule(pool), pool.IsPaused, pool.LastRotatedAt, pool.NextRotationAt}
+}
+
+// WildcardPoolSimulation returns the simulation input of a wildcard pool
+func WildcardPoolSimulation(pool models.WildcardPool) SimulationPool {
+	return SimulationPool{WildcardPoolState(pool), WildcardPoolSchedule(pool), pool.IsPaused, pool.LastRotatedAt, pool.NextRotationAt}
+}
+
+// SimulateRotations evaluates the pool's members and replays its strategy and schedule
+// to predict the next rotations. It reads the database but never writes to it or to DNS.
+// Health is taken as of now and assumed not to change during the simulated period.
+func SimulateRotations(db *database.DB, pool SimulationPool, req SimulationRequest) (*SimulationResult, error) {
+	count := req.Count
+	if count == 0 {
+		count = defaultSimulationCount
+	}
+	if count < 0 || count > maxSimulationCount {
+		return nil, fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidSimulation, maxSimulationCount)
+	}
+
+	scheduleOverridden, err := req.Overrides.apply(&pool)
+	if err != nil {
+		return nil, err
+	}
+	state := pool.State
+
+	seed := time.Now().UnixNano()
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+	result := &SimulationResult{
+		PoolType:  state.PoolType,
+		PoolID:    state.PoolID,
+		Strategy:  state.Strategy,
+		Mode:      pool.Schedule.Mode,
+		Seed:      seed,
+		Rotations: []SimulatedRotation{},
+		Warnings:  []string{},
+	}
+	warn := func(format string, args ...interface{}) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	var members []PoolMember
+	if req.Overrides.MachineIDs != nil {
+		members, err = overrideMembers(db, req.Overrides.MachineIDs, req.Overrides.MemberWeights, state.GroupIDs)
+	} else {
+		members, err = ResolvePoolMembers(db, state.PoolType, state.PoolID, state.GroupIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	trigger := "scheduled"
+	if pool.Schedule.Mode == "failover" {
+		// Failover pools only move when the current machine fails; show where it would go
+		trigger = "failover"
+		if state.CurrentMachineID == nil {
+			warn("pool has no current machine to fail over from")
+		}
+	}
+
+	health := MemberHealthMap(db, state.PoolType, state.PoolID, state.HealthCheckEnabled)
+	candidates, evals, err := EvaluateMembers(state, members, health, trigger)
+	result.Members = evals
+	if result.Members == nil {
+		result.Members = []MemberEvaluation{}
+	}
+	if err != nil {
+		warn("%v", err)
+		return result, nil
+	}
+	if state.HealthCheckEnabled {
+		healthy := 0
+		for _, ev := range evals {
+			if ev.Healthy {
+				healthy++
+			}
+		}
+		if healthy == 0 {
+			warn("no member is healthy, rotations fall back to all members")
+		}
+	}
+	if pool.IsPaused {
+		warn("pool is paused, scheduled rotations won't run until it is resumed")
+	}
+
+	names := make(map[uuid.UUID]string, len(members))
+	for _, m := range members {
+		names[m.MachineID] = m.MachineName
+	}
+	lastUsed := map[uuid.UUID]time.Time{}
+	if state.Strategy == "least_recently_used" {
+		lastUsed = lastRotatedTo(db, state.PoolType, state.PoolID)
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	now := time.Now().UTC()
+	var at *time.Time
+	if trigger == "failover" {
+		if state.CurrentMachineID == nil {
+			return result, nil
+		}
+		count = 1
+	} else {
+		at = pool.NextRotation
+		if at == nil || scheduleOverridden {
+			if at, err = pool.Schedule.NextRotation(pool.LastRotated, now); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSimulation, err)
+			}
+		}
+		if at == nil {
+			warn("pool has no upcoming rotation in its schedule")
+			return result, nil
+		}
+		if at.Before(now) {
+			overdue := now
+			at = &overdue // Missed rotations fire on the scheduler's next tick
+		}
+	}
+
+	for step := 1; step <= count; step++ {
+		selected, newIndex, err := chooseCandidates(state, candidates, trigger, lastUsed, rng)
+		if err != nil {
+			warn("%v", err)
+			break
+		}
+		rotation := SimulatedRotation{
+			Step:        step,
+			At:          at,
+			Trigger:     trigger,
+			MachineID:   selected[0].MachineID,
+			MachineName: names[selected[0].MachineID],
+			Answers:     IPv4Answers(candidateIPs(selected)),
+			Index:       newIndex,
+		}
+		if state.IPv6Enabled {
+			rotation.AnswersIPv6 = MachineIPv6Answers(db, candidateMachineIDs(selected))
+		}
+
+		// Mirrors the scheduler: re-selecting the current machine is a no-op
+		if len(selected) == 1 && state.CurrentMachineID != nil && *state.CurrentMachineID == selected[0].MachineID {
+			rotation.Unchanged = true
+			rotation.Index = state.CurrentIndex
+		} else {
+			current := selected[0].MachineID
+			state.CurrentMachineID = &current
+			state.CurrentIndex = newIndex
+			if at != nil {
+				lastUsed[current] = *at
+			} else {
+				lastUsed[current] = now
+			}
+		}
+		result.Rotations = append(result.Rotations, rotation)
+
+		if at == nil {
+			break
+		}
+		if at, err = pool.Schedule.NextRotation(at, *at); err != nil || at == nil {
+			break
+		}
+	}
+	return result, nil
+}
+
+// apply validates the overrides and applies them to the pool. Reports whether the
+// schedule changed, in which case the pool's persisted next rotation no longer applies.
+func (o SimulationOverrides) apply(pool *SimulationPool) (bool, error) {
+	if o.RotationStrategy != nil {
+		if !IsRotationStrategy(*o.RotationStrategy) {
+			return false, fmt.Errorf("%w: invalid rotation strategy", ErrInvalidSimulation)
+		}
+		pool.State.Strategy = *o.RotationStrategy
+	}
+	if o.AnswerCount != nil {
+		if *o.AnswerCount < 1 {
+			return false, fmt.Errorf("%w: answer count must be at least 1", ErrInvalidSimulation)
+		}
+		pool.State.AnswerCount = *o.AnswerCount
+	}
+	if o.HealthCheckEnabled != nil {
+		pool.State.HealthCheckEnabled = *o.HealthCheckEnabled
+	}
+	if o.IPv6Enabled != nil {
+		pool.State.IPv6Enabled = *o.IPv6Enabled
+	}
+	if o.GroupIDs != nil {
+		for _, id := range o.GroupIDs {
+			if _, err := uuid.Parse(id); err != nil {
+				return false, fmt.Errorf("%w: invalid group ID %q", ErrInvalidSimulation, id)
+			}
+		}
+		pool.State.GroupIDs = pq.StringArray(o.GroupIDs)
+	}
+
+	schedule := pool.Schedule
+	if o.RotationMode != nil {
+		schedule.Mode = *o.RotationMode
+	}
+	if o.IntervalMinutes != nil {
+		schedule.IntervalMinutes = *o.IntervalMinutes
+	}
+	if o.ScheduledTimes != nil {
+		schedule.ScheduledTimes = o.ScheduledTimes
+	}
+	if o.CronExpression != nil {
+		schedule.CronExpression = *o.CronExpression
+	}
+	if o.Timezone != nil {
+		schedule.Timezone = *o.Timezone
+	}
+	if schedule.Mode != "interval" && schedule.Mode != "scheduled" && schedule.Mode != "failover" {
+		return false, fmt.Errorf("%w: rotation mode must be interval, scheduled or failover", ErrInvalidSimulation)
+	}
+	if schedule.Mode == "interval" && schedule.IntervalMinutes < 1 {
+		return false, fmt.Errorf("%w: interval must be at least 1 minute", ErrInvalidSimulation)
+	}
+	if err := schedule.Validate(); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidSimulation, err)
+	}
+
+	overridden := o.RotationMode != nil || o.IntervalMinutes != nil || o.ScheduledTimes != nil ||
+		o.CronExpression != nil || o.Timezone != nil
+	pool.Schedule = schedule
+	return overridden, nil
+}
+
+// overrideMembers builds the direct members a pool update with these machines would
+// have, in the given order, plus the machines of its groups
+func overrideMembers(db *database.DB, machineIDs []string, weights map[string]int, groupIDs pq.StringArray) ([]PoolMember, error) {
+	for _, id := range machineIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("%w: invalid machine ID %q", ErrInvalidSimulation, id)
+		}
+	}
+
+	var machines []PoolMember
+	err := db.Select(&machines, `
+		SELECT m.id as machine_id, COALESCE(NULLIF(m.title, ''), m.hostname, '') as machine_name,
+			COALESCE(m.primary_ip, m.ip_address, '') as machine_ip, a.last_seen, 0 as priority, 1 as weight, 'direct' as source
+		FROM machines m
+		LEFT JOIN agents a ON m.agent_id = a.id
+		WHERE m.id = ANY($1::uuid[])
+	`, pq.StringArray(machineIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machines: %w", err)
+	}
+
+	byID := make(map[string]PoolMember, len(machines))
+	for _, m := range machines {
+		byID[m.MachineID.String()] = m
+	}
+	var members []PoolMember
+	seen := make(map[string]bool, len(machineIDs))
+	for i, raw := range machineIDs {
+		id := uuid.MustParse(raw).String()
+		m, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: machine %s not found", ErrInvalidSimulation, id)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		m.Priority = i
+		if w, ok := weights[raw]; ok && w > 0 {
+			m.Weight = w
+		}
+		members = append(members, m)
+	}
+	return appendGroupMembers(db, members, groupIDs)
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"configuratix/backend/internal/database"
+	"configuratix/backend/internal/dns"
+	"configuratix/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// RotationStrategies lists the supported pool rotation strategies
+var RotationStrategies = []string{"round_robin", "random", "weighted", "least_recently_used", "multi"}
+
+// IsRotationStrategy reports whether s is a supported rotation strategy
+func IsRotationStrategy(s string) bool {
+	for _, strategy := range RotationStrategies {
+		if strategy == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RotationCandidate is a (healthy) pool member that can receive traffic
+type RotationCandidate struct {
+	MachineID uuid.UUID
+	MachineIP string
+	Weight    int
+}
+
+// selectRotation picks the member(s) to publish next. The first returned candidate
+// becomes the pool's current machine; the multi strategy returns up to answerCount
+// members. newIndex is the position stored as the pool's current_index. It doesn't
+// touch the database, so simulations can replay it with their own history and rng.
+func selectRotation(strategy string, candidates []RotationCandidate, currentIndex, answerCount int, lastUsed map[uuid.UUID]time.Time, rng *rand.Rand) ([]RotationCandidate, int) {
+	n := len(candidates)
+	if n == 0 {
+		return nil, 0
+	}
+
+	switch strategy {
+	case "random":
+		idx := rng.Intn(n)
+		return candidates[idx : idx+1], idx
+
+	case "weighted":
+		total := 0
+		for _, c := range candidates {
+			total += candidateWeight(c)
+		}
+		pick := rng.Intn(total)
+		for i, c := range candidates {
+			pick -= candidateWeight(c)
+			if pick < 0 {
+				return candidates[i : i+1], i
+			}
+		}
+		return candidates[n-1:], n - 1
+
+	case "least_recently_used":
+		best := 0
+		for i, c := range candidates {
+			t, used := lastUsed[c.MachineID]
+			bestT, bestUsed := lastUsed[candidates[best].MachineID]
+			if (!used && bestUsed) || (used && bestUsed && t.Before(bestT)) {
+				best = i
+			}
+		}
+		return candidates[best : best+1], best
+
+	case "multi":
+		if answerCount < 1 {
+			answerCount = 1
+		}
+		if answerCount > n {
+			answerCount = n
+		}
+		// Slide a window over the members so every machine takes turns
+		start := (currentIndex + 1) % n
+		selected := make([]RotationCandidate, 0, answerCount)
+		for i := 0; i < answerCount; i++ {
+			selected = append(selected, candidates[(start+i)%n])
+		}
+		return selected, start
+
+	default: // round_robin
+		idx := (currentIndex + 1) % n
+		return candidates[idx : idx+1], idx
+	}
+}
+
+func candidateWeight(c RotationCandidate) int {
+	if c.Weight < 1 {
+		return 1
+	}
+	return c.Weight
+}
+
+// lastRotatedTo returns when each machine last became the pool's current machine
+func lastRotatedTo(db *database.DB, poolType string, poolID uuid.UUID) map[uuid.UUID]time.Time {
+	var rows []struct {
+		MachineID uuid.UUID `db:"to_machine_id"`
+		RotatedAt time.Time `db:"rotated_at"`
+	}
+	db.Select(&rows, `
+		SELECT to_machine_id, MAX(rotated_at) as rotated_at
+		FROM dns_rotation_history
+		WHERE pool_type = $1 AND pool_id = $2 AND to_machine_id IS NOT NULL
+		GROUP BY to_machine_id
+	`, poolType, poolID)
+
+	lastUsed := make(map[uuid.UUID]time.Time, len(rows))
+	for _, r := range rows {
+		lastUsed[r.MachineID] = r.RotatedAt
+	}
+	return lastUsed
+}
+
+// PublishRecordAnswers points a pool record at one or more IPs. The first IP is kept
+// on the pool's dynamic record, the rest are stored as dynamic_extra records, and the
+// whole RRset is pushed to the provider in one changeset.
+func PublishRecordAnswers(db *database.DB, recordID uuid.UUID, ips []string) error {
+	ips = uniqueAnswers(ips)
+	if len(ips) == 0 {
+		return fmt.Errorf("no answers to publish")
+	}
+
+	var record models.DNSRecord
+	if err := db.Get(&record, "SELECT * FROM dns_records WHERE id = $1", recordID); err != nil {
+		return fmt.Errorf("failed to get record: %w", err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Extras go first: the new primary IP may be one of the current extras, and the
+	// value is part of the record's unique key
+	_, err = tx.Exec(`
+		DELETE FROM dns_records
+		WHERE dns_domain_id = $1 AND name = $2 AND record_type = $3 AND mode = 'dynamic_extra'
+	`, record.DNSDomainID, record.Name, record.RecordType)
+	if err != nil {
+		return fmt.Errorf("failed to remove extra answers: %w", err)
+	}
+	_, err = tx.Exec("UPDATE dns_records SET value = $1, sync_status = 'pending', updated_at = NOW() WHERE id = $2", ips[0], recordID)
+	if err != nil {
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+	for _, ip := range ips[1:] {
+		_, err = tx.Exec(`
+			INSERT INTO dns_records (dns_domain_id, name, record_type, value, ttl, proxied, mode, sync_status)
+			VALUES ($1, $2, $3, $4, $5, $6, 'dynamic_extra', 'pending')
+		`, record.DNSDomainID, record.Name, record.RecordType, ip, record.TTL, record.Proxied)
+		if err != nil {
+			return fmt.Errorf("failed to save extra answer %s: %w", ip, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return syncDynamicRRset(db, record.DNSDomainID, record.Name, record.RecordType, ips)
+}
+
+// PublishNameAnswers points the dynamic record of a name/type at one or more IPs,
+// creating it when missing. With no IPs the dynamic records are withdrawn, e.g. the
+// AAAA record of a pool whose current machines have no IPv6.
+func PublishNameAnswers(db *database.DB, domainID uuid.UUID, name, recordType string, ips []string) error {
+	ips = uniqueAnswers(ips)
+	if len(ips) > 0 {
+		// An extra answer holding the new primary value would collide with the upsert
+		_, err := db.Exec(`
+			DELETE FROM dns_records
+			WHERE dns_domain_id = $1 AND name = $2 AND record_type = $3 AND mode = 'dynamic_extra' AND value = $4
+		`, domainID, name, recordType, ips[0])
+		if err != nil {
+			return fmt.Errorf("failed to remove %s %s extra answer: %w", name, recordType, err)
+		}
+
+		var recordID uuid.UUID
+		err = db.Get(&recordID, `
+			INSERT INTO dns_records (dns_domain_id, name, record_type, value, mode, sync_status)
+			VALUES ($1, $2, $3, $4, 'dynamic', 'pending')
+			ON CONFLICT (dns_domain_id, name, record_type) WHERE mode = 'dynamic' DO UPDATE SET
+				value = $4, sync_status = 'pending', updated_at = NOW()
+			RETURNING id
+		`, domainID, name, recordType, ips[0])
+		if err != nil {
+			return fmt.Errorf("failed to save %s %s record: %w", name, recordType, err)
+		}
+		return PublishRecordAnswers(db, recordID, ips)
+	}
+
+	result, err := db.Exec(`
+		DELETE FROM dns_records
+		WHERE dns_domain_id = $1 AND name = $2 AND record_type = $3 AND mode IN ('dynamic', 'dynamic_extra')
+	`, domainID, name, recordType)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s %s records: %w", name, recordType, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil // Nothing published, nothing to withdraw
+	}
+	return syncDynamicRRset(db, domainID, name, recordType, nil)
+}
+
+// uniqueAnswers drops empty and repeated IPs, keeping the order
+func uniqueAnswers(ips []string) []string {
+	seen := make(map[string]bool, len(ips))
+	unique := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if ip == "" || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		unique = append(unique, ip)
+	}
+	return unique
+}
+
+// syncDynamicRRset pushes the local dynamic records of one name/type to the provider
+func syncDynamicRRset(db *database.DB, domainID uuid.UUID, name, recordType string, ips []string) error {
+	var domain models.DNSManagedDomain
+	if err := db.Get(&domain, "SELECT * FROM dns_managed_domains WHERE id = $1", domainID); err != nil {
+		return fmt.Errorf("failed to get domain: %w", err)
+	}
+
+	var rrset []models.DNSRecord
+	db.Select(&rrset, `
+		SELECT * FROM dns_records
+		WHERE dns_domain_id = $1 AND name = $2 AND record_type = $3 AND mode IN ('dynamic', 'dynamic_extra')
+	`, domainID, name, recordType)
+
+	if domain.DNSAccountID == nil {
+		db.Exec(`
+			UPDATE dns_records SET sync_status = 'synced'
+			WHERE dns_domain_id = $1 AND name = $2 AND record_type = $3 AND mode IN ('dynamic', 'dynamic_extra')
+		`, domainID, name, recordType)
+		return nil
+	}
+
+	var account models.DNSAccount
+	if err := db.Get(&account, "SELECT * FROM dns_accounts WHERE id = $1", *domain.DNSAccountID); err != nil {
+		return fmt.Errorf("failed to get DNS account: %w", err)
+	}
+	provider, err := ProviderForAccount(account)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	remoteRecords, err := provider.ListRecords(ctx, domain.FQDN)
+	if err != nil {
+		return fmt.Errorf("failed to list remote records: %w", err)
+	}
+
+	// Only touch this name/type - the rest of the zone is not ours to sync here
+	var remoteSet []dns.Record
+	for _, r := range remoteRecords {
+		if r.Type == recordType && sameRecordName(r.Name, name, domain.FQDN) {
+			r.Name = name
+			remoteSet = append(remoteSet, r)
+		}
+	}
+	localSet := make([]dns.Record, len(rrset))
+	for i, r := range rrset {
+		localSet[i] = DNSRecordFromModel(r)
+	}
+
+	result, err := dns.NewSyncService().ApplyToRemote(ctx, provider, domain.FQDN, localSet, remoteSet)
+	if err != nil {
+		return err
+	}
+	UpdateRecordSyncStatus(db, rrset, result)
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%s", result.Errors[0])
+	}
+
+	if len(ips) == 0 {
+		log.Printf("Passthrough: withdrew %s %s.%s", recordType, name, domain.FQDN)
+		return nil
+	}
+	log.Printf("Passthrough: published %s %s.%s = %s", recordType, name, domain.FQDN, strings.Join(ips, ", "))
+	return nil
+}
+
+// PublishWildcardAnswers points the wildcard (and optionally root) A records of a
+// domain at one or more IPs, creating the dynamic records when missing
+func PublishWildcardAnswers(db *database.DB, domainID uuid.UUID, ips []string, includeRoot bool) error {
+	if len(ips) == 0 {
+		return fmt.Errorf("no answers to publish")
+	}
+
+	names := []string{"*"}
+	if includeRoot {
+		names = append(names, "@")
+	}
+	for _, name := range names {
+		if err := PublishNameAnswers(db, domainID, name, "A", ips); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameRecordName compares a provider record name (relative or fully qualified) with a local name
+func sameRecordName(remote, local, zone string) bool {
+	remote = strings.TrimSuffix(remote, ".")
+	if local == "@" {
+		return remote == "@" || remote == "" || strings.EqualFold(remote, zone)
+	}
+	return strings.EqualFold(remote, local) || strings.EqualFold(remote, local+"."+zone)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"configuratix/backend/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// defaultWarmupTimeout bounds how long a rotation waits for its targets' config jobs
+const defaultWarmupTimeout = 5 * time.Minute
+
+const warmupPollInterval = 3 * time.Second
+
+// PassthroughConfigApplier pushes a machine's passthrough nginx config. It is implemented
+// by the nginx generator, which lives with the handlers.
+type PassthroughConfigApplier interface {
+	// EnsurePassthroughConfig returns the job applying the machine's current config,
+	// or uuid.Nil if the machine already runs it
+	EnsurePassthroughConfig(machineID uuid.UUID) (uuid.UUID, error)
+}
+
+// WarmupTarget is a member about to be published
+type WarmupTarget struct {
+	MachineID uuid.UUID
+	MachineIP string
+}
+
+// WarmupOptions configures the warmup of a pool's rotation
+type WarmupOptions struct {
+	PoolType string // record, wildcard
+	PoolID   uuid.UUID
+	Probe    bool // Probe the targets once their config is applied
+	Timeout  time.Duration
+}
+
+// PoolWarmupOptions returns the warmup options of a pool
+func PoolWarmupOptions(poolType string, poolID uuid.UUID, probe bool, timeoutSeconds int) WarmupOptions {
+	return WarmupOptions{
+		PoolType: poolType,
+		PoolID:   poolID,
+		Probe:    probe,
+		Timeout:  time.Duration(timeoutSeconds) * time.Second,
+	}
+}
+
+// WarmupTargets pushes the passthrough config to every target and waits until the agents
+// report the jobs completed (the job runs nginx -t and checks nginx is up), then probes
+// the targets if asked to. Any failure means the targets must not be published.
+func WarmupTargets(db *database.DB, applier PassthroughConfigApplier, targets []WarmupTarget, opts WarmupOptions) error {
+	if applier == nil {
+		return fmt.Errorf("no nginx config applier configured")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	var probe *probeOptions
+	if opts.Probe {
+		o, err := poolProbeOptions(db, opts.PoolType, opts.PoolID)
+		if err != nil {
+			return err
+		}
+		probe = &o
+	}
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target WarmupTarget) {
+			defer wg.Done()
+			errs[i] = warmupTarget(db, applier, target, probe, deadline)
+		}(i, target)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("warmup of machine %s (%s) failed: %w", targets[i].MachineID, targets[i].MachineIP, err)
+		}
+	}
+	return nil
+}
+
+func warmupTarget(db *database.DB, applier PassthroughConfigApplier, target WarmupTarget, probe *probeOptions, deadline time.Time) error {
+	jobID, err := applier.EnsurePassthroughConfig(target.MachineID)
+	if err != nil {
+		return fmt.Errorf("failed to push nginx config: %w", err)
+	}
+	if jobID != uuid.Nil {
+		if err := waitForJob(db, jobID, deadline); err != nil {
+			return err
+		}
+	}
+
+	if probe != nil {
+		prober := &PassthroughProber{db: db, timeout: 5 * time.Second}
+		if result := prober.probe(target.MachineIP, *probe); !result.ok() {
+			return fmt.Errorf("probe failed: %v", result.Err)
+		}
+	}
+	return nil
+}
+
+// waitForJob polls a job until the agent reports it finished or the deadline passes
+func waitForJob(db *database.DB, jobID uuid.UUID, deadline time.Time) error {
+	for {
+		var job struct {
+			Status string  `db:"status"`
+			Logs   *string `db:"logs"`
+		}
+		if err := db.Get(&job, "SELECT status, logs FROM jobs WHERE id = $1", jobID); err != nil {
+			return fmt.Errorf("nginx config job %s not found", jobID)
+		}
+
+		switch job.Status {
+		case "completed":
+			return nil
+		case "failed":
+			return fmt.Errorf("nginx config job failed: %s", lastLogLine(job.Logs))
+		case "cancelled":
+			return fmt.Errorf("nginx config job was cancelled")
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for nginx config job %s (still %s)", jobID, job.Status)
+		}
+		time.Sleep(warmupPollInterval)
+	}
+}
+
+// lastLogLine returns the last non-empty line of job logs, usually the error
+func lastLogLine(logs *string) string {
+	if logs == nil {
+		return "no logs"
+	}
+	lines := strings.Split(strings.TrimSpace(*logs), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return "no logs"
+}
+
+// poolProbeOptions returns the SNI/Host and HTTP path the prober uses for a pool
+func poolProbeOptions(db *database.DB, poolType string, poolID uuid.UUID) (probeOptions, error) {
+	var pool struct {
+		Path       string `db:"health_check_path"`
+		Host       string `db:"health_check_host"`
+		RecordName string `db:"record_name"`
+		DomainFQDN string `db:"domain_fqdn"`
+	}
+	var err error
+	if poolType == "wildcard" {
+		err = db.Get(&pool, `
+			SELECT COALESCE(wp.health_check_path, '') as health_check_path,
+			       COALESCE(wp.health_check_host, '') as health_check_host, '*' as record_name, d.fqdn as domain_fqdn
+			FROM dns_wildcard_pools wp
+			JOIN dns_managed_domains d ON wp.dns_domain_id = d.id
+			WHERE wp.id = $1
+		`, poolID)
+	} else {
+		err = db.Get(&pool, `
+			SELECT COALESCE(pp.health_check_path, '') as health_check_path,
+			       COALESCE(pp.health_check_host, '') as health_check_host, r.name as record_name, d.fqdn as domain_fqdn
+			FROM dns_passthrough_pools pp
+			JOIN dns_records r ON pp.dns_record_id = r.id
+			JOIN dns_managed_domains d ON r.dns_domain_id = d.id
+			WHERE pp.id = $1
+		`, poolID)
+	}
+	if err != nil {
+		return probeOptions{}, fmt.Errorf("failed to get pool for probe: %w", err)
+	}
+
+	host := pool.Host
+	if host == "" {
+		switch pool.RecordName {
+		case "*":
+			// Any subdomain matches the wildcard SNI map on the members
+			host = "www." + pool.DomainFQDN
+		case "@":
+			host = pool.DomainFQDN
+		default:
+			host = pool.RecordName + "." + pool.DomainFQDN
+		}
+	}
+	return probeOptions{Host: host, HTTPPath: pool.Path}, nil
+}
+
+// PassthroughConfigJobFinished records the outcome of a job on the machine's passthrough
+// config. Jobs that aren't the machine's latest passthrough config job are ignored.
+func PassthroughConfigJobFinished(db *database.DB, jobID uuid.UUID, completed bool) {
+	status := "failed"
+	if completed {
+		status = "applied"
+	}
+
+	var machineID uuid.UUID
+	err := db.Get(&machineID, `
+		UPDATE machine_passthrough_configs SET status = $1, updated_at = NOW()
+		WHERE job_id = $2
+		RETURNING machine_id
+	`, status, jobID)
+	if err != nil {
+		return
+	}
+
+	db.Exec("UPDATE dns_passthrough_members SET nginx_config_applied = $1 WHERE machine_id = $2", completed, machineID)
+	db.Exec("UPDATE dns_wildcard_pool_members SET nginx_config_applied = $1 WHERE machine_id = $2", completed, machineID)
+}
@@ -4,18 +4,48 @@ import (
 	"encoding/json"
 )
 
-// Step represents a single operation
+// Step represents a single operation. Conditions and template content are Go
+// templates evaluated by the agent against .vars, .facts (host facts such as os,
+// distro, distro_version, arch), .steps.<id> and .prev (exit_code, stdout, stderr,
+// failed, skipped, changed).
+//
+// Besides exec, the declarative resources package, line_in_file, user, systemd_unit
+// and sysctl describe the desired state and only change the host when it differs.
+// In check mode (a "check" job) the agent reports what it would change instead.
 type Step struct {
-	Action  string `json:"action"`            // exec, file, service, fetch
-	Command string `json:"command,omitempty"` // for exec
-	Timeout int    `json:"timeout,omitempty"` // seconds
-	Path    string `json:"path,omitempty"`    // for file/fetch
-	Content string `json:"content,omitempty"` // for file
-	URL     string `json:"url,omitempty"`     // for fetch
-	Mode    string `json:"mode,omitempty"`    // file permissions
-	Op      string `json:"op,omitempty"`      // write, append, delete, backup / service action
-	Name    string `json:"name,omitempty"`    // service name
-	Log     string `json:"log,omitempty"`     // "out" = log command output (default), or custom command to execute and log
+	ID       string `json:"id,omitempty"`        // Optional, referenced by outputs and conditions
+	Action   string `json:"action"`              // exec, file, service, fetch, package, line_in_file, user, systemd_unit, sysctl
+	Command  string `json:"command,omitempty"`   // for exec
+	Timeout  int    `json:"timeout,omitempty"`   // seconds
+	Path     string `json:"path,omitempty"`      // for file/fetch
+	Content  string `json:"content,omitempty"`   // for file
+	URL      string `json:"url,omitempty"`       // for fetch
+	Mode     string `json:"mode,omitempty"`      // file permissions
+	Op       string `json:"op,omitempty"`        // write, append, delete, backup, template, directory / service action
+	Name     string `json:"name,omitempty"`      // service, package(s), user, unit or sysctl key
+	Log      string `json:"log,omitempty"`       // "out" = log command output (default), or custom command to execute and log
+	When     string `json:"when,omitempty"`      // Run only if the condition is true, e.g. `ne .steps.check.exit_code 0`
+	OnError  string `json:"on_error,omitempty"`  // Overrides the template on_error for this step
+	Register string `json:"register,omitempty"`  // Var that receives the trimmed stdout for later steps
+	Retries  int    `json:"retries,omitempty"`   // Extra attempts after a failure
+	Delay    int    `json:"delay,omitempty"`     // Seconds between attempts (default 5)
+	Loop      []string `json:"loop,omitempty"`       // Runs the step once per item, {{item}} in fields and .item in conditions
+	WithItems string   `json:"with_items,omitempty"` // Go template expression giving more items, one per line
+	LoopVar   string   `json:"loop_var,omitempty"`   // Name of the item (default item)
+	ReadOnly bool   `json:"read_only,omitempty"` // exec doesn't change the host, so it also runs in check mode
+
+	State       string `json:"state,omitempty"`        // present, absent / unit: started, stopped, restarted, reloaded
+	Version     string `json:"version,omitempty"`      // package version to pin
+	UpdateCache bool   `json:"update_cache,omitempty"` // package: apt-get update first
+	Owner       string `json:"owner,omitempty"`        // file, directory, line_in_file
+	Group       string `json:"group,omitempty"`
+	Line        string `json:"line,omitempty"`    // line_in_file
+	Regexp      string `json:"regexp,omitempty"`  // line_in_file: lines to replace or remove
+	Value       string `json:"value,omitempty"`   // sysctl
+	Shell       string `json:"shell,omitempty"`   // user
+	Home        string `json:"home,omitempty"`    // user
+	System      bool   `json:"system,omitempty"`  // user: system account
+	Enabled     *bool  `json:"enabled,omitempty"` // unit: enable or disable at boot
 }
 
 // CommandTemplate defines a reusable command
@@ -27,6 +57,17 @@ type CommandTemplate struct {
 	Variables   []VariableDef `json:"variables"`
 	Steps       []Step        `json:"steps"`
 	OnError     string        `json:"on_error"` // stop, continue, rollback
+	Outputs     []OutputDef   `json:"outputs,omitempty"`
+}
+
+// OutputDef declares a named job output. The agent resolves it from the result of
+// the step with the given ID and reports it in the job result.
+type OutputDef struct {
+	Name        string `json:"name"`
+	Step        string `json:"step"`                  // Step ID
+	From        string `json:"from,omitempty"`        // stdout (default), stderr, exit_code, or a key the step wrote to $CONFIGURATIX_OUTPUT
+	Trim        bool   `json:"trim,omitempty"`        // Trim surrounding whitespace
+	Description string `json:"description,omitempty"`
 }
 
 // VariableDef describes a template variable
@@ -43,6 +84,7 @@ type RunPayload struct {
 	Steps   []Step            `json:"steps"`
 	Vars    map[string]string `json:"vars,omitempty"`
 	OnError string            `json:"on_error,omitempty"`
+	Outputs []OutputDef       `json:"outputs,omitempty"`
 }
 
 // ToPayload converts template + variables to a run job payload
@@ -51,11 +93,15 @@ func (t *CommandTemplate) ToPayload(vars map[string]string) json.RawMessage {
 		Steps:   t.Steps,
 		Vars:    vars,
 		OnError: t.OnError,
+		Outputs: t.Outputs,
 	}
 	data, _ := json.Marshal(payload)
 	return data
 }
 
+// enabled is shared by the systemd_unit steps that enable a unit at boot
+var enabled = true
+
 // Built-in command templates
 var Commands = map[string]*CommandTemplate{
 	"change_ssh_port": {
@@ -68,12 +114,13 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "rollback",
 		Steps: []Step{
-			{Action: "file", Op: "backup", Path: "/etc/ssh/sshd_config"},
-			{Action: "exec", Command: `sed -i 's/^#\?Port.*/Port {{port}}/' /etc/ssh/sshd_config || echo "Port {{port}}" >> /etc/ssh/sshd_config`, Timeout: 30, Log: "grep '^Port' /etc/ssh/sshd_config"},
-			{Action: "exec", Command: "ufw allow {{port}}/tcp", Timeout: 30, Log: "ufw status | grep {{port}}"},
-			{Action: "exec", Command: "ufw delete allow 22/tcp 2>/dev/null || true", Timeout: 30},
-			{Action: "exec", Command: "systemctl daemon-reload", Timeout: 30},
-			{Action: "exec", Command: "systemctl restart sshd 2>/dev/null || systemctl restart ssh", Timeout: 60, Log: "systemctl is-active sshd || systemctl is-active ssh"},
+			{ID: "config", Action: "line_in_file", Path: "/etc/ssh/sshd_config", Regexp: `^\s*#?\s*Port\s`, Line: "Port {{port}}"},
+			{Action: "exec", Command: "sshd -t", Timeout: 30, ReadOnly: true},
+			{Action: "exec", Command: "ufw show added", Timeout: 30, ReadOnly: true, Register: "ufw_rules"},
+			{Action: "exec", Command: "ufw allow {{port}}/tcp", Timeout: 30, Log: "ufw status | grep {{port}}", When: `not (hasLine .vars.ufw_rules (printf "ufw allow %s/tcp" .vars.port))`},
+			{Action: "exec", Command: "ufw delete allow 22/tcp", Timeout: 30, When: `and (ne .vars.port "22") (hasLine .vars.ufw_rules "ufw allow 22/tcp")`},
+			{Action: "exec", Command: "systemctl daemon-reload", Timeout: 30, When: ".steps.config.changed"},
+			{Action: "exec", Command: "systemctl restart sshd 2>/dev/null || systemctl restart ssh", Timeout: 60, Log: "systemctl is-active sshd || systemctl is-active ssh", When: ".steps.config.changed"},
 		},
 	},
 
@@ -101,7 +148,9 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `if [ "{{enabled}}" = "true" ]; then ufw --force enable; else ufw disable; fi`, Timeout: 30, Log: "ufw status"},
+			{Action: "exec", Command: "ufw status", Timeout: 30, ReadOnly: true, Register: "ufw_status"},
+			{Action: "exec", Command: "ufw --force enable", Timeout: 30, Log: "ufw status", When: `and (eq .vars.enabled "true") (not (hasLine .vars.ufw_status "Status: active"))`},
+			{Action: "exec", Command: "ufw disable", Timeout: 30, Log: "ufw status", When: `and (ne .vars.enabled "true") (hasLine .vars.ufw_status "Status: active")`},
 		},
 	},
 
@@ -116,7 +165,9 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `if [ "{{protocol}}" = "both" ]; then ufw allow {{port}}/tcp && ufw allow {{port}}/udp; else ufw allow {{port}}/{{protocol}}; fi`, Timeout: 30, Log: "ufw status | grep {{port}}"},
+			{Action: "exec", Command: "ufw show added", Timeout: 30, ReadOnly: true, Register: "ufw_rules"},
+			{Action: "exec", Command: "ufw allow {{port}}/tcp", Timeout: 30, Log: "ufw status | grep {{port}}", When: `and (ne .vars.protocol "udp") (not (hasLine .vars.ufw_rules (printf "ufw allow %s/tcp" .vars.port)))`},
+			{Action: "exec", Command: "ufw allow {{port}}/udp", Timeout: 30, Log: "ufw status | grep {{port}}", When: `and (ne .vars.protocol "tcp") (not (hasLine .vars.ufw_rules (printf "ufw allow %s/udp" .vars.port)))`},
 		},
 	},
 
@@ -131,7 +182,9 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "continue",
 		Steps: []Step{
-			{Action: "exec", Command: `if [ "{{protocol}}" = "both" ]; then ufw delete allow {{port}}/tcp; ufw delete allow {{port}}/udp; else ufw delete allow {{port}}/{{protocol}}; fi`, Timeout: 30, Log: "ufw status"},
+			{Action: "exec", Command: "ufw show added", Timeout: 30, ReadOnly: true, Register: "ufw_rules"},
+			{Action: "exec", Command: "ufw delete allow {{port}}/tcp", Timeout: 30, Log: "ufw status", When: `and (ne .vars.protocol "udp") (hasLine .vars.ufw_rules (printf "ufw allow %s/tcp" .vars.port))`},
+			{Action: "exec", Command: "ufw delete allow {{port}}/udp", Timeout: 30, Log: "ufw status", When: `and (ne .vars.protocol "tcp") (hasLine .vars.ufw_rules (printf "ufw allow %s/udp" .vars.port))`},
 		},
 	},
 
@@ -146,8 +199,9 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `if [ -n "{{config}}" ]; then echo '{{config}}' > /etc/fail2ban/jail.local; fi`, Timeout: 30},
-			{Action: "exec", Command: `if [ "{{enabled}}" = "true" ]; then systemctl enable fail2ban && systemctl restart fail2ban; else systemctl stop fail2ban && systemctl disable fail2ban; fi`, Timeout: 60},
+			{Action: "file", Op: "write", Path: "/etc/fail2ban/jail.local", Content: "{{config}}", Mode: "0644", When: `ne .vars.config ""`},
+			{Action: "exec", Command: "systemctl enable fail2ban && systemctl restart fail2ban", Timeout: 60, When: `eq .vars.enabled "true"`},
+			{Action: "exec", Command: "systemctl stop fail2ban && systemctl disable fail2ban", Timeout: 60, When: `ne .vars.enabled "true"`},
 		},
 	},
 
@@ -195,6 +249,8 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "stop",
 		Steps: []Step{
+			{ID: "check", Action: "exec", Command: "command -v certbot", Timeout: 10, OnError: "continue"},
+			{Action: "exec", Command: "apt-get install -y certbot python3-certbot-nginx", Timeout: 300, Retries: 2, Delay: 15, When: `.steps.check.failed`},
 			{Action: "exec", Command: `certbot --nginx -d {{domain}} --non-interactive --agree-tos --email "{{email}}" --redirect || certbot --nginx -d {{domain}} --non-interactive --agree-tos --register-unsafely-without-email --redirect`, Timeout: 300},
 		},
 	},
@@ -207,19 +263,19 @@ var Commands = map[string]*CommandTemplate{
 		Variables:   []VariableDef{},
 		OnError:     "stop",
 		Steps: []Step{
-			{Action: "exec", Command: "apt-get update", Timeout: 120},
-			{Action: "exec", Command: "apt-get install -y nginx certbot python3-certbot-nginx fail2ban ufw", Timeout: 300},
-			{Action: "exec", Command: "mkdir -p /etc/nginx/conf.d/configuratix", Timeout: 10},
-			{Action: "service", Name: "nginx", Op: "enable"},
-			{Action: "service", Name: "nginx", Op: "start"},
-			{Action: "service", Name: "fail2ban", Op: "enable"},
-			{Action: "service", Name: "fail2ban", Op: "start"},
-			{Action: "exec", Command: "ufw default deny incoming", Timeout: 10},
-			{Action: "exec", Command: "ufw default allow outgoing", Timeout: 10},
-			{Action: "exec", Command: "ufw allow 22/tcp", Timeout: 10},
-			{Action: "exec", Command: "ufw allow 80/tcp", Timeout: 10},
-			{Action: "exec", Command: "ufw allow 443/tcp", Timeout: 10},
-			{Action: "exec", Command: "echo 'y' | ufw enable", Timeout: 10},
+			{Action: "package", Name: "nginx certbot python3-certbot-nginx fail2ban ufw", UpdateCache: true, Timeout: 600, Retries: 2, Delay: 15},
+			{Action: "file", Op: "directory", Path: "/etc/nginx/conf.d/configuratix", Mode: "0755"},
+			{Action: "systemd_unit", Name: "nginx", State: "started", Enabled: &enabled},
+			{Action: "systemd_unit", Name: "fail2ban", State: "started", Enabled: &enabled},
+			{Action: "exec", Command: "cat /etc/default/ufw 2>/dev/null || true", Timeout: 10, ReadOnly: true, Register: "ufw_defaults"},
+			{Action: "exec", Command: "ufw default deny incoming", Timeout: 10, When: `not (hasLine .vars.ufw_defaults "DEFAULT_INPUT_POLICY=\"DROP\"")`},
+			{Action: "exec", Command: "ufw default allow outgoing", Timeout: 10, When: `not (hasLine .vars.ufw_defaults "DEFAULT_OUTPUT_POLICY=\"ACCEPT\"")`},
+			{Action: "exec", Command: "ufw show added 2>/dev/null || true", Timeout: 10, ReadOnly: true, Register: "ufw_rules"},
+			{Action: "exec", Command: "ufw allow 22/tcp", Timeout: 10, When: `not (hasLine .vars.ufw_rules "ufw allow 22/tcp")`},
+			{Action: "exec", Command: "ufw allow 80/tcp", Timeout: 10, When: `not (hasLine .vars.ufw_rules "ufw allow 80/tcp")`},
+			{Action: "exec", Command: "ufw allow 443/tcp", Timeout: 10, When: `not (hasLine .vars.ufw_rules "ufw allow 443/tcp")`},
+			{Action: "exec", Command: "ufw status 2>/dev/null || true", Timeout: 10, ReadOnly: true, Register: "ufw_status"},
+			{Action: "exec", Command: "ufw --force enable", Timeout: 10, When: `not (hasLine .vars.ufw_status "Status: active")`},
 		},
 	},
 
@@ -279,7 +335,10 @@ var Commands = map[string]*CommandTemplate{
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: "cat {{path}}", Timeout: 30},
+			{ID: "read", Action: "exec", Command: "cat {{path}}", Timeout: 30},
+		},
+		Outputs: []OutputDef{
+			{Name: "content", Step: "read", Description: "File content"},
 		},
 	},
 
@@ -444,7 +503,10 @@ var Commands = map[string]*CommandTemplate{
 		Variables:   []VariableDef{},
 		OnError:     "continue",
 		Steps: []Step{
-			{Action: "exec", Command: "ls /etc/php/ 2>/dev/null | grep -E '^[0-9]+\\.[0-9]+$' || echo ''", Timeout: 10},
+			{ID: "list", Action: "exec", Command: "ls /etc/php/ 2>/dev/null | grep -E '^[0-9]+\\.[0-9]+$' || echo ''", Timeout: 10},
+		},
+		Outputs: []OutputDef{
+			{Name: "versions", Step: "list", Trim: true, Description: "Installed versions, one per line"},
 		},
 	},
 
@@ -994,7 +1056,7 @@ sed -i 's/^pm.max_spare_servers.*/pm.max_spare_servers = {{max_spare_servers}}/'
 		Variables:   []VariableDef{},
 		OnError:     "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `
+			{ID: "test", Action: "exec", Command: `
 # Check if speedtest is installed, if not install it
 if ! command -v speedtest &> /dev/null && ! command -v speedtest-cli &> /dev/null; then
     echo "Installing speedtest-cli..."
@@ -1002,17 +1064,31 @@ if ! command -v speedtest &> /dev/null && ! command -v speedtest-cli &> /dev/nul
     apt-get install -y speedtest-cli >/dev/null 2>&1 || pip3 install speedtest-cli 2>/dev/null
 fi
 
+set -o pipefail
+OUT_FILE=$(mktemp)
+trap 'rm -f "$OUT_FILE"' EXIT
+
 # Try speedtest (ookla) first, then speedtest-cli
 if command -v speedtest &> /dev/null; then
-    speedtest --accept-license --accept-gdpr 2>/dev/null || speedtest
+    (speedtest --accept-license --accept-gdpr 2>/dev/null || speedtest) | tee "$OUT_FILE"
 elif command -v speedtest-cli &> /dev/null; then
-    speedtest-cli --simple
+    speedtest-cli --simple | tee "$OUT_FILE"
 else
     echo "ERROR: speedtest not available"
     exit 1
 fi
+STATUS=$?
+
+# Both tools print "Download: <Mbit/s> ..." and "Upload: <Mbit/s> ..."
+echo "download_mbps=$(awk '/Download:/ {print $2; exit}' "$OUT_FILE")" >> "${CONFIGURATIX_OUTPUT:-/dev/null}"
+echo "upload_mbps=$(awk '/Upload:/ {print $2; exit}' "$OUT_FILE")" >> "${CONFIGURATIX_OUTPUT:-/dev/null}"
+exit $STATUS
 `, Timeout: 120},
 		},
+		Outputs: []OutputDef{
+			{Name: "download_mbps", Step: "test", From: "download_mbps", Description: "Download speed in Mbit/s"},
+			{Name: "upload_mbps", Step: "test", From: "upload_mbps", Description: "Upload speed in Mbit/s"},
+		},
 	},
 
 	"speedtest_download": {
@@ -1026,7 +1102,7 @@ fi
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `
+			{ID: "test", Action: "exec", Command: `
 URL="{{url}}"
 echo "Testing download speed from: $URL"
 echo "=========================================="
@@ -1045,9 +1121,13 @@ if [ -n "$SPEED_BPS" ]; then
     echo ""
     echo "=========================================="
     echo "Download Speed: $SPEED_MBPS Mbps"
+    echo "download_mbps=$SPEED_MBPS" >> "${CONFIGURATIX_OUTPUT:-/dev/null}"
 fi
 `, Timeout: 180},
 		},
+		Outputs: []OutputDef{
+			{Name: "download_mbps", Step: "test", From: "download_mbps", Description: "Download speed in Mbit/s"},
+		},
 	},
 
 	"speedtest_upload": {
@@ -1061,7 +1141,7 @@ fi
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `
+			{ID: "test", Action: "exec", Command: `
 URL="{{url}}"
 SIZE_MB="{{size_mb}}"
 echo "Testing upload speed to: $URL"
@@ -1096,9 +1176,13 @@ if [ -n "$SPEED_BPS" ]; then
     echo ""
     echo "=========================================="
     echo "Upload Speed: $SPEED_MBPS Mbps"
+    echo "upload_mbps=$SPEED_MBPS" >> "${CONFIGURATIX_OUTPUT:-/dev/null}"
 fi
 `, Timeout: 300},
 		},
+		Outputs: []OutputDef{
+			{Name: "upload_mbps", Step: "test", From: "upload_mbps", Description: "Upload speed in Mbit/s"},
+		},
 	},
 
 	"speedtest_machine_download": {
@@ -1113,7 +1197,7 @@ fi
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `
+			{ID: "test", Action: "exec", Command: `
 SOURCE_IP="{{source_ip}}"
 PORT="{{port}}"
 SIZE_MB="{{size_mb}}"
@@ -1136,9 +1220,13 @@ if [ -n "$SPEED_BPS" ]; then
     echo ""
     echo "=========================================="
     echo "Download Speed: $SPEED_MBPS Mbps"
+    echo "download_mbps=$SPEED_MBPS" >> "${CONFIGURATIX_OUTPUT:-/dev/null}"
 fi
 `, Timeout: 180},
 		},
+		Outputs: []OutputDef{
+			{Name: "download_mbps", Step: "test", From: "download_mbps", Description: "Download speed in Mbit/s"},
+		},
 	},
 
 	"speedtest_serve": {
@@ -1273,7 +1361,7 @@ fi
 		},
 		OnError: "stop",
 		Steps: []Step{
-			{Action: "exec", Command: `
+			{ID: "test", Action: "exec", Command: `
 HOST="{{host}}"
 COUNT="{{count}}"
 
@@ -1281,9 +1369,20 @@ echo "Testing latency to: $HOST"
 echo "Sending $COUNT packets..."
 echo "=========================================="
 
-ping -c $COUNT "$HOST" 2>&1
+OUT_FILE=$(mktemp)
+trap 'rm -f "$OUT_FILE"' EXIT
+ping -c $COUNT "$HOST" 2>&1 | tee "$OUT_FILE"
+STATUS=${PIPESTATUS[0]}
+
+# Summary line: rtt min/avg/max/mdev = 1.1/2.2/3.3/0.4 ms
+AVG_MS=$(awk -F'/' '/^(rtt|round-trip)/ {print $5}' "$OUT_FILE")
+echo "avg_ms=$AVG_MS" >> "${CONFIGURATIX_OUTPUT:-/dev/null}"
+exit $STATUS
 `, Timeout: 60},
 		},
+		Outputs: []OutputDef{
+			{Name: "avg_ms", Step: "test", From: "avg_ms", Description: "Average round trip time in ms"},
+		},
 	},
 
 	"network_info": {
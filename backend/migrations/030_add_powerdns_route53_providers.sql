@@ -0,0 +1,6 @@
+-- Migration 030_add_powerdns_route53_providers.sql
+-- Add powerdns and route53 as valid DNS providers
+
+ALTER TABLE dns_accounts DROP CONSTRAINT IF EXISTS dns_accounts_provider_check;
+ALTER TABLE dns_accounts ADD CONSTRAINT dns_accounts_provider_check
+    CHECK (provider IN ('dnspod', 'cloudflare', 'desec', 'njalla', 'cloudns', 'powerdns', 'route53'));
@@ -0,0 +1,6 @@
+-- Migration 031_add_rfc2136_provider.sql
+-- Add rfc2136 (dynamic update to self-hosted BIND/Knot) as a valid DNS provider
+
+ALTER TABLE dns_accounts DROP CONSTRAINT IF EXISTS dns_accounts_provider_check;
+ALTER TABLE dns_accounts ADD CONSTRAINT dns_accounts_provider_check
+    CHECK (provider IN ('dnspod', 'cloudflare', 'desec', 'njalla', 'cloudns', 'powerdns', 'route53', 'rfc2136'));
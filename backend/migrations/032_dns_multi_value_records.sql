@@ -0,0 +1,8 @@
+-- Migration 032_dns_multi_value_records.sql
+-- Allow multi-value RRsets (round-robin A, multiple MX, several TXT at the apex).
+-- Uniqueness moves from (name, type) to (name, type, value); dynamic (passthrough)
+-- records stay single-valued per name/type.
+
+DROP INDEX IF EXISTS idx_dns_records_unique;
+CREATE UNIQUE INDEX idx_dns_records_unique ON dns_records(dns_domain_id, name, record_type, value);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dns_records_dynamic_unique ON dns_records(dns_domain_id, name, record_type) WHERE mode = 'dynamic';
@@ -0,0 +1,21 @@
+-- Migration 033_dns_extended_record_types.sql
+-- Support SRV, CAA, PTR and TLSA records alongside A/AAAA/CNAME/TXT/MX/NS.
+-- The type check was created on dns_records_new (migration 015) and kept its name after the rename.
+
+ALTER TABLE dns_records DROP CONSTRAINT IF EXISTS dns_records_new_record_type_check;
+ALTER TABLE dns_records DROP CONSTRAINT IF EXISTS dns_records_record_type_check;
+ALTER TABLE dns_records ADD CONSTRAINT dns_records_record_type_check
+    CHECK (record_type IN ('A', 'AAAA', 'CNAME', 'TXT', 'MX', 'NS', 'SRV', 'CAA', 'PTR', 'TLSA'));
+
+-- SRV: priority (existing column), weight, port; value holds the target
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS weight INTEGER;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS port INTEGER;
+
+-- CAA: flags, tag (issue, issuewild, iodef); value holds the CA domain or URL
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS flags INTEGER;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS tag TEXT;
+
+-- TLSA: certificate usage, selector, matching type; value holds the hex association data
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS tlsa_usage INTEGER;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS tlsa_selector INTEGER;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS tlsa_matching_type INTEGER;
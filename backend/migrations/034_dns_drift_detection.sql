@@ -0,0 +1,11 @@
+-- Migration 034_dns_drift_detection.sql
+-- Background drift detection between local dns_records and the provider.
+-- The last comparison is stored per domain; domains with drift_enforce = true
+-- get local state re-applied automatically when drift is found.
+
+ALTER TABLE dns_managed_domains ADD COLUMN IF NOT EXISTS drift_enforce BOOLEAN DEFAULT false;
+ALTER TABLE dns_managed_domains ADD COLUMN IF NOT EXISTS drift_status TEXT DEFAULT 'unknown'
+    CHECK (drift_status IN ('unknown', 'in_sync', 'drifted', 'enforced', 'error'));
+ALTER TABLE dns_managed_domains ADD COLUMN IF NOT EXISTS drift_result JSONB;
+ALTER TABLE dns_managed_domains ADD COLUMN IF NOT EXISTS drift_error TEXT;
+ALTER TABLE dns_managed_domains ADD COLUMN IF NOT EXISTS drift_checked_at TIMESTAMP;
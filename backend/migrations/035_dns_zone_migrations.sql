@@ -0,0 +1,28 @@
+-- Migration 035_dns_zone_migrations.sql
+-- Move a managed domain from one DNS account/provider to another.
+-- Records are copied to the target account and kept in sync until the
+-- delegation at the registrar points to the target nameservers; only then
+-- does dns_managed_domains.dns_account_id switch over.
+
+CREATE TABLE IF NOT EXISTS dns_zone_migrations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dns_domain_id UUID NOT NULL REFERENCES dns_managed_domains(id) ON DELETE CASCADE,
+    from_account_id UUID REFERENCES dns_accounts(id) ON DELETE SET NULL,
+    to_account_id UUID NOT NULL REFERENCES dns_accounts(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'copying'
+        CHECK (status IN ('copying', 'awaiting_ns', 'completed', 'failed', 'cancelled')),
+    nameservers TEXT[],                 -- Nameservers of the target zone to set at the registrar
+    ns_actual TEXT[],                   -- Last observed delegation
+    records_copied INTEGER DEFAULT 0,
+    error TEXT,
+    last_checked_at TIMESTAMP WITH TIME ZONE,
+    detached_at TIMESTAMP WITH TIME ZONE, -- When the old account stopped being used
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dns_zone_migrations_domain ON dns_zone_migrations(dns_domain_id);
+
+-- Only one migration in flight per domain
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dns_zone_migrations_active
+    ON dns_zone_migrations(dns_domain_id) WHERE status IN ('copying', 'awaiting_ns');
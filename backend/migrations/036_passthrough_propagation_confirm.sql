@@ -0,0 +1,12 @@
+-- Migration 036_passthrough_propagation_confirm.sql
+-- Optionally confirm that a rotation reached every authoritative nameserver
+-- before last_rotated_at is advanced. While propagation_pending_since is set the
+-- scheduler re-checks the nameservers instead of rotating again.
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS confirm_propagation BOOLEAN DEFAULT false;
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS propagation_status VARCHAR(20) DEFAULT 'none'; -- none, pending, confirmed, timeout
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS propagation_pending_since TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS confirm_propagation BOOLEAN DEFAULT false;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS propagation_status VARCHAR(20) DEFAULT 'none';
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS propagation_pending_since TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,30 @@
+-- Migration 037_passthrough_health_probes.sql
+-- Active health probing of passthrough pool members from the control plane.
+-- Each member is probed with a TCP connect and a TLS handshake (using the
+-- pool's SNI) on 443, plus an optional HTTP request on 80. Status only flips
+-- after several consecutive results in the same direction (hysteresis).
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS health_check_path VARCHAR(255) DEFAULT ''; -- Empty = no HTTP check
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS health_check_host VARCHAR(255) DEFAULT ''; -- SNI/Host override
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS health_check_path VARCHAR(255) DEFAULT '';
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS health_check_host VARCHAR(255) DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS dns_passthrough_health (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pool_type VARCHAR(20) NOT NULL,                -- 'record' or 'wildcard'
+    pool_id UUID NOT NULL,
+    machine_id UUID NOT NULL REFERENCES machines(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'unknown', -- unknown, healthy, unhealthy
+    consecutive_successes INTEGER DEFAULT 0,
+    consecutive_failures INTEGER DEFAULT 0,
+    tcp_ok BOOLEAN DEFAULT false,
+    tls_ok BOOLEAN DEFAULT false,
+    http_ok BOOLEAN,                               -- NULL when no HTTP check is configured
+    latency_ms INTEGER DEFAULT 0,
+    last_error TEXT,
+    last_probe_at TIMESTAMP WITH TIME ZONE,
+    last_change_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(pool_type, pool_id, machine_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dns_passthrough_health_pool ON dns_passthrough_health(pool_type, pool_id);
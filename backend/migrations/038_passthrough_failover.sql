@@ -0,0 +1,15 @@
+-- Migration 038_passthrough_failover.sql
+-- Failover-triggered rotation: the scheduler rotates away from a current machine
+-- that fails health checks instead of waiting for the next interval/schedule.
+-- rotation_mode 'failover' rotates only on failure; failover_enabled adds failover
+-- on top of 'interval' or 'scheduled'. When preferred_machine_id is set, the pool
+-- rotates back to it once it is healthy again (trigger 'failback').
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS failover_enabled BOOLEAN DEFAULT false;
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS preferred_machine_id UUID REFERENCES machines(id) ON DELETE SET NULL;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS failover_enabled BOOLEAN DEFAULT false;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS preferred_machine_id UUID REFERENCES machines(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN dns_passthrough_pools.rotation_mode IS 'interval, scheduled or failover';
+COMMENT ON COLUMN dns_wildcard_pools.rotation_mode IS 'interval, scheduled or failover';
+COMMENT ON COLUMN dns_rotation_history.trigger IS 'scheduled, manual, health, failover or failback';
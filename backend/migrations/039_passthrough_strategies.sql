@@ -0,0 +1,17 @@
+-- Migration 039_passthrough_strategies.sql
+-- More rotation strategies for passthrough pools:
+--   weighted             - random pick proportional to member weight
+--   least_recently_used  - the member that has gone longest without traffic
+--   multi                - publish answer_count healthy members at once as an RRset
+-- Extra answers of a multi pool are stored as mode 'dynamic_extra' rows next to the
+-- pool's single 'dynamic' record.
+
+ALTER TABLE dns_passthrough_members ADD COLUMN IF NOT EXISTS weight INTEGER DEFAULT 1;
+ALTER TABLE dns_wildcard_pool_members ADD COLUMN IF NOT EXISTS weight INTEGER DEFAULT 1;
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS answer_count INTEGER DEFAULT 2;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS answer_count INTEGER DEFAULT 2;
+
+COMMENT ON COLUMN dns_passthrough_pools.rotation_strategy IS 'round_robin, random, weighted, least_recently_used or multi';
+COMMENT ON COLUMN dns_wildcard_pools.rotation_strategy IS 'round_robin, random, weighted, least_recently_used or multi';
+COMMENT ON COLUMN dns_records.mode IS 'static, dynamic (passthrough pool) or dynamic_extra (additional multi-answer value)';
@@ -0,0 +1,12 @@
+-- Migration 040_passthrough_cron_schedules.sql
+-- Scheduled rotation with cron expressions in an explicit IANA timezone.
+-- The next due time is persisted so rotations missed while the server was down
+-- (or a tick was slow) are caught up instead of skipped. Legacy scheduled_times
+-- ("HH:MM") keep working and are evaluated in the pool's timezone.
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS cron_expression VARCHAR(255) DEFAULT '';
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) DEFAULT 'UTC';
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS next_rotation_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS cron_expression VARCHAR(255) DEFAULT '';
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) DEFAULT 'UTC';
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS next_rotation_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,9 @@
+-- Migration 041_passthrough_ipv6.sql
+-- IPv6 for passthrough pools: machines get a primary IPv6 next to primary_ip
+-- (auto-detected from the first public IPv6 interface unless chosen by the user),
+-- and pools can manage an AAAA record with the same name alongside their A record.
+
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS primary_ipv6 TEXT;
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS ipv6_enabled BOOLEAN DEFAULT false;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS ipv6_enabled BOOLEAN DEFAULT false;
@@ -0,0 +1,32 @@
+-- Migration 042_passthrough_rotation_journal.sql
+-- Crash-safe rotations. Every rotation is journaled before DNS is touched
+-- (planned, with a snapshot of the answers it replaces), marked dns_updated once
+-- the provider accepted the change, and committed together with the pool state
+-- and history in one transaction. The scheduler leader resumes (dns_updated) or
+-- rolls back (planned) operations abandoned by a crashed instance.
+
+CREATE TABLE IF NOT EXISTS dns_rotation_operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pool_type VARCHAR(20) NOT NULL,                -- 'record' or 'wildcard'
+    pool_id UUID NOT NULL,
+    dns_domain_id UUID,
+    state VARCHAR(20) NOT NULL DEFAULT 'planned',  -- planned, dns_updated, committed, rolled_back
+    trigger VARCHAR(50) NOT NULL,
+    from_machine_id UUID,
+    from_ip TEXT DEFAULT '',
+    to_machine_id UUID NOT NULL,
+    to_ip TEXT DEFAULT '',
+    new_index INTEGER DEFAULT 0,
+    await_propagation BOOLEAN DEFAULT false,       -- Leave last_rotated_at to the propagation check
+    reset_schedule BOOLEAN DEFAULT false,          -- Clear next_rotation_at on commit
+    previous_answers JSONB DEFAULT '[]'::jsonb,    -- RRsets before the rotation, for rollback
+    instance VARCHAR(255) DEFAULT '',              -- Backend instance that planned the rotation
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- At most one unfinished rotation per pool, across all backend instances
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dns_rotation_operations_inflight
+    ON dns_rotation_operations(pool_type, pool_id) WHERE state IN ('planned', 'dns_updated');
+CREATE INDEX IF NOT EXISTS idx_dns_rotation_operations_pool ON dns_rotation_operations(pool_type, pool_id, created_at DESC);
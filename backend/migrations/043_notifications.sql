@@ -0,0 +1,51 @@
+-- Migration 043_notifications.sql
+-- Notification channels (webhook, Telegram, SMTP) with per-pool, per-project or
+-- global subscriptions. Events are queued as deliveries and sent by the
+-- notification dispatcher with retries and exponential backoff; the delivery
+-- table doubles as the delivery log.
+
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL,                     -- webhook, telegram, smtp
+    config JSONB NOT NULL DEFAULT '{}'::jsonb,     -- Type-specific settings (url/secret, bot_token/chat_id, host/port/...)
+    is_enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS notification_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel_id UUID NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL,                    -- global, project, pool
+    project_id UUID REFERENCES projects(id) ON DELETE CASCADE,
+    pool_type VARCHAR(20),                         -- 'record' or 'wildcard' (scope = pool)
+    pool_id UUID,
+    events TEXT[] DEFAULT '{}',                    -- Empty = all events
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_subscriptions_channel ON notification_subscriptions(channel_id);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel_id UUID NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,               -- rotation, failover, ns_status, agent_offline, test
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sending, delivered, failed
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    response_status INTEGER,
+    last_error TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_channel ON notification_deliveries(channel_id, created_at DESC);
+
+-- Agent offline events fire once per outage; the heartbeat clears the flag
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS offline_notified BOOLEAN DEFAULT false;
+UPDATE agents SET offline_notified = true WHERE last_seen IS NULL OR last_seen < NOW() - INTERVAL '5 minutes';
@@ -0,0 +1,30 @@
+-- Migration 044_passthrough_warmup.sql
+-- Pre-rotation warmup. Before a rotation publishes a new member, the member's
+-- passthrough nginx config is pushed and the rotation waits for the job to
+-- complete (nginx -t passed, nginx running), optionally followed by a probe.
+-- If the warmup fails the rotation is aborted without touching DNS: the journal
+-- operation ends in state 'aborted' and the reason is recorded on it and the pool.
+
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS warmup_enabled BOOLEAN DEFAULT false;
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS warmup_probe BOOLEAN DEFAULT false;            -- Probe the target after its config is applied
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS warmup_timeout_seconds INTEGER DEFAULT 300;
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS last_rotation_error TEXT;                     -- Why the last rotation was aborted, cleared on success
+ALTER TABLE dns_passthrough_pools ADD COLUMN IF NOT EXISTS last_rotation_error_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS warmup_enabled BOOLEAN DEFAULT false;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS warmup_probe BOOLEAN DEFAULT false;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS warmup_timeout_seconds INTEGER DEFAULT 300;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS last_rotation_error TEXT;
+ALTER TABLE dns_wildcard_pools ADD COLUMN IF NOT EXISTS last_rotation_error_at TIMESTAMP WITH TIME ZONE;
+
+-- Last passthrough config pushed to each machine. status follows the job, so
+-- 'applied' means the agent reported the config job completed.
+CREATE TABLE IF NOT EXISTS machine_passthrough_configs (
+    machine_id UUID PRIMARY KEY REFERENCES machines(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    config_hash VARCHAR(64) NOT NULL,              -- sha256 of the generated stream config
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, applied, failed
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_machine_passthrough_configs_job ON machine_passthrough_configs(job_id);
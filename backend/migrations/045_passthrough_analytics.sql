@@ -0,0 +1,28 @@
+-- Migration 045_passthrough_analytics.sql
+-- Rotation analytics. Rotations now record every member they published (the
+-- multi strategy publishes several), so reports can compute how long each
+-- machine served a pool. Machine outages are recorded as intervals: agent
+-- outages when heartbeats stop, probe outages when a pool's health check marks
+-- a member unhealthy. Reports intersect them with the time a machine was current.
+
+ALTER TABLE dns_rotation_operations ADD COLUMN IF NOT EXISTS answer_machine_ids UUID[] DEFAULT '{}'; -- All published members, to_machine_id first
+ALTER TABLE dns_rotation_history ADD COLUMN IF NOT EXISTS answer_machine_ids UUID[] DEFAULT '{}';    -- Empty for rotations recorded before this migration
+
+CREATE INDEX IF NOT EXISTS idx_rotation_history_pool_time ON dns_rotation_history(pool_type, pool_id, rotated_at);
+
+CREATE TABLE IF NOT EXISTS machine_outages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    machine_id UUID NOT NULL REFERENCES machines(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL,          -- agent (heartbeats stopped), probe (pool health check failed)
+    pool_type VARCHAR(20),                -- Probe outages only: 'record' or 'wildcard'
+    pool_id UUID,
+    reason TEXT DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE     -- NULL while ongoing
+);
+
+-- At most one ongoing outage per machine and source (and pool for probes)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_machine_outages_open
+    ON machine_outages(machine_id, source, COALESCE(pool_id, '00000000-0000-0000-0000-000000000000'::uuid))
+    WHERE ended_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_machine_outages_machine ON machine_outages(machine_id, started_at);
@@ -0,0 +1,20 @@
+-- Migration 046_job_leases.sql
+-- Lease-based job claiming. Agents claim pending jobs atomically (FOR UPDATE
+-- SKIP LOCKED), which moves them to running with a short lease that the agent
+-- renews while the job executes. Jobs whose lease expires (agent died) are
+-- requeued until max_attempts is reached, then failed. Users can cancel pending
+-- or running jobs; the agent stops a cancelled job at its next lease renewal.
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INTEGER DEFAULT 0;           -- Times the job was claimed
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_attempts INTEGER DEFAULT 3;       -- Claims allowed before an expired lease fails the job
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lease_owner VARCHAR(255);             -- Agent process holding the job
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN jobs.status IS 'pending, running, completed, failed, cancelled';
+
+-- Jobs left running by agents that predate leases would never finish
+UPDATE jobs SET attempts = 1, lease_expires_at = updated_at + INTERVAL '6 hours'
+WHERE status = 'running' AND lease_expires_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_agent_pending ON jobs(agent_id, created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_lease_expires ON jobs(lease_expires_at) WHERE status = 'running';
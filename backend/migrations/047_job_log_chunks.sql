@@ -0,0 +1,21 @@
+-- Migration 047_job_log_chunks.sql
+-- Streaming job logs. Agents post step output while a job runs as ordered
+-- chunks (numbered per claim attempt), with markers for step boundaries and
+-- exit codes. Output is capped per job: the chunk that crosses the cap is
+-- stored as a truncation marker and later output is only counted.
+
+CREATE TABLE IF NOT EXISTS job_log_chunks (
+    id BIGSERIAL PRIMARY KEY,
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL DEFAULT 1,  -- jobs.attempts when the chunk was written
+    seq INTEGER NOT NULL,                -- Agent-assigned order within the attempt
+    step INTEGER NOT NULL DEFAULT 0,     -- Run step number, 0 outside steps
+    kind VARCHAR(20) NOT NULL,           -- output, step_start, step_end, truncated
+    content TEXT NOT NULL DEFAULT '',
+    exit_code INTEGER,                   -- step_end only
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (job_id, attempt, seq)
+);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS log_bytes BIGINT DEFAULT 0;          -- Streamed output stored
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS log_dropped_bytes BIGINT DEFAULT 0;  -- Streamed output dropped after the cap
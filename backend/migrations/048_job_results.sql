@@ -0,0 +1,6 @@
+-- Migration 048_job_results.sql
+-- Structured job results. Agents report run jobs as one result per step (exit
+-- code, stdout, stderr, duration, files changed, rollback) plus the named
+-- outputs declared by the command template, so handlers no longer parse logs.
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result JSONB; -- NULL for non-run jobs and agents before 0.8.0
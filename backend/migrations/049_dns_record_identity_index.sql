@@ -0,0 +1,12 @@
+-- Migration 049_dns_record_identity_index.sql
+-- Records of an RRset are unique by their full identity, not just the value: CAA
+-- issue and issuewild may name the same CA, SRV records may share a target on
+-- different ports, TLSA records may differ only in usage/selector/matching type.
+-- Matches sameIdentity in internal/dns/sync.go; NULL and 0 are the same there.
+
+DROP INDEX IF EXISTS idx_dns_records_unique;
+CREATE UNIQUE INDEX idx_dns_records_unique ON dns_records(
+    dns_domain_id, name, record_type, value,
+    COALESCE(tag, ''), COALESCE(port, 0), COALESCE(weight, 0),
+    COALESCE(tlsa_usage, 0), COALESCE(tlsa_selector, 0), COALESCE(tlsa_matching_type, 0)
+);
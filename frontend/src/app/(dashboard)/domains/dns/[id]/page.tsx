@@ -11,7 +11,7 @@ import { Dialog, DialogContent, DialogDescription, DialogHeader, DialogTitle, Di
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
 import { Checkbox } from "@/components/ui/checkbox";
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs";
-import { api, DNSManagedDomain, DNSAccount, DNSRecord, NSStatus, DNSSyncResult, Machine, PassthroughPoolResponse, WildcardPoolResponse, RotationHistory, MachineGroupWithCount } from "@/lib/api";
+import { api, DNSManagedDomain, DNSAccount, DNSRecord, NSStatus, DNSSyncResult, Machine, PassthroughPoolResponse, WildcardPoolResponse, RotationHistory, RotationOperation, MachineGroupWithCount } from "@/lib/api";
 import { copyToClipboard } from "@/lib/clipboard";
 import { Globe, CheckCircle, XCircle, AlertTriangle, RefreshCw, Copy, Trash, Settings2, Play, Pause, RotateCcw, Server, History, Zap, Users, MoreHorizontal, ArrowLeft, X, Plus } from "lucide-react";
 import { DropdownMenu, DropdownMenuContent, DropdownMenuItem, DropdownMenuSeparator, DropdownMenuTrigger } from "@/components/ui/dropdown-menu";
@@ -317,6 +317,13 @@ export default function DomainDNSSettingsPage() {
     httpOutPort: 80,
     httpsInPort: 443,
     httpsOutPort: 443,
+    weight: 5,
+    port: 443,
+    flags: 0,
+    tag: "issue",
+    tlsaUsage: 3,
+    tlsaSelector: 1,
+    tlsaMatchingType: 1,
   });
 
   // Load initial data
@@ -701,12 +708,19 @@ export default function DomainDNSSettingsPage() {
         record_type: newRecord.record_type,
         value: newRecord.value,
         ttl: newRecord.ttl,
-        priority: newRecord.record_type === "MX" ? newRecord.priority : undefined,
+        priority: newRecord.record_type === "MX" || newRecord.record_type === "SRV" ? newRecord.priority : undefined,
         proxied: isCloudflare ? newRecord.proxied : false,
         http_incoming_port: newRecord.customPorts ? newRecord.httpInPort : undefined,
         http_outgoing_port: newRecord.customPorts ? newRecord.httpOutPort : undefined,
         https_incoming_port: newRecord.customPorts ? newRecord.httpsInPort : undefined,
         https_outgoing_port: newRecord.customPorts ? newRecord.httpsOutPort : undefined,
+        ...(newRecord.record_type === "SRV" && { weight: newRecord.weight, port: newRecord.port }),
+        ...(newRecord.record_type === "CAA" && { flags: newRecord.flags, tag: newRecord.tag }),
+        ...(newRecord.record_type === "TLSA" && {
+          tlsa_usage: newRecord.tlsaUsage,
+          tlsa_selector: newRecord.tlsaSelector,
+          tlsa_matching_type: newRecord.tlsaMatchingType,
+        }),
       });
       setNewRecord({ 
         name: "", 
@@ -720,6 +734,13 @@ export default function DomainDNSSettingsPage() {
         httpOutPort: 80,
         httpsInPort: 443,
         httpsOutPort: 443,
+        weight: 5,
+        port: 443,
+        flags: 0,
+        tag: "issue",
+        tlsaUsage: 3,
+        tlsaSelector: 1,
+        tlsaMatchingType: 1,
       });
       loadRecords();
       toast.success("Record added");
@@ -756,8 +777,13 @@ export default function DomainDNSSettingsPage() {
     if (!domain) return;
     setSaving(true);
     try {
-      await api.applyDNSToRemote(domain.id);
-      toast.success("Records synced to provider");
+      const result = await api.applyDNSToRemote(domain.id);
+      if (result.in_sync) {
+        toast.success("Records synced to provider");
+      } else {
+        const failed = (result.changes || []).filter(c => c.status !== "applied").length;
+        toast.error(`Sync failed, ${failed} change(s) not applied: ${result.errors[0] || "unknown error"}`);
+      }
       loadRecords();
       setSyncResult(null);
     } catch (err: unknown) {
@@ -784,12 +810,23 @@ export default function DomainDNSSettingsPage() {
 
   const handleRotateNow = async (poolId: string, isWildcard: boolean) => {
     try {
-      if (isWildcard) {
-        await api.rotateWildcardPool(poolId);
+      const started = isWildcard ? await api.rotateWildcardPool(poolId) : await api.rotateRecordPool(poolId);
+      toast.success("Rotation triggered");
+
+      // The rotation runs in the background (warmup can take minutes)
+      let op: RotationOperation;
+      do {
+        await new Promise((resolve) => setTimeout(resolve, 3000));
+        op = isWildcard
+          ? await api.getWildcardPoolRotation(poolId, started.operation_id)
+          : await api.getRecordPoolRotation(poolId, started.operation_id);
+      } while (op.state === "planned" || op.state === "dns_updated");
+
+      if (op.state === "committed") {
+        toast.success(`Rotated to ${op.to_ip}`);
       } else {
-        await api.rotateRecordPool(poolId);
+        toast.error(`Rotation ${op.state === "aborted" ? "aborted" : "rolled back"}: ${op.error || "unknown error"}`);
       }
-      toast.success("Rotation triggered");
       if (isWildcard) {
         loadWildcardPool();
       } else {
@@ -1075,6 +1112,11 @@ export default function DomainDNSSettingsPage() {
                             <SelectItem value="CNAME">CNAME</SelectItem>
                             <SelectItem value="TXT">TXT</SelectItem>
                             <SelectItem value="MX">MX</SelectItem>
+                            <SelectItem value="NS">NS</SelectItem>
+                            <SelectItem value="SRV">SRV</SelectItem>
+                            <SelectItem value="CAA">CAA</SelectItem>
+                            <SelectItem value="PTR">PTR</SelectItem>
+                            <SelectItem value="TLSA">TLSA</SelectItem>
                           </SelectContent>
                         </Select>
                       </div>
@@ -1128,6 +1170,109 @@ export default function DomainDNSSettingsPage() {
                         </Button>
                       </div>
                     </div>
+                    {["MX", "SRV", "CAA", "TLSA"].includes(newRecord.record_type) && (
+                      <div className="grid grid-cols-12 gap-3 mt-3">
+                        {(newRecord.record_type === "MX" || newRecord.record_type === "SRV") && (
+                          <div className="col-span-2 space-y-1.5">
+                            <Label className="text-xs text-muted-foreground">Priority</Label>
+                            <Input
+                              className="h-10"
+                              type="number"
+                              value={newRecord.priority}
+                              onChange={(e) => setNewRecord({ ...newRecord, priority: parseInt(e.target.value) || 0 })}
+                            />
+                          </div>
+                        )}
+                        {newRecord.record_type === "SRV" && (
+                          <>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Weight</Label>
+                              <Input
+                                className="h-10"
+                                type="number"
+                                value={newRecord.weight}
+                                onChange={(e) => setNewRecord({ ...newRecord, weight: parseInt(e.target.value) || 0 })}
+                              />
+                            </div>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Port</Label>
+                              <Input
+                                className="h-10"
+                                type="number"
+                                value={newRecord.port}
+                                onChange={(e) => setNewRecord({ ...newRecord, port: parseInt(e.target.value) || 0 })}
+                              />
+                            </div>
+                          </>
+                        )}
+                        {newRecord.record_type === "CAA" && (
+                          <>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Flags</Label>
+                              <Select
+                                value={String(newRecord.flags)}
+                                onValueChange={(v) => setNewRecord({ ...newRecord, flags: parseInt(v) })}
+                              >
+                                <SelectTrigger className="h-10">
+                                  <SelectValue />
+                                </SelectTrigger>
+                                <SelectContent>
+                                  <SelectItem value="0">0</SelectItem>
+                                  <SelectItem value="128">128 (critical)</SelectItem>
+                                </SelectContent>
+                              </Select>
+                            </div>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Tag</Label>
+                              <Select
+                                value={newRecord.tag}
+                                onValueChange={(v) => setNewRecord({ ...newRecord, tag: v })}
+                              >
+                                <SelectTrigger className="h-10">
+                                  <SelectValue />
+                                </SelectTrigger>
+                                <SelectContent>
+                                  <SelectItem value="issue">issue</SelectItem>
+                                  <SelectItem value="issuewild">issuewild</SelectItem>
+                                  <SelectItem value="iodef">iodef</SelectItem>
+                                </SelectContent>
+                              </Select>
+                            </div>
+                          </>
+                        )}
+                        {newRecord.record_type === "TLSA" && (
+                          <>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Usage</Label>
+                              <Input
+                                className="h-10"
+                                type="number"
+                                value={newRecord.tlsaUsage}
+                                onChange={(e) => setNewRecord({ ...newRecord, tlsaUsage: parseInt(e.target.value) || 0 })}
+                              />
+                            </div>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Selector</Label>
+                              <Input
+                                className="h-10"
+                                type="number"
+                                value={newRecord.tlsaSelector}
+                                onChange={(e) => setNewRecord({ ...newRecord, tlsaSelector: parseInt(e.target.value) || 0 })}
+                              />
+                            </div>
+                            <div className="col-span-2 space-y-1.5">
+                              <Label className="text-xs text-muted-foreground">Matching Type</Label>
+                              <Input
+                                className="h-10"
+                                type="number"
+                                value={newRecord.tlsaMatchingType}
+                                onChange={(e) => setNewRecord({ ...newRecord, tlsaMatchingType: parseInt(e.target.value) || 0 })}
+                              />
+                            </div>
+                          </>
+                        )}
+                      </div>
+                    )}
                   </div>
 
                   {/* Records Table */}
@@ -1144,7 +1289,7 @@ export default function DomainDNSSettingsPage() {
                         </tr>
                       </thead>
                       <tbody>
-                        {records.filter(r => r.mode !== "dynamic").map((record) => (
+                        {records.filter(r => r.mode !== "dynamic" && r.mode !== "dynamic_extra").map((record) => (
                           <tr key={record.id} className="border-t">
                             <td className="py-3 px-4 font-mono">
                               {record.name === "@" ? domain.fqdn : `${record.name}.${domain.fqdn}`}
@@ -1177,7 +1322,7 @@ export default function DomainDNSSettingsPage() {
                             </td>
                           </tr>
                         ))}
-                        {records.filter(r => r.mode !== "dynamic").length === 0 && (
+                        {records.filter(r => r.mode !== "dynamic" && r.mode !== "dynamic_extra").length === 0 && (
                           <tr>
                             <td colSpan={6} className="py-8 text-center text-muted-foreground">
                               No records yet. Add one above.
@@ -1641,6 +1786,24 @@ export default function DomainDNSSettingsPage() {
                     </Card>
                   </div>
 
+                  {syncResult.conflicts?.length > 0 && (
+                    <div className="space-y-1 text-sm">
+                      {syncResult.conflicts.map((c) => (
+                        <div key={`${c.local_id}-${c.remote_id}`} className="flex gap-2 font-mono">
+                          <span className="text-orange-500">{c.record_type}</span>
+                          <span>{c.record_name}</span>
+                          <span className="text-muted-foreground">
+                            {(c.differences || ["value"]).map((d) =>
+                              d === "ttl" ? `ttl ${c.remote_ttl} → ${c.local_ttl}`
+                              : d === "priority" ? `priority ${c.remote_priority} → ${c.local_priority}`
+                              : `${c.remote_value} → ${c.local_value}`
+                            ).join(", ")}
+                          </span>
+                        </div>
+                      ))}
+                    </div>
+                  )}
+
                   {syncResult.in_sync ? (
                     <p className="text-sm text-green-500">✓ All records are in sync</p>
                   ) : (
@@ -413,6 +413,9 @@ export default function DNSManagementPage() {
                   <SelectItem value="desec">🔒 deSEC</SelectItem>
                   <SelectItem value="njalla">🛡️ Njalla</SelectItem>
                   <SelectItem value="cloudns">🌍 ClouDNS</SelectItem>
+                  <SelectItem value="powerdns">⚡ PowerDNS</SelectItem>
+                  <SelectItem value="route53">🟧 Route53</SelectItem>
+                  <SelectItem value="rfc2136">🖥️ RFC 2136 (BIND/Knot)</SelectItem>
                   <SelectItem value="dnspod">🌐 DNSPod</SelectItem>
                 </SelectContent>
               </Select>
@@ -425,11 +428,11 @@ export default function DNSManagementPage() {
                 onChange={(e) => setDnsAccountForm({ ...dnsAccountForm, name: e.target.value })}
               />
             </div>
-            {(dnsAccountForm.provider === "dnspod" || dnsAccountForm.provider === "cloudns") && (
+            {(dnsAccountForm.provider === "dnspod" || dnsAccountForm.provider === "cloudns" || dnsAccountForm.provider === "powerdns" || dnsAccountForm.provider === "route53" || dnsAccountForm.provider === "rfc2136") && (
               <div className="space-y-2">
-                <Label>{dnsAccountForm.provider === "cloudns" ? "Auth ID" : "API ID"}</Label>
+                <Label>{dnsAccountForm.provider === "cloudns" ? "Auth ID" : dnsAccountForm.provider === "powerdns" ? "API URL" : dnsAccountForm.provider === "route53" ? "Access Key ID" : dnsAccountForm.provider === "rfc2136" ? "TSIG Key @ Server" : "API ID"}</Label>
                 <Input
-                  placeholder={dnsAccountForm.provider === "cloudns" ? "12345 (or sub-12345 for sub-user)" : "123456"}
+                  placeholder={dnsAccountForm.provider === "cloudns" ? "12345 (or sub-12345 for sub-user)" : dnsAccountForm.provider === "powerdns" ? "http://pdns.internal:8081" : dnsAccountForm.provider === "route53" ? "AKIA... (or AKIA...@https://endpoint)" : dnsAccountForm.provider === "rfc2136" ? "configuratix-key@ns1.internal:53" : "123456"}
                   value={dnsAccountForm.api_id}
                   onChange={(e) => setDnsAccountForm({ ...dnsAccountForm, api_id: e.target.value })}
                 />
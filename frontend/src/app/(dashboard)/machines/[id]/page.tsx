@@ -507,24 +507,17 @@ function MachineToolsTab({ machineId, machineIp }: { machineId: string; machineI
       const testResult = await api.runSpeedTestSync(machineId, request);
       setResult(testResult.logs);
       
-      // Extract summary from logs
-      const lines = testResult.logs.split("\n");
+      // Summary from the measured values the test reports
+      const outputs = testResult.outputs || {};
       let summary = "";
-      if (testType === "public") {
-        const downloadLine = lines.find(l => l.includes("Download:"));
-        const uploadLine = lines.find(l => l.includes("Upload:"));
-        if (downloadLine && uploadLine) {
-          summary = `${downloadLine.trim()}, ${uploadLine.trim()}`;
-        }
-      } else if (testType === "download" || testType === "machine_download") {
-        const speedLine = lines.find(l => l.includes("Download Speed:"));
-        summary = speedLine?.trim() || "Test completed";
-      } else if (testType === "upload") {
-        const speedLine = lines.find(l => l.includes("Upload Speed:"));
-        summary = speedLine?.trim() || "Test completed";
-      } else if (testType === "latency") {
-        const avgLine = lines.find(l => l.includes("avg"));
-        summary = avgLine?.trim() || "Ping completed";
+      if (testType === "public" && outputs.download_mbps && outputs.upload_mbps) {
+        summary = `Download: ${outputs.download_mbps} Mbps, Upload: ${outputs.upload_mbps} Mbps`;
+      } else if ((testType === "download" || testType === "machine_download") && outputs.download_mbps) {
+        summary = `Download Speed: ${outputs.download_mbps} Mbps`;
+      } else if (testType === "upload" && outputs.upload_mbps) {
+        summary = `Upload Speed: ${outputs.upload_mbps} Mbps`;
+      } else if (testType === "latency" && outputs.avg_ms) {
+        summary = `Average latency: ${outputs.avg_ms} ms`;
       } else {
         summary = testResult.status === "completed" ? "Test completed successfully" : "Test finished";
       }
@@ -76,6 +76,7 @@ export interface Machine {
   ip_address: string | null;
   detected_ips: InterfaceIP[] | null;
   primary_ip: string | null;
+  primary_ipv6: string | null; // Selected IPv6 for passthrough AAAA records
   ubuntu_version: string | null;
   notes_md: string | null;
   access_token_set: boolean;
@@ -166,11 +167,66 @@ export interface Job {
   agent_id: string;
   type: string;
   payload_json: unknown;
-  status: string;
+  status: string; // pending, running, completed, failed, cancelled
   logs: string | null;
   created_at: string;
   started_at: string | null;
   finished_at: string | null;
+  attempts: number;
+  max_attempts: number;
+  lease_owner: string | null;
+  lease_expires_at: string | null;
+  log_bytes: number;
+  log_dropped_bytes: number;
+  result: JobResult | null; // run jobs, agents 0.8.0+
+}
+
+export interface JobStepResult {
+  step: number;
+  id?: string;
+  action: string;
+  exit_code: number;
+  stdout: string;
+  stderr: string;
+  stdout_truncated?: boolean;
+  duration_ms: number;
+  files_changed?: string[];
+  outputs?: Record<string, string>;
+  rolled_back: boolean;
+  error?: string;
+  skipped?: boolean; // when condition was false
+  attempts?: number; // including retries
+  changed: boolean; // changed the host, or would in check mode
+  unknown?: boolean; // check mode: not run (or depends on a step that wasn't), outcome unknown
+}
+
+export interface JobResult {
+  steps: JobStepResult[];
+  outputs: Record<string, string> | null;
+  rolled_back: boolean;
+  changed: boolean;
+  check: boolean; // check mode: changes were reported, not applied
+}
+
+export interface JobLogChunk {
+  id: number;
+  job_id: string;
+  attempt: number;
+  seq: number;
+  step: number; // 0 outside run steps
+  kind: "output" | "step_start" | "step_end" | "truncated";
+  content: string;
+  exit_code: number | null; // step_end only
+  created_at: string;
+}
+
+export interface JobLogsResponse {
+  job_id: string;
+  status: string;
+  chunks: JobLogChunk[];
+  next_after: number;
+  log_bytes: number;
+  log_dropped_bytes: number;
 }
 
 export interface VariableDef {
@@ -182,6 +238,7 @@ export interface VariableDef {
 }
 
 export interface CommandStep {
+  id?: string;
   action: string;
   command?: string;
   timeout?: number;
@@ -191,6 +248,27 @@ export interface CommandStep {
   mode?: string;
   op?: string;
   name?: string;
+  when?: string; // Go template condition, e.g. `ne .steps.check.exit_code 0`
+  on_error?: string;
+  register?: string;
+  retries?: number;
+  delay?: number;
+  loop?: string[]; // runs the step once per item, {{item}} in fields and .item in conditions
+  with_items?: string; // Go template expression giving more items, one per line
+  loop_var?: string;
+  read_only?: boolean; // exec that also runs in check mode
+  state?: string;
+  version?: string;
+  update_cache?: boolean;
+  owner?: string;
+  group?: string;
+  line?: string;
+  regexp?: string;
+  value?: string;
+  shell?: string;
+  home?: string;
+  system?: boolean;
+  enabled?: boolean;
 }
 
 export interface CommandTemplate {
@@ -201,6 +279,15 @@ export interface CommandTemplate {
   variables: VariableDef[];
   steps: CommandStep[];
   on_error: string;
+  outputs?: CommandOutput[];
+}
+
+export interface CommandOutput {
+  name: string;
+  step: string; // Step ID
+  from?: string; // stdout (default), stderr, exit_code, or a key written to $CONFIGURATIX_OUTPUT
+  trim?: boolean;
+  description?: string;
 }
 
 export interface EnrollmentToken {
@@ -254,16 +341,80 @@ export interface DNSManagedDomain {
   ns_expected: string[] | null;
   ns_actual: string[] | null;
   notes_md: string | null;
+  drift_enforce: boolean;
+  drift_status: string; // unknown, in_sync, drifted, enforced, error
+  drift_result: DNSSyncResult | null;
+  drift_error: string | null;
+  drift_checked_at: string | null;
   created_at: string;
   updated_at: string;
   dns_account_name?: string | null;
   dns_account_provider?: string | null;
 }
 
+export interface DNSZoneMigration {
+  id: string;
+  dns_domain_id: string;
+  from_account_id: string | null;
+  to_account_id: string;
+  status: string; // copying, awaiting_ns, completed, failed, cancelled
+  nameservers: string[] | null;
+  ns_actual: string[] | null;
+  records_copied: number;
+  error: string | null;
+  last_checked_at: string | null;
+  detached_at: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface DNSDSRecord {
+  key_tag: number;
+  algorithm: number;
+  digest_type: number;
+  digest: string;
+}
+
+export interface DNSSECInfo {
+  supported: boolean;
+  dnssec: {
+    enabled: boolean;
+    status: string;
+    ds_records: DNSDSRecord[];
+    dnskeys?: string[];
+  } | null;
+  validation: {
+    status: string; // unsigned, valid, missing_ds, mismatch, insecure_ds, error
+    provider_ds: DNSDSRecord[];
+    parent_ds: DNSDSRecord[];
+    matched: DNSDSRecord[];
+    message: string;
+  };
+}
+
+export interface DNSPropagationResult {
+  zone: string;
+  name: string;
+  type: string;
+  expected?: string;
+  servers: {
+    nameserver: string;
+    address: string;
+    serial: number;
+    answers: string[];
+    matched: boolean;
+    rtt_ms: number;
+    error?: string;
+  }[];
+  serials_in_sync: boolean;
+  propagated: boolean;
+  checked_at: string;
+}
+
 export interface DNSAccount {
   id: string;
   owner_id: string;
-  provider: string; // dnspod, cloudflare, desec, njalla, cloudns
+  provider: string; // dnspod, cloudflare, desec, njalla, cloudns, powerdns, route53, rfc2136
   name: string;
   is_default: boolean;
   created_at: string;
@@ -274,11 +425,18 @@ export interface DNSRecord {
   id: string;
   dns_domain_id: string; // References dns_managed_domains
   name: string; // subdomain: www, @, *
-  record_type: string; // A, AAAA, CNAME, TXT, MX
+  record_type: string; // A, AAAA, CNAME, TXT, MX, NS, SRV, CAA, PTR, TLSA
   value: string;
   ttl: number;
-  priority: number | null;
+  priority: number | null; // MX, SRV
   proxied: boolean;
+  weight: number | null; // SRV
+  port: number | null; // SRV
+  flags: number | null; // CAA
+  tag: string | null; // CAA: issue, issuewild, iodef
+  tlsa_usage: number | null;
+  tlsa_selector: number | null;
+  tlsa_matching_type: number | null;
   http_incoming_port: number | null;
   http_outgoing_port: number | null;
   https_incoming_port: number | null;
@@ -287,7 +445,7 @@ export interface DNSRecord {
   sync_status: string; // synced, pending, conflict, local_only, remote_only, error
   sync_error: string | null;
   last_synced_at: string | null;
-  mode: string; // 'static' or 'dynamic'
+  mode: string; // 'static', 'dynamic' or 'dynamic_extra' (extra answer of a multi-answer pool)
   created_at: string;
   updated_at: string;
 }
@@ -299,8 +457,8 @@ export interface PassthroughPool {
   target_ip: string;
   target_port: number;       // HTTPS (443) target port
   target_port_http: number;  // HTTP (80) target port
-  rotation_strategy: string; // 'round_robin' or 'random'
-  rotation_mode: string; // 'interval' or 'scheduled'
+  rotation_strategy: string; // 'round_robin', 'random', 'weighted', 'least_recently_used' or 'multi'
+  rotation_mode: string; // 'interval', 'scheduled' or 'failover'
   interval_minutes: number;
   scheduled_times: string[];
   health_check_enabled: boolean;
@@ -310,6 +468,23 @@ export interface PassthroughPool {
   is_paused: boolean;
   last_rotated_at: string | null;
   group_ids: string[]; // Machine groups for dynamic membership
+  health_check_path: string;    // HTTP probe path on port 80, empty = TCP/TLS only
+  health_check_host: string;    // SNI/Host used by probes, empty = record FQDN
+  confirm_propagation: boolean; // Wait for all nameservers before advancing the rotation
+  propagation_status: string;   // none, pending, confirmed, timeout
+  propagation_pending_since: string | null;
+  failover_enabled: boolean;           // Rotate away from an unhealthy current machine
+  preferred_machine_id: string | null; // Fail back here once healthy
+  answer_count: number;                // Members published at once by the multi strategy
+  cron_expression: string;             // Scheduled mode, e.g. "0 */6 * * mon-fri"
+  timezone: string;                    // IANA zone for the schedule
+  next_rotation_at: string | null;     // Next planned rotation
+  ipv6_enabled: boolean;               // Also manage an AAAA record from members' primary IPv6
+  warmup_enabled: boolean;             // Push and confirm nginx config on the target before flipping DNS
+  warmup_probe: boolean;               // Also probe the target before flipping DNS
+  warmup_timeout_seconds: number;
+  last_rotation_error: string | null;  // Why the last rotation was aborted
+  last_rotation_error_at: string | null;
   created_at: string;
   updated_at: string;
 }
@@ -319,14 +494,91 @@ export interface PassthroughMember {
   pool_id: string;
   machine_id: string;
   priority: number;
+  weight: number; // Relative share for the weighted strategy
   is_enabled: boolean;
   nginx_config_applied: boolean;
   created_at: string;
   // Extended fields
   machine_name?: string;
   machine_ip?: string;
+  machine_ipv6?: string; // Primary IPv6, empty if none
   last_seen?: string;
   is_online?: boolean;
+  health?: MemberHealth; // Latest active probe result
+}
+
+export interface MemberHealth {
+  id: string;
+  pool_type: string; // 'record' or 'wildcard'
+  pool_id: string;
+  machine_id: string;
+  status: string;    // unknown, healthy, unhealthy
+  consecutive_successes: number;
+  consecutive_failures: number;
+  tcp_ok: boolean;
+  tls_ok: boolean;
+  http_ok: boolean | null; // null when no HTTP check is configured
+  latency_ms: number;
+  last_error: string | null;
+  last_probe_at: string | null;
+  last_change_at: string | null;
+}
+
+export interface PoolSimulationOverrides {
+  rotation_strategy?: string;
+  answer_count?: number;
+  health_check_enabled?: boolean;
+  ipv6_enabled?: boolean;
+  machine_ids?: string[];    // Replaces the direct members, in priority order
+  member_weights?: Record<string, number>;
+  group_ids?: string[];
+  rotation_mode?: string;
+  interval_minutes?: number;
+  scheduled_times?: string[];
+  cron_expression?: string;
+  timezone?: string;
+}
+
+export interface PoolSimulationRequest {
+  count?: number; // Default 5, max 50
+  seed?: number;  // Reproduce random/weighted picks
+  overrides?: PoolSimulationOverrides;
+}
+
+export interface SimulatedMember {
+  machine_id: string;
+  machine_name: string;
+  machine_ip: string;
+  last_seen: string | null;
+  priority: number;
+  weight: number;
+  source: string; // direct, group
+  healthy: boolean;
+  eligible: boolean;
+  reason?: string; // Why the member is skipped
+}
+
+export interface SimulatedRotation {
+  step: number;
+  at: string | null; // null for failover
+  trigger: string;
+  machine_id: string;
+  machine_name: string;
+  answers: string[];
+  answers_ipv6?: string[];
+  index: number;
+  unchanged: boolean; // Current machine selected again, DNS left as is
+}
+
+export interface PoolSimulation {
+  pool_type: string;
+  pool_id: string;
+  strategy: string;
+  mode: string;
+  seed: number;
+  members: SimulatedMember[];
+  rotations: SimulatedRotation[];
+  warnings: string[];
 }
 
 export interface PassthroughPoolResponse {
@@ -345,8 +597,21 @@ export interface PassthroughPoolRequest {
   scheduled_times?: string[];
   health_check_enabled?: boolean;
   proxy_protocol?: boolean;   // Send PROXY protocol to backend
+  health_check_path?: string;
+  health_check_host?: string;
+  confirm_propagation?: boolean;
+  failover_enabled?: boolean;
+  preferred_machine_id?: string | null;
+  answer_count?: number;
+  cron_expression?: string;
+  timezone?: string;
+  ipv6_enabled?: boolean;
+  warmup_enabled?: boolean;
+  warmup_probe?: boolean;
+  warmup_timeout_seconds?: number; // 30-1800, default 300
   machine_ids: string[];
   group_ids?: string[]; // Machine groups for dynamic membership
+  member_weights?: Record<string, number>; // machine_id -> weight
 }
 
 export interface WildcardPool {
@@ -367,6 +632,23 @@ export interface WildcardPool {
   is_paused: boolean;
   last_rotated_at: string | null;
   group_ids: string[]; // Machine groups for dynamic membership
+  health_check_path: string;    // HTTP probe path on port 80, empty = TCP/TLS only
+  health_check_host: string;    // SNI/Host used by probes, empty = record FQDN
+  confirm_propagation: boolean; // Wait for all nameservers before advancing the rotation
+  propagation_status: string;   // none, pending, confirmed, timeout
+  propagation_pending_since: string | null;
+  failover_enabled: boolean;           // Rotate away from an unhealthy current machine
+  preferred_machine_id: string | null; // Fail back here once healthy
+  answer_count: number;                // Members published at once by the multi strategy
+  cron_expression: string;             // Scheduled mode, e.g. "0 */6 * * mon-fri"
+  timezone: string;                    // IANA zone for the schedule
+  next_rotation_at: string | null;     // Next planned rotation
+  ipv6_enabled: boolean;               // Also manage an AAAA record from members' primary IPv6
+  warmup_enabled: boolean;             // Push and confirm nginx config on the target before flipping DNS
+  warmup_probe: boolean;               // Also probe the target before flipping DNS
+  warmup_timeout_seconds: number;
+  last_rotation_error: string | null;  // Why the last rotation was aborted
+  last_rotation_error_at: string | null;
   created_at: string;
   updated_at: string;
 }
@@ -376,13 +658,16 @@ export interface WildcardPoolMember {
   pool_id: string;
   machine_id: string;
   priority: number;
+  weight: number; // Relative share for the weighted strategy
   is_enabled: boolean;
   nginx_config_applied: boolean;
   created_at: string;
   machine_name?: string;
   machine_ip?: string;
+  machine_ipv6?: string; // Primary IPv6, empty if none
   last_seen?: string;
   is_online?: boolean;
+  health?: MemberHealth; // Latest active probe result
 }
 
 export interface WildcardPoolResponse {
@@ -402,8 +687,21 @@ export interface WildcardPoolRequest {
   scheduled_times?: string[];
   health_check_enabled?: boolean;
   proxy_protocol?: boolean;   // Send PROXY protocol to backend
+  health_check_path?: string;
+  health_check_host?: string;
+  confirm_propagation?: boolean;
+  failover_enabled?: boolean;
+  preferred_machine_id?: string | null;
+  answer_count?: number;
+  cron_expression?: string;
+  timezone?: string;
+  ipv6_enabled?: boolean;
+  warmup_enabled?: boolean;
+  warmup_probe?: boolean;
+  warmup_timeout_seconds?: number; // 30-1800, default 300
   machine_ids: string[];
   group_ids?: string[]; // Machine groups for dynamic membership
+  member_weights?: Record<string, number>; // machine_id -> weight
 }
 
 export interface RotationHistory {
@@ -416,12 +714,77 @@ export interface RotationHistory {
   from_ip: string;
   to_machine_id: string | null;
   to_ip: string;
-  trigger: string; // 'scheduled', 'manual', 'health'
+  trigger: string; // 'scheduled', 'manual', 'health', 'failover', 'failback'
   rotated_at: string;
+  answer_machine_ids: string[]; // All published members, empty for older rotations
   from_machine_name?: string;
   to_machine_name?: string;
 }
 
+export interface RotationOperation {
+  id: string;
+  pool_type: string;
+  pool_id: string;
+  dns_domain_id: string | null;
+  state: "planned" | "dns_updated" | "committed" | "rolled_back" | "aborted";
+  trigger: string;
+  from_machine_id: string | null;
+  from_ip: string;
+  to_machine_id: string;
+  to_ip: string;
+  new_index: number;
+  await_propagation: boolean;
+  reset_schedule: boolean;
+  error: string | null; // Why the rotation was aborted or rolled back
+  created_at: string;
+  updated_at: string;
+  answer_machine_ids: string[];
+}
+
+export interface RotationStarted {
+  status: "rotating";
+  operation_id: string; // Poll with getRecordPoolRotation / getWildcardPoolRotation
+}
+
+export interface MachineServiceStats {
+  machine_id: string;
+  machine_name: string;
+  rotations_to: number;
+  current_seconds: number;  // Time as the pool's current machine
+  serving_seconds: number;  // Time in the published answers (multi included)
+  node_hours: number;
+  offline_seconds: number;  // Time offline while current
+  availability: number;     // 0-1
+}
+
+export interface ServiceGap {
+  machine_id: string;
+  machine_name: string;
+  sources: string[]; // agent, probe
+  reason: string;
+  start: string;
+  end: string;
+  seconds: number;
+  ongoing: boolean;
+}
+
+export interface RotationReport {
+  pool_type: string;
+  pool_id: string;
+  from: string;
+  to: string;
+  rotations: number;
+  triggers: Record<string, number>;
+  machines: MachineServiceStats[];
+  gaps: ServiceGap[];
+  untracked_seconds: number;
+}
+
+export interface RotationReportQuery {
+  from?: string; // RFC3339 or YYYY-MM-DD, default 30 days before to
+  to?: string;   // RFC3339 or YYYY-MM-DD (inclusive), default now
+}
+
 export interface NSStatus {
   valid: boolean;
   status: string; // unknown, pending, valid, invalid, external
@@ -437,6 +800,26 @@ export interface DNSSyncResult {
   deleted: DNSSyncRecord[];
   conflicts: DNSConflict[];
   errors: string[];
+  changes?: DNSChangeResult[];
+}
+
+export interface DNSZoneFileImportResult {
+  records: DNSSyncRecord[];
+  diff: DNSSyncResult; // created = added locally, deleted = removed, conflicts = updated
+  warnings: string[];
+  applied: boolean;
+}
+
+export interface DNSChangeResult {
+  change: {
+    action: string; // create, update, delete
+    local_id?: string;
+    record_id?: string;
+    record: DNSSyncRecord;
+  };
+  status: string; // applied, failed, rolled_back, rollback_failed, skipped
+  record?: DNSSyncRecord;
+  error?: string;
 }
 
 export interface DNSSyncRecord {
@@ -454,6 +837,11 @@ export interface DNSConflict {
   record_type: string;
   local_value: string;
   remote_value: string;
+  local_ttl: number;
+  remote_ttl: number;
+  local_priority: number;
+  remote_priority: number;
+  differences: string[]; // value, ttl, priority
   remote_id: string;
   local_id: string;
 }
@@ -784,7 +1172,7 @@ class ApiClient {
     return this.request<Machine>(`/api/machines/${id}`);
   }
 
-  async updateMachine(id: string, data: { title?: string; project_id?: string | null; notes_md?: string; primary_ip?: string }): Promise<void> {
+  async updateMachine(id: string, data: { title?: string; project_id?: string | null; notes_md?: string; primary_ip?: string; primary_ipv6?: string }): Promise<void> {
     await this.request(`/api/machines/${id}`, {
       method: "PUT",
       body: JSON.stringify(data),
@@ -988,6 +1376,22 @@ class ApiClient {
     return this.request<Job>(`/api/jobs/${id}`);
   }
 
+  async cancelJob(id: string): Promise<Job> {
+    return this.request<Job>(`/api/jobs/${id}/cancel`, { method: "POST" });
+  }
+
+  async getJobLogs(id: string, after = 0, limit?: number): Promise<JobLogsResponse> {
+    const params = new URLSearchParams({ after: String(after) });
+    if (limit) params.set("limit", String(limit));
+    return this.request<JobLogsResponse>(`/api/jobs/${id}/logs?${params}`);
+  }
+
+  // Live tail of job output: "chunk" events carry a JobLogChunk, "end" carries the final status
+  followJobLogs(id: string, after = 0): EventSource {
+    const params = new URLSearchParams({ follow: "true", after: String(after), token: this.token || "" });
+    return new EventSource(`${this.baseUrl}/api/jobs/${id}/logs?${params}`);
+  }
+
   // Domains
   async listDomains(): Promise<Domain[]> {
     return this.request<Domain[]>("/api/domains");
@@ -1093,6 +1497,7 @@ class ApiClient {
   async updateDNSManagedDomain(id: string, data: {
     dns_account_id?: string | null;
     notes_md?: string;
+    drift_enforce?: boolean;
   }): Promise<void> {
     await this.request(`/api/dns-domains/${id}`, {
       method: "PUT",
@@ -1110,6 +1515,40 @@ class ApiClient {
     });
   }
 
+  // DNSSEC
+  async getDNSSEC(id: string): Promise<DNSSECInfo> {
+    return this.request<DNSSECInfo>(`/api/dns-domains/${id}/dnssec`);
+  }
+
+  async setDNSSEC(id: string, enabled: boolean): Promise<DNSSECInfo> {
+    return this.request<DNSSECInfo>(`/api/dns-domains/${id}/dnssec`, {
+      method: "PUT",
+      body: JSON.stringify({ enabled }),
+    });
+  }
+
+  // DNS Zone Migration
+  async migrateDNSZone(id: string, targetAccountId: string): Promise<DNSZoneMigration> {
+    return this.request<DNSZoneMigration>(`/api/dns-domains/${id}/migrate`, {
+      method: "POST",
+      body: JSON.stringify({ target_account_id: targetAccountId }),
+    });
+  }
+
+  async getDNSZoneMigration(id: string): Promise<DNSZoneMigration> {
+    return this.request<DNSZoneMigration>(`/api/dns-domains/${id}/migration`);
+  }
+
+  async checkDNSZoneMigration(id: string): Promise<DNSZoneMigration> {
+    return this.request<DNSZoneMigration>(`/api/dns-domains/${id}/migration/check`, {
+      method: "POST",
+    });
+  }
+
+  async cancelDNSZoneMigration(id: string): Promise<void> {
+    await this.request(`/api/dns-domains/${id}/migration`, { method: "DELETE" });
+  }
+
   // DNS Records (for DNS Managed Domains)
   async listDNSRecords(dnsDomainId: string): Promise<DNSRecord[]> {
     return this.request<DNSRecord[]>(`/api/dns-domains/${dnsDomainId}/records`);
@@ -1126,6 +1565,13 @@ class ApiClient {
     http_outgoing_port?: number;
     https_incoming_port?: number;
     https_outgoing_port?: number;
+    weight?: number;
+    port?: number;
+    flags?: number;
+    tag?: string;
+    tlsa_usage?: number;
+    tlsa_selector?: number;
+    tlsa_matching_type?: number;
   }): Promise<DNSRecord> {
     return this.request<DNSRecord>(`/api/dns-domains/${dnsDomainId}/records`, {
       method: "POST",
@@ -1144,6 +1590,13 @@ class ApiClient {
     http_outgoing_port?: number;
     https_incoming_port?: number;
     https_outgoing_port?: number;
+    weight?: number;
+    port?: number;
+    flags?: number;
+    tag?: string;
+    tlsa_usage?: number;
+    tlsa_selector?: number;
+    tlsa_matching_type?: number;
   }): Promise<void> {
     await this.request(`/api/dns-domains/${dnsDomainId}/records/${recordId}`, {
       method: "PUT",
@@ -1166,12 +1619,52 @@ class ApiClient {
     });
   }
 
+  async checkDNSDrift(dnsDomainId: string): Promise<DNSManagedDomain> {
+    return this.request<DNSManagedDomain>(`/api/dns-domains/${dnsDomainId}/drift-check`, {
+      method: "POST",
+    });
+  }
+
   async importDNSFromRemote(dnsDomainId: string): Promise<{ imported: number; message: string }> {
     return this.request<{ imported: number; message: string }>(`/api/dns-domains/${dnsDomainId}/sync/import`, {
       method: "POST",
     });
   }
 
+  async exportZoneFile(dnsDomainId: string): Promise<string> {
+    const headers: Record<string, string> = {};
+    if (this.token) {
+      headers["Authorization"] = `Bearer ${this.token}`;
+    }
+
+    const response = await fetch(`${this.baseUrl}/api/dns-domains/${dnsDomainId}/zonefile`, {
+      method: "GET",
+      headers,
+    });
+
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText || "Export failed");
+    }
+
+    return response.text();
+  }
+
+  async importZoneFile(dnsDomainId: string, content: string, apply = false): Promise<DNSZoneFileImportResult> {
+    return this.request<DNSZoneFileImportResult>(`/api/dns-domains/${dnsDomainId}/zonefile`, {
+      method: "POST",
+      body: JSON.stringify({ content, apply }),
+    });
+  }
+
+  async checkDNSPropagation(dnsDomainId: string, params: { name?: string; type?: string; value?: string }): Promise<DNSPropagationResult> {
+    const query = new URLSearchParams();
+    if (params.name) query.set("name", params.name);
+    if (params.type) query.set("type", params.type);
+    if (params.value) query.set("value", params.value);
+    return this.request<DNSPropagationResult>(`/api/dns-domains/${dnsDomainId}/propagation?${query.toString()}`);
+  }
+
   async lookupDNS(dnsDomainId: string, subdomain?: string): Promise<{
     domain: string;
     subdomain: string;
@@ -1226,10 +1719,31 @@ class ApiClient {
     return this.request(`/api/dns/records/${recordId}/passthrough`, { method: "DELETE" });
   }
 
-  async rotateRecordPool(poolId: string): Promise<void> {
+  async rotateRecordPool(poolId: string): Promise<RotationStarted> {
     return this.request(`/api/dns/passthrough/${poolId}/rotate`, { method: "POST" });
   }
 
+  async getRecordPoolRotation(poolId: string, operationId: string): Promise<RotationOperation> {
+    return this.request(`/api/dns/passthrough/${poolId}/rotations/${operationId}`);
+  }
+
+  async getRecordPoolReport(poolId: string, query: RotationReportQuery = {}): Promise<RotationReport> {
+    const params = new URLSearchParams(query as Record<string, string>);
+    return this.request(`/api/dns/passthrough/${poolId}/report?${params}`);
+  }
+
+  async downloadRecordPoolReport(poolId: string, section: "machines" | "gaps" | "triggers", query: RotationReportQuery = {}): Promise<void> {
+    const params = new URLSearchParams({ ...query, format: "csv", section } as Record<string, string>);
+    return this.downloadReport(`/api/dns/passthrough/${poolId}/report?${params}`, `passthrough-pool-${poolId}-${section}.csv`);
+  }
+
+  async simulateRecordPool(poolId: string, data: PoolSimulationRequest = {}): Promise<PoolSimulation> {
+    return this.request(`/api/dns/passthrough/${poolId}/simulate`, {
+      method: "POST",
+      body: JSON.stringify(data),
+    });
+  }
+
   async pauseRecordPool(poolId: string): Promise<void> {
     return this.request(`/api/dns/passthrough/${poolId}/pause`, { method: "POST" });
   }
@@ -1262,10 +1776,31 @@ class ApiClient {
     return this.request(`/api/dns-domains/${domainId}/wildcard`, { method: "DELETE" });
   }
 
-  async rotateWildcardPool(poolId: string): Promise<void> {
+  async rotateWildcardPool(poolId: string): Promise<RotationStarted> {
     return this.request(`/api/dns/wildcard/${poolId}/rotate`, { method: "POST" });
   }
 
+  async getWildcardPoolRotation(poolId: string, operationId: string): Promise<RotationOperation> {
+    return this.request(`/api/dns/wildcard/${poolId}/rotations/${operationId}`);
+  }
+
+  async getWildcardPoolReport(poolId: string, query: RotationReportQuery = {}): Promise<RotationReport> {
+    const params = new URLSearchParams(query as Record<string, string>);
+    return this.request(`/api/dns/wildcard/${poolId}/report?${params}`);
+  }
+
+  async downloadWildcardPoolReport(poolId: string, section: "machines" | "gaps" | "triggers", query: RotationReportQuery = {}): Promise<void> {
+    const params = new URLSearchParams({ ...query, format: "csv", section } as Record<string, string>);
+    return this.downloadReport(`/api/dns/wildcard/${poolId}/report?${params}`, `wildcard-pool-${poolId}-${section}.csv`);
+  }
+
+  async simulateWildcardPool(poolId: string, data: PoolSimulationRequest = {}): Promise<PoolSimulation> {
+    return this.request(`/api/dns/wildcard/${poolId}/simulate`, {
+      method: "POST",
+      body: JSON.stringify(data),
+    });
+  }
+
   async pauseWildcardPool(poolId: string): Promise<void> {
     return this.request(`/api/dns/wildcard/${poolId}/pause`, { method: "POST" });
   }
@@ -1329,13 +1864,14 @@ class ApiClient {
     return this.request<CommandTemplate>(`/api/commands/${id}`);
   }
 
-  async executeCommand(machineId: string, commandId: string, variables: Record<string, string>): Promise<Job> {
+  async executeCommand(machineId: string, commandId: string, variables: Record<string, string>, check = false): Promise<Job> {
     return this.request<Job>("/api/commands/execute", {
       method: "POST",
       body: JSON.stringify({
         machine_id: machineId,
         command_id: commandId,
         variables,
+        check,
       }),
     });
   }
@@ -1412,6 +1948,29 @@ class ApiClient {
     document.body.removeChild(a);
   }
 
+  private async downloadReport(path: string, fileName: string): Promise<void> {
+    const token = this.getToken();
+    const headers: Record<string, string> = {};
+    if (token) {
+      headers["Authorization"] = `Bearer ${token}`;
+    }
+
+    const response = await fetch(`${this.baseUrl}${path}`, { method: "GET", headers });
+    if (!response.ok) {
+      throw new Error(await response.text() || "Download failed");
+    }
+
+    const blob = await response.blob();
+    const url = window.URL.createObjectURL(blob);
+    const a = document.createElement("a");
+    a.href = url;
+    a.download = fileName;
+    document.body.appendChild(a);
+    a.click();
+    window.URL.revokeObjectURL(url);
+    document.body.removeChild(a);
+  }
+
   // Machine Configs (file editing)
   async listMachineConfigs(machineId: string): Promise<ConfigListResponse> {
     return this.request<ConfigListResponse>(`/api/machines/${machineId}/configs`);
@@ -1704,6 +2263,85 @@ class ApiClient {
   async getSecurityStats(): Promise<SecurityStats> {
     return this.request("/api/security/stats");
   }
+
+  // ============================================================
+  // Notifications
+  // ============================================================
+
+  // Channels
+  async listNotificationChannels(): Promise<NotificationChannel[]> {
+    return this.request("/api/notifications/channels");
+  }
+
+  async createNotificationChannel(data: NotificationChannelRequest): Promise<NotificationChannel> {
+    return this.request("/api/notifications/channels", {
+      method: "POST",
+      body: JSON.stringify(data),
+    });
+  }
+
+  async updateNotificationChannel(id: string, data: Partial<NotificationChannelRequest>): Promise<NotificationChannel> {
+    return this.request(`/api/notifications/channels/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(data),
+    });
+  }
+
+  async deleteNotificationChannel(id: string): Promise<void> {
+    await this.request(`/api/notifications/channels/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async testNotificationChannel(id: string): Promise<NotificationTestResult> {
+    return this.request(`/api/notifications/channels/${id}/test`, {
+      method: "POST",
+    });
+  }
+
+  // Subscriptions
+  async listNotificationSubscriptions(channelId: string): Promise<NotificationSubscription[]> {
+    return this.request(`/api/notifications/channels/${channelId}/subscriptions`);
+  }
+
+  async createNotificationSubscription(channelId: string, data: {
+    scope: NotificationScope;
+    project_id?: string;
+    pool_type?: "record" | "wildcard";
+    pool_id?: string;
+    events?: NotificationEventType[];
+  }): Promise<NotificationSubscription> {
+    return this.request(`/api/notifications/channels/${channelId}/subscriptions`, {
+      method: "POST",
+      body: JSON.stringify(data),
+    });
+  }
+
+  async deleteNotificationSubscription(id: string): Promise<void> {
+    await this.request(`/api/notifications/subscriptions/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  // Delivery log
+  async listNotificationDeliveries(params?: {
+    channel_id?: string;
+    status?: NotificationDelivery["status"];
+    limit?: number;
+  }): Promise<NotificationDelivery[]> {
+    const searchParams = new URLSearchParams();
+    if (params?.channel_id) searchParams.set("channel_id", params.channel_id);
+    if (params?.status) searchParams.set("status", params.status);
+    if (params?.limit) searchParams.set("limit", params.limit.toString());
+    const query = searchParams.toString();
+    return this.request(`/api/notifications/deliveries${query ? `?${query}` : ""}`);
+  }
+
+  async retryNotificationDelivery(id: string): Promise<{ status: string }> {
+    return this.request(`/api/notifications/deliveries/${id}/retry`, {
+      method: "POST",
+    });
+  }
 }
 
 export interface PHPRuntime {
@@ -1913,6 +2551,90 @@ export interface NftablesState {
   checked_at: string;
 }
 
+// ============================================================
+// Notifications Types
+// ============================================================
+
+export type NotificationChannelType = "webhook" | "telegram" | "smtp";
+export type NotificationScope = "global" | "project" | "pool";
+export type NotificationEventType = "rotation" | "failover" | "ns_status" | "agent_offline";
+
+// Secret fields (secret, bot_token, password) come back as "********"; sending that
+// value on update keeps the stored secret
+export interface NotificationChannelConfig {
+  // webhook
+  url?: string;
+  secret?: string;
+  // telegram
+  bot_token?: string;
+  chat_id?: string;
+  api_url?: string;
+  // smtp
+  host?: string;
+  port?: number;
+  username?: string;
+  password?: string;
+  from?: string;
+  to?: string[];
+}
+
+export interface NotificationChannel {
+  id: string;
+  owner_id: string;
+  name: string;
+  type: NotificationChannelType;
+  config: NotificationChannelConfig;
+  is_enabled: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface NotificationChannelRequest {
+  name: string;
+  type: NotificationChannelType;
+  config: NotificationChannelConfig;
+  is_enabled?: boolean;
+}
+
+export interface NotificationSubscription {
+  id: string;
+  channel_id: string;
+  scope: NotificationScope;
+  project_id?: string;
+  pool_type?: "record" | "wildcard";
+  pool_id?: string;
+  events: NotificationEventType[]; // Empty = all events
+  created_at: string;
+}
+
+export interface NotificationDelivery {
+  id: string;
+  channel_id: string;
+  channel_name: string;
+  channel_type: NotificationChannelType;
+  event_type: NotificationEventType | "test";
+  payload: {
+    event: string;
+    summary: string;
+    occurred_at: string;
+    data: Record<string, unknown>;
+  };
+  status: "pending" | "sending" | "delivered" | "failed";
+  attempts: number;
+  next_attempt_at: string;
+  response_status?: number;
+  last_error?: string;
+  delivered_at?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface NotificationTestResult {
+  success: boolean;
+  response_status?: number;
+  error?: string;
+}
+
 // Speed Test / Tools Types
 export interface SpeedTestRequest {
   type: "public" | "download" | "upload" | "iperf_server" | "iperf_client" | "latency" | "network_info" | "machine_download" | "serve";
@@ -1931,6 +2653,7 @@ export interface SpeedTestResult {
   type: string;
   logs: string;
   finished: boolean;
+  outputs?: Record<string, string> | null; // e.g. download_mbps, upload_mbps, avg_ms
 }
 
 export interface SpeedTestMachine {